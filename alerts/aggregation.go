@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// aggregatedAlert repeated alerts collapsed within aggregation window.
+type aggregatedAlert struct {
+	last  cloudprotocol.AlertItem
+	first time.Time
+	count uint64
+	timer *time.Timer
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// aggregateAlert collapses repeated alert. It returns false if alert is repeated within aggregation window and should
+// not be queued. Repeated alerts are queued as single alert with occurrence count when the window expires.
+func (instance *Alerts) aggregateAlert(alert cloudprotocol.AlertItem) bool {
+	if instance.aggregationWindow == 0 {
+		return true
+	}
+
+	key := getAggregationKey(alert)
+
+	if aggregated, ok := instance.aggregatedAlerts[key]; ok {
+		aggregated.last = alert
+		aggregated.count++
+
+		return false
+	}
+
+	instance.aggregatedAlerts[key] = &aggregatedAlert{
+		last: alert, first: alert.Timestamp, count: 1,
+		timer: time.AfterFunc(instance.aggregationWindow, func() {
+			instance.Lock()
+			defer instance.Unlock()
+
+			instance.flushAggregatedAlert(key)
+		}),
+	}
+
+	return true
+}
+
+func (instance *Alerts) flushAggregatedAlert(key string) {
+	aggregated, ok := instance.aggregatedAlerts[key]
+	if !ok {
+		return
+	}
+
+	delete(instance.aggregatedAlerts, key)
+
+	// First occurrence is already queued
+	if aggregated.count <= 1 {
+		return
+	}
+
+	log.WithFields(log.Fields{"tag": aggregated.last.Tag, "count": aggregated.count}).Debug("Repeated alerts collapsed")
+
+	alert := aggregated.last
+	alert.Payload = addOccurrenceInfo(alert.Payload, fmt.Sprintf("occurred %d times from %s to %s",
+		aggregated.count, aggregated.first.UTC().Format(time.RFC3339), alert.Timestamp.UTC().Format(time.RFC3339)))
+
+	instance.queueAlert(alert)
+}
+
+func (instance *Alerts) flushAggregatedAlerts() {
+	for key, aggregated := range instance.aggregatedAlerts {
+		aggregated.timer.Stop()
+		instance.flushAggregatedAlert(key)
+	}
+}
+
+// queueAlert puts alert into the queue if it fits the tag rate limit.
+func (instance *Alerts) queueAlert(alert cloudprotocol.AlertItem) {
+	if bucket, ok := instance.rateLimiters[alert.Tag]; ok && !bucket.allow(time.Now()) {
+		instance.rateLimitedCount++
+
+		return
+	}
+
+	instance.addAlert(alert)
+	instance.notify()
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (bucket *tokenBucket) allow(now time.Time) bool {
+	bucket.tokens = math.Min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// getAggregationKey returns key of alert tag, alert source and payload key. Changing values like quota values are not
+// part of the key, so the last value is reported for collapsed alerts.
+func getAggregationKey(alert cloudprotocol.AlertItem) string {
+	var source, payloadKey string
+
+	switch payload := alert.Payload.(type) {
+	case cloudprotocol.SystemAlert:
+		source, payloadKey = payload.NodeID, payload.Message
+
+	case cloudprotocol.CoreAlert:
+		source, payloadKey = payload.CoreComponent, payload.Message
+
+	case cloudprotocol.ServiceInstanceAlert:
+		source, payloadKey = fmt.Sprintf("%v:%d", payload.InstanceIdent, payload.AosVersion), payload.Message
+
+	case cloudprotocol.DeviceAllocateAlert:
+		source, payloadKey = fmt.Sprintf("%v:%s", payload.InstanceIdent, payload.Device), payload.Message
+
+	case cloudprotocol.SystemQuotaAlert:
+		payloadKey = payload.Parameter
+
+	case cloudprotocol.InstanceQuotaAlert:
+		source, payloadKey = fmt.Sprintf("%v", payload.InstanceIdent), payload.Parameter
+
+	default:
+		data, err := json.Marshal(alert.Payload)
+		if err != nil {
+			log.Errorf("Can't encode alert payload: %v", err)
+		}
+
+		payloadKey = string(data)
+	}
+
+	return fmt.Sprintf("%s|%s|%s", alert.Tag, source, payloadKey)
+}
+
+// addOccurrenceInfo adds occurrence info to alert message. Payloads without message are reported as is.
+func addOccurrenceInfo(payload interface{}, info string) interface{} {
+	switch alert := payload.(type) {
+	case cloudprotocol.SystemAlert:
+		alert.Message = fmt.Sprintf("%s (%s)", alert.Message, info)
+
+		return alert
+
+	case cloudprotocol.CoreAlert:
+		alert.Message = fmt.Sprintf("%s (%s)", alert.Message, info)
+
+		return alert
+
+	case cloudprotocol.ServiceInstanceAlert:
+		alert.Message = fmt.Sprintf("%s (%s)", alert.Message, info)
+
+		return alert
+
+	case cloudprotocol.DeviceAllocateAlert:
+		alert.Message = fmt.Sprintf("%s (%s)", alert.Message, info)
+
+		return alert
+
+	default:
+		return payload
+	}
+}
@@ -19,52 +19,345 @@
 package alerts
 
 import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
 )
 
 /***********************************************************************************************************************
  * Consts
  **********************************************************************************************************************/
 
-const alertChannelSize = 50
+const defaultQueueSize = 1000
+
+// CoreComponentSM core component name of service manager used in system alerts.
+const CoreComponentSM = "SM"
 
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
 
+// Storage provides API to store alerts queue.
+type Storage interface {
+	AddAlert(alert StoredAlert) error
+	RemoveAlert(id uint64) error
+	GetAlerts() ([]StoredAlert, error)
+}
+
+// StoredAlert alert stored in the queue storage.
+type StoredAlert struct {
+	ID        uint64
+	Timestamp time.Time
+	Tag       string
+	Payload   []byte
+}
+
+// Alert queued alert.
+type Alert struct {
+	ID uint64
+	cloudprotocol.AlertItem
+}
+
 // Alerts instance.
 type Alerts struct {
-	alertsChannel chan cloudprotocol.AlertItem
+	sync.Mutex
+	storage       Storage
+	queueSize     int
+	maxAge        time.Duration
+	queue         []Alert
+	nextID        uint64
+	overflowCount uint64
+	expiredCount  uint64
+	notifyChannel chan struct{}
+
+	aggregationWindow time.Duration
+	aggregatedAlerts  map[string]*aggregatedAlert
+	rateLimiters      map[string]*tokenBucket
+	rateLimitedCount  uint64
 }
 
 /***********************************************************************************************************************
  * Public
  **********************************************************************************************************************/
 
-// New creates new alerts object.
-func New() (instance *Alerts, err error) {
+// New creates new alerts object. Alerts which are not sent before restart are restored from the storage.
+func New(config *config.Config, storage Storage) (instance *Alerts, err error) {
 	log.Debug("New alerts")
 
 	instance = &Alerts{
-		alertsChannel: make(chan cloudprotocol.AlertItem, alertChannelSize),
+		storage:       storage,
+		queueSize:     config.Alerts.QueueSize,
+		maxAge:        config.Alerts.MaxAge.Duration,
+		notifyChannel: make(chan struct{}, 1),
+
+		aggregationWindow: config.Alerts.AggregationWindow.Duration,
+		aggregatedAlerts:  make(map[string]*aggregatedAlert),
+		rateLimiters:      make(map[string]*tokenBucket),
+	}
+
+	if instance.queueSize <= 0 {
+		instance.queueSize = defaultQueueSize
+	}
+
+	for tag, rateLimit := range config.Alerts.RateLimits {
+		instance.rateLimiters[tag] = newTokenBucket(rateLimit.Rate, rateLimit.Burst)
+	}
+
+	if err = instance.restoreQueue(); err != nil {
+		return nil, err
 	}
 
 	return instance, nil
 }
 
-// GetAlertsChannel returns channel with alerts to be sent.
-func (instance *Alerts) GetAlertsChannel() (channel <-chan cloudprotocol.AlertItem) {
-	return instance.alertsChannel
+// Close closes alerts instance. Collapsed repeated alerts are queued on close.
+func (instance *Alerts) Close() {
+	instance.Lock()
+	defer instance.Unlock()
+
+	instance.flushAggregatedAlerts()
 }
 
-// SendResourceAlert sends resource alert.
+// GetAlertsNotifyChannel returns channel which notifies that there are new alerts in the queue.
+func (instance *Alerts) GetAlertsNotifyChannel() (channel <-chan struct{}) {
+	return instance.notifyChannel
+}
+
+// SendAlert puts alert into the queue. Repeated alerts are collapsed and alerts exceeding tag rate limit are dropped.
+// The oldest alert is dropped if the queue is full.
 func (instance *Alerts) SendAlert(alert cloudprotocol.AlertItem) {
-	if len(instance.alertsChannel) >= cap(instance.alertsChannel) {
-		log.Warn("Skip alert, channel is full")
+	instance.Lock()
+	defer instance.Unlock()
+
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
 
+	if !instance.aggregateAlert(alert) {
 		return
 	}
 
-	instance.alertsChannel <- alert
+	instance.queueAlert(alert)
+}
+
+// GetAlerts returns queued alerts in order they were sent. Alert should be removed from the queue with RemoveAlert
+// once it is delivered.
+func (instance *Alerts) GetAlerts() (alerts []Alert) {
+	instance.Lock()
+	defer instance.Unlock()
+
+	instance.removeExpiredAlerts()
+
+	if droppedCount := instance.overflowCount + instance.expiredCount + instance.rateLimitedCount; droppedCount != 0 {
+		log.WithFields(log.Fields{
+			"overflow": instance.overflowCount, "expired": instance.expiredCount,
+			"rateLimited": instance.rateLimitedCount,
+		}).Warn("Alerts dropped")
+
+		message := fmt.Sprintf("%d alerts dropped: %d on queue overflow, %d expired, %d rate limited",
+			droppedCount, instance.overflowCount, instance.expiredCount, instance.rateLimitedCount)
+
+		instance.overflowCount, instance.expiredCount, instance.rateLimitedCount = 0, 0, 0
+
+		// Report is added even if the queue is full, otherwise it causes dropping of another alert
+		instance.pushAlert(cloudprotocol.AlertItem{
+			Timestamp: time.Now(),
+			Tag:       cloudprotocol.AlertTagSystemError,
+			Payload:   cloudprotocol.SystemAlert{Message: message},
+		})
+	}
+
+	return append(alerts, instance.queue...)
+}
+
+// RemoveAlert removes delivered alert from the queue.
+func (instance *Alerts) RemoveAlert(id uint64) error {
+	instance.Lock()
+	defer instance.Unlock()
+
+	for i, alert := range instance.queue {
+		if alert.ID != id {
+			continue
+		}
+
+		instance.queue = append(instance.queue[:i], instance.queue[i+1:]...)
+
+		return instance.removeStoredAlert(id)
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (instance *Alerts) restoreQueue() error {
+	if instance.storage == nil {
+		return nil
+	}
+
+	storedAlerts, err := instance.storage.GetAlerts()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, storedAlert := range storedAlerts {
+		if storedAlert.ID >= instance.nextID {
+			instance.nextID = storedAlert.ID + 1
+		}
+
+		payload, err := decodePayload(storedAlert.Tag, storedAlert.Payload)
+		if err != nil {
+			log.WithField("id", storedAlert.ID).Errorf("Can't decode stored alert: %v", err)
+
+			if err = instance.removeStoredAlert(storedAlert.ID); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		instance.queue = append(instance.queue, Alert{ID: storedAlert.ID, AlertItem: cloudprotocol.AlertItem{
+			Timestamp: storedAlert.Timestamp, Tag: storedAlert.Tag, Payload: payload,
+		}})
+	}
+
+	for len(instance.queue) > instance.queueSize {
+		if err = instance.dropOldestAlert(); err != nil {
+			return err
+		}
+
+		instance.overflowCount++
+	}
+
+	instance.removeExpiredAlerts()
+
+	if len(instance.queue) != 0 {
+		instance.notify()
+	}
+
+	return nil
+}
+
+func (instance *Alerts) addAlert(item cloudprotocol.AlertItem) {
+	if len(instance.queue) >= instance.queueSize {
+		if err := instance.dropOldestAlert(); err != nil {
+			log.Errorf("Can't drop alert: %v", err)
+		}
+
+		instance.overflowCount++
+	}
+
+	instance.pushAlert(item)
+}
+
+func (instance *Alerts) pushAlert(item cloudprotocol.AlertItem) {
+	alert := Alert{ID: instance.nextID, AlertItem: item}
+
+	instance.nextID++
+	instance.queue = append(instance.queue, alert)
+
+	if instance.storage == nil {
+		return
+	}
+
+	payload, err := json.Marshal(item.Payload)
+	if err != nil {
+		log.Errorf("Can't encode alert payload: %v", err)
+
+		return
+	}
+
+	if err = instance.storage.AddAlert(StoredAlert{
+		ID: alert.ID, Timestamp: item.Timestamp, Tag: item.Tag, Payload: payload,
+	}); err != nil {
+		log.Errorf("Can't store alert: %v", err)
+	}
+}
+
+func (instance *Alerts) removeExpiredAlerts() {
+	if instance.maxAge == 0 {
+		return
+	}
+
+	for len(instance.queue) != 0 && time.Since(instance.queue[0].Timestamp) > instance.maxAge {
+		if err := instance.dropOldestAlert(); err != nil {
+			log.Errorf("Can't drop alert: %v", err)
+		}
+
+		instance.expiredCount++
+	}
+}
+
+func (instance *Alerts) dropOldestAlert() error {
+	id := instance.queue[0].ID
+
+	instance.queue = instance.queue[1:]
+
+	return instance.removeStoredAlert(id)
+}
+
+func (instance *Alerts) removeStoredAlert(id uint64) error {
+	if instance.storage == nil {
+		return nil
+	}
+
+	return aoserrors.Wrap(instance.storage.RemoveAlert(id))
+}
+
+func (instance *Alerts) notify() {
+	select {
+	case instance.notifyChannel <- struct{}{}:
+
+	default:
+	}
+}
+
+func decodePayload(tag string, data []byte) (payload interface{}, err error) {
+	switch tag {
+	case cloudprotocol.AlertTagSystemError:
+		payload, err = unmarshalPayload[cloudprotocol.SystemAlert](data)
+
+	case cloudprotocol.AlertTagAosCore:
+		payload, err = unmarshalPayload[cloudprotocol.CoreAlert](data)
+
+	case cloudprotocol.AlertTagResourceValidate:
+		payload, err = unmarshalPayload[cloudprotocol.ResourceValidateAlert](data)
+
+	case cloudprotocol.AlertTagDeviceAllocate:
+		payload, err = unmarshalPayload[cloudprotocol.DeviceAllocateAlert](data)
+
+	case cloudprotocol.AlertTagSystemQuota:
+		payload, err = unmarshalPayload[cloudprotocol.SystemQuotaAlert](data)
+
+	case cloudprotocol.AlertTagInstanceQuota:
+		payload, err = unmarshalPayload[cloudprotocol.InstanceQuotaAlert](data)
+
+	case cloudprotocol.AlertTagServiceInstance:
+		payload, err = unmarshalPayload[cloudprotocol.ServiceInstanceAlert](data)
+
+	case cloudprotocol.AlertTagDownloadProgress:
+		payload, err = unmarshalPayload[cloudprotocol.DownloadAlert](data)
+
+	default:
+		return nil, aoserrors.Errorf("unknown alert tag: %s", tag)
+	}
+
+	return payload, err
+}
+
+func unmarshalPayload[T any](data []byte) (payload T, err error) {
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return payload, aoserrors.Wrap(err)
+	}
+
+	return payload, nil
 }
@@ -18,15 +18,31 @@
 package alerts_test
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aoscloud/aos_common/aostypes"
 	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
 	"github.com/aoscloud/aos_servicemanager/alerts"
+	"github.com/aoscloud/aos_servicemanager/config"
 )
 
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	sync.Mutex
+	alerts []alerts.StoredAlert
+}
+
 /***********************************************************************************************************************
  * Init
  **********************************************************************************************************************/
@@ -46,7 +62,7 @@ func init() {
  **********************************************************************************************************************/
 
 func TestAlerts(t *testing.T) {
-	alertsHandler, err := alerts.New()
+	alertsHandler, err := alerts.New(&config.Config{Alerts: config.Alerts{QueueSize: 50}}, nil)
 	if err != nil {
 		t.Fatalf("Can't create alerts: %s", err)
 	}
@@ -60,7 +76,273 @@ func TestAlerts(t *testing.T) {
 		alertsHandler.SendAlert(testAlert)
 	}
 
-	if len(alertsHandler.GetAlertsChannel()) != 50 {
-		t.Error("Incorrect channel size ", len(alertsHandler.GetAlertsChannel()))
+	select {
+	case <-alertsHandler.GetAlertsNotifyChannel():
+
+	default:
+		t.Error("Alerts notification expected")
+	}
+
+	queuedAlerts := alertsHandler.GetAlerts()
+
+	// Queue contains 50 newest alerts and dropped alerts report
+	if len(queuedAlerts) != 51 {
+		t.Fatalf("Incorrect queue size: %d", len(queuedAlerts))
+	}
+
+	if queuedAlerts[0].ID != 4 {
+		t.Errorf("Wrong oldest alert ID: %d", queuedAlerts[0].ID)
+	}
+
+	checkDroppedAlertsReport(t, queuedAlerts[50], "4 alerts dropped: 4 on queue overflow, 0 expired, 0 rate limited")
+
+	for _, alert := range queuedAlerts {
+		if err = alertsHandler.RemoveAlert(alert.ID); err != nil {
+			t.Errorf("Can't remove alert: %v", err)
+		}
+	}
+
+	if queuedAlerts = alertsHandler.GetAlerts(); len(queuedAlerts) != 0 {
+		t.Errorf("Queue should be empty: %v", queuedAlerts)
+	}
+}
+
+func TestExpiredAlerts(t *testing.T) {
+	alertsHandler, err := alerts.New(&config.Config{
+		Alerts: config.Alerts{MaxAge: aostypes.Duration{Duration: time.Hour}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %s", err)
+	}
+
+	alertsHandler.SendAlert(cloudprotocol.AlertItem{
+		Timestamp: time.Now().Add(-2 * time.Hour),
+		Tag:       cloudprotocol.AlertTagSystemError,
+		Payload:   cloudprotocol.SystemAlert{Message: "expired"},
+	})
+
+	alertsHandler.SendAlert(cloudprotocol.AlertItem{
+		Tag:     cloudprotocol.AlertTagSystemError,
+		Payload: cloudprotocol.SystemAlert{Message: "actual"},
+	})
+
+	queuedAlerts := alertsHandler.GetAlerts()
+
+	if len(queuedAlerts) != 2 {
+		t.Fatalf("Incorrect queue size: %d", len(queuedAlerts))
+	}
+
+	if payload, ok := queuedAlerts[0].Payload.(cloudprotocol.SystemAlert); !ok || payload.Message != "actual" {
+		t.Errorf("Wrong alert payload: %v", queuedAlerts[0].Payload)
+	}
+
+	checkDroppedAlertsReport(t, queuedAlerts[1], "1 alerts dropped: 0 on queue overflow, 1 expired, 0 rate limited")
+}
+
+func TestPersistentQueue(t *testing.T) {
+	storage := &testStorage{}
+
+	alertsHandler, err := alerts.New(&config.Config{}, storage)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %s", err)
+	}
+
+	sentAlerts := []cloudprotocol.AlertItem{
+		{
+			Tag:     cloudprotocol.AlertTagSystemError,
+			Payload: cloudprotocol.SystemAlert{Message: "system"},
+		},
+		{
+			Tag:     cloudprotocol.AlertTagAosCore,
+			Payload: cloudprotocol.CoreAlert{CoreComponent: "SM", Message: "core"},
+		},
+		{
+			Tag: cloudprotocol.AlertTagServiceInstance,
+			Payload: cloudprotocol.ServiceInstanceAlert{
+				InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject1"},
+				AosVersion:    1, Message: "instance",
+			},
+		},
+	}
+
+	for _, alert := range sentAlerts {
+		alertsHandler.SendAlert(alert)
+	}
+
+	// Delivered alert should not be restored
+
+	if err = alertsHandler.RemoveAlert(alertsHandler.GetAlerts()[0].ID); err != nil {
+		t.Fatalf("Can't remove alert: %v", err)
+	}
+
+	// Restore queue as after restart
+
+	if alertsHandler, err = alerts.New(&config.Config{}, storage); err != nil {
+		t.Fatalf("Can't create alerts: %s", err)
+	}
+
+	select {
+	case <-alertsHandler.GetAlertsNotifyChannel():
+
+	case <-time.After(time.Second):
+		t.Error("Alerts notification expected")
+	}
+
+	queuedAlerts := alertsHandler.GetAlerts()
+
+	if len(queuedAlerts) != 2 {
+		t.Fatalf("Incorrect queue size: %d", len(queuedAlerts))
+	}
+
+	for i, alert := range queuedAlerts {
+		if alert.Tag != sentAlerts[i+1].Tag || alert.Payload != sentAlerts[i+1].Payload {
+			t.Errorf("Wrong restored alert: %v", alert)
+		}
+	}
+
+	// New alerts should get new IDs
+
+	alertsHandler.SendAlert(sentAlerts[0])
+
+	if queuedAlerts = alertsHandler.GetAlerts(); queuedAlerts[2].ID <= queuedAlerts[1].ID {
+		t.Errorf("Wrong new alert ID: %d", queuedAlerts[2].ID)
+	}
+}
+
+func TestAggregatedAlerts(t *testing.T) {
+	alertsHandler, err := alerts.New(&config.Config{
+		Alerts: config.Alerts{AggregationWindow: aostypes.Duration{Duration: 100 * time.Millisecond}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %s", err)
+	}
+	defer alertsHandler.Close()
+
+	for i := 0; i < 5; i++ {
+		alertsHandler.SendAlert(cloudprotocol.AlertItem{
+			Tag:     cloudprotocol.AlertTagSystemError,
+			Payload: cloudprotocol.SystemAlert{NodeID: "node0", Message: "repeated"},
+		})
+	}
+
+	alertsHandler.SendAlert(cloudprotocol.AlertItem{
+		Tag:     cloudprotocol.AlertTagSystemError,
+		Payload: cloudprotocol.SystemAlert{NodeID: "node1", Message: "repeated"},
+	})
+
+	<-alertsHandler.GetAlertsNotifyChannel()
+
+	queuedAlerts := alertsHandler.GetAlerts()
+
+	if len(queuedAlerts) != 2 {
+		t.Fatalf("Incorrect queue size: %d", len(queuedAlerts))
+	}
+
+	for _, alert := range queuedAlerts {
+		if err = alertsHandler.RemoveAlert(alert.ID); err != nil {
+			t.Fatalf("Can't remove alert: %v", err)
+		}
+	}
+
+	select {
+	case <-alertsHandler.GetAlertsNotifyChannel():
+
+	case <-time.After(time.Second):
+		t.Fatal("Alerts notification expected")
+	}
+
+	if queuedAlerts = alertsHandler.GetAlerts(); len(queuedAlerts) != 1 {
+		t.Fatalf("Incorrect queue size: %d", len(queuedAlerts))
+	}
+
+	payload, ok := queuedAlerts[0].Payload.(cloudprotocol.SystemAlert)
+	if !ok || payload.NodeID != "node0" || !strings.HasPrefix(payload.Message, "repeated (occurred 5 times from ") {
+		t.Errorf("Wrong aggregated alert payload: %v", queuedAlerts[0].Payload)
+	}
+}
+
+func TestRateLimitedAlerts(t *testing.T) {
+	alertsHandler, err := alerts.New(&config.Config{
+		Alerts: config.Alerts{RateLimits: map[string]config.AlertRateLimit{
+			cloudprotocol.AlertTagSystemError: {Rate: 0.001, Burst: 2},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %s", err)
+	}
+	defer alertsHandler.Close()
+
+	for i := 0; i < 5; i++ {
+		alertsHandler.SendAlert(cloudprotocol.AlertItem{
+			Tag:     cloudprotocol.AlertTagSystemError,
+			Payload: cloudprotocol.SystemAlert{Message: fmt.Sprintf("alert %d", i)},
+		})
+	}
+
+	alertsHandler.SendAlert(cloudprotocol.AlertItem{
+		Tag:     cloudprotocol.AlertTagAosCore,
+		Payload: cloudprotocol.CoreAlert{CoreComponent: "SM", Message: "not limited"},
+	})
+
+	queuedAlerts := alertsHandler.GetAlerts()
+
+	if len(queuedAlerts) != 4 {
+		t.Fatalf("Incorrect queue size: %d", len(queuedAlerts))
+	}
+
+	if queuedAlerts[2].Tag != cloudprotocol.AlertTagAosCore {
+		t.Errorf("Wrong alert tag: %s", queuedAlerts[2].Tag)
+	}
+
+	checkDroppedAlertsReport(t, queuedAlerts[3], "3 alerts dropped: 0 on queue overflow, 0 expired, 3 rate limited")
+}
+
+/***********************************************************************************************************************
+ * testStorage
+ **********************************************************************************************************************/
+
+func (storage *testStorage) AddAlert(alert alerts.StoredAlert) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.alerts = append(storage.alerts, alert)
+
+	return nil
+}
+
+func (storage *testStorage) RemoveAlert(id uint64) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	if index := slices.IndexFunc(storage.alerts, func(alert alerts.StoredAlert) bool {
+		return alert.ID == id
+	}); index >= 0 {
+		storage.alerts = slices.Delete(storage.alerts, index, index+1)
+	}
+
+	return nil
+}
+
+func (storage *testStorage) GetAlerts() ([]alerts.StoredAlert, error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return slices.Clone(storage.alerts), nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkDroppedAlertsReport(t *testing.T, alert alerts.Alert, message string) {
+	t.Helper()
+
+	payload, ok := alert.Payload.(cloudprotocol.SystemAlert)
+	if alert.Tag != cloudprotocol.AlertTagSystemError || !ok {
+		t.Fatalf("Wrong dropped alerts report: %v", alert)
+	}
+
+	if payload.Message != message {
+		t.Errorf("Wrong dropped alerts report message: %s", payload.Message)
 	}
 }
@@ -0,0 +1,551 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blobstore provides content-addressed storage of service and layer blobs shared between their owners
+package blobstore
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/spaceallocator"
+	"github.com/aoscloud/aos_common/utils/fs"
+	"github.com/opencontainers/go-digest"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const copySuffix = ".copy"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Storage provides API to store blob references.
+type Storage interface {
+	AddBlobReference(ref BlobReference) error
+	RemoveBlobReference(ref BlobReference) error
+	GetBlobReferences() ([]BlobReference, error)
+}
+
+// BlobReference reference of blob owner to the blob.
+type BlobReference struct {
+	Digest string
+	Owner  string
+}
+
+// OwnerRemover removes outdated blob owner.
+type OwnerRemover func() error
+
+// BlobStore content-addressed blob store.
+type BlobStore struct {
+	sync.Mutex
+
+	blobsDir       string
+	storage        Storage
+	allocator      spaceallocator.Allocator
+	owners         map[string]map[string]struct{}
+	outdatedOwners map[string]outdatedOwner
+	outdatedBlobs  map[string]struct{}
+}
+
+type outdatedOwner struct {
+	timestamp time.Time
+	remover   OwnerRemover
+}
+
+type outdatedBlob struct {
+	digest    string
+	size      uint64
+	timestamp time.Time
+}
+
+// allocatorUpdate collects allocator changes to be applied outside of the store lock: allocator may call the store
+// remover while holding its own lock.
+type allocatorUpdate struct {
+	outdatedBlobs []outdatedBlob
+	restoredBlobs []string
+	freedSize     uint64
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// NewSpaceAllocator space allocator constructor.
+//
+//nolint:gochecknoglobals // used for unit test mock
+var NewSpaceAllocator = spaceallocator.New
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new blob store.
+func New(config *config.Config, storage Storage) (blobStore *BlobStore, err error) {
+	log.Debug("New blob store")
+
+	blobStore = &BlobStore{
+		blobsDir:       config.BlobsDir,
+		storage:        storage,
+		owners:         make(map[string]map[string]struct{}),
+		outdatedOwners: make(map[string]outdatedOwner),
+		outdatedBlobs:  make(map[string]struct{}),
+	}
+
+	if err = os.MkdirAll(blobStore.blobsDir, 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if blobStore.allocator, err = NewSpaceAllocator(
+		blobStore.blobsDir, config.BlobsPartLimit, blobStore.removeOutdatedBlob); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	refs, err := storage.GetBlobReferences()
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	for _, ref := range refs {
+		blobStore.addOwner(ref)
+	}
+
+	if err = blobStore.removeDamagedBlobs(); err != nil {
+		log.Errorf("Can't remove damaged blobs: %v", err)
+	}
+
+	return blobStore, nil
+}
+
+// Close closes blob store.
+func (blobStore *BlobStore) Close() {
+	if err := blobStore.allocator.Close(); err != nil {
+		log.Errorf("Can't close blobs allocator: %v", err)
+	}
+}
+
+// AddBlob moves blob file or directory into the store and adds owner reference to it. If the store already contains
+// the blob, the source is removed. It returns path of the blob in the store and size of the removed source. Blob
+// space is accounted by the store, so owners should not account the returned size.
+func (blobStore *BlobStore) AddBlob(
+	blobDigest digest.Digest, owner, sourcePath string,
+) (blobPath string, size uint64, err error) {
+	if err = blobDigest.Validate(); err != nil {
+		return "", 0, aoserrors.Wrap(err)
+	}
+
+	sourceSize, err := fs.GetDirSize(sourcePath)
+	if err != nil {
+		return "", 0, aoserrors.Wrap(err)
+	}
+
+	var space spaceallocator.Space
+
+	// Space is allocated outside of the store lock: allocator may remove outdated blobs
+	if !blobStore.isBlobStored(blobDigest) {
+		if space, err = blobStore.allocator.AllocateSpace(uint64(sourceSize)); err != nil {
+			return "", 0, aoserrors.Wrap(err)
+		}
+	}
+
+	blobPath, moved, update, err := blobStore.storeBlob(blobDigest, owner, sourcePath)
+
+	blobStore.updateAllocator(update)
+
+	if space != nil {
+		if err != nil || !moved {
+			if releaseErr := space.Release(); releaseErr != nil {
+				log.Errorf("Can't release blob space: %v", releaseErr)
+			}
+		} else {
+			if acceptErr := space.Accept(); acceptErr != nil {
+				log.Errorf("Can't accept blob space: %v", acceptErr)
+			}
+		}
+	}
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	return blobPath, uint64(sourceSize), nil
+}
+
+// ReleaseBlobs removes owner references to blobs. Blobs which are not referenced anymore are removed from the store.
+func (blobStore *BlobStore) ReleaseBlobs(owner string) error {
+	blobStore.Lock()
+	update, err := blobStore.releaseOwner(owner)
+	blobStore.Unlock()
+
+	blobStore.updateAllocator(update)
+
+	return err
+}
+
+// SetOwnerOutdated marks blob owner as outdated. Blobs referenced by outdated owners only are removed together with
+// their owners when the store requires space.
+func (blobStore *BlobStore) SetOwnerOutdated(owner string, timestamp time.Time, remover OwnerRemover) {
+	blobStore.Lock()
+
+	blobStore.outdatedOwners[owner] = outdatedOwner{timestamp: timestamp, remover: remover}
+	update := blobStore.updateOwnerBlobs(owner)
+
+	blobStore.Unlock()
+
+	blobStore.updateAllocator(update)
+}
+
+// RestoreOwner restores outdated blob owner.
+func (blobStore *BlobStore) RestoreOwner(owner string) {
+	blobStore.Lock()
+
+	delete(blobStore.outdatedOwners, owner)
+	update := blobStore.updateOwnerBlobs(owner)
+
+	blobStore.Unlock()
+
+	blobStore.updateAllocator(update)
+}
+
+// IsBlobPath checks if path belongs to the store.
+func (blobStore *BlobStore) IsBlobPath(path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), filepath.Clean(blobStore.blobsDir)+string(os.PathSeparator))
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (blobStore *BlobStore) getBlobPath(blobDigest digest.Digest) string {
+	return filepath.Join(blobStore.blobsDir, string(blobDigest.Algorithm()), blobDigest.Hex())
+}
+
+func (blobStore *BlobStore) addOwner(ref BlobReference) {
+	owners, ok := blobStore.owners[ref.Digest]
+	if !ok {
+		owners = make(map[string]struct{})
+		blobStore.owners[ref.Digest] = owners
+	}
+
+	owners[ref.Owner] = struct{}{}
+}
+
+func (blobStore *BlobStore) isBlobStored(blobDigest digest.Digest) bool {
+	blobStore.Lock()
+	defer blobStore.Unlock()
+
+	_, err := os.Stat(blobStore.getBlobPath(blobDigest))
+
+	return err == nil
+}
+
+func (blobStore *BlobStore) storeBlob(
+	blobDigest digest.Digest, owner, sourcePath string,
+) (blobPath string, moved bool, update allocatorUpdate, err error) {
+	blobStore.Lock()
+	defer blobStore.Unlock()
+
+	blobPath = blobStore.getBlobPath(blobDigest)
+
+	if _, err = os.Stat(blobPath); err == nil {
+		log.WithFields(log.Fields{"digest": blobDigest, "owner": owner}).Debug("Blob already exists")
+
+		if err = os.RemoveAll(sourcePath); err != nil {
+			return "", false, update, aoserrors.Wrap(err)
+		}
+	} else {
+		if err = os.MkdirAll(filepath.Dir(blobPath), 0o755); err != nil {
+			return "", false, update, aoserrors.Wrap(err)
+		}
+
+		if err = moveBlob(sourcePath, blobPath); err != nil {
+			return "", false, update, err
+		}
+
+		moved = true
+
+		log.WithFields(log.Fields{"digest": blobDigest, "owner": owner}).Debug("Blob added")
+	}
+
+	ref := BlobReference{Digest: blobDigest.String(), Owner: owner}
+
+	if err = blobStore.storage.AddBlobReference(ref); err != nil {
+		return "", moved, update, aoserrors.Wrap(err)
+	}
+
+	blobStore.addOwner(ref)
+	blobStore.updateBlobOutdated(ref.Digest, &update)
+
+	return blobPath, moved, update, nil
+}
+
+func (blobStore *BlobStore) releaseOwner(owner string) (update allocatorUpdate, releaseErr error) {
+	_, ownerOutdated := blobStore.outdatedOwners[owner]
+
+	delete(blobStore.outdatedOwners, owner)
+
+	for blobDigest, owners := range blobStore.owners {
+		if _, ok := owners[owner]; !ok {
+			continue
+		}
+
+		if err := blobStore.storage.RemoveBlobReference(
+			BlobReference{Digest: blobDigest, Owner: owner}); err != nil && releaseErr == nil {
+			releaseErr = aoserrors.Wrap(err)
+		}
+
+		delete(owners, owner)
+
+		if len(owners) != 0 {
+			// Blob of outdated owner remains outdated if it was
+			if !ownerOutdated {
+				blobStore.updateBlobOutdated(blobDigest, &update)
+			}
+
+			continue
+		}
+
+		delete(blobStore.owners, blobDigest)
+
+		if err := blobStore.removeBlob(digest.Digest(blobDigest), &update); err != nil && releaseErr == nil {
+			releaseErr = err
+		}
+	}
+
+	return update, releaseErr
+}
+
+func (blobStore *BlobStore) updateOwnerBlobs(owner string) (update allocatorUpdate) {
+	for blobDigest, owners := range blobStore.owners {
+		if _, ok := owners[owner]; ok {
+			blobStore.updateBlobOutdated(blobDigest, &update)
+		}
+	}
+
+	return update
+}
+
+// updateBlobOutdated adds blob to allocator outdated items if all its owners are outdated and restores it otherwise.
+func (blobStore *BlobStore) updateBlobOutdated(blobDigest string, update *allocatorUpdate) {
+	var timestamp time.Time
+
+	outdated := true
+
+	for owner := range blobStore.owners[blobDigest] {
+		outdatedOwner, ok := blobStore.outdatedOwners[owner]
+		if !ok {
+			outdated = false
+
+			break
+		}
+
+		if outdatedOwner.timestamp.After(timestamp) {
+			timestamp = outdatedOwner.timestamp
+		}
+	}
+
+	_, wasOutdated := blobStore.outdatedBlobs[blobDigest]
+
+	switch {
+	case outdated && !wasOutdated:
+		size, err := fs.GetDirSize(blobStore.getBlobPath(digest.Digest(blobDigest)))
+		if err != nil {
+			log.WithField("digest", blobDigest).Errorf("Can't get blob size: %v", err)
+
+			return
+		}
+
+		blobStore.outdatedBlobs[blobDigest] = struct{}{}
+		update.outdatedBlobs = append(update.outdatedBlobs,
+			outdatedBlob{digest: blobDigest, size: uint64(size), timestamp: timestamp})
+
+	case !outdated && wasOutdated:
+		delete(blobStore.outdatedBlobs, blobDigest)
+		update.restoredBlobs = append(update.restoredBlobs, blobDigest)
+	}
+}
+
+func (blobStore *BlobStore) updateAllocator(update allocatorUpdate) {
+	for _, blob := range update.outdatedBlobs {
+		if err := blobStore.allocator.AddOutdatedItem(blob.digest, blob.size, blob.timestamp); err != nil {
+			log.WithField("digest", blob.digest).Errorf("Can't add outdated blob: %v", err)
+		}
+	}
+
+	for _, blobDigest := range update.restoredBlobs {
+		blobStore.allocator.RestoreOutdatedItem(blobDigest)
+	}
+
+	if update.freedSize != 0 {
+		blobStore.allocator.FreeSpace(update.freedSize)
+	}
+}
+
+// removeOutdatedBlob is called by allocator to free space. Blob is removed together with all its outdated owners.
+func (blobStore *BlobStore) removeOutdatedBlob(id string) error {
+	removers, err := blobStore.releaseOutdatedOwners(id)
+	if err != nil {
+		return err
+	}
+
+	for _, remover := range removers {
+		if err := remover(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func (blobStore *BlobStore) releaseOutdatedOwners(blobDigest string) (removers []OwnerRemover, err error) {
+	blobStore.Lock()
+	defer blobStore.Unlock()
+
+	owners := blobStore.owners[blobDigest]
+
+	for owner := range owners {
+		outdatedOwner, ok := blobStore.outdatedOwners[owner]
+		if !ok {
+			return nil, aoserrors.Errorf("blob %s is used by %s", blobDigest, owner)
+		}
+
+		removers = append(removers, outdatedOwner.remover)
+	}
+
+	for owner := range owners {
+		// Allocator accounts space of the removed item itself. Other outdated blobs removed here stay in allocator
+		// until it handles them, so allocator update is not required.
+		if _, err = blobStore.releaseOwner(owner); err != nil {
+			return nil, err
+		}
+	}
+
+	return removers, nil
+}
+
+func (blobStore *BlobStore) removeBlob(blobDigest digest.Digest, update *allocatorUpdate) error {
+	blobPath := blobStore.getBlobPath(blobDigest)
+
+	size, err := fs.GetDirSize(blobPath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err := os.RemoveAll(blobPath); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, ok := blobStore.outdatedBlobs[blobDigest.String()]; ok {
+		delete(blobStore.outdatedBlobs, blobDigest.String())
+		update.restoredBlobs = append(update.restoredBlobs, blobDigest.String())
+	}
+
+	update.freedSize += uint64(size)
+
+	log.WithField("digest", blobDigest).Debug("Blob removed")
+
+	return nil
+}
+
+func (blobStore *BlobStore) removeDamagedBlobs() error {
+	for blobDigest, owners := range blobStore.owners {
+		if _, err := os.Stat(blobStore.getBlobPath(digest.Digest(blobDigest))); err == nil {
+			continue
+		}
+
+		log.WithField("digest", blobDigest).Warn("Blob missing")
+
+		for owner := range owners {
+			if err := blobStore.storage.RemoveBlobReference(
+				BlobReference{Digest: blobDigest, Owner: owner}); err != nil {
+				return aoserrors.Wrap(err)
+			}
+		}
+
+		delete(blobStore.owners, blobDigest)
+	}
+
+	algorithms, err := os.ReadDir(blobStore.blobsDir)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, algorithm := range algorithms {
+		hexes, err := os.ReadDir(filepath.Join(blobStore.blobsDir, algorithm.Name()))
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		for _, hex := range hexes {
+			blobDigest := digest.NewDigestFromEncoded(digest.Algorithm(algorithm.Name()), hex.Name())
+
+			if _, ok := blobStore.owners[blobDigest.String()]; ok {
+				continue
+			}
+
+			log.WithField("digest", blobDigest).Warn("Blob is not referenced")
+
+			if err = os.RemoveAll(filepath.Join(blobStore.blobsDir, algorithm.Name(), hex.Name())); err != nil {
+				return aoserrors.Wrap(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// moveBlob moves blob into the store. Blobs dir may be located on another partition, in this case blob is copied.
+func moveBlob(sourcePath, blobPath string) error {
+	err := os.Rename(sourcePath, blobPath)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, syscall.EXDEV) {
+		return aoserrors.Wrap(err)
+	}
+
+	copyPath := blobPath + copySuffix
+	defer os.RemoveAll(copyPath)
+
+	if output, err := exec.Command("cp", "-a", sourcePath, copyPath).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("%v (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	if err = os.Rename(copyPath, blobPath); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = os.RemoveAll(sourcePath); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
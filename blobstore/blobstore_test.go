@@ -0,0 +1,443 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobstore_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/spaceallocator"
+	"github.com/aoscloud/aos_common/utils/fs"
+	"github.com/opencontainers/go-digest"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/blobstore"
+	"github.com/aoscloud/aos_servicemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	sync.Mutex
+	refs []blobstore.BlobReference
+}
+
+type testAllocator struct {
+	sync.Mutex
+	allocatedSize uint64
+	remover       spaceallocator.ItemRemover
+	outdatedItems map[string]uint64
+}
+
+type testSpace struct {
+	allocator *testAllocator
+	size      uint64
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "aos_"); err != nil {
+		log.Fatalf("Can't create tmp dir: %v", err)
+	}
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Errorf("Can't remove tmp dir: %v", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestSharedBlob(t *testing.T) {
+	allocator := &testAllocator{}
+	storage := &testStorage{}
+
+	blobStore := newBlobStore(t, allocator, storage)
+	defer blobStore.Close()
+
+	blobContent := []byte("blob content")
+	blobDigest := digest.FromBytes(blobContent)
+
+	owners := []string{"service:service1_1", "service:service1_2"}
+
+	var (
+		blobPath string
+		blobSize uint64
+	)
+
+	for _, owner := range owners {
+		sourcePath, err := createSourceFile(blobContent)
+		if err != nil {
+			t.Fatalf("Can't create source file: %v", err)
+		}
+
+		sourceSize, err := fs.GetDirSize(sourcePath)
+		if err != nil {
+			t.Fatalf("Can't get source size: %v", err)
+		}
+
+		blobSize = uint64(sourceSize)
+
+		path, size, err := blobStore.AddBlob(blobDigest, owner, sourcePath)
+		if err != nil {
+			t.Fatalf("Can't add blob: %v", err)
+		}
+
+		if size != blobSize {
+			t.Errorf("Wrong blob size: %d", size)
+		}
+
+		if _, err = os.Stat(sourcePath); !os.IsNotExist(err) {
+			t.Error("Source should be removed")
+		}
+
+		if !blobStore.IsBlobPath(path) {
+			t.Errorf("Wrong blob path: %s", path)
+		}
+
+		blobPath = path
+	}
+
+	if allocator.allocatedSize != blobSize {
+		t.Errorf("Blob should be allocated once: %d", allocator.allocatedSize)
+	}
+
+	if len(storage.refs) != len(owners) {
+		t.Errorf("Wrong references count: %d", len(storage.refs))
+	}
+
+	if err := blobStore.ReleaseBlobs(owners[0]); err != nil {
+		t.Fatalf("Can't release blobs: %v", err)
+	}
+
+	if _, err := os.Stat(blobPath); err != nil {
+		t.Errorf("Referenced blob should not be removed: %v", err)
+	}
+
+	if err := blobStore.ReleaseBlobs(owners[1]); err != nil {
+		t.Fatalf("Can't release blobs: %v", err)
+	}
+
+	if _, err := os.Stat(blobPath); !os.IsNotExist(err) {
+		t.Error("Not referenced blob should be removed")
+	}
+
+	if len(storage.refs) != 0 {
+		t.Errorf("Wrong references count: %d", len(storage.refs))
+	}
+
+	if allocator.allocatedSize != 0 {
+		t.Errorf("Blob space should be freed: %d", allocator.allocatedSize)
+	}
+}
+
+func TestRemoveOutdatedBlob(t *testing.T) {
+	allocator := &testAllocator{}
+	storage := &testStorage{}
+
+	blobStore := newBlobStore(t, allocator, storage)
+	defer blobStore.Close()
+
+	blobContent := []byte("outdated blob")
+	blobDigest := digest.FromBytes(blobContent)
+
+	owners := []string{"service:service2_1", "layer:layer3"}
+	removedOwners := make(map[string]struct{})
+
+	var blobPath string
+
+	for _, owner := range owners {
+		sourcePath, err := createSourceFile(blobContent)
+		if err != nil {
+			t.Fatalf("Can't create source file: %v", err)
+		}
+
+		if blobPath, _, err = blobStore.AddBlob(blobDigest, owner, sourcePath); err != nil {
+			t.Fatalf("Can't add blob: %v", err)
+		}
+	}
+
+	setOwnerOutdated := func(owner string) {
+		blobStore.SetOwnerOutdated(owner, time.Now(), func() error {
+			removedOwners[owner] = struct{}{}
+
+			return blobStore.ReleaseBlobs(owner)
+		})
+	}
+
+	// Blob is outdated only if all its owners are outdated
+
+	setOwnerOutdated(owners[0])
+
+	if len(allocator.outdatedItems) != 0 {
+		t.Errorf("Blob should not be outdated: %v", allocator.outdatedItems)
+	}
+
+	setOwnerOutdated(owners[1])
+
+	if _, ok := allocator.outdatedItems[blobDigest.String()]; !ok {
+		t.Fatal("Blob should be outdated")
+	}
+
+	blobStore.RestoreOwner(owners[1])
+
+	if len(allocator.outdatedItems) != 0 {
+		t.Errorf("Blob should not be outdated: %v", allocator.outdatedItems)
+	}
+
+	setOwnerOutdated(owners[1])
+
+	// Outdated blob is removed with its owners by allocator
+
+	if err := allocator.remover(blobDigest.String()); err != nil {
+		t.Fatalf("Can't remove outdated blob: %v", err)
+	}
+
+	if len(removedOwners) != len(owners) {
+		t.Errorf("Wrong removed owners: %v", removedOwners)
+	}
+
+	if _, err := os.Stat(blobPath); !os.IsNotExist(err) {
+		t.Error("Outdated blob should be removed")
+	}
+
+	if len(storage.refs) != 0 {
+		t.Errorf("Wrong references count: %d", len(storage.refs))
+	}
+}
+
+func TestRemoveDamagedBlobs(t *testing.T) {
+	allocator := &testAllocator{}
+	storage := &testStorage{}
+
+	blobStore := newBlobStore(t, allocator, storage)
+
+	blobContent := []byte("referenced blob")
+	blobDigest := digest.FromBytes(blobContent)
+
+	sourcePath, err := createSourceFile(blobContent)
+	if err != nil {
+		t.Fatalf("Can't create source file: %v", err)
+	}
+
+	blobPath, _, err := blobStore.AddBlob(blobDigest, "layer:layer1", sourcePath)
+	if err != nil {
+		t.Fatalf("Can't add blob: %v", err)
+	}
+
+	blobStore.Close()
+
+	missingDigest := digest.FromString("missing blob")
+
+	if err := storage.AddBlobReference(
+		blobstore.BlobReference{Digest: missingDigest.String(), Owner: "layer:layer2"}); err != nil {
+		t.Fatalf("Can't add blob reference: %v", err)
+	}
+
+	notReferencedDigest := digest.FromString("not referenced blob")
+	notReferencedPath := filepath.Join(
+		tmpDir, "blobs", string(notReferencedDigest.Algorithm()), notReferencedDigest.Hex())
+
+	if err := os.WriteFile(notReferencedPath, []byte("not referenced blob"), 0o600); err != nil {
+		t.Fatalf("Can't create blob: %v", err)
+	}
+
+	blobStore = newBlobStore(t, allocator, storage)
+	defer blobStore.Close()
+
+	if _, err := os.Stat(blobPath); err != nil {
+		t.Errorf("Referenced blob should not be removed: %v", err)
+	}
+
+	if _, err := os.Stat(notReferencedPath); !os.IsNotExist(err) {
+		t.Error("Not referenced blob should be removed")
+	}
+
+	if len(storage.refs) != 1 || storage.refs[0].Digest != blobDigest.String() {
+		t.Errorf("Wrong references: %v", storage.refs)
+	}
+
+	if err := blobStore.ReleaseBlobs("layer:layer1"); err != nil {
+		t.Fatalf("Can't release blobs: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (storage *testStorage) AddBlobReference(ref blobstore.BlobReference) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	for _, storedRef := range storage.refs {
+		if storedRef == ref {
+			return nil
+		}
+	}
+
+	storage.refs = append(storage.refs, ref)
+
+	return nil
+}
+
+func (storage *testStorage) RemoveBlobReference(ref blobstore.BlobReference) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	for i, storedRef := range storage.refs {
+		if storedRef == ref {
+			storage.refs = append(storage.refs[:i], storage.refs[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (storage *testStorage) GetBlobReferences() ([]blobstore.BlobReference, error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return append([]blobstore.BlobReference{}, storage.refs...), nil
+}
+
+func (allocator *testAllocator) AllocateSpace(size uint64) (spaceallocator.Space, error) {
+	allocator.Lock()
+	defer allocator.Unlock()
+
+	allocator.allocatedSize += size
+
+	return &testSpace{allocator: allocator, size: size}, nil
+}
+
+func (allocator *testAllocator) FreeSpace(size uint64) {
+	allocator.Lock()
+	defer allocator.Unlock()
+
+	if size > allocator.allocatedSize {
+		allocator.allocatedSize = 0
+	} else {
+		allocator.allocatedSize -= size
+	}
+}
+
+func (allocator *testAllocator) AddOutdatedItem(id string, size uint64, timestamp time.Time) error {
+	allocator.Lock()
+	defer allocator.Unlock()
+
+	allocator.outdatedItems[id] = size
+
+	return nil
+}
+
+func (allocator *testAllocator) RestoreOutdatedItem(id string) {
+	allocator.Lock()
+	defer allocator.Unlock()
+
+	delete(allocator.outdatedItems, id)
+}
+
+func (allocator *testAllocator) Close() error {
+	return nil
+}
+
+func (space *testSpace) Accept() error {
+	return nil
+}
+
+func (space *testSpace) Release() error {
+	space.allocator.FreeSpace(space.size)
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newBlobStore(t *testing.T, allocator *testAllocator, storage *testStorage) *blobstore.BlobStore {
+	t.Helper()
+
+	blobstore.NewSpaceAllocator = func(
+		path string, partLimit uint, remover spaceallocator.ItemRemover,
+	) (spaceallocator.Allocator, error) {
+		allocator.remover = remover
+		allocator.outdatedItems = make(map[string]uint64)
+
+		return allocator, nil
+	}
+
+	blobStore, err := blobstore.New(&config.Config{BlobsDir: filepath.Join(tmpDir, "blobs")}, storage)
+	if err != nil {
+		t.Fatalf("Can't create blob store: %v", err)
+	}
+
+	return blobStore
+}
+
+func createSourceFile(content []byte) (fileName string, err error) {
+	file, err := os.CreateTemp(tmpDir, "source_")
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if _, err = file.Write(content); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return file.Name(), nil
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clocksync synchronizes system clock with CM
+package clocksync
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// MaxTimeDiff max allowed difference between system and CM time. System time is not changed if the difference is
+// below this value.
+const MaxTimeDiff = 1 * time.Second
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// SyncedChannelProvider provides channel which is closed when clock is synced.
+type SyncedChannelProvider interface {
+	GetClockSyncedChannel() (channel <-chan struct{})
+}
+
+// ClockSync clock sync instance.
+type ClockSync struct {
+	sync.Mutex
+
+	synced        bool
+	syncedChannel chan struct{}
+	timeOffset    time.Duration
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// SetTimeFunc sets system time.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var SetTimeFunc = setSystemTime
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new clock sync instance.
+func New() (clockSync *ClockSync, err error) {
+	log.Debug("New clock sync")
+
+	clockSync = &ClockSync{syncedChannel: make(chan struct{})}
+
+	return clockSync, nil
+}
+
+// GetSyncedChannel returns clock synced channel of the provider. Clock is considered as synced if there is no provider.
+func GetSyncedChannel(provider SyncedChannelProvider) (channel <-chan struct{}) {
+	if provider != nil {
+		return provider.GetClockSyncedChannel()
+	}
+
+	syncedChannel := make(chan struct{})
+	close(syncedChannel)
+
+	return syncedChannel
+}
+
+// SyncClock synchronizes system clock with time received from CM. Clock is not marked as synced if system time
+// can't be set: TTL checks are done against system time.
+func (clockSync *ClockSync) SyncClock(currentTime time.Time) error {
+	clockSync.Lock()
+	defer clockSync.Unlock()
+
+	clockSync.timeOffset = time.Until(currentTime)
+
+	log.WithFields(log.Fields{
+		"currentTime": currentTime, "offset": clockSync.timeOffset,
+	}).Debug("Sync clock")
+
+	if clockSync.timeOffset.Abs() > MaxTimeDiff {
+		if err := SetTimeFunc(currentTime); err != nil {
+			return aoserrors.Errorf("can't set system time, offset %v: %v", clockSync.timeOffset, err)
+		}
+
+		log.WithField("offset", clockSync.timeOffset).Info("System time adjusted")
+	}
+
+	if !clockSync.synced {
+		log.Info("Clock synced")
+
+		clockSync.synced = true
+		close(clockSync.syncedChannel)
+	}
+
+	return nil
+}
+
+// IsClockSynced returns true if system clock is synchronized with CM.
+func (clockSync *ClockSync) IsClockSynced() bool {
+	clockSync.Lock()
+	defer clockSync.Unlock()
+
+	return clockSync.synced
+}
+
+// GetTimeOffset returns offset between CM and system time detected on last sync.
+func (clockSync *ClockSync) GetTimeOffset() time.Duration {
+	clockSync.Lock()
+	defer clockSync.Unlock()
+
+	return clockSync.timeOffset
+}
+
+// GetClockSyncedChannel returns channel which is closed when system clock is synchronized with CM.
+func (clockSync *ClockSync) GetClockSyncedChannel() (channel <-chan struct{}) {
+	return clockSync.syncedChannel
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func setSystemTime(currentTime time.Time) error {
+	timeVal := unix.NsecToTimeval(currentTime.UnixNano())
+
+	if err := unix.Settimeofday(&timeVal); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
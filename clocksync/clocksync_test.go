@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocksync_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/clocksync"
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestSyncClock(t *testing.T) {
+	var setTime *time.Time
+
+	clocksync.SetTimeFunc = func(currentTime time.Time) error {
+		setTime = &currentTime
+
+		return nil
+	}
+
+	clockSync, err := clocksync.New()
+	if err != nil {
+		t.Fatalf("Can't create clock sync: %v", err)
+	}
+
+	if clockSync.IsClockSynced() {
+		t.Error("Clock should not be synced")
+	}
+
+	select {
+	case <-clockSync.GetClockSyncedChannel():
+		t.Error("Clock synced channel should not be closed")
+
+	default:
+	}
+
+	// Time is close enough, system time should not be changed
+
+	if err = clockSync.SyncClock(time.Now()); err != nil {
+		t.Fatalf("Can't sync clock: %v", err)
+	}
+
+	if setTime != nil {
+		t.Error("System time should not be set")
+	}
+
+	if !clockSync.IsClockSynced() {
+		t.Error("Clock should be synced")
+	}
+
+	select {
+	case <-clockSync.GetClockSyncedChannel():
+
+	default:
+		t.Error("Clock synced channel should be closed")
+	}
+
+	// Time differs, system time should be changed
+
+	cmTime := time.Now().Add(24 * time.Hour)
+
+	if err = clockSync.SyncClock(cmTime); err != nil {
+		t.Fatalf("Can't sync clock: %v", err)
+	}
+
+	if setTime == nil || !setTime.Equal(cmTime) {
+		t.Errorf("Wrong system time: %v", setTime)
+	}
+
+	if clockSync.GetTimeOffset() < 23*time.Hour {
+		t.Errorf("Wrong time offset: %v", clockSync.GetTimeOffset())
+	}
+}
+
+func TestSyncClockError(t *testing.T) {
+	clocksync.SetTimeFunc = func(currentTime time.Time) error {
+		return errors.New("permission denied")
+	}
+
+	clockSync, err := clocksync.New()
+	if err != nil {
+		t.Fatalf("Can't create clock sync: %v", err)
+	}
+
+	if err = clockSync.SyncClock(time.Now().Add(-time.Hour)); err == nil {
+		t.Error("Error expected")
+	}
+
+	if clockSync.IsClockSynced() {
+		t.Error("Clock should not be synced")
+	}
+
+	if offset := clockSync.GetTimeOffset(); offset > -59*time.Minute {
+		t.Errorf("Wrong time offset: %v", offset)
+	}
+}
@@ -40,6 +40,7 @@ const (
 	defaultSystemAlertPriority  = 3
 	maxAlertPriorityLevel       = 7
 	minAlertPriorityLevel       = 0
+	defaultMaxParallelInstalls  = 2
 )
 
 /***********************************************************************************************************************
@@ -48,8 +49,10 @@ const (
 
 // Logging configuration for system and service logging.
 type Logging struct {
-	MaxPartSize  uint64 `json:"maxPartSize"`
-	MaxPartCount uint64 `json:"maxPartCount"`
+	MaxPartSize         uint64            `json:"maxPartSize"`
+	MaxPartCount        uint64            `json:"maxPartCount"`
+	FollowFlushInterval aostypes.Duration `json:"followFlushInterval"`
+	MaxFollowDuration   aostypes.Duration `json:"maxFollowDuration"`
 }
 
 // Migration struct represents path for db migration.
@@ -58,6 +61,56 @@ type Migration struct {
 	MergedMigrationPath string `json:"mergedMigrationPath"`
 }
 
+// Download package download configuration.
+type Download struct {
+	RetryDelay     aostypes.Duration `json:"retryDelay"`
+	MaxRetryDelay  aostypes.Duration `json:"maxRetryDelay"`
+	Timeout        aostypes.Duration `json:"timeout"`
+	ProgressPeriod aostypes.Duration `json:"progressPeriod"`
+}
+
+// Rollback service update rollback configuration.
+type Rollback struct {
+	Enabled bool              `json:"enabled"`
+	Window  aostypes.Duration `json:"window"`
+}
+
+// Integrity periodic integrity verification of installed services and layers configuration.
+type Integrity struct {
+	Enabled     bool              `json:"enabled"`
+	CheckPeriod aostypes.Duration `json:"checkPeriod"`
+	RateLimit   uint64            `json:"rateLimit"`
+}
+
+// AlertRateLimit token bucket rate limit of alerts with the same tag. Rate is number of alerts per second.
+type AlertRateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Alerts persistent alerts queue configuration.
+type Alerts struct {
+	QueueSize         int                       `json:"queueSize"`
+	MaxAge            aostypes.Duration         `json:"maxAge"`
+	AggregationWindow aostypes.Duration         `json:"aggregationWindow"`
+	RateLimits        map[string]AlertRateLimit `json:"rateLimits,omitempty"`
+}
+
+// MonitoringBuffer node monitoring buffer configuration. Monitoring data are buffered while CM connection is down.
+// If downsample period is set, buffered samples are kept not more often than this period.
+type MonitoringBuffer struct {
+	Size             int               `json:"size"`
+	BatchSize        int               `json:"batchSize"`
+	Persistent       bool              `json:"persistent"`
+	DownsamplePeriod aostypes.Duration `json:"downsamplePeriod"`
+}
+
+// SignatureVerification package signature verification configuration.
+type SignatureVerification struct {
+	TrustStore string `json:"trustStore"`
+	Strict     bool   `json:"strict"`
+}
+
 // Config instance.
 type Config struct {
 	CACert                    string                 `json:"caCert"`
@@ -72,20 +125,34 @@ type Config struct {
 	ServicesPartLimit         uint                   `json:"servicesPartLimit"`
 	LayersDir                 string                 `json:"layersDir"`
 	LayersPartLimit           uint                   `json:"layersPartLimit"`
+	BlobsDir                  string                 `json:"blobsDir"`
+	BlobsPartLimit            uint                   `json:"blobsPartLimit"`
 	DownloadDir               string                 `json:"downloadDir"`
 	ExtractDir                string                 `json:"extractDir"`
+	ImageFormat               string                 `json:"imageFormat,omitempty"`
+	Verity                    bool                   `json:"verity,omitempty"`
+	DeltaUpdates              bool                   `json:"deltaUpdates,omitempty"`
+	Download                  Download               `json:"download"`
+	MaxParallelInstalls       int                    `json:"maxParallelInstalls"`
 	RemoteNode                bool                   `json:"remoteNode"`
 	RunnerFeatures            []string               `json:"runnerFeatures"`
+	InstanceRunner            string                 `json:"instanceRunner,omitempty"`
 	UnitConfigFile            string                 `json:"unitConfigFile"`
 	ServiceTTLDays            uint64                 `json:"serviceTtlDays"`
 	LayerTTLDays              uint64                 `json:"layerTtlDays"`
 	ServiceHealthCheckTimeout aostypes.Duration      `json:"serviceHealthCheckTimeout"`
+	Rollback                  Rollback               `json:"rollback"`
+	Integrity                 Integrity              `json:"integrity"`
+	Alerts                    Alerts                 `json:"alerts"`
 	Monitoring                resourcemonitor.Config `json:"monitoring"`
+	MonitoringBuffer          MonitoringBuffer       `json:"monitoringBuffer"`
 	Logging                   Logging                `json:"logging"`
 	JournalAlerts             journalalerts.Config   `json:"journalAlerts,omitempty"`
 	HostBinds                 []string               `json:"hostBinds"`
 	Hosts                     []aostypes.Host        `json:"hosts,omitempty"`
 	Migration                 Migration              `json:"migration"`
+	DiagnosticsSocket         string                 `json:"diagnosticsSocket,omitempty"`
+	SignatureVerification     SignatureVerification  `json:"signatureVerification,omitempty"`
 }
 
 /***********************************************************************************************************************
@@ -103,13 +170,38 @@ func New(fileName string) (config *Config, err error) {
 		ServiceTTLDays:            30,                                            //nolint:gomnd
 		LayerTTLDays:              30,                                            //nolint:gomnd
 		ServiceHealthCheckTimeout: aostypes.Duration{Duration: 35 * time.Second}, //nolint:gomnd
+		MaxParallelInstalls:       defaultMaxParallelInstalls,
 		Monitoring: resourcemonitor.Config{
 			SendPeriod: aostypes.Duration{Duration: 1 * time.Minute},
 			PollPeriod: aostypes.Duration{Duration: 10 * time.Second},
 		},
+		MonitoringBuffer: MonitoringBuffer{
+			Size:      1440, //nolint:gomnd
+			BatchSize: 32,   //nolint:gomnd
+		},
 		Logging: Logging{
-			MaxPartSize:  524288, //nolint:gomnd
-			MaxPartCount: 20,     //nolint:gomnd
+			MaxPartSize:         524288,                                       //nolint:gomnd
+			MaxPartCount:        20,                                           //nolint:gomnd
+			FollowFlushInterval: aostypes.Duration{Duration: 5 * time.Second}, //nolint:gomnd
+			MaxFollowDuration:   aostypes.Duration{Duration: 1 * time.Hour},
+		},
+		Rollback: Rollback{
+			Window: aostypes.Duration{Duration: 5 * time.Minute}, //nolint:gomnd
+		},
+		Integrity: Integrity{
+			CheckPeriod: aostypes.Duration{Duration: 24 * time.Hour}, //nolint:gomnd
+			RateLimit:   4 * 1024 * 1024,                             //nolint:gomnd
+		},
+		Alerts: Alerts{
+			QueueSize:         1000,                                          //nolint:gomnd
+			MaxAge:            aostypes.Duration{Duration: 72 * time.Hour},   //nolint:gomnd
+			AggregationWindow: aostypes.Duration{Duration: 10 * time.Second}, //nolint:gomnd
+		},
+		Download: Download{
+			RetryDelay:     aostypes.Duration{Duration: 1 * time.Second},
+			MaxRetryDelay:  aostypes.Duration{Duration: 1 * time.Minute},
+			Timeout:        aostypes.Duration{Duration: 1 * time.Hour},
+			ProgressPeriod: aostypes.Duration{Duration: 30 * time.Second}, //nolint:gomnd
 		},
 		JournalAlerts: journalalerts.Config{
 			SystemAlertPriority:  defaultSystemAlertPriority,
@@ -137,6 +229,10 @@ func New(fileName string) (config *Config, err error) {
 		config.ServicesDir = path.Join(config.WorkingDir, "services")
 	}
 
+	if config.BlobsDir == "" {
+		config.BlobsDir = path.Join(config.WorkingDir, "blobs")
+	}
+
 	if config.DownloadDir == "" {
 		config.DownloadDir = path.Join(config.WorkingDir, "download")
 	}
@@ -48,10 +48,42 @@ func createConfigFile() (err error) {
 	"servicesPartLimit": 10,
 	"layersDir": "/var/aos/srvlib",
 	"layersPartLimit": 20,
+	"blobsDir": "/var/aos/blobs",
+	"blobsPartLimit": 30,
 	"downloadDir": "/var/aos/servicemanager/download",
 	"extractDir": "/var/aos/servicemanager/extract",
+	"imageFormat": "squashfs",
+	"verity": true,
+	"deltaUpdates": true,
+	"maxParallelInstalls": 4,
+	"rollback": {
+		"enabled": true,
+		"window": "2m"
+	},
+	"integrity": {
+		"enabled": true,
+		"checkPeriod": "12h",
+		"rateLimit": 1048576
+	},
+	"alerts": {
+		"queueSize": 200,
+		"maxAge": "24h",
+		"aggregationWindow": "30s",
+		"rateLimits": {
+			"serviceInstanceAlert": {
+				"rate": 0.5,
+				"burst": 10
+			}
+		}
+	},
+	"download": {
+		"retryDelay": "2s",
+		"maxRetryDelay": "5m",
+		"progressPeriod": "10s"
+	},
 	"remoteNode": true,
 	"runnerFeatures": ["crun", "runc"],
+	"instanceRunner": "process",
 	"unitConfigFile": "/var/aos/aos_unit.cfg",
 	"layerTtlDays": 40,
 	"serviceHealthCheckTimeout": "10s",
@@ -69,9 +101,17 @@ func createConfigFile() (err error) {
 			"maxThreshold": 150
 		}
 	},
+	"monitoringBuffer": {
+		"size": 100,
+		"batchSize": 10,
+		"persistent": true,
+		"downsamplePeriod": "5m"
+	},
 	"logging": {
 		"maxPartSize": 1024,
-		"maxPartCount": 10
+		"maxPartCount": 10,
+		"followFlushInterval": "2s",
+		"maxFollowDuration": "30m"
 	},
 	"journalAlerts": {		
 		"filter": ["(test)", "(regexp)"],
@@ -91,6 +131,11 @@ func createConfigFile() (err error) {
 	"migration": {
 		"migrationPath" : "/usr/share/aos_servicemnager/migration",
 		"mergedMigrationPath" : "/var/aos/servicemanager/mergedMigration"
+	},
+	"diagnosticsSocket": "/run/aos/servicemanager/diagnostics.sock",
+	"signatureVerification": {
+		"trustStore": "/etc/aos/truststore",
+		"strict": true
 	}
 }`
 
@@ -213,6 +258,17 @@ func TestGetLayersDir(t *testing.T) {
 	}
 }
 
+func TestGetBlobsDir(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %s", err)
+	}
+
+	if config.BlobsDir != "/var/aos/blobs" {
+		t.Errorf("Wrong blobs dir value: %s", config.BlobsDir)
+	}
+}
+
 func TestGetServicesDir(t *testing.T) {
 	config, err := config.New("tmp/aos_servicemanager.cfg")
 	if err != nil {
@@ -246,6 +302,153 @@ func TestGetExtractDir(t *testing.T) {
 	}
 }
 
+func TestImageFormat(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if config.ImageFormat != "squashfs" {
+		t.Errorf("Wrong image format value: %s", config.ImageFormat)
+	}
+}
+
+func TestVerity(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if !config.Verity {
+		t.Error("Verity should be enabled")
+	}
+}
+
+func TestDeltaUpdates(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if !config.DeltaUpdates {
+		t.Error("Delta updates should be enabled")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if config.Download.RetryDelay.Duration != 2*time.Second {
+		t.Errorf("Wrong retry delay value: %v", config.Download.RetryDelay)
+	}
+
+	if config.Download.MaxRetryDelay.Duration != 5*time.Minute {
+		t.Errorf("Wrong max retry delay value: %v", config.Download.MaxRetryDelay)
+	}
+
+	if config.Download.Timeout.Duration != time.Hour {
+		t.Errorf("Wrong timeout value: %v", config.Download.Timeout)
+	}
+
+	if config.Download.ProgressPeriod.Duration != 10*time.Second {
+		t.Errorf("Wrong progress period value: %v", config.Download.ProgressPeriod)
+	}
+}
+
+func TestMaxParallelInstalls(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if config.MaxParallelInstalls != 4 {
+		t.Errorf("Wrong max parallel installs value: %d", config.MaxParallelInstalls)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if !config.Rollback.Enabled {
+		t.Error("Rollback should be enabled")
+	}
+
+	if config.Rollback.Window.Duration != 2*time.Minute {
+		t.Errorf("Wrong rollback window value: %v", config.Rollback.Window)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if !config.Integrity.Enabled {
+		t.Error("Integrity verification should be enabled")
+	}
+
+	if config.Integrity.CheckPeriod.Duration != 12*time.Hour {
+		t.Errorf("Wrong integrity check period value: %v", config.Integrity.CheckPeriod)
+	}
+
+	if config.Integrity.RateLimit != 1048576 {
+		t.Errorf("Wrong integrity rate limit value: %d", config.Integrity.RateLimit)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if config.Alerts.QueueSize != 200 {
+		t.Errorf("Wrong alerts queue size value: %d", config.Alerts.QueueSize)
+	}
+
+	if config.Alerts.MaxAge.Duration != 24*time.Hour {
+		t.Errorf("Wrong alerts max age value: %v", config.Alerts.MaxAge)
+	}
+
+	if config.Alerts.AggregationWindow.Duration != 30*time.Second {
+		t.Errorf("Wrong alerts aggregation window value: %v", config.Alerts.AggregationWindow)
+	}
+
+	if rateLimit := config.Alerts.RateLimits["serviceInstanceAlert"]; rateLimit.Rate != 0.5 || rateLimit.Burst != 10 {
+		t.Errorf("Wrong alerts rate limit value: %v", rateLimit)
+	}
+}
+
+func TestMonitoringBuffer(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if config.MonitoringBuffer.Size != 100 {
+		t.Errorf("Wrong monitoring buffer size value: %d", config.MonitoringBuffer.Size)
+	}
+
+	if config.MonitoringBuffer.BatchSize != 10 {
+		t.Errorf("Wrong monitoring buffer batch size value: %d", config.MonitoringBuffer.BatchSize)
+	}
+
+	if !config.MonitoringBuffer.Persistent {
+		t.Error("Monitoring buffer should be persistent")
+	}
+
+	if config.MonitoringBuffer.DownsamplePeriod.Duration != 5*time.Minute {
+		t.Errorf("Wrong monitoring buffer downsample period value: %v", config.MonitoringBuffer.DownsamplePeriod)
+	}
+}
+
 func TestGetIAMProtectedServerURL(t *testing.T) {
 	config, err := config.New("tmp/aos_servicemanager.cfg")
 	if err != nil {
@@ -326,6 +529,14 @@ func TestGetLoggingConfig(t *testing.T) {
 	if config.Logging.MaxPartCount != 10 {
 		t.Errorf("Wrong max part count: %d", config.Logging.MaxPartCount)
 	}
+
+	if config.Logging.FollowFlushInterval.Duration != 2*time.Second {
+		t.Errorf("Wrong follow flush interval: %v", config.Logging.FollowFlushInterval)
+	}
+
+	if config.Logging.MaxFollowDuration.Duration != 30*time.Minute {
+		t.Errorf("Wrong max follow duration: %v", config.Logging.MaxFollowDuration)
+	}
 }
 
 func TestGetAlertsConfig(t *testing.T) {
@@ -396,6 +607,32 @@ func TestDatabaseMigration(t *testing.T) {
 	}
 }
 
+func TestDiagnosticsSocket(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %s", err)
+	}
+
+	if config.DiagnosticsSocket != "/run/aos/servicemanager/diagnostics.sock" {
+		t.Errorf("Wrong diagnostics socket value: %s", config.DiagnosticsSocket)
+	}
+}
+
+func TestSignatureVerification(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %s", err)
+	}
+
+	if config.SignatureVerification.TrustStore != "/etc/aos/truststore" {
+		t.Errorf("Wrong trust store value: %s", config.SignatureVerification.TrustStore)
+	}
+
+	if !config.SignatureVerification.Strict {
+		t.Error("Strict mode should be enabled")
+	}
+}
+
 func TestCertStorage(t *testing.T) {
 	config, err := config.New("tmp/aos_servicemanager.cfg")
 	if err != nil {
@@ -431,6 +668,10 @@ func TestPartLimit(t *testing.T) {
 	if config.LayersPartLimit != 20 {
 		t.Errorf("Wrong LayersPartLimit value: %v", config.LayersPartLimit)
 	}
+
+	if config.BlobsPartLimit != 30 {
+		t.Errorf("Wrong BlobsPartLimit value: %v", config.BlobsPartLimit)
+	}
 }
 
 func TestRemoteNodeFalg(t *testing.T) {
@@ -454,3 +695,14 @@ func TestRunnerFeatures(t *testing.T) {
 		t.Errorf("Wrong runnerFeatures value: %v", config.RunnerFeatures)
 	}
 }
+
+func TestInstanceRunner(t *testing.T) {
+	config, err := config.New("tmp/aos_servicemanager.cfg")
+	if err != nil {
+		t.Fatalf("Error opening config file: %v", err)
+	}
+
+	if config.InstanceRunner != "process" {
+		t.Errorf("Wrong instanceRunner value: %s", config.InstanceRunner)
+	}
+}
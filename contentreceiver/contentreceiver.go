@@ -0,0 +1,402 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contentreceiver provides set of API to receive service and layer images from CM
+package contentreceiver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Content types.
+const (
+	ContentTypeService = "service"
+	ContentTypeLayer   = "layer"
+)
+
+const requestChannelSize = 1
+
+// DefaultRequestTimeout default max time to receive whole image content.
+const DefaultRequestTimeout = 1 * time.Hour
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ContentRequest image content request.
+type ContentRequest struct {
+	URL         string
+	RequestID   uint64
+	ContentType string
+}
+
+// ContentFile image content file information.
+type ContentFile struct {
+	RelativePath string
+	Sha256       []byte
+	Size         uint64
+}
+
+// ContentInfo image content information.
+type ContentInfo struct {
+	RequestID uint64
+	Files     []ContentFile
+	Error     string
+}
+
+// ContentPart image content part.
+type ContentPart struct {
+	RequestID    uint64
+	RelativePath string
+	PartsCount   uint64
+	Part         uint64
+	Data         []byte
+}
+
+// ContentReceiver content receiver instance.
+type ContentReceiver struct {
+	sync.Mutex
+
+	requestChannel chan ContentRequest
+	requests       map[uint64]*contentRequest
+	lastRequestID  uint64
+}
+
+type contentRequest struct {
+	sync.Mutex
+
+	destination     string
+	files           map[string]*contentFile
+	infoReceived    bool
+	doneChannel     chan error
+	activityChannel chan struct{}
+}
+
+type contentFile struct {
+	ContentFile
+	path          string
+	receivedParts uint64
+	receivedSize  uint64
+	hash          hash.Hash
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ContentTimeout specifies max time between two consecutive content messages.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var ContentTimeout = 1 * time.Minute
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new content receiver.
+func New() (receiver *ContentReceiver, err error) {
+	log.Debug("New content receiver")
+
+	receiver = &ContentReceiver{
+		requestChannel: make(chan ContentRequest, requestChannelSize),
+		requests:       make(map[uint64]*contentRequest),
+	}
+
+	return receiver, nil
+}
+
+// GetContentRequestChannel returns channel with content requests to be sent.
+func (receiver *ContentReceiver) GetContentRequestChannel() (channel <-chan ContentRequest) {
+	return receiver.requestChannel
+}
+
+// GetImageContent requests image content from CM and stores received files into destination folder.
+func (receiver *ContentReceiver) GetImageContent(
+	ctx context.Context, url, contentType, destination string,
+) (fileNames []string, err error) {
+	requestID, request := receiver.newRequest(destination)
+	defer receiver.removeRequest(requestID)
+
+	log.WithFields(log.Fields{
+		"url": url, "requestID": requestID, "contentType": contentType,
+	}).Debug("Request image content")
+
+	select {
+	case receiver.requestChannel <- ContentRequest{URL: url, RequestID: requestID, ContentType: contentType}:
+
+	case <-ctx.Done():
+		return nil, aoserrors.Wrap(ctx.Err())
+	}
+
+	for {
+		select {
+		case err := <-request.doneChannel:
+			if err != nil {
+				return nil, err
+			}
+
+			return request.getFileNames(), nil
+
+		case <-request.activityChannel:
+
+		case <-time.After(ContentTimeout):
+			return nil, aoserrors.New("wait image content timeout")
+
+		case <-ctx.Done():
+			return nil, aoserrors.Wrap(ctx.Err())
+		}
+	}
+}
+
+// ProcessContentInfo processes image content info received from CM.
+func (receiver *ContentReceiver) ProcessContentInfo(info ContentInfo) error {
+	request, err := receiver.getRequest(info.RequestID)
+	if err != nil {
+		return err
+	}
+
+	request.Lock()
+	defer request.Unlock()
+
+	request.notifyActivity()
+
+	if request.infoReceived {
+		return aoserrors.Errorf("content info already received: %d", info.RequestID)
+	}
+
+	request.infoReceived = true
+
+	if info.Error != "" {
+		request.done(aoserrors.New(info.Error))
+
+		return nil
+	}
+
+	if len(info.Files) == 0 {
+		request.done(aoserrors.New("no image content files"))
+
+		return nil
+	}
+
+	for _, file := range info.Files {
+		if err := request.addFile(file); err != nil {
+			request.done(err)
+
+			return nil
+		}
+	}
+
+	if request.isCompleted() {
+		request.done(request.validateFiles())
+	}
+
+	return nil
+}
+
+// ProcessContentPart processes image content part received from CM.
+func (receiver *ContentReceiver) ProcessContentPart(part ContentPart) error {
+	request, err := receiver.getRequest(part.RequestID)
+	if err != nil {
+		return err
+	}
+
+	request.Lock()
+	defer request.Unlock()
+
+	request.notifyActivity()
+
+	if !request.infoReceived {
+		request.done(aoserrors.New("content part received before content info"))
+
+		return nil
+	}
+
+	if err := request.writePart(part); err != nil {
+		request.done(err)
+
+		return nil
+	}
+
+	if request.isCompleted() {
+		request.done(request.validateFiles())
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (receiver *ContentReceiver) newRequest(destination string) (requestID uint64, request *contentRequest) {
+	receiver.Lock()
+	defer receiver.Unlock()
+
+	receiver.lastRequestID++
+
+	request = &contentRequest{
+		destination:     destination,
+		files:           make(map[string]*contentFile),
+		doneChannel:     make(chan error, 1),
+		activityChannel: make(chan struct{}, 1),
+	}
+
+	receiver.requests[receiver.lastRequestID] = request
+
+	return receiver.lastRequestID, request
+}
+
+func (receiver *ContentReceiver) removeRequest(requestID uint64) {
+	receiver.Lock()
+	defer receiver.Unlock()
+
+	delete(receiver.requests, requestID)
+}
+
+func (receiver *ContentReceiver) getRequest(requestID uint64) (*contentRequest, error) {
+	receiver.Lock()
+	defer receiver.Unlock()
+
+	request, ok := receiver.requests[requestID]
+	if !ok {
+		return nil, aoserrors.Errorf("unknown content request: %d", requestID)
+	}
+
+	return request, nil
+}
+
+func (request *contentRequest) notifyActivity() {
+	select {
+	case request.activityChannel <- struct{}{}:
+
+	default:
+	}
+}
+
+func (request *contentRequest) done(err error) {
+	select {
+	case request.doneChannel <- err:
+
+	default:
+	}
+}
+
+func (request *contentRequest) addFile(file ContentFile) error {
+	if !filepath.IsLocal(file.RelativePath) {
+		return aoserrors.Errorf("invalid content file path: %s", file.RelativePath)
+	}
+
+	if _, ok := request.files[file.RelativePath]; ok {
+		return aoserrors.Errorf("duplicated content file: %s", file.RelativePath)
+	}
+
+	filePath := filepath.Join(request.destination, file.RelativePath)
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	// Create empty file, as CM doesn't send parts for files without content
+	if err := os.WriteFile(filePath, nil, 0o600); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	request.files[file.RelativePath] = &contentFile{ContentFile: file, path: filePath, hash: sha256.New()}
+
+	return nil
+}
+
+func (request *contentRequest) writePart(part ContentPart) error {
+	file, ok := request.files[part.RelativePath]
+	if !ok {
+		return aoserrors.Errorf("unknown content file: %s", part.RelativePath)
+	}
+
+	if part.Part != file.receivedParts+1 || part.Part > part.PartsCount {
+		return aoserrors.Errorf("unexpected content part %d of %d: %s", part.Part, part.PartsCount, part.RelativePath)
+	}
+
+	if file.receivedSize+uint64(len(part.Data)) > file.Size {
+		return aoserrors.Errorf("content file size exceeded: %s", part.RelativePath)
+	}
+
+	osFile, err := os.OpenFile(file.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer osFile.Close()
+
+	if _, err = osFile.Write(part.Data); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	file.hash.Write(part.Data)
+
+	file.receivedParts = part.Part
+	file.receivedSize += uint64(len(part.Data))
+
+	if file.receivedParts == part.PartsCount && file.receivedSize != file.Size {
+		return aoserrors.Errorf("content file size mismatch: %s", part.RelativePath)
+	}
+
+	return nil
+}
+
+func (request *contentRequest) isCompleted() bool {
+	for _, file := range request.files {
+		if file.receivedSize != file.Size {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (request *contentRequest) validateFiles() error {
+	for _, file := range request.files {
+		if !bytes.Equal(file.hash.Sum(nil), file.Sha256) {
+			return aoserrors.Errorf("content file checksum mismatch: %s", file.RelativePath)
+		}
+	}
+
+	return nil
+}
+
+func (request *contentRequest) getFileNames() (fileNames []string) {
+	request.Lock()
+	defer request.Unlock()
+
+	for _, file := range request.files {
+		fileNames = append(fileNames, file.path)
+	}
+
+	return fileNames
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contentreceiver_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/contentreceiver"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testResult struct {
+	fileNames []string
+	err       error
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestReceiveContent(t *testing.T) {
+	receiver, err := contentreceiver.New()
+	if err != nil {
+		t.Fatalf("Can't create content receiver: %v", err)
+	}
+
+	destination := t.TempDir()
+	data := []byte("service image content")
+	checksum := sha256.Sum256(data)
+
+	resultChannel := getImageContent(receiver, "file:///service1", destination)
+
+	request := waitRequest(t, receiver)
+
+	if request.URL != "file:///service1" || request.ContentType != contentreceiver.ContentTypeService {
+		t.Errorf("Wrong content request: %v", request)
+	}
+
+	if err = receiver.ProcessContentInfo(contentreceiver.ContentInfo{
+		RequestID: request.RequestID,
+		Files: []contentreceiver.ContentFile{
+			{RelativePath: "image/service.tar", Sha256: checksum[:], Size: uint64(len(data))},
+			{RelativePath: "image/empty", Sha256: sha256.New().Sum(nil), Size: 0},
+		},
+	}); err != nil {
+		t.Fatalf("Can't process content info: %v", err)
+	}
+
+	for i, part := range [][]byte{data[:10], data[10:]} {
+		if err = receiver.ProcessContentPart(contentreceiver.ContentPart{
+			RequestID: request.RequestID, RelativePath: "image/service.tar",
+			PartsCount: 2, Part: uint64(i + 1), Data: part,
+		}); err != nil {
+			t.Fatalf("Can't process content part: %v", err)
+		}
+	}
+
+	result := waitResult(t, resultChannel)
+	if result.err != nil {
+		t.Fatalf("Can't get image content: %v", result.err)
+	}
+
+	if len(result.fileNames) != 2 {
+		t.Fatalf("Wrong file names count: %d", len(result.fileNames))
+	}
+
+	receivedData, err := os.ReadFile(filepath.Join(destination, "image/service.tar"))
+	if err != nil {
+		t.Fatalf("Can't read received file: %v", err)
+	}
+
+	if string(receivedData) != string(data) {
+		t.Errorf("Wrong received data: %s", receivedData)
+	}
+
+	if _, err = os.Stat(filepath.Join(destination, "image/empty")); err != nil {
+		t.Errorf("Empty file is not created: %v", err)
+	}
+}
+
+func TestReceiveContentError(t *testing.T) {
+	receiver, err := contentreceiver.New()
+	if err != nil {
+		t.Fatalf("Can't create content receiver: %v", err)
+	}
+
+	data := []byte("service image content")
+
+	// Error reported by CM
+
+	resultChannel := getImageContent(receiver, "file:///service1", t.TempDir())
+	request := waitRequest(t, receiver)
+
+	if err = receiver.ProcessContentInfo(contentreceiver.ContentInfo{
+		RequestID: request.RequestID, Error: "image not found",
+	}); err != nil {
+		t.Fatalf("Can't process content info: %v", err)
+	}
+
+	if result := waitResult(t, resultChannel); result.err == nil {
+		t.Error("Error expected")
+	}
+
+	// Checksum mismatch
+
+	resultChannel = getImageContent(receiver, "file:///service1", t.TempDir())
+	request = waitRequest(t, receiver)
+
+	if err = receiver.ProcessContentInfo(contentreceiver.ContentInfo{
+		RequestID: request.RequestID,
+		Files: []contentreceiver.ContentFile{
+			{RelativePath: "service.tar", Sha256: []byte{1, 2, 3}, Size: uint64(len(data))},
+		},
+	}); err != nil {
+		t.Fatalf("Can't process content info: %v", err)
+	}
+
+	if err = receiver.ProcessContentPart(contentreceiver.ContentPart{
+		RequestID: request.RequestID, RelativePath: "service.tar", PartsCount: 1, Part: 1, Data: data,
+	}); err != nil {
+		t.Fatalf("Can't process content part: %v", err)
+	}
+
+	if result := waitResult(t, resultChannel); result.err == nil {
+		t.Error("Error expected")
+	}
+
+	// Invalid file path
+
+	resultChannel = getImageContent(receiver, "file:///service1", t.TempDir())
+	request = waitRequest(t, receiver)
+
+	if err = receiver.ProcessContentInfo(contentreceiver.ContentInfo{
+		RequestID: request.RequestID,
+		Files:     []contentreceiver.ContentFile{{RelativePath: "../service.tar", Size: uint64(len(data))}},
+	}); err != nil {
+		t.Fatalf("Can't process content info: %v", err)
+	}
+
+	if result := waitResult(t, resultChannel); result.err == nil {
+		t.Error("Error expected")
+	}
+
+	// Unknown request
+
+	if err = receiver.ProcessContentPart(contentreceiver.ContentPart{RequestID: 100}); err == nil {
+		t.Error("Error expected")
+	}
+}
+
+func TestReceiveContentTimeout(t *testing.T) {
+	contentreceiver.ContentTimeout = 100 * time.Millisecond
+
+	defer func() {
+		contentreceiver.ContentTimeout = 1 * time.Minute
+	}()
+
+	receiver, err := contentreceiver.New()
+	if err != nil {
+		t.Fatalf("Can't create content receiver: %v", err)
+	}
+
+	resultChannel := getImageContent(receiver, "file:///service1", t.TempDir())
+
+	waitRequest(t, receiver)
+
+	if result := waitResult(t, resultChannel); result.err == nil {
+		t.Error("Error expected")
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func getImageContent(
+	receiver *contentreceiver.ContentReceiver, url, destination string,
+) (resultChannel <-chan testResult) {
+	channel := make(chan testResult, 1)
+
+	go func() {
+		fileNames, err := receiver.GetImageContent(
+			context.Background(), url, contentreceiver.ContentTypeService, destination)
+
+		channel <- testResult{fileNames: fileNames, err: err}
+	}()
+
+	return channel
+}
+
+func waitRequest(t *testing.T, receiver *contentreceiver.ContentReceiver) contentreceiver.ContentRequest {
+	t.Helper()
+
+	select {
+	case request := <-receiver.GetContentRequestChannel():
+		return request
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait content request timeout")
+	}
+
+	return contentreceiver.ContentRequest{}
+}
+
+func waitResult(t *testing.T, resultChannel <-chan testResult) testResult {
+	t.Helper()
+
+	select {
+	case result := <-resultChannel:
+		return result
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait content result timeout")
+	}
+
+	return testResult{}
+}
@@ -34,8 +34,11 @@ import (
 	_ "github.com/mattn/go-sqlite3" // ignore lint
 	log "github.com/sirupsen/logrus"
 
+	"github.com/aoscloud/aos_servicemanager/alerts"
+	"github.com/aoscloud/aos_servicemanager/blobstore"
 	"github.com/aoscloud/aos_servicemanager/launcher"
 	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/monitorcontroller"
 	"github.com/aoscloud/aos_servicemanager/networkmanager"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
 )
@@ -50,7 +53,7 @@ const (
 	syncMode    = "NORMAL"
 )
 
-const dbVersion = 6
+const dbVersion = 14
 
 /***********************************************************************************************************************
  * Vars
@@ -100,9 +103,10 @@ func (db *Database) SetOperationVersion(version uint64) error {
 
 // AddService adds new service.
 func (db *Database) AddService(service servicemanager.ServiceInfo) (err error) {
-	return db.executeQuery("INSERT INTO services values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	return db.executeQuery("INSERT INTO services values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		service.ServiceID, service.AosVersion, service.ServiceProvider, service.Description, service.ImagePath,
-		service.ManifestDigest, service.Cached, service.Timestamp, service.Size, service.GID)
+		service.ManifestDigest, service.Cached, service.Timestamp, service.Size, service.GID, service.SignatureStatus,
+		service.Rejected, service.Quarantined, service.VerityRootHash)
 }
 
 // RemoveService removes existing service.
@@ -124,7 +128,8 @@ func (db *Database) GetServices() (services []servicemanager.ServiceInfo, err er
 			return []any{
 				&service.ServiceID, &service.AosVersion, &service.ServiceProvider, &service.Description,
 				&service.ImagePath, &service.ManifestDigest, &service.Cached, &service.Timestamp,
-				&service.Size, &service.GID,
+				&service.Size, &service.GID, &service.SignatureStatus, &service.Rejected, &service.Quarantined,
+				&service.VerityRootHash,
 			}
 		})
 }
@@ -138,7 +143,8 @@ func (db *Database) GetAllServiceVersions(id string) (services []servicemanager.
 			return []any{
 				&service.ServiceID, &service.AosVersion, &service.ServiceProvider, &service.Description,
 				&service.ImagePath, &service.ManifestDigest, &service.Cached, &service.Timestamp,
-				&service.Size, &service.GID,
+				&service.Size, &service.GID, &service.SignatureStatus, &service.Rejected, &service.Quarantined,
+				&service.VerityRootHash,
 			}
 		}, id); err != nil {
 		return nil, err
@@ -161,6 +167,26 @@ func (db *Database) SetServiceCached(serviceID string, aosVersion uint64, cached
 	return err
 }
 
+// SetServiceRejected sets rejected status for the service.
+func (db *Database) SetServiceRejected(serviceID string, aosVersion uint64, rejected bool) (err error) {
+	if err = db.executeQuery("UPDATE services SET rejected = ? WHERE id = ? AND aosVersion = ?",
+		rejected, serviceID, aosVersion); errors.Is(err, errNotExist) {
+		return servicemanager.ErrNotExist
+	}
+
+	return err
+}
+
+// SetServiceQuarantined sets quarantined status for the service.
+func (db *Database) SetServiceQuarantined(serviceID string, aosVersion uint64, quarantined bool) (err error) {
+	if err = db.executeQuery("UPDATE services SET quarantined = ? WHERE id = ? AND aosVersion = ?",
+		quarantined, serviceID, aosVersion); errors.Is(err, errNotExist) {
+		return servicemanager.ErrNotExist
+	}
+
+	return err
+}
+
 // SetTrafficMonitorData stores traffic monitor data.
 func (db *Database) SetTrafficMonitorData(chain string, timestamp time.Time, value uint64) (err error) {
 	if err = db.executeQuery("UPDATE trafficmonitor SET time = ?, value = ? where chain = ?",
@@ -257,9 +283,10 @@ func (db *Database) SetOnlineTime(onlineTime time.Time) error {
 
 // AddLayer add layer to layers table.
 func (db *Database) AddLayer(layer layermanager.LayerInfo) (err error) {
-	return db.executeQuery("INSERT INTO layers values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	return db.executeQuery("INSERT INTO layers values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		layer.Digest, layer.LayerID, layer.Path, layer.OSVersion, layer.VendorVersion,
-		layer.Description, layer.AosVersion, layer.Timestamp, layer.Cached, layer.Size)
+		layer.Description, layer.AosVersion, layer.Timestamp, layer.Cached, layer.Size, layer.SignatureStatus,
+		layer.ContentDigest, layer.Quarantined)
 }
 
 // DeleteLayerByDigest remove layer from DB by digest.
@@ -280,7 +307,7 @@ func (db *Database) GetLayersInfo() (layersList []layermanager.LayerInfo, err er
 			return []any{
 				&layer.Digest, &layer.LayerID, &layer.Path, &layer.OSVersion,
 				&layer.VendorVersion, &layer.Description, &layer.AosVersion, &layer.Timestamp,
-				&layer.Cached, &layer.Size,
+				&layer.Cached, &layer.Size, &layer.SignatureStatus, &layer.ContentDigest, &layer.Quarantined,
 			}
 		})
 }
@@ -290,7 +317,8 @@ func (db *Database) GetLayerInfoByDigest(digest string) (layer layermanager.Laye
 	if err = db.getDataFromQuery(fmt.Sprintf("SELECT * FROM layers WHERE digest = \"%s\"", digest),
 		&layer.Digest, &layer.LayerID, &layer.Path, &layer.OSVersion,
 		&layer.VendorVersion, &layer.Description,
-		&layer.AosVersion, &layer.Timestamp, &layer.Cached, &layer.Size); err != nil {
+		&layer.AosVersion, &layer.Timestamp, &layer.Cached, &layer.Size, &layer.SignatureStatus,
+		&layer.ContentDigest, &layer.Quarantined); err != nil {
 		if errors.Is(err, errNotExist) {
 			return layer, layermanager.ErrNotExist
 		}
@@ -301,6 +329,80 @@ func (db *Database) GetLayerInfoByDigest(digest string) (layer layermanager.Laye
 	return layer, nil
 }
 
+// AddBlobReference adds blob reference.
+func (db *Database) AddBlobReference(ref blobstore.BlobReference) error {
+	return db.executeQuery("INSERT OR REPLACE INTO blobs values(?, ?)", ref.Digest, ref.Owner)
+}
+
+// RemoveBlobReference removes blob reference.
+func (db *Database) RemoveBlobReference(ref blobstore.BlobReference) (err error) {
+	if err = db.executeQuery("DELETE FROM blobs WHERE digest = ? AND owner = ?",
+		ref.Digest, ref.Owner); errors.Is(err, errNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// GetBlobReferences returns all blob references.
+func (db *Database) GetBlobReferences() (refs []blobstore.BlobReference, err error) {
+	return getFromQuery(
+		db,
+		"SELECT * FROM blobs",
+		func(ref *blobstore.BlobReference) []any {
+			return []any{&ref.Digest, &ref.Owner}
+		})
+}
+
+// AddAlert adds alert to the alerts queue.
+func (db *Database) AddAlert(alert alerts.StoredAlert) error {
+	return db.executeQuery("INSERT INTO alerts values(?, ?, ?, ?)",
+		alert.ID, alert.Timestamp, alert.Tag, alert.Payload)
+}
+
+// RemoveAlert removes alert from the alerts queue.
+func (db *Database) RemoveAlert(id uint64) (err error) {
+	if err = db.executeQuery("DELETE FROM alerts WHERE id = ?", id); errors.Is(err, errNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// GetAlerts returns queued alerts ordered by ID.
+func (db *Database) GetAlerts() (storedAlerts []alerts.StoredAlert, err error) {
+	return getFromQuery(
+		db,
+		"SELECT * FROM alerts ORDER BY id",
+		func(alert *alerts.StoredAlert) []any {
+			return []any{&alert.ID, &alert.Timestamp, &alert.Tag, &alert.Payload}
+		})
+}
+
+// AddMonitoringData adds monitoring data to the monitoring buffer.
+func (db *Database) AddMonitoringData(data monitorcontroller.StoredMonitoringData) error {
+	return db.executeQuery("INSERT INTO monitoring values(?, ?, ?)", data.ID, data.Timestamp, data.Data)
+}
+
+// RemoveMonitoringData removes monitoring data up to specified ID from the monitoring buffer.
+func (db *Database) RemoveMonitoringData(id uint64) (err error) {
+	if err = db.executeQuery("DELETE FROM monitoring WHERE id <= ?", id); errors.Is(err, errNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// GetMonitoringData returns buffered monitoring data ordered by ID.
+func (db *Database) GetMonitoringData() (storedData []monitorcontroller.StoredMonitoringData, err error) {
+	return getFromQuery(
+		db,
+		"SELECT * FROM monitoring ORDER BY id",
+		func(data *monitorcontroller.StoredMonitoringData) []any {
+			return []any{&data.ID, &data.Timestamp, &data.Data}
+		})
+}
+
 // SetLayerTimestamp sets timestamp for the layer.
 func (db *Database) SetLayerTimestamp(digest string, timestamp time.Time) error {
 	if err := db.executeQuery("UPDATE layers SET timestamp = ? WHERE digest = ?",
@@ -321,6 +423,16 @@ func (db *Database) SetLayerCached(digest string, cached bool) (err error) {
 	return err
 }
 
+// SetLayerQuarantined sets quarantined status for the layer.
+func (db *Database) SetLayerQuarantined(digest string, quarantined bool) (err error) {
+	if err = db.executeQuery("UPDATE layers SET quarantined = ? WHERE digest = ?",
+		quarantined, digest); errors.Is(err, errNotExist) {
+		return layermanager.ErrNotExist
+	}
+
+	return err
+}
+
 // AddInstance adds instance information to db.
 func (db *Database) AddInstance(instance launcher.InstanceInfo) error {
 	network, err := json.Marshal(instance.NetworkParameters)
@@ -530,6 +642,10 @@ func newDatabase(name string, migrationPath string, mergedMigrationPath string,
 			return db, aoserrors.Wrap(ErrMigrationFailed)
 		}
 	} else {
+		if err = db.fixNetworkTableVersion(migrationPath); err != nil {
+			return db, err
+		}
+
 		if err = migration.DoMigrate(db.sql, mergedMigrationPath, version); err != nil {
 			log.Errorf("Error during database migration. Err: %s", err)
 
@@ -561,9 +677,69 @@ func newDatabase(name string, migrationPath string, mergedMigrationPath string,
 		return db, err
 	}
 
+	if err := db.createBlobsTable(); err != nil {
+		return db, err
+	}
+
+	if err := db.createAlertsTable(); err != nil {
+		return db, err
+	}
+
+	if err := db.createMonitoringTable(); err != nil {
+		return db, err
+	}
+
 	return db, nil
 }
 
+// fixNetworkTableVersion sets version 7 for databases which were created with version 6 but already have
+// network vlanIfName column added by migration 7. Otherwise, migration 7 fails on such databases.
+func (db *Database) fixNetworkTableVersion(migrationPath string) error {
+	const (
+		networkTableVersion     = 7
+		prevNetworkTableVersion = 6
+	)
+
+	var (
+		version     uint
+		columnCount int
+	)
+
+	exist, err := db.isTableExist("schema_migrations")
+	if err != nil || !exist {
+		return err
+	}
+
+	if err = db.getDataFromQuery("SELECT version FROM schema_migrations", &version); err != nil {
+		if errors.Is(err, errNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if version != prevNetworkTableVersion {
+		return nil
+	}
+
+	if err = db.getDataFromQuery(
+		"SELECT COUNT(*) FROM pragma_table_info('network') WHERE name = 'vlanIfName'", &columnCount); err != nil {
+		return err
+	}
+
+	if columnCount == 0 {
+		return nil
+	}
+
+	log.Warnf("Set database version %d as network table is already up to date", networkTableVersion)
+
+	if err = migration.SetDatabaseVersion(db.sql, migrationPath, networkTableVersion); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
 func (db *Database) isTableExist(name string) (result bool, err error) {
 	rows, err := db.sql.Query("SELECT * FROM sqlite_master WHERE name = ? and type='table'", name)
 	if err != nil {
@@ -634,6 +810,10 @@ func (db *Database) createServiceTable() (err error) {
 															   timestamp TIMESTAMP,
 															   size INTEGER,
 															   GID INTEGER,
+															   signatureStatus TEXT,
+															   rejected INTEGER,
+															   quarantined INTEGER,
+															   verityRootHash TEXT,
 															   PRIMARY KEY(id, aosVersion))`)
 
 	return aoserrors.Wrap(err)
@@ -661,7 +841,10 @@ func (db *Database) createLayersTable() (err error) {
 															 aosVersion INTEGER,
 															 timestamp TIMESTAMP,
 															 cached INTEGER,
-															 size INTEGER)`)
+															 size INTEGER,
+															 signatureStatus TEXT,
+															 contentDigest TEXT,
+															 quarantined INTEGER)`)
 
 	return aoserrors.Wrap(err)
 }
@@ -682,6 +865,37 @@ func (db *Database) createInstancesTable() (err error) {
 	return aoserrors.Wrap(err)
 }
 
+func (db *Database) createBlobsTable() (err error) {
+	log.Info("Create blobs table")
+
+	_, err = db.sql.Exec(`CREATE TABLE IF NOT EXISTS blobs (digest TEXT NOT NULL,
+															owner TEXT NOT NULL,
+															PRIMARY KEY(digest, owner))`)
+
+	return aoserrors.Wrap(err)
+}
+
+func (db *Database) createAlertsTable() (err error) {
+	log.Info("Create alerts table")
+
+	_, err = db.sql.Exec(`CREATE TABLE IF NOT EXISTS alerts (id INTEGER NOT NULL PRIMARY KEY,
+															 timestamp TIMESTAMP,
+															 tag TEXT,
+															 payload BLOB)`)
+
+	return aoserrors.Wrap(err)
+}
+
+func (db *Database) createMonitoringTable() (err error) {
+	log.Info("Create monitoring table")
+
+	_, err = db.sql.Exec(`CREATE TABLE IF NOT EXISTS monitoring (id INTEGER NOT NULL PRIMARY KEY,
+																 timestamp TIMESTAMP,
+																 data BLOB)`)
+
+	return aoserrors.Wrap(err)
+}
+
 func (db *Database) removeAllServices() (err error) {
 	_, err = db.sql.Exec("DELETE FROM services")
 
@@ -35,9 +35,13 @@ import (
 	"github.com/aoscloud/aos_common/migration"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
+	"github.com/aoscloud/aos_servicemanager/alerts"
+	"github.com/aoscloud/aos_servicemanager/blobstore"
 	"github.com/aoscloud/aos_servicemanager/launcher"
 	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/monitorcontroller"
 	"github.com/aoscloud/aos_servicemanager/networkmanager"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
 )
@@ -108,6 +112,8 @@ func TestAddGetService(t *testing.T) {
 		ServiceProvider: "sp1",
 		ImagePath:       "to/service1",
 		Size:            30,
+		SignatureStatus: "verified",
+		VerityRootHash:  "sha256:1111",
 	}
 
 	if _, err := db.GetAllServiceVersions(service.ServiceID); err == nil || !errors.Is(err, servicemanager.ErrNotExist) {
@@ -311,6 +317,234 @@ func TestCachedService(t *testing.T) {
 	}
 }
 
+func TestRejectedService(t *testing.T) {
+	service := servicemanager.ServiceInfo{
+		ServiceID:       "serviceRejected",
+		VersionInfo:     aostypes.VersionInfo{AosVersion: 1},
+		ServiceProvider: "sp1",
+		ImagePath:       "to/service1",
+	}
+
+	if err := db.AddService(service); err != nil {
+		t.Errorf("Can't add service: %v", err)
+	}
+
+	if err := db.SetServiceRejected(service.ServiceID, service.AosVersion, true); err != nil {
+		t.Errorf("Can't set service rejected: %v", err)
+	}
+
+	services, err := db.GetAllServiceVersions("serviceRejected")
+	if err != nil {
+		t.Errorf("Can't get service: %v", err)
+	}
+
+	service.Rejected = true
+
+	if !reflect.DeepEqual([]servicemanager.ServiceInfo{service}, services) {
+		t.Error("Unexpected services")
+	}
+
+	if err := db.SetServiceRejected(service.ServiceID, 2, true); !errors.Is(err, servicemanager.ErrNotExist) {
+		t.Errorf("Wrong set rejected error: %v", err)
+	}
+}
+
+func TestQuarantinedService(t *testing.T) {
+	service := servicemanager.ServiceInfo{
+		ServiceID:       "serviceQuarantined",
+		VersionInfo:     aostypes.VersionInfo{AosVersion: 1},
+		ServiceProvider: "sp1",
+		ImagePath:       "to/service1",
+	}
+
+	if err := db.AddService(service); err != nil {
+		t.Errorf("Can't add service: %v", err)
+	}
+
+	if err := db.SetServiceQuarantined(service.ServiceID, service.AosVersion, true); err != nil {
+		t.Errorf("Can't set service quarantined: %v", err)
+	}
+
+	services, err := db.GetAllServiceVersions("serviceQuarantined")
+	if err != nil {
+		t.Errorf("Can't get service: %v", err)
+	}
+
+	service.Quarantined = true
+
+	if !reflect.DeepEqual([]servicemanager.ServiceInfo{service}, services) {
+		t.Error("Unexpected services")
+	}
+
+	if err := db.SetServiceQuarantined(service.ServiceID, 2, true); !errors.Is(err, servicemanager.ErrNotExist) {
+		t.Errorf("Wrong set quarantined error: %v", err)
+	}
+}
+
+func TestQuarantinedLayer(t *testing.T) {
+	layer := layermanager.LayerInfo{
+		Digest: "sha256:quarantined", LayerID: "quarantined", Path: "quarantinedPath", ContentDigest: "sha256:1",
+	}
+
+	if err := db.AddLayer(layer); err != nil {
+		t.Fatalf("Can't add layer: %v", err)
+	}
+
+	defer func() {
+		if err := db.DeleteLayerByDigest(layer.Digest); err != nil {
+			t.Errorf("Can't delete layer: %v", err)
+		}
+	}()
+
+	if err := db.SetLayerQuarantined(layer.Digest, true); err != nil {
+		t.Errorf("Can't set layer quarantined: %v", err)
+	}
+
+	savedLayer, err := db.GetLayerInfoByDigest(layer.Digest)
+	if err != nil {
+		t.Errorf("Can't get layer: %v", err)
+	}
+
+	layer.Quarantined = true
+
+	if !reflect.DeepEqual(savedLayer, layer) {
+		t.Error("Unexpected layer")
+	}
+
+	if err := db.SetLayerQuarantined("sha256:notExist", true); !errors.Is(err, layermanager.ErrNotExist) {
+		t.Errorf("Wrong set quarantined error: %v", err)
+	}
+}
+
+func TestBlobReferences(t *testing.T) {
+	refs := []blobstore.BlobReference{
+		{Digest: "sha256:1111", Owner: "service:service1_1"},
+		{Digest: "sha256:1111", Owner: "service:service1_2"},
+		{Digest: "sha256:2222", Owner: "layer:sha256:3333"},
+	}
+
+	for _, ref := range refs {
+		if err := db.AddBlobReference(ref); err != nil {
+			t.Errorf("Can't add blob reference: %v", err)
+		}
+	}
+
+	// Adding existing reference should not fail
+	if err := db.AddBlobReference(refs[0]); err != nil {
+		t.Errorf("Can't add blob reference: %v", err)
+	}
+
+	dbRefs, err := db.GetBlobReferences()
+	if err != nil {
+		t.Fatalf("Can't get blob references: %v", err)
+	}
+
+	if len(dbRefs) != len(refs) {
+		t.Errorf("Wrong blob references: %v", dbRefs)
+	}
+
+	for _, ref := range refs {
+		if !slices.Contains(dbRefs, ref) {
+			t.Errorf("Blob reference not found: %v", ref)
+		}
+
+		if err := db.RemoveBlobReference(ref); err != nil {
+			t.Errorf("Can't remove blob reference: %v", err)
+		}
+	}
+
+	// Removing not existing reference should not fail
+	if err := db.RemoveBlobReference(refs[0]); err != nil {
+		t.Errorf("Can't remove blob reference: %v", err)
+	}
+
+	if dbRefs, err = db.GetBlobReferences(); err != nil {
+		t.Fatalf("Can't get blob references: %v", err)
+	}
+
+	if len(dbRefs) != 0 {
+		t.Errorf("Wrong blob references: %v", dbRefs)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	storedAlerts := []alerts.StoredAlert{
+		{ID: 1, Timestamp: timestamp, Tag: cloudprotocol.AlertTagSystemError, Payload: []byte(`{"message":"1"}`)},
+		{ID: 2, Timestamp: timestamp, Tag: cloudprotocol.AlertTagAosCore, Payload: []byte(`{"message":"2"}`)},
+		{ID: 3, Timestamp: timestamp, Tag: cloudprotocol.AlertTagSystemError, Payload: []byte(`{"message":"3"}`)},
+	}
+
+	for _, alert := range storedAlerts {
+		if err := db.AddAlert(alert); err != nil {
+			t.Errorf("Can't add alert: %v", err)
+		}
+	}
+
+	if err := db.RemoveAlert(2); err != nil {
+		t.Errorf("Can't remove alert: %v", err)
+	}
+
+	// Removing not existing alert should not fail
+	if err := db.RemoveAlert(2); err != nil {
+		t.Errorf("Can't remove alert: %v", err)
+	}
+
+	dbAlerts, err := db.GetAlerts()
+	if err != nil {
+		t.Fatalf("Can't get alerts: %v", err)
+	}
+
+	if !reflect.DeepEqual(dbAlerts, []alerts.StoredAlert{storedAlerts[0], storedAlerts[2]}) {
+		t.Errorf("Wrong alerts: %v", dbAlerts)
+	}
+
+	for _, alert := range dbAlerts {
+		if err := db.RemoveAlert(alert.ID); err != nil {
+			t.Errorf("Can't remove alert: %v", err)
+		}
+	}
+}
+
+func TestMonitoringData(t *testing.T) {
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	storedData := []monitorcontroller.StoredMonitoringData{
+		{ID: 1, Timestamp: timestamp, Data: []byte(`{"ram":1}`)},
+		{ID: 2, Timestamp: timestamp.Add(time.Minute), Data: []byte(`{"ram":2}`)},
+		{ID: 3, Timestamp: timestamp.Add(2 * time.Minute), Data: []byte(`{"ram":3}`)},
+	}
+
+	for _, data := range storedData {
+		if err := db.AddMonitoringData(data); err != nil {
+			t.Errorf("Can't add monitoring data: %v", err)
+		}
+	}
+
+	if err := db.RemoveMonitoringData(2); err != nil {
+		t.Errorf("Can't remove monitoring data: %v", err)
+	}
+
+	// Removing already removed data should not fail
+	if err := db.RemoveMonitoringData(2); err != nil {
+		t.Errorf("Can't remove monitoring data: %v", err)
+	}
+
+	dbData, err := db.GetMonitoringData()
+	if err != nil {
+		t.Fatalf("Can't get monitoring data: %v", err)
+	}
+
+	if !reflect.DeepEqual(dbData, storedData[2:]) {
+		t.Errorf("Wrong monitoring data: %v", dbData)
+	}
+
+	if err := db.RemoveMonitoringData(3); err != nil {
+		t.Errorf("Can't remove monitoring data: %v", err)
+	}
+}
+
 func TestSetTimestampService(t *testing.T) {
 	service := servicemanager.ServiceInfo{
 		ServiceID: "serviceTimestamp",
@@ -466,7 +700,9 @@ func TestLayers(t *testing.T) {
 			Description:   "some layer 1",
 			AosVersion:    1,
 		},
-		Size: 40,
+		Size:            40,
+		SignatureStatus: "unsigned",
+		ContentDigest:   "sha256:11",
 	}
 
 	if err := db.AddLayer(layer1); err != nil {
@@ -1013,114 +1249,507 @@ func TestMigrationToV7(t *testing.T) {
 	db.Close()
 }
 
-/***********************************************************************************************************************
- * Private
- **********************************************************************************************************************/
+func TestMigrationToV8(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
 
-func createDatabaseV0(name string) (err error) {
-	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_sync=%s",
-		name, busyTimeout, journalMode, syncMode))
-	if err != nil {
-		return aoserrors.Wrap(err)
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
 	}
-	defer sqlite.Close()
 
-	if _, err = sqlite.Exec(
-		`CREATE TABLE config (
-			operationVersion INTEGER,
-			cursor TEXT)`); err != nil {
-		return aoserrors.Wrap(err)
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
 	}
 
-	if _, err = sqlite.Exec(
-		`INSERT INTO config (
-			operationVersion,
-			cursor) values(?, ?)`, launcher.OperationVersion, ""); err != nil {
-		return aoserrors.Wrap(err)
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 8)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
 	}
 
-	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS services (id TEXT NOT NULL PRIMARY KEY,
-															   aosVersion INTEGER,
-															   serviceProvider TEXT,
-															   path TEXT,
-															   unit TEXT,
-															   uid INTEGER,
-															   gid INTEGER,
-															   hostName TEXT,
-															   permissions TEXT,
-															   state INTEGER,
-															   status INTEGER,
-															   startat TIMESTAMP,
-															   ttl INTEGER,
-															   alertRules TEXT,
-															   ulLimit INTEGER,
-															   dlLimit INTEGER,
-															   ulSpeed INTEGER,
-															   dlSpeed INTEGER,
-															   storageLimit INTEGER,
-															   stateLimit INTEGER,
-															   layerList TEXT,
-															   deviceResources TEXT,
-															   boardResources TEXT,
-															   vendorVersion TEXT,
-															   description TEXT)`); err != nil {
-		return aoserrors.Wrap(err)
+	if err = isDatabaseVer8(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
 	}
 
-	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS users (users TEXT NOT NULL,
-															serviceid TEXT NOT NULL,
-															storageFolder TEXT,
-															stateCheckSum BLOB,
-															PRIMARY KEY(users, serviceid))`); err != nil {
-		return aoserrors.Wrap(err)
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 7)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
 	}
 
-	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS trafficmonitor (chain TEXT NOT NULL PRIMARY KEY,
-																	 time TIMESTAMP,
-																	 value INTEGER)`); err != nil {
-		return aoserrors.Wrap(err)
+	if err = isDatabaseVer7(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
 	}
 
-	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS layers (digest TEXT NOT NULL PRIMARY KEY,
-															 layerId TEXT,
-															 path TEXT,
-															 osVersion TEXT,
-															 vendorVersion TEXT,
-															 description TEXT,
-															 aosVersion INTEGER)`); err != nil {
-		return aoserrors.Wrap(err)
+	if count, err := getColumnCount(db.sql, "services", "signatureStatus"); err != nil || count != 0 {
+		t.Errorf("signatureStatus column should not exist: %v", err)
 	}
 
-	return nil
+	db.Close()
 }
 
-func createDatabaseV6(name string, mergedMigrationPath string) (err error) {
-	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_sync=%s",
-		name, busyTimeout, journalMode, syncMode))
-	if err != nil {
-		return aoserrors.Wrap(err)
-	}
-	defer sqlite.Close()
+func TestMigrationToV9(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
 
-	if _, err = sqlite.Exec(
-		`CREATE TABLE config (
-			operationVersion INTEGER,
-			cursor TEXT,
-			envvars TEXT,
-			onlineTime TIMESTAMP)`); err != nil {
-		return aoserrors.Wrap(err)
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
 	}
 
-	if _, err = sqlite.Exec(
-		`INSERT INTO config (
-			operationVersion,
-			cursor,
-			envvars,
-			onlineTime) values(?, ?, ?, ?)`, launcher.OperationVersion, "", "", time.Now()); err != nil {
-		return aoserrors.Wrap(err)
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
 	}
 
-	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS services (id TEXT NOT NULL ,
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 9)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer9(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 8)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer8(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	if count, err := getColumnCount(db.sql, "services", "rejected"); err != nil || count != 0 {
+		t.Errorf("rejected column should not exist: %v", err)
+	}
+
+	db.Close()
+}
+
+func TestMigrationToV10(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
+
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
+	}
+
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 10)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer10(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 9)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer9(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+}
+
+func TestMigrationToV11(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
+
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
+	}
+
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 11)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer11(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 10)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer10(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	for _, table := range []string{"services", "layers"} {
+		if count, err := getColumnCount(db.sql, table, "quarantined"); err != nil || count != 0 {
+			t.Errorf("quarantined column should not exist in %s table: %v", table, err)
+		}
+	}
+
+	db.Close()
+}
+
+func TestMigrationToV12(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
+
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
+	}
+
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 12)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer12(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 11)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer11(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	if count, err := getColumnCount(db.sql, "services", "verityRootHash"); err != nil || count != 0 {
+		t.Errorf("verityRootHash column should not exist: %v", err)
+	}
+
+	db.Close()
+}
+
+func TestMigrationToV13(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
+
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
+	}
+
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 13)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer13(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 12)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer12(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+}
+
+func TestMigrationToV14(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
+
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
+	}
+
+	// Migration upward
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 14)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer14(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+
+	// Migration downward
+	db, err = newDatabase(migrationDB, "migration", mergedMigrationDir, 13)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer13(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+}
+
+func TestMigrationFromV6WithVLANIfName(t *testing.T) {
+	migrationDB := path.Join(tmpDir, "test_migration.db")
+	mergedMigrationDir := path.Join(tmpDir, "mergedMigration")
+
+	if err := os.MkdirAll(mergedMigrationDir, 0o755); err != nil {
+		t.Fatalf("Error creating merged migration dir: %v", err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(mergedMigrationDir); err != nil {
+			t.Fatalf("Error removing merged migration dir: %v", err)
+		}
+
+		if err := os.RemoveAll(migrationDB); err != nil {
+			t.Fatalf("Error removing migration db: %v", err)
+		}
+	}()
+
+	if err := createDatabaseV6(migrationDB, mergedMigrationDir); err != nil {
+		t.Fatalf("Can't create initial database %v", err)
+	}
+
+	// Database created from scratch with version 6 already contains vlanIfName column
+	sqlite, err := sql.Open("sqlite3", migrationDB)
+	if err != nil {
+		t.Fatalf("Can't open database: %v", err)
+	}
+
+	if _, err = sqlite.Exec("ALTER TABLE network ADD vlanIfName TEXT"); err != nil {
+		t.Fatalf("Can't add vlanIfName column: %v", err)
+	}
+
+	sqlite.Close()
+
+	db, err := newDatabase(migrationDB, "migration", mergedMigrationDir, 8)
+	if err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+
+	if err = isDatabaseVer8(db.sql); err != nil {
+		t.Fatalf("Error checking db version: %v", err)
+	}
+
+	db.Close()
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func createDatabaseV0(name string) (err error) {
+	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_sync=%s",
+		name, busyTimeout, journalMode, syncMode))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer sqlite.Close()
+
+	if _, err = sqlite.Exec(
+		`CREATE TABLE config (
+			operationVersion INTEGER,
+			cursor TEXT)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(
+		`INSERT INTO config (
+			operationVersion,
+			cursor) values(?, ?)`, launcher.OperationVersion, ""); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS services (id TEXT NOT NULL PRIMARY KEY,
+															   aosVersion INTEGER,
+															   serviceProvider TEXT,
+															   path TEXT,
+															   unit TEXT,
+															   uid INTEGER,
+															   gid INTEGER,
+															   hostName TEXT,
+															   permissions TEXT,
+															   state INTEGER,
+															   status INTEGER,
+															   startat TIMESTAMP,
+															   ttl INTEGER,
+															   alertRules TEXT,
+															   ulLimit INTEGER,
+															   dlLimit INTEGER,
+															   ulSpeed INTEGER,
+															   dlSpeed INTEGER,
+															   storageLimit INTEGER,
+															   stateLimit INTEGER,
+															   layerList TEXT,
+															   deviceResources TEXT,
+															   boardResources TEXT,
+															   vendorVersion TEXT,
+															   description TEXT)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS users (users TEXT NOT NULL,
+															serviceid TEXT NOT NULL,
+															storageFolder TEXT,
+															stateCheckSum BLOB,
+															PRIMARY KEY(users, serviceid))`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS trafficmonitor (chain TEXT NOT NULL PRIMARY KEY,
+																	 time TIMESTAMP,
+																	 value INTEGER)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS layers (digest TEXT NOT NULL PRIMARY KEY,
+															 layerId TEXT,
+															 path TEXT,
+															 osVersion TEXT,
+															 vendorVersion TEXT,
+															 description TEXT,
+															 aosVersion INTEGER)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func createDatabaseV6(name string, mergedMigrationPath string) (err error) {
+	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_sync=%s",
+		name, busyTimeout, journalMode, syncMode))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer sqlite.Close()
+
+	if _, err = sqlite.Exec(
+		`CREATE TABLE config (
+			operationVersion INTEGER,
+			cursor TEXT,
+			envvars TEXT,
+			onlineTime TIMESTAMP)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(
+		`INSERT INTO config (
+			operationVersion,
+			cursor,
+			envvars,
+			onlineTime) values(?, ?, ?, ?)`, launcher.OperationVersion, "", "", time.Now()); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = sqlite.Exec(`CREATE TABLE IF NOT EXISTS services (id TEXT NOT NULL ,
                                                                   aosVersion INTEGER,
                                                                   providerID TEXT,
                                                                   description TEXT,
@@ -1340,3 +1969,137 @@ func isDatabaseVer7(sqlite *sql.DB) (err error) {
 
 	return nil
 }
+
+func isDatabaseVer8(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer7(sqlite); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"services", "layers"} {
+		count, err := getColumnCount(sqlite, table, "signatureStatus")
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return aoserrors.Errorf("signatureStatus column should exist in %s table", table)
+		}
+	}
+
+	return nil
+}
+
+func isDatabaseVer9(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer8(sqlite); err != nil {
+		return err
+	}
+
+	count, err := getColumnCount(sqlite, "services", "rejected")
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return aoserrors.New("rejected column should exist in services table")
+	}
+
+	return nil
+}
+
+func isDatabaseVer10(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer9(sqlite); err != nil {
+		return err
+	}
+
+	count, err := getColumnCount(sqlite, "blobs", "digest")
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return aoserrors.New("blobs table should exist")
+	}
+
+	return nil
+}
+
+func isDatabaseVer11(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer10(sqlite); err != nil {
+		return err
+	}
+
+	for _, column := range []struct{ table, name string }{
+		{"services", "quarantined"}, {"layers", "contentDigest"}, {"layers", "quarantined"},
+	} {
+		count, err := getColumnCount(sqlite, column.table, column.name)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return aoserrors.Errorf("%s column should exist in %s table", column.name, column.table)
+		}
+	}
+
+	return nil
+}
+
+func isDatabaseVer12(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer11(sqlite); err != nil {
+		return err
+	}
+
+	count, err := getColumnCount(sqlite, "services", "verityRootHash")
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return aoserrors.New("verityRootHash column should exist in services table")
+	}
+
+	return nil
+}
+
+func isDatabaseVer13(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer12(sqlite); err != nil {
+		return err
+	}
+
+	count, err := getColumnCount(sqlite, "alerts", "payload")
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return aoserrors.New("alerts table should exist")
+	}
+
+	return nil
+}
+
+func isDatabaseVer14(sqlite *sql.DB) (err error) {
+	if err = isDatabaseVer13(sqlite); err != nil {
+		return err
+	}
+
+	count, err := getColumnCount(sqlite, "monitoring", "data")
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return aoserrors.New("monitoring table should exist")
+	}
+
+	return nil
+}
+
+func getColumnCount(sqlite *sql.DB, table, column string) (count int, err error) {
+	if err = sqlite.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count); err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	return count, nil
+}
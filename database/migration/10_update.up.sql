@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS blobs (
+    digest TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    PRIMARY KEY(digest, owner)
+);
@@ -0,0 +1,49 @@
+CREATE TABLE IF NOT EXISTS services_temp (
+    id TEXT NOT NULL,
+    aosVersion INTEGER,
+    providerID TEXT,
+    description TEXT,
+    imagePath TEXT,
+    manifestDigest BLOB,
+    cached INTEGER,
+    timestamp TIMESTAMP,
+    size INTEGER,
+    GID INTEGER,
+    signatureStatus TEXT,
+    rejected INTEGER,
+    PRIMARY KEY(id, aosVersion)
+);
+
+INSERT INTO services_temp (id, aosVersion, providerID, description, imagePath, manifestDigest, cached, timestamp,
+    size, GID, signatureStatus, rejected)
+SELECT id, aosVersion, providerID, description, imagePath, manifestDigest, cached, timestamp, size, GID,
+    signatureStatus, rejected
+FROM services;
+
+DROP TABLE services;
+
+ALTER TABLE services_temp RENAME TO services;
+
+CREATE TABLE IF NOT EXISTS layers_temp (
+    digest TEXT NOT NULL PRIMARY KEY,
+    layerId TEXT,
+    path TEXT,
+    osVersion TEXT,
+    vendorVersion TEXT,
+    description TEXT,
+    aosVersion INTEGER,
+    timestamp TIMESTAMP,
+    cached INTEGER,
+    size INTEGER,
+    signatureStatus TEXT
+);
+
+INSERT INTO layers_temp (digest, layerId, path, osVersion, vendorVersion, description, aosVersion, timestamp, cached,
+    size, signatureStatus)
+SELECT digest, layerId, path, osVersion, vendorVersion, description, aosVersion, timestamp, cached, size,
+    signatureStatus
+FROM layers;
+
+DROP TABLE layers;
+
+ALTER TABLE layers_temp RENAME TO layers;
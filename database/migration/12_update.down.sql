@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS services_temp (
+    id TEXT NOT NULL,
+    aosVersion INTEGER,
+    providerID TEXT,
+    description TEXT,
+    imagePath TEXT,
+    manifestDigest BLOB,
+    cached INTEGER,
+    timestamp TIMESTAMP,
+    size INTEGER,
+    GID INTEGER,
+    signatureStatus TEXT,
+    rejected INTEGER,
+    quarantined INTEGER,
+    PRIMARY KEY(id, aosVersion)
+);
+
+INSERT INTO services_temp (id, aosVersion, providerID, description, imagePath, manifestDigest, cached, timestamp,
+    size, GID, signatureStatus, rejected, quarantined)
+SELECT id, aosVersion, providerID, description, imagePath, manifestDigest, cached, timestamp, size, GID,
+    signatureStatus, rejected, quarantined
+FROM services;
+
+DROP TABLE services;
+
+ALTER TABLE services_temp RENAME TO services;
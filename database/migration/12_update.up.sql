@@ -0,0 +1,2 @@
+ALTER TABLE services ADD verityRootHash TEXT;
+UPDATE services SET verityRootHash = "";
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS alerts (
+    id INTEGER NOT NULL PRIMARY KEY,
+    timestamp TIMESTAMP,
+    tag TEXT,
+    payload BLOB
+);
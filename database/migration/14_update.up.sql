@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS monitoring (
+    id INTEGER NOT NULL PRIMARY KEY,
+    timestamp TIMESTAMP,
+    data BLOB
+);
@@ -0,0 +1,4 @@
+ALTER TABLE services ADD signatureStatus TEXT;
+UPDATE services SET signatureStatus = "";
+ALTER TABLE layers ADD signatureStatus TEXT;
+UPDATE layers SET signatureStatus = "";
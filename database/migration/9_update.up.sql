@@ -0,0 +1,2 @@
+ALTER TABLE services ADD rejected INTEGER;
+UPDATE services SET rejected = 0;
@@ -0,0 +1,451 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics provides local read-only SM diagnostics API
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/launcher"
+	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/networkmanager"
+	"github.com/aoscloud/aos_servicemanager/servicemanager"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	socketPermissions = 0o600
+	readHeaderTimeout = 5 * time.Second
+)
+
+const (
+	instancesPath  = "/instances"
+	servicesPath   = "/services"
+	layersPath     = "/layers"
+	networksPath   = "/networks"
+	devicesPath    = "/devices"
+	envVarsPath    = "/envvars"
+	unitConfigPath = "/unitconfig"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// InstanceProvider provides current instances info.
+type InstanceProvider interface {
+	GetInstancesRuntimeInfo() []launcher.InstanceRuntimeInfo
+	GetOverrideEnvVars() []cloudprotocol.EnvVarsInstanceInfo
+}
+
+// ServiceProvider provides installed services info.
+type ServiceProvider interface {
+	GetServicesInfo() ([]servicemanager.ServiceInfo, error)
+}
+
+// LayerProvider provides installed layers info.
+type LayerProvider interface {
+	GetLayersInfo() ([]layermanager.LayerInfo, error)
+}
+
+// NetworkProvider provides provider networks info.
+type NetworkProvider interface {
+	GetNetworksInfo() []networkmanager.NetworkInfo
+}
+
+// ResourceProvider provides allocated devices and unit config info.
+type ResourceProvider interface {
+	GetAllocatedDevices() map[string][]string
+	GetUnitConfigInfo() (version string)
+}
+
+// InstanceInfo instance diagnostics info.
+type InstanceInfo struct {
+	InstanceID  string `json:"instanceId"`
+	ServiceID   string `json:"serviceId"`
+	SubjectID   string `json:"subjectId"`
+	Instance    uint64 `json:"instance"`
+	AosVersion  uint64 `json:"aosVersion"`
+	UID         uint32 `json:"uid"`
+	Priority    uint64 `json:"priority"`
+	StoragePath string `json:"storagePath,omitempty"`
+	StatePath   string `json:"statePath,omitempty"`
+	RunState    string `json:"runState"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ServiceInfo service diagnostics info.
+type ServiceInfo struct {
+	ServiceID  string    `json:"serviceId"`
+	ProviderID string    `json:"providerId"`
+	AosVersion uint64    `json:"aosVersion"`
+	Size       uint64    `json:"size"`
+	Cached     bool      `json:"cached"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// LayerInfo layer diagnostics info.
+type LayerInfo struct {
+	LayerID    string    `json:"layerId"`
+	Digest     string    `json:"digest"`
+	AosVersion uint64    `json:"aosVersion"`
+	Size       uint64    `json:"size"`
+	Cached     bool      `json:"cached"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// NetworkInfo provider network diagnostics info.
+type NetworkInfo struct {
+	NetworkID string                `json:"networkId"`
+	Subnet    string                `json:"subnet"`
+	IP        string                `json:"ip"`
+	VlanID    uint64                `json:"vlanId"`
+	Instances []NetworkInstanceInfo `json:"instances"`
+}
+
+// NetworkInstanceInfo network instance diagnostics info.
+type NetworkInstanceInfo struct {
+	InstanceID string `json:"instanceId"`
+	IP         string `json:"ip"`
+}
+
+// DeviceInfo allocated device diagnostics info.
+type DeviceInfo struct {
+	Name      string   `json:"name"`
+	Instances []string `json:"instances"`
+}
+
+// UnitConfigInfo unit config diagnostics info.
+type UnitConfigInfo struct {
+	Version string `json:"version"`
+}
+
+// Server diagnostics server instance.
+type Server struct {
+	instanceProvider InstanceProvider
+	serviceProvider  ServiceProvider
+	layerProvider    LayerProvider
+	networkProvider  NetworkProvider
+	resourceProvider ResourceProvider
+
+	socketPath string
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+type rootOnlyListener struct {
+	net.Listener
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new diagnostics server.
+func New(
+	cfg *config.Config, instanceProvider InstanceProvider, serviceProvider ServiceProvider,
+	layerProvider LayerProvider, networkProvider NetworkProvider, resourceProvider ResourceProvider,
+) (server *Server, err error) {
+	log.WithField("socket", cfg.DiagnosticsSocket).Debug("Create diagnostics server")
+
+	server = &Server{
+		instanceProvider: instanceProvider,
+		serviceProvider:  serviceProvider,
+		layerProvider:    layerProvider,
+		networkProvider:  networkProvider,
+		resourceProvider: resourceProvider,
+		socketPath:       cfg.DiagnosticsSocket,
+	}
+
+	if err = os.MkdirAll(filepath.Dir(server.socketPath), 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = os.RemoveAll(server.socketPath); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	listener, err := net.Listen("unix", server.socketPath)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = os.Chmod(server.socketPath, socketPermissions); err != nil {
+		listener.Close()
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	server.listener = &rootOnlyListener{Listener: listener}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(instancesPath, server.handleInstances)
+	mux.HandleFunc(servicesPath, server.handleServices)
+	mux.HandleFunc(layersPath, server.handleLayers)
+	mux.HandleFunc(networksPath, server.handleNetworks)
+	mux.HandleFunc(devicesPath, server.handleDevices)
+	mux.HandleFunc(envVarsPath, server.handleEnvVars)
+	mux.HandleFunc(unitConfigPath, server.handleUnitConfig)
+
+	server.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() {
+		if err := server.httpServer.Serve(server.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Diagnostics server error: %v", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// Close closes diagnostics server.
+func (server *Server) Close() {
+	log.Debug("Close diagnostics server")
+
+	if err := server.httpServer.Close(); err != nil {
+		log.Errorf("Can't close diagnostics server: %v", err)
+	}
+
+	if err := os.RemoveAll(server.socketPath); err != nil {
+		log.Errorf("Can't remove diagnostics socket: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (listener *rootOnlyListener) Accept() (net.Conn, error) {
+	for {
+		// Raw error is returned as HTTP server checks it for net.ErrClosed and temporary errors
+		conn, err := listener.Listener.Accept()
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		if err = checkPeerIsRoot(conn); err != nil {
+			log.Warnf("Diagnostics connection rejected: %v", err)
+
+			conn.Close()
+
+			continue
+		}
+
+		return conn, nil
+	}
+}
+
+func checkPeerIsRoot(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return aoserrors.New("not unix connection")
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	var (
+		cred    *unix.Ucred
+		credErr error
+	)
+
+	if err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if credErr != nil {
+		return aoserrors.Wrap(credErr)
+	}
+
+	if cred.Uid != 0 {
+		return aoserrors.Errorf("peer uid %d is not root", cred.Uid)
+	}
+
+	return nil
+}
+
+func (server *Server) handleInstances(w http.ResponseWriter, r *http.Request) {
+	runtimeInstances := server.instanceProvider.GetInstancesRuntimeInfo()
+	instances := make([]InstanceInfo, 0, len(runtimeInstances))
+
+	for _, runtimeInstance := range runtimeInstances {
+		instance := InstanceInfo{
+			InstanceID:  runtimeInstance.InstanceID,
+			ServiceID:   runtimeInstance.ServiceID,
+			SubjectID:   runtimeInstance.SubjectID,
+			Instance:    runtimeInstance.Instance,
+			AosVersion:  runtimeInstance.AosVersion,
+			UID:         runtimeInstance.UID,
+			Priority:    runtimeInstance.Priority,
+			StoragePath: runtimeInstance.StoragePath,
+			StatePath:   runtimeInstance.StatePath,
+			RunState:    runtimeInstance.RunState,
+		}
+
+		if runtimeInstance.Err != nil {
+			instance.Error = runtimeInstance.Err.Error()
+		}
+
+		instances = append(instances, instance)
+	}
+
+	writeResponse(w, r, instances, nil)
+}
+
+func (server *Server) handleServices(w http.ResponseWriter, r *http.Request) {
+	installedServices, err := server.serviceProvider.GetServicesInfo()
+
+	services := make([]ServiceInfo, 0, len(installedServices))
+
+	for _, service := range installedServices {
+		services = append(services, ServiceInfo{
+			ServiceID:  service.ServiceID,
+			ProviderID: service.ServiceProvider,
+			AosVersion: service.AosVersion,
+			Size:       service.Size,
+			Cached:     service.Cached,
+			Timestamp:  service.Timestamp,
+		})
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].ServiceID == services[j].ServiceID {
+			return services[i].AosVersion < services[j].AosVersion
+		}
+
+		return services[i].ServiceID < services[j].ServiceID
+	})
+
+	writeResponse(w, r, services, err)
+}
+
+func (server *Server) handleLayers(w http.ResponseWriter, r *http.Request) {
+	installedLayers, err := server.layerProvider.GetLayersInfo()
+
+	layers := make([]LayerInfo, 0, len(installedLayers))
+
+	for _, layer := range installedLayers {
+		layers = append(layers, LayerInfo{
+			LayerID:    layer.LayerID,
+			Digest:     layer.Digest,
+			AosVersion: layer.AosVersion,
+			Size:       layer.Size,
+			Cached:     layer.Cached,
+			Timestamp:  layer.Timestamp,
+		})
+	}
+
+	sort.Slice(layers, func(i, j int) bool { return layers[i].Digest < layers[j].Digest })
+
+	writeResponse(w, r, layers, err)
+}
+
+func (server *Server) handleNetworks(w http.ResponseWriter, r *http.Request) {
+	providerNetworks := server.networkProvider.GetNetworksInfo()
+	networks := make([]NetworkInfo, 0, len(providerNetworks))
+
+	for _, providerNetwork := range providerNetworks {
+		network := NetworkInfo{
+			NetworkID: providerNetwork.NetworkID,
+			Subnet:    providerNetwork.Subnet,
+			IP:        providerNetwork.IP,
+			VlanID:    providerNetwork.VlanID,
+			Instances: make([]NetworkInstanceInfo, 0, len(providerNetwork.Instances)),
+		}
+
+		for _, instance := range providerNetwork.Instances {
+			network.Instances = append(network.Instances, NetworkInstanceInfo{
+				InstanceID: instance.InstanceID,
+				IP:         instance.IP,
+			})
+		}
+
+		networks = append(networks, network)
+	}
+
+	writeResponse(w, r, networks, nil)
+}
+
+func (server *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	allocatedDevices := server.resourceProvider.GetAllocatedDevices()
+	devices := make([]DeviceInfo, 0, len(allocatedDevices))
+
+	for name, instances := range allocatedDevices {
+		devices = append(devices, DeviceInfo{Name: name, Instances: instances})
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+
+	writeResponse(w, r, devices, nil)
+}
+
+func (server *Server) handleEnvVars(w http.ResponseWriter, r *http.Request) {
+	envVars := server.instanceProvider.GetOverrideEnvVars()
+	if envVars == nil {
+		envVars = make([]cloudprotocol.EnvVarsInstanceInfo, 0)
+	}
+
+	writeResponse(w, r, envVars, nil)
+}
+
+func (server *Server) handleUnitConfig(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, UnitConfigInfo{Version: server.resourceProvider.GetUnitConfigInfo()}, nil)
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, data interface{}, err error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if err != nil {
+		log.WithField("path", r.URL.Path).Errorf("Can't get diagnostics data: %v", err)
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.WithField("path", r.URL.Path).Errorf("Can't write diagnostics response: %v", err)
+	}
+}
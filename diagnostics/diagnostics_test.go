@@ -0,0 +1,380 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/diagnostics"
+	"github.com/aoscloud/aos_servicemanager/launcher"
+	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/networkmanager"
+	"github.com/aoscloud/aos_servicemanager/servicemanager"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testInstanceProvider struct {
+	instances []launcher.InstanceRuntimeInfo
+	envVars   []cloudprotocol.EnvVarsInstanceInfo
+}
+
+type testServiceProvider struct {
+	services []servicemanager.ServiceInfo
+	err      error
+}
+
+type testLayerProvider struct {
+	layers []layermanager.LayerInfo
+}
+
+type testNetworkProvider struct {
+	networks []networkmanager.NetworkInfo
+}
+
+type testResourceProvider struct {
+	devices map[string][]string
+	version string
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "sm_"); err != nil {
+		log.Fatalf("Error creating tmp dir: %v", err)
+	}
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Errorf("Error removing tmp dir: %v", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDiagnostics(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Diagnostics API is available for root only")
+	}
+
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	serviceProvider := &testServiceProvider{
+		services: []servicemanager.ServiceInfo{
+			{
+				VersionInfo: aostypes.VersionInfo{AosVersion: 2}, ServiceID: "service1", ServiceProvider: "provider0",
+				Size: 2048, Timestamp: timestamp,
+			},
+			{
+				VersionInfo: aostypes.VersionInfo{AosVersion: 1}, ServiceID: "service0", ServiceProvider: "provider0",
+				Size: 1024, Cached: true, Timestamp: timestamp,
+			},
+		},
+	}
+
+	server, err := diagnostics.New(&config.Config{DiagnosticsSocket: filepath.Join(tmpDir, "diagnostics.sock")},
+		&testInstanceProvider{
+			instances: []launcher.InstanceRuntimeInfo{
+				{
+					InstanceInfo: launcher.InstanceInfo{
+						InstanceInfo: aostypes.InstanceInfo{
+							InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0"},
+							UID:           5000, StoragePath: "storage0",
+						},
+						InstanceID: "instance0",
+					},
+					AosVersion: 1, RunState: cloudprotocol.InstanceStateActive,
+				},
+				{
+					InstanceInfo: launcher.InstanceInfo{
+						InstanceInfo: aostypes.InstanceInfo{
+							InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0"},
+							UID:           5001,
+						},
+						InstanceID: "instance1",
+					},
+					AosVersion: 2, RunState: cloudprotocol.InstanceStateFailed, Err: errors.New("start failed"),
+				},
+			},
+		},
+		serviceProvider,
+		&testLayerProvider{
+			layers: []layermanager.LayerInfo{
+				{Digest: "sha256:1", LayerID: "layer0", Size: 512, Timestamp: timestamp},
+			},
+		},
+		&testNetworkProvider{
+			networks: []networkmanager.NetworkInfo{
+				{
+					NetworkParameters: networkmanager.NetworkParameters{
+						NetworkID: "network0", Subnet: "172.17.0.0/16", IP: "172.17.0.1", VlanID: 1,
+					},
+					Instances: []networkmanager.NetworkInstanceInfo{{InstanceID: "instance0", IP: "172.17.0.2"}},
+				},
+			},
+		},
+		&testResourceProvider{
+			devices: map[string][]string{"random": {"instance0", "instance1"}, "camera": {"instance0"}},
+			version: "1.0.0",
+		})
+	if err != nil {
+		t.Fatalf("Can't create diagnostics server: %v", err)
+	}
+	defer server.Close()
+
+	info, err := os.Stat(filepath.Join(tmpDir, "diagnostics.sock"))
+	if err != nil {
+		t.Fatalf("Can't stat diagnostics socket: %v", err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Wrong socket permissions: %v", info.Mode().Perm())
+	}
+
+	client := newTestClient(filepath.Join(tmpDir, "diagnostics.sock"))
+
+	var instances []diagnostics.InstanceInfo
+
+	if err = getDiagnostics(client, "/instances", &instances); err != nil {
+		t.Fatalf("Can't get instances: %v", err)
+	}
+
+	if !reflect.DeepEqual(instances, []diagnostics.InstanceInfo{
+		{
+			InstanceID: "instance0", ServiceID: "service0", SubjectID: "subject0", AosVersion: 1, UID: 5000,
+			StoragePath: "storage0", RunState: cloudprotocol.InstanceStateActive,
+		},
+		{
+			InstanceID: "instance1", ServiceID: "service1", SubjectID: "subject0", AosVersion: 2, UID: 5001,
+			RunState: cloudprotocol.InstanceStateFailed, Error: "start failed",
+		},
+	}) {
+		t.Errorf("Wrong instances: %v", instances)
+	}
+
+	var services []diagnostics.ServiceInfo
+
+	if err = getDiagnostics(client, "/services", &services); err != nil {
+		t.Fatalf("Can't get services: %v", err)
+	}
+
+	if !reflect.DeepEqual(services, []diagnostics.ServiceInfo{
+		{ServiceID: "service0", ProviderID: "provider0", AosVersion: 1, Size: 1024, Cached: true, Timestamp: timestamp},
+		{ServiceID: "service1", ProviderID: "provider0", AosVersion: 2, Size: 2048, Timestamp: timestamp},
+	}) {
+		t.Errorf("Wrong services: %v", services)
+	}
+
+	var layers []diagnostics.LayerInfo
+
+	if err = getDiagnostics(client, "/layers", &layers); err != nil {
+		t.Fatalf("Can't get layers: %v", err)
+	}
+
+	if !reflect.DeepEqual(layers, []diagnostics.LayerInfo{
+		{LayerID: "layer0", Digest: "sha256:1", Size: 512, Timestamp: timestamp},
+	}) {
+		t.Errorf("Wrong layers: %v", layers)
+	}
+
+	var networks []diagnostics.NetworkInfo
+
+	if err = getDiagnostics(client, "/networks", &networks); err != nil {
+		t.Fatalf("Can't get networks: %v", err)
+	}
+
+	if !reflect.DeepEqual(networks, []diagnostics.NetworkInfo{
+		{
+			NetworkID: "network0", Subnet: "172.17.0.0/16", IP: "172.17.0.1", VlanID: 1,
+			Instances: []diagnostics.NetworkInstanceInfo{{InstanceID: "instance0", IP: "172.17.0.2"}},
+		},
+	}) {
+		t.Errorf("Wrong networks: %v", networks)
+	}
+
+	var devices []diagnostics.DeviceInfo
+
+	if err = getDiagnostics(client, "/devices", &devices); err != nil {
+		t.Fatalf("Can't get devices: %v", err)
+	}
+
+	if !reflect.DeepEqual(devices, []diagnostics.DeviceInfo{
+		{Name: "camera", Instances: []string{"instance0"}},
+		{Name: "random", Instances: []string{"instance0", "instance1"}},
+	}) {
+		t.Errorf("Wrong devices: %v", devices)
+	}
+
+	var envVars []cloudprotocol.EnvVarsInstanceInfo
+
+	if err = getDiagnostics(client, "/envvars", &envVars); err != nil {
+		t.Fatalf("Can't get env vars: %v", err)
+	}
+
+	if len(envVars) != 0 {
+		t.Errorf("Wrong env vars: %v", envVars)
+	}
+
+	var unitConfig diagnostics.UnitConfigInfo
+
+	if err = getDiagnostics(client, "/unitconfig", &unitConfig); err != nil {
+		t.Fatalf("Can't get unit config: %v", err)
+	}
+
+	if unitConfig.Version != "1.0.0" {
+		t.Errorf("Wrong unit config version: %s", unitConfig.Version)
+	}
+
+	// Check provider error
+
+	serviceProvider.err = aoserrors.New("db error")
+
+	if err = getDiagnostics(client, "/services", &services); err == nil {
+		t.Error("Error expected")
+	}
+
+	// Check only read requests are allowed
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://sm/instances", nil)
+	if err != nil {
+		t.Fatalf("Can't create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Can't send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Wrong status code: %d", resp.StatusCode)
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (provider *testInstanceProvider) GetInstancesRuntimeInfo() []launcher.InstanceRuntimeInfo {
+	return provider.instances
+}
+
+func (provider *testInstanceProvider) GetOverrideEnvVars() []cloudprotocol.EnvVarsInstanceInfo {
+	return provider.envVars
+}
+
+func (provider *testServiceProvider) GetServicesInfo() ([]servicemanager.ServiceInfo, error) {
+	return provider.services, provider.err
+}
+
+func (provider *testLayerProvider) GetLayersInfo() ([]layermanager.LayerInfo, error) {
+	return provider.layers, nil
+}
+
+func (provider *testNetworkProvider) GetNetworksInfo() []networkmanager.NetworkInfo {
+	return provider.networks
+}
+
+func (provider *testResourceProvider) GetAllocatedDevices() map[string][]string {
+	return provider.devices
+}
+
+func (provider *testResourceProvider) GetUnitConfigInfo() string {
+	return provider.version
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTestClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func getDiagnostics(client *http.Client, path string, data interface{}) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://sm"+path, nil)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return aoserrors.Errorf("wrong status code: %d", resp.StatusCode)
+	}
+
+	return aoserrors.Wrap(json.NewDecoder(resp.Body).Decode(data))
+}
@@ -0,0 +1,588 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package downloader provides resumable downloads of service and layer packages
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// PartialFileExt extension of partially downloaded file.
+const PartialFileExt = ".part"
+
+// Partial downloads which are not resumed during this period are removed.
+const partialFileTTL = 7 * 24 * time.Hour
+
+// Validator (ETag or Last-Modified) of partially downloaded content is kept next to partial file with this extension.
+// It is sent in If-Range header on resume to not append changed content to the partial file.
+const validatorFileExt = ".validator"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// AlertSender provides alert sender interface.
+type AlertSender interface {
+	SendAlert(alert cloudprotocol.AlertItem)
+}
+
+// SpaceAllocator download dir space allocator. Partial downloads are registered in it as outdated items to be
+// removed when space is required.
+type SpaceAllocator interface {
+	AddOutdatedItem(id string, size uint64, timestamp time.Time) error
+	RestoreOutdatedItem(id string)
+}
+
+// TargetInfo downloaded package information used in progress alerts.
+type TargetInfo struct {
+	Type          string
+	ID            string
+	AosVersion    uint64
+	VendorVersion string
+}
+
+// Downloader downloads packages with resume and retry.
+type Downloader struct {
+	downloadDir    string
+	retryDelay     time.Duration
+	maxRetryDelay  time.Duration
+	timeout        time.Duration
+	progressPeriod time.Duration
+	alertSender    AlertSender
+}
+
+type permanentError struct {
+	err error
+}
+
+type progressWriter struct {
+	downloader *Downloader
+	url        string
+	target     TargetInfo
+	downloaded int64
+	total      int64
+	reportTime time.Time
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// HTTPClient HTTP client used for downloading.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var HTTPClient = http.DefaultClient
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new downloader.
+func New(config *config.Config, alertSender AlertSender) (downloader *Downloader, err error) {
+	log.Debug("New downloader")
+
+	downloader = &Downloader{
+		downloadDir:    config.DownloadDir,
+		retryDelay:     config.Download.RetryDelay.Duration,
+		maxRetryDelay:  config.Download.MaxRetryDelay.Duration,
+		timeout:        config.Download.Timeout.Duration,
+		progressPeriod: config.Download.ProgressPeriod.Duration,
+		alertSender:    alertSender,
+	}
+
+	if downloader.maxRetryDelay < downloader.retryDelay {
+		downloader.maxRetryDelay = downloader.retryDelay
+	}
+
+	if err = os.MkdirAll(downloader.downloadDir, 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = removeStalePartialFiles(downloader.downloadDir); err != nil {
+		log.Errorf("Can't remove stale partial downloads: %v", err)
+	}
+
+	return downloader, nil
+}
+
+// Download downloads file by URL. Partially downloaded file is kept in download dir and download is resumed from it
+// on next call with the same URL.
+func (downloader *Downloader) Download(
+	ctx context.Context, url string, target TargetInfo,
+) (fileName string, err error) {
+	log.WithFields(log.Fields{"url": url, "id": target.ID}).Debug("Start downloading file")
+
+	if downloader.timeout > 0 {
+		var cancelFunc context.CancelFunc
+
+		ctx, cancelFunc = context.WithTimeout(ctx, downloader.timeout)
+		defer cancelFunc()
+	}
+
+	partialFile := downloader.getPartialFileName(url)
+	retryDelay := downloader.retryDelay
+
+	for attempt := 1; ; attempt++ {
+		if err = downloader.download(ctx, url, partialFile, target); err == nil {
+			break
+		}
+
+		var permErr *permanentError
+
+		if errors.As(err, &permErr) {
+			if removeErr := removePartialFile(partialFile); removeErr != nil {
+				log.Errorf("Can't remove partial file: %v", removeErr)
+			}
+
+			return "", permErr.err
+		}
+
+		log.WithFields(log.Fields{
+			"url": url, "attempt": attempt, "retryDelay": retryDelay,
+		}).Warnf("Download failed: %v", err)
+
+		select {
+		case <-ctx.Done():
+			// Partial file is kept to resume download next time
+			return "", aoserrors.Errorf("download failed: %v, last error: %v", ctx.Err(), err)
+
+		case <-time.After(retryDelay):
+		}
+
+		if retryDelay *= 2; retryDelay > downloader.maxRetryDelay {
+			retryDelay = downloader.maxRetryDelay
+		}
+	}
+
+	fileName = strings.TrimSuffix(partialFile, PartialFileExt)
+
+	if err = os.Rename(partialFile, fileName); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = os.RemoveAll(partialFile + validatorFileExt); err != nil {
+		log.Errorf("Can't remove partial file validator: %v", err)
+	}
+
+	log.WithFields(log.Fields{"url": url, "file": fileName}).Debug("Download complete")
+
+	return fileName, nil
+}
+
+// CleanDownloadDir removes download dir content except partial downloads.
+func CleanDownloadDir(downloadDir string) error {
+	entries, err := os.ReadDir(downloadDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), PartialFileExt) {
+			continue
+		}
+
+		// Keep validator only together with its partial file
+		if partialFile, ok := strings.CutSuffix(entry.Name(), validatorFileExt); ok && !entry.IsDir() &&
+			strings.HasSuffix(partialFile, PartialFileExt) {
+			if _, err = os.Stat(filepath.Join(downloadDir, partialFile)); err == nil {
+				continue
+			}
+		}
+
+		if err = os.RemoveAll(filepath.Join(downloadDir, entry.Name())); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// AddPartialFiles registers partial downloads of download dir as outdated items of the allocator. The allocator
+// should remove them with RemovePartialFile.
+func AddPartialFiles(downloadDir string, allocator SpaceAllocator) error {
+	entries, err := os.ReadDir(downloadDir)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), PartialFileExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if err = allocator.AddOutdatedItem(entry.Name(), uint64(info.Size()), info.ModTime()); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// RemovePartialFile removes partial download registered as outdated item.
+func RemovePartialFile(downloadDir, id string) error {
+	if filepath.Base(id) != id || !strings.HasSuffix(id, PartialFileExt) {
+		return aoserrors.Errorf("wrong partial file id: %s", id)
+	}
+
+	log.WithField("file", id).Debug("Remove outdated partial download")
+
+	return removePartialFile(filepath.Join(downloadDir, id))
+}
+
+// ReservePartialFiles excludes partial downloads of the URLs from allocator outdated items while they are resumed.
+// Returns size of reserved partial downloads which is already taken on the disk.
+func ReservePartialFiles(downloadDir string, allocator SpaceAllocator, urls ...string) (size uint64) {
+	for _, url := range urls {
+		partialFile := getPartialFileName(downloadDir, url)
+
+		allocator.RestoreOutdatedItem(filepath.Base(partialFile))
+
+		if info, err := os.Stat(partialFile); err == nil {
+			size += uint64(info.Size())
+		}
+	}
+
+	return size
+}
+
+// ReleasePartialFiles returns partial downloads of the URLs, kept after failed download, to allocator outdated items.
+func ReleasePartialFiles(downloadDir string, allocator SpaceAllocator, urls ...string) {
+	for _, url := range urls {
+		partialFile := getPartialFileName(downloadDir, url)
+
+		info, err := os.Stat(partialFile)
+		if err != nil {
+			continue
+		}
+
+		if err = allocator.AddOutdatedItem(
+			filepath.Base(partialFile), uint64(info.Size()), info.ModTime()); err != nil {
+			log.WithField("file", partialFile).Errorf("Can't add partial download to outdated items: %v", err)
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (downloader *Downloader) getPartialFileName(url string) string {
+	return getPartialFileName(downloader.downloadDir, url)
+}
+
+func (downloader *Downloader) download(ctx context.Context, url, partialFile string, target TargetInfo) error {
+	file, err := os.OpenFile(partialFile, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &permanentError{aoserrors.Wrap(err)}
+	}
+
+	if offset > 0 {
+		validator, err := os.ReadFile(partialFile + validatorFileExt)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return aoserrors.Wrap(err)
+		}
+
+		// Content may be changed since partial file was downloaded: without validator it can't be resumed
+		if len(validator) > 0 {
+			log.WithFields(log.Fields{"url": url, "offset": offset}).Debug("Resume download")
+
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", string(validator))
+		} else {
+			log.WithField("url", url).Warn("No partial content validator, download from the beginning")
+
+			if offset, err = truncateFile(file); err != nil {
+				return err
+			}
+		}
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	var total int64
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Appending range which doesn't start at the partial file end corrupts the file, download from the beginning
+		if start := getContentRangeStart(resp.Header.Get("Content-Range")); start != offset {
+			if _, err = truncateFile(file); err != nil {
+				return err
+			}
+
+			return aoserrors.Errorf("wrong content range start: %d, expected: %d", start, offset)
+		}
+
+		total = getContentRangeSize(resp.Header.Get("Content-Range"))
+
+	case resp.StatusCode == http.StatusOK:
+		// Server ignores range request or content is changed, download from the beginning
+		if offset > 0 {
+			if offset, err = truncateFile(file); err != nil {
+				return err
+			}
+		}
+
+		if err = saveValidator(partialFile, resp.Header); err != nil {
+			return err
+		}
+
+		total = resp.ContentLength
+
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Partial file may already contain whole content
+		if getContentRangeSize(resp.Header.Get("Content-Range")) == offset {
+			return nil
+		}
+
+		if _, err = truncateFile(file); err != nil {
+			return err
+		}
+
+		return aoserrors.New("requested range not satisfiable")
+
+	case resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return &permanentError{aoserrors.Errorf("download failed: %s", resp.Status)}
+
+	default:
+		return aoserrors.Errorf("download failed: %s", resp.Status)
+	}
+
+	progress := &progressWriter{
+		downloader: downloader, url: url, target: target, downloaded: offset, total: total, reportTime: time.Now(),
+	}
+
+	if _, err = io.Copy(file, io.TeeReader(resp.Body, progress)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	progress.sendProgress("Download completed")
+
+	return nil
+}
+
+func (err *permanentError) Error() string {
+	return err.err.Error()
+}
+
+func (writer *progressWriter) Write(data []byte) (int, error) {
+	writer.downloaded += int64(len(data))
+
+	if writer.downloader.progressPeriod > 0 && time.Since(writer.reportTime) >= writer.downloader.progressPeriod {
+		writer.reportTime = time.Now()
+
+		writer.sendProgress("Download progress")
+	}
+
+	return len(data), nil
+}
+
+func (writer *progressWriter) sendProgress(message string) {
+	log.WithFields(log.Fields{
+		"url": writer.url, "downloaded": writer.downloaded, "total": writer.total,
+	}).Debug(message)
+
+	if writer.downloader.alertSender == nil {
+		return
+	}
+
+	alert := cloudprotocol.DownloadAlert{
+		TargetType:          writer.target.Type,
+		TargetID:            writer.target.ID,
+		TargetAosVersion:    writer.target.AosVersion,
+		TargetVendorVersion: writer.target.VendorVersion,
+		Message:             message,
+		URL:                 writer.url,
+		DownloadedBytes:     strconv.FormatInt(writer.downloaded, 10),
+	}
+
+	if writer.total > 0 {
+		alert.TotalBytes = strconv.FormatInt(writer.total, 10)
+		alert.Progress = strconv.FormatInt(writer.downloaded*100/writer.total, 10) + "%" //nolint:gomnd
+	}
+
+	writer.downloader.alertSender.SendAlert(cloudprotocol.AlertItem{
+		Timestamp: time.Now(),
+		Tag:       cloudprotocol.AlertTagDownloadProgress,
+		Payload:   alert,
+	})
+}
+
+func getPartialFileName(downloadDir, url string) string {
+	hash := sha256.Sum256([]byte(url))
+
+	return filepath.Join(downloadDir, hex.EncodeToString(hash[:])+PartialFileExt)
+}
+
+func removePartialFile(partialFile string) error {
+	if err := os.RemoveAll(partialFile); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err := os.RemoveAll(partialFile + validatorFileExt); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// saveValidator stores validator of downloaded content: strong ETag or Last-Modified. Weak ETag can't be used in
+// If-Range header.
+func saveValidator(partialFile string, header http.Header) error {
+	validator := header.Get("ETag")
+
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+
+	if validator == "" {
+		if err := os.RemoveAll(partialFile + validatorFileExt); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		return nil
+	}
+
+	if err := os.WriteFile(partialFile+validatorFileExt, []byte(validator), 0o600); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func truncateFile(file *os.File) (offset int64, err error) {
+	if err = file.Truncate(0); err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	if offset, err = file.Seek(0, io.SeekStart); err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	return offset, nil
+}
+
+// getContentRangeStart returns first byte position from Content-Range header: "bytes 100-199/1000".
+func getContentRangeStart(contentRange string) int64 {
+	rangeStr, found := strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return -1
+	}
+
+	startStr, _, found := strings.Cut(rangeStr, "-")
+	if !found {
+		return -1
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return start
+}
+
+// getContentRangeSize returns complete length from Content-Range header: "bytes 100-199/1000" or "bytes */1000".
+func getContentRangeSize(contentRange string) int64 {
+	_, sizeStr, found := strings.Cut(contentRange, "/")
+	if !found {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return size
+}
+
+func removeStalePartialFiles(downloadDir string) error {
+	entries, err := os.ReadDir(downloadDir)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), PartialFileExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if time.Since(info.ModTime()) < partialFileTTL {
+			continue
+		}
+
+		log.WithField("file", entry.Name()).Debug("Remove stale partial download")
+
+		if err = removePartialFile(filepath.Join(downloadDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,563 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package downloader_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/downloader"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	testFileSize = 64 * 1024
+	testETag     = `"v1"`
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testAlertSender struct {
+	sync.Mutex
+	alerts []cloudprotocol.DownloadAlert
+}
+
+type testAllocator struct {
+	outdatedItems map[string]uint64
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestResumeDownload(t *testing.T) {
+	content := generateContent(t)
+
+	var (
+		requests      int
+		rangeHeader   string
+		ifRangeHeader string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.Header().Set("ETag", testETag)
+
+		if requests == 1 {
+			// Send half of content and break connection
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(content[:testFileSize/2])
+
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		}
+
+		rangeHeader = r.Header.Get("Range")
+		ifRangeHeader = r.Header.Get("If-Range")
+
+		http.ServeContent(w, r, "package", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	alertSender := &testAlertSender{}
+
+	testDownloader, err := downloader.New(newTestConfig(t, time.Minute), alertSender)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	fileName, err := testDownloader.Download(context.Background(), server.URL+"/package", downloader.TargetInfo{
+		Type: cloudprotocol.DownloadTargetService, ID: "service0", AosVersion: 1,
+	})
+	if err != nil {
+		t.Fatalf("Can't download file: %v", err)
+	}
+
+	checkFileContent(t, fileName, content)
+
+	if requests != 2 {
+		t.Errorf("Wrong requests count: %d", requests)
+	}
+
+	if rangeHeader != "bytes=32768-" {
+		t.Errorf("Wrong range header: %s", rangeHeader)
+	}
+
+	if ifRangeHeader != testETag {
+		t.Errorf("Wrong if-range header: %s", ifRangeHeader)
+	}
+
+	alert := alertSender.getLastAlert(t)
+
+	if alert.TargetID != "service0" || alert.DownloadedBytes != "65536" || alert.TotalBytes != "65536" ||
+		alert.Progress != "100%" {
+		t.Errorf("Wrong download alert: %v", alert)
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	content := generateContent(t)
+	failed := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", testETag)
+
+		if failed && r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if failed {
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(content[:testFileSize/4])
+
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "package", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(t, 100*time.Millisecond)
+
+	testDownloader, err := downloader.New(cfg, nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	if _, err = testDownloader.Download(
+		context.Background(), server.URL+"/package", downloader.TargetInfo{}); err == nil {
+		t.Fatal("Download should fail")
+	}
+
+	// Partial file should survive download dir cleanup
+
+	if err = downloader.CleanDownloadDir(cfg.DownloadDir); err != nil {
+		t.Fatalf("Can't clean download dir: %v", err)
+	}
+
+	if partialFiles, _ := filepath.Glob(filepath.Join(cfg.DownloadDir, "*"+downloader.PartialFileExt)); len(
+		partialFiles) != 1 {
+		t.Fatalf("Wrong partial files count: %d", len(partialFiles))
+	}
+
+	failed = false
+
+	if testDownloader, err = downloader.New(cfg, nil); err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	fileName, err := testDownloader.Download(context.Background(), server.URL+"/package", downloader.TargetInfo{})
+	if err != nil {
+		t.Fatalf("Can't download file: %v", err)
+	}
+
+	checkFileContent(t, fileName, content)
+}
+
+func TestResumeChangedContent(t *testing.T) {
+	oldContent := generateContent(t)
+	newContent := generateContent(t)
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if requests == 1 {
+			w.Header().Set("ETag", testETag)
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(oldContent[:testFileSize/2])
+
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		}
+
+		// Content is changed on the server: If-Range doesn't match and whole new content is sent
+
+		w.Header().Set("ETag", `"v2"`)
+
+		http.ServeContent(w, r, "package", time.Now(), bytes.NewReader(newContent))
+	}))
+	defer server.Close()
+
+	testDownloader, err := downloader.New(newTestConfig(t, time.Minute), nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	fileName, err := testDownloader.Download(context.Background(), server.URL+"/package", downloader.TargetInfo{})
+	if err != nil {
+		t.Fatalf("Can't download file: %v", err)
+	}
+
+	checkFileContent(t, fileName, newContent)
+}
+
+func TestWrongContentRangeStart(t *testing.T) {
+	content := generateContent(t)
+
+	var (
+		requests    int
+		rangeHeader string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.Header().Set("ETag", testETag)
+
+		switch requests {
+		case 1:
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(content[:testFileSize/2])
+
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+
+		case 2:
+			// Partial content doesn't start at requested offset
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", testFileSize/4, testFileSize-1, testFileSize))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(content[testFileSize/4:])
+
+		default:
+			rangeHeader = r.Header.Get("Range")
+
+			http.ServeContent(w, r, "package", time.Now(), bytes.NewReader(content))
+		}
+	}))
+	defer server.Close()
+
+	testDownloader, err := downloader.New(newTestConfig(t, time.Minute), nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	fileName, err := testDownloader.Download(context.Background(), server.URL+"/package", downloader.TargetInfo{})
+	if err != nil {
+		t.Fatalf("Can't download file: %v", err)
+	}
+
+	checkFileContent(t, fileName, content)
+
+	if requests != 3 {
+		t.Errorf("Wrong requests count: %d", requests)
+	}
+
+	if rangeHeader != "" {
+		t.Errorf("Unexpected range header: %s", rangeHeader)
+	}
+}
+
+func TestNoValidator(t *testing.T) {
+	content := generateContent(t)
+
+	var (
+		requests    int
+		rangeHeader string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if requests == 1 {
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(content[:testFileSize/2])
+
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		}
+
+		rangeHeader = r.Header.Get("Range")
+
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	testDownloader, err := downloader.New(newTestConfig(t, time.Minute), nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	fileName, err := testDownloader.Download(context.Background(), server.URL+"/package", downloader.TargetInfo{})
+	if err != nil {
+		t.Fatalf("Can't download file: %v", err)
+	}
+
+	checkFileContent(t, fileName, content)
+
+	// Partial content without validator should not be resumed
+	if rangeHeader != "" {
+		t.Errorf("Unexpected range header: %s", rangeHeader)
+	}
+}
+
+func TestPartialFilesAllocation(t *testing.T) {
+	content := generateContent(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", testETag)
+		w.Header().Set("Content-Length", "65536")
+		_, _ = w.Write(content[:testFileSize/4])
+
+		w.(http.Flusher).Flush()
+
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(t, 100*time.Millisecond)
+
+	testDownloader, err := downloader.New(cfg, nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	url := server.URL + "/package"
+
+	if _, err = testDownloader.Download(context.Background(), url, downloader.TargetInfo{}); err == nil {
+		t.Fatal("Download should fail")
+	}
+
+	allocator := &testAllocator{outdatedItems: make(map[string]uint64)}
+
+	if err = downloader.AddPartialFiles(cfg.DownloadDir, allocator); err != nil {
+		t.Fatalf("Can't add partial files: %v", err)
+	}
+
+	if len(allocator.outdatedItems) != 1 {
+		t.Fatalf("Wrong outdated items count: %d", len(allocator.outdatedItems))
+	}
+
+	// Resumed partial file should be excluded from outdated items
+
+	if size := downloader.ReservePartialFiles(cfg.DownloadDir, allocator, url); size != testFileSize/4 {
+		t.Errorf("Wrong reserved size: %d", size)
+	}
+
+	if len(allocator.outdatedItems) != 0 {
+		t.Errorf("Wrong outdated items count: %d", len(allocator.outdatedItems))
+	}
+
+	downloader.ReleasePartialFiles(cfg.DownloadDir, allocator, url)
+
+	if len(allocator.outdatedItems) != 1 {
+		t.Fatalf("Wrong outdated items count: %d", len(allocator.outdatedItems))
+	}
+
+	for id := range allocator.outdatedItems {
+		if err = downloader.RemovePartialFile(cfg.DownloadDir, id); err != nil {
+			t.Errorf("Can't remove partial file: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(cfg.DownloadDir)
+	if err != nil {
+		t.Fatalf("Can't read download dir: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("Download dir should be empty: %d", len(entries))
+	}
+}
+
+func TestRetryDownload(t *testing.T) {
+	content := generateContent(t)
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		http.ServeContent(w, r, "package", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	testDownloader, err := downloader.New(newTestConfig(t, time.Minute), nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	fileName, err := testDownloader.Download(context.Background(), server.URL+"/package", downloader.TargetInfo{})
+	if err != nil {
+		t.Fatalf("Can't download file: %v", err)
+	}
+
+	checkFileContent(t, fileName, content)
+
+	if requests != 3 {
+		t.Errorf("Wrong requests count: %d", requests)
+	}
+}
+
+func TestPermanentError(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	testDownloader, err := downloader.New(newTestConfig(t, time.Minute), nil)
+	if err != nil {
+		t.Fatalf("Can't create downloader: %v", err)
+	}
+
+	if _, err = testDownloader.Download(
+		context.Background(), server.URL+"/package", downloader.TargetInfo{}); err == nil {
+		t.Error("Download should fail")
+	}
+
+	if requests != 1 {
+		t.Errorf("Wrong requests count: %d", requests)
+	}
+}
+
+/***********************************************************************************************************************
+ * testAlertSender
+ **********************************************************************************************************************/
+
+func (sender *testAlertSender) SendAlert(alert cloudprotocol.AlertItem) {
+	sender.Lock()
+	defer sender.Unlock()
+
+	if downloadAlert, ok := alert.Payload.(cloudprotocol.DownloadAlert); ok {
+		sender.alerts = append(sender.alerts, downloadAlert)
+	}
+}
+
+func (sender *testAlertSender) getLastAlert(t *testing.T) cloudprotocol.DownloadAlert {
+	t.Helper()
+
+	sender.Lock()
+	defer sender.Unlock()
+
+	if len(sender.alerts) == 0 {
+		t.Fatal("No download alerts received")
+	}
+
+	return sender.alerts[len(sender.alerts)-1]
+}
+
+/***********************************************************************************************************************
+ * testAllocator
+ **********************************************************************************************************************/
+
+func (allocator *testAllocator) AddOutdatedItem(id string, size uint64, timestamp time.Time) error {
+	allocator.outdatedItems[id] = size
+
+	return nil
+}
+
+func (allocator *testAllocator) RestoreOutdatedItem(id string) {
+	delete(allocator.outdatedItems, id)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTestConfig(t *testing.T, timeout time.Duration) *config.Config {
+	t.Helper()
+
+	return &config.Config{
+		DownloadDir: filepath.Join(t.TempDir(), "download"),
+		Download: config.Download{
+			RetryDelay:     aostypes.Duration{Duration: 10 * time.Millisecond},
+			MaxRetryDelay:  aostypes.Duration{Duration: 50 * time.Millisecond},
+			Timeout:        aostypes.Duration{Duration: timeout},
+			ProgressPeriod: aostypes.Duration{Duration: time.Second},
+		},
+	}
+}
+
+func generateContent(t *testing.T) []byte {
+	t.Helper()
+
+	content := make([]byte, testFileSize)
+
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("Can't generate content: %v", err)
+	}
+
+	return content
+}
+
+func checkFileContent(t *testing.T, fileName string, content []byte) {
+	t.Helper()
+
+	if strings.HasSuffix(fileName, downloader.PartialFileExt) {
+		t.Errorf("Wrong downloaded file name: %s", fileName)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("Can't read downloaded file: %v", err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Error("Downloaded file content mismatch")
+	}
+}
@@ -0,0 +1,431 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck provides service instances health checks
+package healthcheck
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Probe types.
+const (
+	ProbeTypeExec = "exec"
+	ProbeTypeTCP  = "tcp"
+	ProbeTypeHTTP = "http"
+	ProbeTypeFile = "file"
+)
+
+const (
+	defaultPeriod           = 10 * time.Second
+	defaultTimeout          = 5 * time.Second
+	defaultFailureThreshold = 3
+	defaultExecRunner       = "runc"
+	startProbePeriod        = 1 * time.Second
+)
+
+const healthChannelSize = 10
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Config health check configuration declared in service config.
+type Config struct {
+	Type             string            `json:"type"`
+	Command          []string          `json:"command,omitempty"`
+	Port             uint16            `json:"port,omitempty"`
+	Path             string            `json:"path,omitempty"`
+	Period           aostypes.Duration `json:"period,omitempty"`
+	Timeout          aostypes.Duration `json:"timeout,omitempty"`
+	FailureThreshold uint              `json:"failureThreshold,omitempty"`
+}
+
+// InstanceParams instance health check parameters.
+type InstanceParams struct {
+	aostypes.InstanceIdent
+	IP       string
+	Runner   string
+	StateDir string
+	Config   Config
+}
+
+// InstanceHealth instance health information. Err is nil when instance passes start probe, otherwise instance failed
+// start probe within health check timeout or periodic probe failed failure threshold times.
+type InstanceHealth struct {
+	InstanceID string
+	Err        error
+}
+
+// HealthChecker health checker instance.
+type HealthChecker struct {
+	sync.Mutex
+
+	startTimeout  time.Duration
+	instances     map[string]context.CancelFunc
+	healthChannel chan InstanceHealth
+	closeChannel  chan struct{}
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ExecCommand creates command for exec probe.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var ExecCommand = exec.CommandContext
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new health checker.
+func New(config *config.Config) (checker *HealthChecker, err error) {
+	log.Debug("New health checker")
+
+	checker = &HealthChecker{
+		startTimeout:  config.ServiceHealthCheckTimeout.Duration,
+		instances:     make(map[string]context.CancelFunc),
+		healthChannel: make(chan InstanceHealth, healthChannelSize),
+		closeChannel:  make(chan struct{}),
+	}
+
+	return checker, nil
+}
+
+// Close closes health checker.
+func (checker *HealthChecker) Close() {
+	checker.Lock()
+	defer checker.Unlock()
+
+	log.Debug("Close health checker")
+
+	close(checker.closeChannel)
+
+	for instanceID, cancelFunc := range checker.instances {
+		cancelFunc()
+		delete(checker.instances, instanceID)
+	}
+}
+
+// StartInstanceHealthCheck starts instance health check. Instance is probed till probe passes or health check timeout
+// expires, then it is probed periodically. Result of start probe and periodic probe failure are reported by health
+// channel.
+func (checker *HealthChecker) StartInstanceHealthCheck(instanceID string, params InstanceParams) error {
+	checker.Lock()
+	defer checker.Unlock()
+
+	if err := params.Config.validate(); err != nil {
+		return err
+	}
+
+	if _, ok := checker.instances[instanceID]; ok {
+		return aoserrors.New("instance health check already started")
+	}
+
+	log.WithFields(instanceLogFields(instanceID, params)).Debug("Start instance health check")
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	checker.instances[instanceID] = cancelFunc
+
+	go checker.checkInstance(ctx, instanceID, params)
+
+	return nil
+}
+
+// StopInstanceHealthCheck stops instance health check.
+func (checker *HealthChecker) StopInstanceHealthCheck(instanceID string) error {
+	checker.Lock()
+	defer checker.Unlock()
+
+	cancelFunc, ok := checker.instances[instanceID]
+	if !ok {
+		return nil
+	}
+
+	log.WithField("instanceID", instanceID).Debug("Stop instance health check")
+
+	cancelFunc()
+	delete(checker.instances, instanceID)
+
+	return nil
+}
+
+// GetInstancesHealthChannel returns channel with instances health.
+func (checker *HealthChecker) GetInstancesHealthChannel() (channel <-chan InstanceHealth) {
+	return checker.healthChannel
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (checker *HealthChecker) checkInstance(ctx context.Context, instanceID string, params InstanceParams) {
+	if err := checker.waitInstanceHealthy(ctx, instanceID, params); err != nil {
+		if ctx.Err() == nil {
+			checker.notifyInstanceHealth(instanceID, err)
+		}
+
+		return
+	}
+
+	checker.notifyInstanceHealth(instanceID, nil)
+
+	period := params.Config.Period.Duration
+	if period == 0 {
+		period = defaultPeriod
+	}
+
+	failureThreshold := params.Config.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = defaultFailureThreshold
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	var failures uint
+
+	for {
+		select {
+		case <-ticker.C:
+			err := probeInstance(ctx, instanceID, params)
+			if err == nil {
+				failures = 0
+
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			failures++
+
+			log.WithFields(instanceLogFields(instanceID, params)).Warnf(
+				"Instance health check failed (%d of %d): %v", failures, failureThreshold, err)
+
+			if failures < failureThreshold {
+				continue
+			}
+
+			checker.notifyInstanceHealth(instanceID, aoserrors.Errorf("health check failed: %v", err))
+
+			return
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (checker *HealthChecker) waitInstanceHealthy(ctx context.Context, instanceID string, params InstanceParams) error {
+	log.WithFields(instanceLogFields(instanceID, params)).Debug("Wait instance healthy")
+
+	startCtx, cancelFunc := context.WithTimeout(ctx, checker.startTimeout)
+	defer cancelFunc()
+
+	for {
+		err := probeInstance(startCtx, instanceID, params)
+		if err == nil {
+			log.WithFields(instanceLogFields(instanceID, params)).Debug("Instance is healthy")
+
+			return nil
+		}
+
+		select {
+		case <-startCtx.Done():
+			return aoserrors.Errorf("health check timeout: %v", err)
+
+		case <-time.After(startProbePeriod):
+		}
+	}
+}
+
+func (checker *HealthChecker) notifyInstanceHealth(instanceID string, err error) {
+	checker.Lock()
+
+	_, ok := checker.instances[instanceID]
+
+	// Unhealthy instance is not checked anymore till it is restarted
+	if ok && err != nil {
+		delete(checker.instances, instanceID)
+	}
+
+	checker.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// Health notification should not be lost as instance state depends on it
+	select {
+	case checker.healthChannel <- InstanceHealth{InstanceID: instanceID, Err: err}:
+
+	case <-checker.closeChannel:
+	}
+}
+
+func (config Config) validate() error {
+	switch config.Type {
+	case ProbeTypeExec:
+		if len(config.Command) == 0 {
+			return aoserrors.New("health check command is not set")
+		}
+
+	case ProbeTypeTCP, ProbeTypeHTTP:
+		if config.Port == 0 {
+			return aoserrors.New("health check port is not set")
+		}
+
+	case ProbeTypeFile:
+		if !filepath.IsLocal(config.Path) {
+			return aoserrors.Errorf("invalid health check file path: %s", config.Path)
+		}
+
+	default:
+		return aoserrors.Errorf("unsupported health check type: %s", config.Type)
+	}
+
+	return nil
+}
+
+func probeInstance(ctx context.Context, instanceID string, params InstanceParams) error {
+	timeout := params.Config.Timeout.Duration
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	probeCtx, cancelFunc := context.WithTimeout(ctx, timeout)
+	defer cancelFunc()
+
+	switch params.Config.Type {
+	case ProbeTypeExec:
+		return probeExec(probeCtx, instanceID, params)
+
+	case ProbeTypeTCP:
+		return probeTCP(probeCtx, params)
+
+	case ProbeTypeHTTP:
+		return probeHTTP(probeCtx, params)
+
+	case ProbeTypeFile:
+		return probeFile(params)
+
+	default:
+		return aoserrors.Errorf("unsupported health check type: %s", params.Config.Type)
+	}
+}
+
+func probeExec(ctx context.Context, instanceID string, params InstanceParams) error {
+	runner := params.Runner
+	if runner == "" {
+		runner = defaultExecRunner
+	}
+
+	args := append([]string{"exec", instanceID}, params.Config.Command...)
+
+	if output, err := ExecCommand(ctx, runner, args...).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("command failed: %v, output: %s", err, string(output))
+	}
+
+	return nil
+}
+
+func probeTCP(ctx context.Context, params InstanceParams) error {
+	if params.IP == "" {
+		return aoserrors.New("instance IP is not set")
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(params.IP, strconv.Itoa(int(params.Config.Port))))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	conn.Close()
+
+	return nil
+}
+
+func probeHTTP(ctx context.Context, params InstanceParams) error {
+	if params.IP == "" {
+		return aoserrors.New("instance IP is not set")
+	}
+
+	url := "http://" + net.JoinHostPort(params.IP, strconv.Itoa(int(params.Config.Port))) + "/" + params.Config.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return aoserrors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func probeFile(params InstanceParams) error {
+	if params.StateDir == "" {
+		return aoserrors.New("instance state is not set")
+	}
+
+	if _, err := os.Stat(filepath.Join(params.StateDir, params.Config.Path)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func instanceLogFields(instanceID string, params InstanceParams) log.Fields {
+	return log.Fields{
+		"instanceID":    instanceID,
+		"serviceID":     params.ServiceID,
+		"subjectID":     params.SubjectID,
+		"instanceIndex": params.Instance,
+		"type":          params.Config.Type,
+	}
+}
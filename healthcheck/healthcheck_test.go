@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/healthcheck"
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestProbes(t *testing.T) {
+	checker := newTestChecker(t, 1*time.Second)
+
+	stateDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(stateDir, "ready"), nil, 0o600); err != nil {
+		t.Fatalf("Can't create readiness file: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't create listener: %v", err)
+	}
+	defer listener.Close()
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer httpServer.Close()
+
+	var execArgs []string
+
+	healthcheck.ExecCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		execArgs = append([]string{name}, args...)
+
+		if args[len(args)-1] == "fail" {
+			return exec.CommandContext(ctx, "false")
+		}
+
+		return exec.CommandContext(ctx, "true")
+	}
+
+	testData := []struct {
+		config      healthcheck.Config
+		shouldFail  bool
+		expectedCmd []string
+	}{
+		{
+			config:      healthcheck.Config{Type: healthcheck.ProbeTypeExec, Command: []string{"check"}},
+			expectedCmd: []string{"runc", "exec", "instance0", "check"},
+		},
+		{
+			config:     healthcheck.Config{Type: healthcheck.ProbeTypeExec, Command: []string{"fail"}},
+			shouldFail: true,
+		},
+		{
+			config: healthcheck.Config{Type: healthcheck.ProbeTypeTCP, Port: getPort(t, listener.Addr().String())},
+		},
+		{
+			config:     healthcheck.Config{Type: healthcheck.ProbeTypeTCP, Port: getFreePort(t)},
+			shouldFail: true,
+		},
+		{
+			config: healthcheck.Config{
+				Type: healthcheck.ProbeTypeHTTP, Port: getPort(t, httpServer.Listener.Addr().String()), Path: "healthz",
+			},
+		},
+		{
+			config: healthcheck.Config{
+				Type: healthcheck.ProbeTypeHTTP, Port: getPort(t, httpServer.Listener.Addr().String()), Path: "unknown",
+			},
+			shouldFail: true,
+		},
+		{
+			config: healthcheck.Config{Type: healthcheck.ProbeTypeFile, Path: "ready"},
+		},
+		{
+			config:     healthcheck.Config{Type: healthcheck.ProbeTypeFile, Path: "notready"},
+			shouldFail: true,
+		},
+		{
+			config:     healthcheck.Config{Type: healthcheck.ProbeTypeFile, Path: "../ready"},
+			shouldFail: true,
+		},
+		{
+			config:     healthcheck.Config{Type: "unknown"},
+			shouldFail: true,
+		},
+	}
+
+	for i, item := range testData {
+		execArgs = nil
+
+		err := waitInstanceHealth(checker, "instance0", healthcheck.InstanceParams{
+			InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0"},
+			IP:            "127.0.0.1",
+			StateDir:      stateDir,
+			Config:        item.config,
+		})
+
+		if item.shouldFail && err == nil {
+			t.Errorf("Probe %d: error expected", i)
+		}
+
+		if !item.shouldFail && err != nil {
+			t.Errorf("Probe %d: can't wait instance healthy: %v", i, err)
+		}
+
+		if item.expectedCmd != nil && !reflect.DeepEqual(execArgs, item.expectedCmd) {
+			t.Errorf("Probe %d: wrong exec command: %v", i, execArgs)
+		}
+	}
+}
+
+func TestUnhealthyInstance(t *testing.T) {
+	checker := newTestChecker(t, 3*time.Second)
+
+	stateDir := t.TempDir()
+	readyFile := filepath.Join(stateDir, "ready")
+
+	if err := os.WriteFile(readyFile, nil, 0o600); err != nil {
+		t.Fatalf("Can't create readiness file: %v", err)
+	}
+
+	if err := checker.StartInstanceHealthCheck("instance0", healthcheck.InstanceParams{
+		StateDir: stateDir,
+		Config: healthcheck.Config{
+			Type: healthcheck.ProbeTypeFile, Path: "ready",
+			Period: aostypes.Duration{Duration: 100 * time.Millisecond}, FailureThreshold: 3,
+		},
+	}); err != nil {
+		t.Fatalf("Can't start health check: %v", err)
+	}
+
+	select {
+	case health := <-checker.GetInstancesHealthChannel():
+		if health.InstanceID != "instance0" || health.Err != nil {
+			t.Errorf("Wrong instance health: %v", health)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait instance healthy timeout")
+	}
+
+	select {
+	case unhealthy := <-checker.GetInstancesHealthChannel():
+		t.Errorf("Unexpected unhealthy instance: %v", unhealthy)
+
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if err := os.Remove(readyFile); err != nil {
+		t.Fatalf("Can't remove readiness file: %v", err)
+	}
+
+	select {
+	case unhealthy := <-checker.GetInstancesHealthChannel():
+		if unhealthy.InstanceID != "instance0" || unhealthy.Err == nil {
+			t.Errorf("Wrong unhealthy instance: %v", unhealthy)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait unhealthy instance timeout")
+	}
+
+	if err := checker.StopInstanceHealthCheck("instance0"); err != nil {
+		t.Errorf("Can't stop health check: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTestChecker(t *testing.T, timeout time.Duration) *healthcheck.HealthChecker {
+	t.Helper()
+
+	checker, err := healthcheck.New(&config.Config{ServiceHealthCheckTimeout: aostypes.Duration{Duration: timeout}})
+	if err != nil {
+		t.Fatalf("Can't create health checker: %v", err)
+	}
+
+	t.Cleanup(checker.Close)
+
+	return checker
+}
+
+func waitInstanceHealth(
+	checker *healthcheck.HealthChecker, instanceID string, params healthcheck.InstanceParams,
+) error {
+	if err := checker.StartInstanceHealthCheck(instanceID, params); err != nil {
+		return err
+	}
+
+	defer checker.StopInstanceHealthCheck(instanceID) //nolint:errcheck
+
+	select {
+	case health := <-checker.GetInstancesHealthChannel():
+		return health.Err
+
+	case <-time.After(5 * time.Second):
+		return aoserrors.New("wait instance health timeout")
+	}
+}
+
+func getPort(t *testing.T, address string) uint16 {
+	t.Helper()
+
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatalf("Can't parse address: %v", err)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		t.Fatalf("Can't parse port: %v", err)
+	}
+
+	return uint16(port)
+}
+
+func getFreePort(t *testing.T) uint16 {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't create listener: %v", err)
+	}
+	defer listener.Close()
+
+	return getPort(t, listener.Addr().String())
+}
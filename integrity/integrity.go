@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package integrity periodically verifies content of installed services and layers
+package integrity
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	"github.com/aoscloud/aos_servicemanager/alerts"
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ServiceProvider provides installed services verification.
+type ServiceProvider interface {
+	GetServicesInfo() ([]servicemanager.ServiceInfo, error)
+	VerifyService(service servicemanager.ServiceInfo, limiter *ratelimit.Limiter) error
+	QuarantineService(service servicemanager.ServiceInfo) error
+}
+
+// LayerProvider provides installed layers verification.
+type LayerProvider interface {
+	GetLayersInfo() ([]layermanager.LayerInfo, error)
+	VerifyLayer(layer layermanager.LayerInfo, limiter *ratelimit.Limiter) error
+	QuarantineLayer(layer layermanager.LayerInfo) error
+}
+
+// InstanceStopper stops instances of quarantined services and layers.
+type InstanceStopper interface {
+	StopServiceInstances(serviceID string, aosVersion uint64, reason error)
+	StopLayerInstances(digest string, reason error)
+}
+
+// AlertSender sends integrity alerts.
+type AlertSender interface {
+	SendAlert(alert cloudprotocol.AlertItem)
+}
+
+// Verifier integrity verifier instance.
+type Verifier struct {
+	serviceProvider ServiceProvider
+	layerProvider   LayerProvider
+	instanceStopper InstanceStopper
+	alertSender     AlertSender
+	checkPeriod     time.Duration
+	limiter         *ratelimit.Limiter
+	stopChannel     chan struct{}
+	wg              sync.WaitGroup
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new integrity verifier. Verification starts after first check period to not load I/O on start.
+func New(
+	config *config.Config, serviceProvider ServiceProvider, layerProvider LayerProvider,
+	instanceStopper InstanceStopper, alertSender AlertSender,
+) (verifier *Verifier, err error) {
+	log.WithFields(log.Fields{
+		"checkPeriod": config.Integrity.CheckPeriod, "rateLimit": config.Integrity.RateLimit,
+	}).Debug("New integrity verifier")
+
+	if config.Integrity.CheckPeriod.Duration <= 0 {
+		return nil, aoserrors.New("integrity check period should be positive")
+	}
+
+	verifier = &Verifier{
+		serviceProvider: serviceProvider,
+		layerProvider:   layerProvider,
+		instanceStopper: instanceStopper,
+		alertSender:     alertSender,
+		checkPeriod:     config.Integrity.CheckPeriod.Duration,
+		limiter:         ratelimit.New(config.Integrity.RateLimit),
+		stopChannel:     make(chan struct{}),
+	}
+
+	verifier.wg.Add(1)
+
+	go verifier.run()
+
+	return verifier, nil
+}
+
+// Close closes integrity verifier. Verification in progress is aborted.
+func (verifier *Verifier) Close() {
+	log.Debug("Close integrity verifier")
+
+	verifier.limiter.Close()
+	close(verifier.stopChannel)
+
+	verifier.wg.Wait()
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (verifier *Verifier) run() {
+	defer verifier.wg.Done()
+
+	checkTicker := time.NewTicker(verifier.checkPeriod)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-checkTicker.C:
+			verifier.verifyServices()
+			verifier.verifyLayers()
+
+		case <-verifier.stopChannel:
+			return
+		}
+	}
+}
+
+func (verifier *Verifier) verifyServices() {
+	if verifier.serviceProvider == nil {
+		return
+	}
+
+	log.Debug("Verify services integrity")
+
+	services, err := verifier.serviceProvider.GetServicesInfo()
+	if err != nil {
+		log.Errorf("Can't get services: %v", err)
+
+		return
+	}
+
+	for _, service := range services {
+		if service.Quarantined {
+			continue
+		}
+
+		verifyErr := verifier.serviceProvider.VerifyService(service, verifier.limiter)
+		if verifyErr == nil {
+			continue
+		}
+
+		if errors.Is(verifyErr, ratelimit.ErrClosed) {
+			return
+		}
+
+		// Service may be removed during verification
+		if !verifier.isServiceInstalled(service) {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"id": service.ServiceID, "aosVersion": service.AosVersion,
+		}).Errorf("Service integrity check failed: %v", verifyErr)
+
+		if err := verifier.serviceProvider.QuarantineService(service); err != nil {
+			log.WithFields(log.Fields{
+				"id": service.ServiceID, "aosVersion": service.AosVersion,
+			}).Errorf("Can't quarantine service: %v", err)
+		}
+
+		// Quarantined service is removed on next desired services processing: stop its instances to not run them
+		// from removed and corrupted content.
+		if verifier.instanceStopper != nil {
+			verifier.instanceStopper.StopServiceInstances(service.ServiceID, service.AosVersion,
+				aoserrors.Errorf("%w: %v", servicemanager.ErrServiceQuarantined, verifyErr))
+		}
+
+		verifier.sendAlert(cloudprotocol.AlertItem{
+			Timestamp: time.Now(),
+			Tag:       cloudprotocol.AlertTagServiceInstance,
+			Payload: cloudprotocol.ServiceInstanceAlert{
+				InstanceIdent: aostypes.InstanceIdent{ServiceID: service.ServiceID},
+				AosVersion:    service.AosVersion,
+				Message:       fmt.Sprintf("Service integrity check failed, service quarantined: %v", verifyErr),
+			},
+		})
+	}
+}
+
+func (verifier *Verifier) verifyLayers() {
+	if verifier.layerProvider == nil {
+		return
+	}
+
+	log.Debug("Verify layers integrity")
+
+	layers, err := verifier.layerProvider.GetLayersInfo()
+	if err != nil {
+		log.Errorf("Can't get layers: %v", err)
+
+		return
+	}
+
+	for _, layer := range layers {
+		if layer.Quarantined {
+			continue
+		}
+
+		verifyErr := verifier.layerProvider.VerifyLayer(layer, verifier.limiter)
+		if verifyErr == nil {
+			continue
+		}
+
+		if errors.Is(verifyErr, ratelimit.ErrClosed) {
+			return
+		}
+
+		// Layer may be removed during verification
+		if !verifier.isLayerInstalled(layer) {
+			continue
+		}
+
+		log.WithField("digest", layer.Digest).Errorf("Layer integrity check failed: %v", verifyErr)
+
+		if err := verifier.layerProvider.QuarantineLayer(layer); err != nil {
+			log.WithField("digest", layer.Digest).Errorf("Can't quarantine layer: %v", err)
+		}
+
+		// Quarantined layer is removed on next desired layers processing: stop instances which use it
+		if verifier.instanceStopper != nil {
+			verifier.instanceStopper.StopLayerInstances(layer.Digest,
+				aoserrors.Errorf("%w: %v", layermanager.ErrLayerQuarantined, verifyErr))
+		}
+
+		verifier.sendAlert(cloudprotocol.AlertItem{
+			Timestamp: time.Now(),
+			Tag:       cloudprotocol.AlertTagAosCore,
+			Payload: cloudprotocol.CoreAlert{
+				CoreComponent: alerts.CoreComponentSM,
+				Message: fmt.Sprintf("Layer %s integrity check failed, layer quarantined: %v",
+					layer.Digest, verifyErr),
+			},
+		})
+	}
+}
+
+func (verifier *Verifier) isServiceInstalled(service servicemanager.ServiceInfo) bool {
+	services, err := verifier.serviceProvider.GetServicesInfo()
+	if err != nil {
+		log.Errorf("Can't get services: %v", err)
+
+		return false
+	}
+
+	return slices.ContainsFunc(services, func(installedService servicemanager.ServiceInfo) bool {
+		return installedService.ServiceID == service.ServiceID &&
+			installedService.AosVersion == service.AosVersion && installedService.ImagePath == service.ImagePath
+	})
+}
+
+func (verifier *Verifier) isLayerInstalled(layer layermanager.LayerInfo) bool {
+	layers, err := verifier.layerProvider.GetLayersInfo()
+	if err != nil {
+		log.Errorf("Can't get layers: %v", err)
+
+		return false
+	}
+
+	return slices.ContainsFunc(layers, func(installedLayer layermanager.LayerInfo) bool {
+		return installedLayer.Digest == layer.Digest && installedLayer.Path == layer.Path
+	})
+}
+
+func (verifier *Verifier) sendAlert(alert cloudprotocol.AlertItem) {
+	if verifier.alertSender == nil {
+		return
+	}
+
+	verifier.alertSender.SendAlert(alert)
+}
@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integrity_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/integrity"
+	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	checkPeriod  = 100 * time.Millisecond
+	alertTimeout = 5 * time.Second
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testServiceProvider struct {
+	sync.Mutex
+	services       []servicemanager.ServiceInfo
+	corrupted      map[string]bool
+	removeOnVerify bool
+}
+
+type testLayerProvider struct {
+	sync.Mutex
+	layers    []layermanager.LayerInfo
+	corrupted map[string]bool
+}
+
+type testInstanceStopper struct {
+	sync.Mutex
+	stoppedServices []string
+	stoppedLayers   []string
+}
+
+type testAlertSender struct {
+	alertChannel chan cloudprotocol.AlertItem
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestQuarantineCorruptedItems(t *testing.T) {
+	serviceProvider := &testServiceProvider{
+		services: []servicemanager.ServiceInfo{
+			{ServiceID: "service1", VersionInfo: aostypes.VersionInfo{AosVersion: 1}, ImagePath: "service1"},
+			{ServiceID: "service2", VersionInfo: aostypes.VersionInfo{AosVersion: 2}, ImagePath: "service2"},
+		},
+		corrupted: map[string]bool{"service2": true},
+	}
+
+	layerProvider := &testLayerProvider{
+		layers: []layermanager.LayerInfo{
+			{Digest: "sha256:1", Path: "layer1"},
+			{Digest: "sha256:2", Path: "layer2"},
+		},
+		corrupted: map[string]bool{"sha256:1": true},
+	}
+
+	instanceStopper := &testInstanceStopper{}
+	alertSender := &testAlertSender{alertChannel: make(chan cloudprotocol.AlertItem, 10)}
+
+	verifier, err := integrity.New(&config.Config{Integrity: config.Integrity{
+		Enabled: true, CheckPeriod: aostypes.Duration{Duration: checkPeriod},
+	}}, serviceProvider, layerProvider, instanceStopper, alertSender)
+	if err != nil {
+		t.Fatalf("Can't create integrity verifier: %v", err)
+	}
+	defer verifier.Close()
+
+	serviceAlert := alertSender.waitAlert(t)
+
+	if serviceAlert.Tag != cloudprotocol.AlertTagServiceInstance {
+		t.Errorf("Wrong alert tag: %s", serviceAlert.Tag)
+	}
+
+	if payload, ok := serviceAlert.Payload.(cloudprotocol.ServiceInstanceAlert); !ok ||
+		payload.ServiceID != "service2" || payload.AosVersion != 2 {
+		t.Errorf("Wrong alert payload: %v", serviceAlert.Payload)
+	}
+
+	layerAlert := alertSender.waitAlert(t)
+
+	if layerAlert.Tag != cloudprotocol.AlertTagAosCore {
+		t.Errorf("Wrong alert tag: %s", layerAlert.Tag)
+	}
+
+	if _, ok := layerAlert.Payload.(cloudprotocol.CoreAlert); !ok {
+		t.Errorf("Wrong alert payload: %v", layerAlert.Payload)
+	}
+
+	serviceProvider.checkQuarantined(t, map[string]bool{"service1": false, "service2": true})
+	layerProvider.checkQuarantined(t, map[string]bool{"sha256:1": true, "sha256:2": false})
+	instanceStopper.checkStopped(t, []string{"service2"}, []string{"sha256:1"})
+
+	// Quarantined items should not be reported again
+
+	select {
+	case alert := <-alertSender.alertChannel:
+		t.Errorf("Unexpected alert: %v", alert)
+
+	case <-time.After(3 * checkPeriod):
+	}
+}
+
+func TestSkipRemovedItems(t *testing.T) {
+	// Service is removed during verification
+	serviceProvider := &testServiceProvider{
+		services: []servicemanager.ServiceInfo{
+			{ServiceID: "service1", VersionInfo: aostypes.VersionInfo{AosVersion: 1}, ImagePath: "service1"},
+		},
+		corrupted:      map[string]bool{"service1": true},
+		removeOnVerify: true,
+	}
+
+	alertSender := &testAlertSender{alertChannel: make(chan cloudprotocol.AlertItem, 10)}
+
+	verifier, err := integrity.New(&config.Config{Integrity: config.Integrity{
+		Enabled: true, CheckPeriod: aostypes.Duration{Duration: checkPeriod},
+	}}, serviceProvider, nil, nil, alertSender)
+	if err != nil {
+		t.Fatalf("Can't create integrity verifier: %v", err)
+	}
+	defer verifier.Close()
+
+	select {
+	case alert := <-alertSender.alertChannel:
+		t.Errorf("Unexpected alert: %v", alert)
+
+	case <-time.After(3 * checkPeriod):
+	}
+}
+
+func TestWrongCheckPeriod(t *testing.T) {
+	if _, err := integrity.New(&config.Config{Integrity: config.Integrity{Enabled: true}}, nil, nil, nil, nil); err == nil {
+		t.Error("Error expected")
+	}
+}
+
+/***********************************************************************************************************************
+ * testServiceProvider
+ **********************************************************************************************************************/
+
+func (provider *testServiceProvider) GetServicesInfo() ([]servicemanager.ServiceInfo, error) {
+	provider.Lock()
+	defer provider.Unlock()
+
+	return append([]servicemanager.ServiceInfo(nil), provider.services...), nil
+}
+
+func (provider *testServiceProvider) VerifyService(
+	service servicemanager.ServiceInfo, limiter *ratelimit.Limiter,
+) error {
+	provider.Lock()
+	defer provider.Unlock()
+
+	if !provider.corrupted[service.ServiceID] {
+		return nil
+	}
+
+	if provider.removeOnVerify {
+		provider.services = nil
+	}
+
+	return aoserrors.New("service corrupted")
+}
+
+func (provider *testServiceProvider) QuarantineService(service servicemanager.ServiceInfo) error {
+	provider.Lock()
+	defer provider.Unlock()
+
+	for i := range provider.services {
+		if provider.services[i].ServiceID == service.ServiceID &&
+			provider.services[i].AosVersion == service.AosVersion {
+			provider.services[i].Quarantined = true
+
+			return nil
+		}
+	}
+
+	return servicemanager.ErrNotExist
+}
+
+func (provider *testServiceProvider) checkQuarantined(t *testing.T, expected map[string]bool) {
+	t.Helper()
+
+	services, _ := provider.GetServicesInfo()
+
+	for _, service := range services {
+		if service.Quarantined != expected[service.ServiceID] {
+			t.Errorf("Wrong service %s quarantined state: %v", service.ServiceID, service.Quarantined)
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * testLayerProvider
+ **********************************************************************************************************************/
+
+func (provider *testLayerProvider) GetLayersInfo() ([]layermanager.LayerInfo, error) {
+	provider.Lock()
+	defer provider.Unlock()
+
+	return append([]layermanager.LayerInfo(nil), provider.layers...), nil
+}
+
+func (provider *testLayerProvider) VerifyLayer(layer layermanager.LayerInfo, limiter *ratelimit.Limiter) error {
+	provider.Lock()
+	defer provider.Unlock()
+
+	if provider.corrupted[layer.Digest] {
+		return aoserrors.New("layer corrupted")
+	}
+
+	return nil
+}
+
+func (provider *testLayerProvider) QuarantineLayer(layer layermanager.LayerInfo) error {
+	provider.Lock()
+	defer provider.Unlock()
+
+	for i := range provider.layers {
+		if provider.layers[i].Digest == layer.Digest {
+			provider.layers[i].Quarantined = true
+
+			return nil
+		}
+	}
+
+	return layermanager.ErrNotExist
+}
+
+func (provider *testLayerProvider) checkQuarantined(t *testing.T, expected map[string]bool) {
+	t.Helper()
+
+	layers, _ := provider.GetLayersInfo()
+
+	for _, layer := range layers {
+		if layer.Quarantined != expected[layer.Digest] {
+			t.Errorf("Wrong layer %s quarantined state: %v", layer.Digest, layer.Quarantined)
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * testInstanceStopper
+ **********************************************************************************************************************/
+
+func (stopper *testInstanceStopper) StopServiceInstances(serviceID string, aosVersion uint64, reason error) {
+	stopper.Lock()
+	defer stopper.Unlock()
+
+	if !errors.Is(reason, servicemanager.ErrServiceQuarantined) {
+		return
+	}
+
+	stopper.stoppedServices = append(stopper.stoppedServices, serviceID)
+}
+
+func (stopper *testInstanceStopper) StopLayerInstances(digest string, reason error) {
+	stopper.Lock()
+	defer stopper.Unlock()
+
+	if !errors.Is(reason, layermanager.ErrLayerQuarantined) {
+		return
+	}
+
+	stopper.stoppedLayers = append(stopper.stoppedLayers, digest)
+}
+
+func (stopper *testInstanceStopper) checkStopped(t *testing.T, expectedServices, expectedLayers []string) {
+	t.Helper()
+
+	stopper.Lock()
+	defer stopper.Unlock()
+
+	if !reflect.DeepEqual(stopper.stoppedServices, expectedServices) {
+		t.Errorf("Wrong stopped services: %v", stopper.stoppedServices)
+	}
+
+	if !reflect.DeepEqual(stopper.stoppedLayers, expectedLayers) {
+		t.Errorf("Wrong stopped layers: %v", stopper.stoppedLayers)
+	}
+}
+
+/***********************************************************************************************************************
+ * testAlertSender
+ **********************************************************************************************************************/
+
+func (sender *testAlertSender) SendAlert(alert cloudprotocol.AlertItem) {
+	sender.alertChannel <- alert
+}
+
+func (sender *testAlertSender) waitAlert(t *testing.T) (alert cloudprotocol.AlertItem) {
+	t.Helper()
+
+	select {
+	case alert = <-sender.alertChannel:
+		return alert
+
+	case <-time.After(alertTimeout):
+		t.Fatal("Wait alert timeout")
+	}
+
+	return alert
+}
@@ -38,6 +38,7 @@ func (launcher *Launcher) setEnvVars(
 	envVarsStatus := make([]cloudprotocol.EnvVarsInstanceStatus, len(envVarsInfo))
 
 	now := time.Now()
+	clockSynced := launcher.isClockSynced()
 
 	for i, envVarInfo := range envVarsInfo {
 		envVarStatus := cloudprotocol.EnvVarsInstanceStatus{
@@ -53,7 +54,8 @@ func (launcher *Launcher) setEnvVars(
 				}),
 			).Debug("Override env var")
 
-			if envVar.TTL != nil && envVar.TTL.Before(now) {
+			// Expired env vars can't be detected until system clock is synchronized
+			if clockSynced && envVar.TTL != nil && envVar.TTL.Before(now) {
 				err := aoserrors.New("environment variable expired")
 
 				envVarStatus.Statuses = append(envVarStatus.Statuses,
@@ -70,7 +72,7 @@ func (launcher *Launcher) setEnvVars(
 		envVarsStatus[i] = envVarStatus
 	}
 
-	launcher.currentEnvVars = envVarsInfo
+	launcher.setCurrentEnvVars(envVarsInfo)
 
 	if err := launcher.storage.SetOverrideEnvVars(envVarsInfo); err != nil {
 		return setEnvVarsErr(envVarsStatus, err)
@@ -101,6 +103,15 @@ func setEnvVarsErr(
 	return envVarsStatus
 }
 
+// setCurrentEnvVars is called under launcher lock which serializes all env vars changes. Run mutex is taken
+// additionally to let GetOverrideEnvVars read env vars without waiting for instances restart.
+func (launcher *Launcher) setCurrentEnvVars(envVars []cloudprotocol.EnvVarsInstanceInfo) {
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	launcher.currentEnvVars = envVars
+}
+
 func (launcher *Launcher) getInstanceEnvVars(instance InstanceInfo) (envVars []string) {
 	for _, envVarInfo := range launcher.currentEnvVars {
 		if (envVarInfo.ServiceID == nil || *envVarInfo.ServiceID == instance.ServiceID) &&
@@ -116,6 +127,10 @@ func (launcher *Launcher) getInstanceEnvVars(instance InstanceInfo) (envVars []s
 }
 
 func (launcher *Launcher) removeOutdatedEnvVars() {
+	if !launcher.isClockSynced() {
+		return
+	}
+
 	var (
 		now            = time.Now()
 		updatedEnvVars = make([]cloudprotocol.EnvVarsInstanceInfo, 0, len(launcher.currentEnvVars))
@@ -150,7 +165,7 @@ func (launcher *Launcher) removeOutdatedEnvVars() {
 	}
 
 	if updated {
-		launcher.currentEnvVars = updatedEnvVars
+		launcher.setCurrentEnvVars(updatedEnvVars)
 
 		if err := launcher.storage.SetOverrideEnvVars(updatedEnvVars); err != nil {
 			log.Errorf("Can't set override env vars: %s", err)
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package launcher
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/healthcheck"
+)
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (launcher *Launcher) startInstanceHealthCheck(instance *runtimeInstanceInfo) error {
+	if launcher.healthChecker == nil || instance.service.serviceConfig.HealthCheck == nil {
+		return nil
+	}
+
+	launcher.runMutex.Lock()
+
+	failed := instance.runStatus.State == cloudprotocol.InstanceStateFailed
+	if failed {
+		instance.healthPending = false
+	}
+
+	launcher.runMutex.Unlock()
+
+	if failed {
+		return nil
+	}
+
+	params := healthcheck.InstanceParams{
+		InstanceIdent: instance.InstanceIdent,
+		IP:            instance.IP,
+		Runner:        instance.service.serviceConfig.Runner,
+		Config:        *instance.service.serviceConfig.HealthCheck,
+	}
+
+	if instance.StatePath != "" {
+		params.StateDir = filepath.Dir(launcher.getAbsStatePath(instance.StatePath))
+	}
+
+	if err := launcher.healthChecker.StartInstanceHealthCheck(instance.InstanceID, params); err != nil {
+		launcher.stopFailedInstance(instance)
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (launcher *Launcher) handleInstanceHealth(instanceHealth healthcheck.InstanceHealth) {
+	launcher.runMutex.Lock()
+	instance, ok := launcher.currentInstances[instanceHealth.InstanceID]
+	healthPending := ok && instance.healthPending
+	launcher.runMutex.Unlock()
+
+	if !ok {
+		log.WithField("instanceID", instanceHealth.InstanceID).Warn("Health status of not running instance received")
+
+		return
+	}
+
+	if healthPending {
+		launcher.handleStartProbe(instance, instanceHealth.Err)
+
+		return
+	}
+
+	if instanceHealth.Err == nil {
+		return
+	}
+
+	launcher.Lock()
+	defer launcher.Unlock()
+
+	launcher.restartUnhealthyInstance(instance, instanceHealth.Err)
+}
+
+// handleStartProbe sets state of instance which waits for start probe. Instance failed start probe is stopped and
+// reported as failed.
+func (launcher *Launcher) handleStartProbe(instance *runtimeInstanceInfo, err error) {
+	if err != nil {
+		launcher.alertSender.SendAlert(unhealthyInstanceAlert(instance, err))
+		launcher.stopFailedInstance(instance)
+	}
+
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	if !instance.healthPending {
+		return
+	}
+
+	instance.healthPending = false
+
+	switch {
+	case err != nil:
+		launcher.instanceFailed(instance, err)
+
+		if launcher.isRollbackCandidate(instance.ServiceID) {
+			go launcher.handleFailedServices()
+		}
+
+	case instance.runStatus.State == instanceStateActivating:
+		instance.runStatus.State = cloudprotocol.InstanceStateActive
+
+		log.WithFields(instanceLogFields(instance, nil)).Info("Instance is healthy")
+
+	default:
+		return
+	}
+
+	if !launcher.runInstancesInProgress {
+		launcher.runtimeStatusChannel <- RuntimeStatus{UpdateStatus: &InstancesStatus{
+			Instances: []cloudprotocol.InstanceStatus{instance.getCloudStatus()},
+		}}
+	}
+}
+
+func (launcher *Launcher) stopFailedInstance(instance *runtimeInstanceInfo) {
+	if err := launcher.instanceRunner.StopInstance(instance.InstanceID); err != nil {
+		log.WithFields(instanceLogFields(instance, nil)).Errorf("Can't stop failed instance: %v", err)
+	}
+}
+
+func (launcher *Launcher) restartUnhealthyInstance(instance *runtimeInstanceInfo, unhealthyErr error) {
+	launcher.runMutex.Lock()
+	rollback := launcher.isRollbackCandidate(instance.ServiceID)
+	launcher.runMutex.Unlock()
+
+	launcher.alertSender.SendAlert(unhealthyInstanceAlert(instance, unhealthyErr))
+
+	if rollback {
+		instances, err := launcher.rollbackService(instance.ServiceID, unhealthyErr.Error())
+		if err == nil {
+			launcher.sendInstancesUpdateStatus(instances)
+
+			return
+		}
+
+		log.WithFields(instanceLogFields(instance, nil)).Errorf("Can't rollback service: %v", err)
+	}
+
+	log.WithFields(instanceLogFields(instance, nil)).Errorf("Restart unhealthy instance: %v", unhealthyErr)
+
+	launcher.doStopAction(instance)
+	launcher.doStartAction(instance)
+
+	launcher.actionHandler.Wait()
+
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	launcher.runtimeStatusChannel <- RuntimeStatus{UpdateStatus: &InstancesStatus{
+		Instances: []cloudprotocol.InstanceStatus{instance.getCloudStatus()},
+	}}
+}
+
+func unhealthyInstanceAlert(instance *runtimeInstanceInfo, err error) cloudprotocol.AlertItem {
+	payload := cloudprotocol.ServiceInstanceAlert{InstanceIdent: instance.InstanceIdent, Message: err.Error()}
+
+	if instance.service != nil {
+		payload.AosVersion = instance.service.AosVersion
+	}
+
+	return cloudprotocol.AlertItem{
+		Timestamp: time.Now(),
+		Tag:       cloudprotocol.AlertTagServiceInstance,
+		Payload:   payload,
+	}
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package launcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+	"github.com/aoscloud/aos_servicemanager/utils/fsverity"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// sharedImagesDir dir in runtime dir where layer images are mounted. Each image is mounted once and shared by all
+// instances which use it.
+const sharedImagesDir = ".images"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// imageLayer instance rootfs layer. Layer with verity root hash is checked before it is used.
+type imageLayer struct {
+	path           string
+	hashTreePath   string
+	verityRootHash string
+}
+
+// imageMount mounted layer image and instances which use it.
+type imageMount struct {
+	mountPoint string
+	instances  map[string]struct{}
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// MountImageFunc mounts read-only layer image.
+//
+//nolint:gochecknoglobals
+var MountImageFunc = fsimage.Mount
+
+// MountVerityImageFunc mounts read-only layer image protected by dm-verity.
+//
+//nolint:gochecknoglobals
+var MountVerityImageFunc = fsimage.MountVerity
+
+// CloseVerityImageFunc closes dm-verity device of unmounted layer image.
+//
+//nolint:gochecknoglobals
+var CloseVerityImageFunc = fsimage.CloseVerity
+
+// VerifyFSVerityFunc checks fs-verity root hash of unpacked layer.
+//
+//nolint:gochecknoglobals
+var VerifyFSVerityFunc = fsverity.Verify
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// mountImageLayers mounts layers stored as images, checks verity of protected layers and returns lower dirs to be used
+// for instance root FS. Images which are already mounted for other instances are reused.
+func (launcher *Launcher) mountImageLayers(instance *runtimeInstanceInfo, layers []imageLayer) (
+	lowerDirs []string, err error,
+) {
+	// Images which are left from previous run are released as layers may be updated
+	if err = launcher.releaseImageLayers(instance.InstanceID); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			if releaseErr := launcher.releaseImageLayers(instance.InstanceID); releaseErr != nil {
+				log.WithFields(instanceLogFields(instance, nil)).Errorf("Can't release layer images: %v", releaseErr)
+			}
+		}
+	}()
+
+	lowerDirs = make([]string, 0, len(layers))
+
+	for _, layer := range layers {
+		fileInfo, err := os.Stat(layer.path)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if !fileInfo.Mode().IsRegular() {
+			if layer.verityRootHash != "" {
+				if err = VerifyFSVerityFunc(layer.path, layer.verityRootHash); err != nil {
+					return nil, aoserrors.Wrap(err)
+				}
+			}
+
+			lowerDirs = append(lowerDirs, layer.path)
+
+			continue
+		}
+
+		mountPoint, err := launcher.acquireImageMount(instance, layer)
+		if err != nil {
+			return nil, err
+		}
+
+		lowerDirs = append(lowerDirs, mountPoint)
+	}
+
+	return lowerDirs, nil
+}
+
+func (launcher *Launcher) acquireImageMount(instance *runtimeInstanceInfo, layer imageLayer) (string, error) {
+	launcher.imageMountsMutex.Lock()
+	defer launcher.imageMountsMutex.Unlock()
+
+	if mount, ok := launcher.imageMounts[layer.path]; ok {
+		mount.instances[instance.InstanceID] = struct{}{}
+
+		return mount.mountPoint, nil
+	}
+
+	mountPoint := getImageMountPoint(layer.path)
+
+	log.WithFields(instanceLogFields(instance, nil)).WithField("image", layer.path).Debug("Mount layer image")
+
+	if err := mountImageLayer(layer, mountPoint); err != nil {
+		os.RemoveAll(mountPoint)
+
+		return "", err
+	}
+
+	launcher.imageMounts[layer.path] = &imageMount{
+		mountPoint: mountPoint,
+		instances:  map[string]struct{}{instance.InstanceID: {}},
+	}
+
+	return mountPoint, nil
+}
+
+// releaseImageLayers releases layer images used by instance. Images which are not used anymore are unmounted.
+func (launcher *Launcher) releaseImageLayers(instanceID string) (err error) {
+	launcher.imageMountsMutex.Lock()
+	defer launcher.imageMountsMutex.Unlock()
+
+	for imagePath, mount := range launcher.imageMounts {
+		if _, ok := mount.instances[instanceID]; !ok {
+			continue
+		}
+
+		delete(mount.instances, instanceID)
+
+		if len(mount.instances) > 0 {
+			continue
+		}
+
+		log.WithField("image", imagePath).Debug("Unmount layer image")
+
+		delete(launcher.imageMounts, imagePath)
+
+		if unmountErr := unmountImageLayer(mount.mountPoint); unmountErr != nil && err == nil {
+			err = unmountErr
+		}
+	}
+
+	return err
+}
+
+// removeStaleImageMounts unmounts layer images which are left from previous run.
+func (launcher *Launcher) removeStaleImageMounts() (err error) {
+	imagesDir := filepath.Join(RuntimeDir, sharedImagesDir)
+
+	entries, readErr := os.ReadDir(imagesDir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil
+		}
+
+		return aoserrors.Wrap(readErr)
+	}
+
+	launcher.imageMountsMutex.Lock()
+	defer launcher.imageMountsMutex.Unlock()
+
+	usedMountPoints := make(map[string]struct{}, len(launcher.imageMounts))
+
+	for _, mount := range launcher.imageMounts {
+		usedMountPoints[mount.mountPoint] = struct{}{}
+	}
+
+	for _, entry := range entries {
+		mountPoint := filepath.Join(imagesDir, entry.Name())
+
+		if _, ok := usedMountPoints[mountPoint]; ok {
+			continue
+		}
+
+		log.WithField("mountPoint", mountPoint).Debug("Remove stale layer image mount")
+
+		if unmountErr := unmountImageLayer(mountPoint); unmountErr != nil && err == nil {
+			err = unmountErr
+		}
+	}
+
+	return err
+}
+
+func getImageMountPoint(imagePath string) string {
+	pathHash := sha256.Sum256([]byte(imagePath))
+
+	return filepath.Join(RuntimeDir, sharedImagesDir, hex.EncodeToString(pathHash[:]))
+}
+
+func mountImageLayer(layer imageLayer, mountPoint string) error {
+	if layer.verityRootHash == "" {
+		return aoserrors.Wrap(MountImageFunc(layer.path, mountPoint))
+	}
+
+	if layer.hashTreePath == "" {
+		return aoserrors.Errorf("verity hash tree of %s not found", layer.path)
+	}
+
+	return aoserrors.Wrap(MountVerityImageFunc(layer.path, layer.hashTreePath, layer.verityRootHash, mountPoint))
+}
+
+func unmountImageLayer(mountPoint string) error {
+	if err := UnmountFunc(mountPoint); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err := CloseVerityImageFunc(mountPoint); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.RemoveAll(mountPoint))
+}
@@ -37,6 +37,7 @@ type runtimeInstanceInfo struct {
 	runtimeDir      string
 	secret          string
 	overrideEnvVars []string
+	healthPending   bool
 }
 
 /***********************************************************************************************************************
@@ -63,6 +64,14 @@ func (instance *runtimeInstanceInfo) getCloudStatus() cloudprotocol.InstanceStat
 		if instance.runStatus.Err != nil {
 			status.ErrorInfo.Message = instance.runStatus.Err.Error()
 		}
+
+		if terminationInfo := instance.runStatus.TerminationInfo(); terminationInfo != "" {
+			if status.ErrorInfo.Message != "" {
+				status.ErrorInfo.Message += " (" + terminationInfo + ")"
+			} else {
+				status.ErrorInfo.Message = terminationInfo
+			}
+		}
 	}
 
 	return status
@@ -72,7 +81,17 @@ func (instance *runtimeInstanceInfo) setRunStatus(runStatus runner.InstanceStatu
 	instance.runStatus = runStatus
 
 	if runStatus.State == cloudprotocol.InstanceStateFailed {
-		log.WithFields(instanceLogFields(instance, nil)).Errorf("Instance failed: %v", runStatus.Err)
+		log.WithFields(instanceLogFields(instance, log.Fields{
+			"exitCode": runStatus.ExitCode, "reason": runStatus.Reason,
+		})).Errorf("Instance failed: %v", runStatus.Err)
+
+		return
+	}
+
+	if instance.healthPending {
+		instance.runStatus.State = instanceStateActivating
+
+		log.WithFields(instanceLogFields(instance, nil)).Info("Instance started, wait health check")
 
 		return
 	}
@@ -85,6 +104,7 @@ func (launcher *Launcher) instanceFailed(instance *runtimeInstanceInfo, err erro
 
 	instance.runStatus.State = cloudprotocol.InstanceStateFailed
 	instance.runStatus.Err = err
+	instance.healthPending = false
 }
 
 func instanceLogFields(instance *runtimeInstanceInfo, extraFields log.Fields) log.Fields {
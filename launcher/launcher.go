@@ -42,11 +42,14 @@ import (
 	"golang.org/x/exp/slices"
 	"golang.org/x/sys/unix"
 
+	"github.com/aoscloud/aos_servicemanager/clocksync"
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/healthcheck"
 	"github.com/aoscloud/aos_servicemanager/layermanager"
 	"github.com/aoscloud/aos_servicemanager/networkmanager"
 	"github.com/aoscloud/aos_servicemanager/runner"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/storagequota"
 )
 
 /***********************************************************************************************************************
@@ -65,6 +68,14 @@ const (
 	runxRunner             = "runx"
 )
 
+const (
+	storagePartitionName = "storage"
+	statePartitionName   = "state"
+)
+
+// Instance is activating while it waits for health check start probe.
+const instanceStateActivating = "activating"
+
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
@@ -86,6 +97,7 @@ type ServiceProvider interface {
 	GetServiceInfo(serviceID string) (servicemanager.ServiceInfo, error)
 	GetImageParts(service servicemanager.ServiceInfo) (servicemanager.ImageParts, error)
 	ValidateService(service servicemanager.ServiceInfo) error
+	RollbackService(serviceID string, aosVersion uint64) (servicemanager.ServiceInfo, error)
 }
 
 // LayerProvider layer provider.
@@ -136,6 +148,25 @@ type AlertSender interface {
 	SendAlert(alert cloudprotocol.AlertItem)
 }
 
+// HealthChecker provides API to check instance health.
+type HealthChecker interface {
+	StartInstanceHealthCheck(instanceID string, params healthcheck.InstanceParams) error
+	StopInstanceHealthCheck(instanceID string) error
+	GetInstancesHealthChannel() (channel <-chan healthcheck.InstanceHealth)
+}
+
+// StorageQuota provides API to limit instance storage and state size.
+type StorageQuota interface {
+	SetInstanceLimits(params storagequota.InstanceParams) error
+	RemoveInstanceLimits(instanceIdent aostypes.InstanceIdent) error
+	RemoveUnusedImages(instanceIDs []string) error
+}
+
+// ClockProvider provides system clock synchronization state.
+type ClockProvider interface {
+	GetClockSyncedChannel() (channel <-chan struct{})
+}
+
 // InstanceInfo instance information.
 type InstanceInfo struct {
 	aostypes.InstanceInfo
@@ -153,6 +184,14 @@ type InstancesStatus struct {
 	Instances []cloudprotocol.InstanceStatus
 }
 
+// InstanceRuntimeInfo current instance runtime info.
+type InstanceRuntimeInfo struct {
+	InstanceInfo
+	AosVersion uint64
+	RunState   string
+	Err        error
+}
+
 // Launcher launcher instance.
 type Launcher struct {
 	sync.Mutex
@@ -166,6 +205,8 @@ type Launcher struct {
 	instanceRegistrar InstanceRegistrar
 	instanceMonitor   InstanceMonitor
 	alertSender       AlertSender
+	healthChecker     HealthChecker
+	storageQuota      StorageQuota
 
 	config                 *config.Config
 	runtimeStatusChannel   chan RuntimeStatus
@@ -175,9 +216,14 @@ type Launcher struct {
 	runInstancesInProgress bool
 	currentInstances       map[string]*runtimeInstanceInfo
 	currentServices        map[string]*serviceInfo
+	updatedServices        map[string]updatedService
 	currentEnvVars         []cloudprotocol.EnvVarsInstanceInfo
+	imageMountsMutex       sync.Mutex
+	imageMounts            map[string]*imageMount
 	onlineTime             time.Time
 	isCloudOnline          bool
+	wasOnlineBeforeSync    bool
+	clockSyncedChannel     <-chan struct{}
 }
 
 /***********************************************************************************************************************
@@ -219,6 +265,7 @@ var defaultHostFSBinds = []string{"bin", "sbin", "lib", "lib64", "usr"} //nolint
 func New(config *config.Config, storage Storage, serviceProvider ServiceProvider, layerProvider LayerProvider,
 	instanceRunner InstanceRunner, resourceManager ResourceManager, networkManager NetworkManager,
 	instanceRegistrar InstanceRegistrar, instanceMonitor InstanceMonitor, alertSender AlertSender,
+	clockProvider ClockProvider, healthChecker HealthChecker, storageQuota StorageQuota,
 ) (launcher *Launcher, err error) {
 	log.Debug("New launcher")
 
@@ -226,18 +273,22 @@ func New(config *config.Config, storage Storage, serviceProvider ServiceProvider
 		storage: storage, serviceProvider: serviceProvider, layerProvider: layerProvider,
 		instanceRunner: instanceRunner, resourceManager: resourceManager, networkManager: networkManager,
 		instanceRegistrar: instanceRegistrar, instanceMonitor: instanceMonitor, alertSender: alertSender,
+		healthChecker: healthChecker, storageQuota: storageQuota,
 
 		config:               config,
 		actionHandler:        action.New(maxParallelInstanceActions),
 		runtimeStatusChannel: make(chan RuntimeStatus, 1),
 		currentInstances:     make(map[string]*runtimeInstanceInfo),
+		updatedServices:      make(map[string]updatedService),
+		imageMounts:          make(map[string]*imageMount),
+		clockSyncedChannel:   clocksync.GetSyncedChannel(clockProvider),
 	}
 
 	ctx, cancelFunction := context.WithCancel(context.Background())
 
 	launcher.cancelFunction = cancelFunction
 
-	go launcher.handleChannels(ctx)
+	go launcher.handleChannels(ctx, launcher.isClockSynced())
 
 	if err = launcher.prepareHostFSDir(); err != nil {
 		return nil, err
@@ -247,6 +298,10 @@ func New(config *config.Config, storage Storage, serviceProvider ServiceProvider
 		return nil, aoserrors.Wrap(err)
 	}
 
+	if err = launcher.removeStaleRuntimeDirs(); err != nil {
+		log.Errorf("Can't remove stale runtime dirs: %v", err)
+	}
+
 	if launcher.onlineTime, err = launcher.storage.GetOnlineTime(); err != nil {
 		log.Errorf("Can't get online time: %v", err)
 	}
@@ -273,6 +328,10 @@ func (launcher *Launcher) Close() (err error) {
 	launcher.cancelFunction()
 	launcher.stopCurrentInstances()
 
+	if removeErr := launcher.removeStaleRuntimeDirs(); removeErr != nil && err == nil {
+		err = removeErr
+	}
+
 	if removeErr := os.RemoveAll(RuntimeDir); removeErr != nil && err == nil {
 		err = aoserrors.Wrap(removeErr)
 	}
@@ -298,6 +357,49 @@ func (launcher *Launcher) RunInstances(instances []aostypes.InstanceInfo, forceR
 	return nil
 }
 
+// StopServiceInstances stops running instances of the service version. Stopped instances are reported as failed
+// with the reason and started again on next run instances request.
+func (launcher *Launcher) StopServiceInstances(serviceID string, aosVersion uint64, reason error) {
+	launcher.Lock()
+	defer launcher.Unlock()
+
+	stopInstances := launcher.getCurrentInstances(func(instance *runtimeInstanceInfo) bool {
+		return instance.ServiceID == serviceID && instance.service.AosVersion == aosVersion
+	})
+	if len(stopInstances) == 0 {
+		return
+	}
+
+	log.WithFields(log.Fields{"serviceID": serviceID, "aosVersion": aosVersion}).Warn("Stop service instances")
+
+	launcher.stopFailedInstances(stopInstances, reason)
+}
+
+// StopLayerInstances stops running instances which use the layer. Stopped instances are reported as failed with the
+// reason and started again on next run instances request.
+func (launcher *Launcher) StopLayerInstances(digest string, reason error) {
+	launcher.Lock()
+	defer launcher.Unlock()
+
+	stopInstances := launcher.getCurrentInstances(func(instance *runtimeInstanceInfo) bool {
+		imageParts, err := launcher.serviceProvider.GetImageParts(instance.service.ServiceInfo)
+		if err != nil {
+			log.WithFields(instanceLogFields(instance, nil)).Errorf("Can't get image parts: %v", err)
+
+			return false
+		}
+
+		return slices.Contains(imageParts.LayersDigest, digest)
+	})
+	if len(stopInstances) == 0 {
+		return
+	}
+
+	log.WithField("digest", digest).Warn("Stop layer instances")
+
+	launcher.stopFailedInstances(stopInstances, reason)
+}
+
 // OverrideEnvVars overrides service instance environment variables.
 func (launcher *Launcher) OverrideEnvVars(
 	envVarsInfo []cloudprotocol.EnvVarsInstanceInfo,
@@ -328,6 +430,15 @@ func (launcher *Launcher) CloudConnection(connected bool) error {
 		launcher.isCloudOnline = connected
 	}()
 
+	// Online time can't be trusted until system clock is synchronized, it will be updated on clock sync
+	if !launcher.isClockSynced() {
+		if connected {
+			launcher.wasOnlineBeforeSync = true
+		}
+
+		return nil
+	}
+
 	if connected || launcher.isCloudOnline {
 		launcher.onlineTime = time.Now()
 
@@ -339,17 +450,79 @@ func (launcher *Launcher) CloudConnection(connected bool) error {
 	return nil
 }
 
+// GetInstancesRuntimeInfo returns runtime info of current instances.
+func (launcher *Launcher) GetInstancesRuntimeInfo() (instances []InstanceRuntimeInfo) {
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	instances = make([]InstanceRuntimeInfo, 0, len(launcher.currentInstances))
+
+	for _, currentInstance := range launcher.currentInstances {
+		instance := InstanceRuntimeInfo{
+			InstanceInfo: currentInstance.InstanceInfo,
+			RunState:     currentInstance.runStatus.State,
+			Err:          currentInstance.runStatus.Err,
+		}
+
+		if currentInstance.service != nil {
+			instance.AosVersion = currentInstance.service.AosVersion
+		}
+
+		instances = append(instances, instance)
+	}
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].InstanceID < instances[j].InstanceID })
+
+	return instances
+}
+
+// GetOverrideEnvVars returns current override env vars.
+func (launcher *Launcher) GetOverrideEnvVars() []cloudprotocol.EnvVarsInstanceInfo {
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	return append([]cloudprotocol.EnvVarsInstanceInfo(nil), launcher.currentEnvVars...)
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
 
-func (launcher *Launcher) handleChannels(ctx context.Context) {
+func (launcher *Launcher) handleChannels(ctx context.Context, clockSynced bool) {
+	clockSyncedChannel := launcher.clockSyncedChannel
+
+	if clockSynced {
+		clockSyncedChannel = nil
+	}
+
+	var instancesHealthChannel <-chan healthcheck.InstanceHealth
+
+	if launcher.healthChecker != nil {
+		instancesHealthChannel = launcher.healthChecker.GetInstancesHealthChannel()
+	}
+
 	for {
 		select {
 		case instances := <-launcher.instanceRunner.InstanceStatusChannel():
 			launcher.updateInstancesStatuses(instances)
 
+		case instanceHealth := <-instancesHealthChannel:
+			launcher.handleInstanceHealth(instanceHealth)
+
+		case <-clockSyncedChannel:
+			clockSyncedChannel = nil
+
+			launcher.Lock()
+			launcher.handleClockSynced()
+			launcher.Unlock()
+
 		case <-time.After(CheckTTLsPeriod):
+			if !launcher.isClockSynced() {
+				log.Warn("Clock is not synced, skip TTLs check")
+
+				continue
+			}
+
 			launcher.Lock()
 			launcher.updateInstancesEnvVars()
 			launcher.updateOfflineTimeouts()
@@ -361,11 +534,38 @@ func (launcher *Launcher) handleChannels(ctx context.Context) {
 	}
 }
 
+func (launcher *Launcher) handleClockSynced() {
+	log.Debug("Clock synced, check TTLs")
+
+	if launcher.isCloudOnline || launcher.wasOnlineBeforeSync {
+		launcher.wasOnlineBeforeSync = false
+		launcher.onlineTime = time.Now()
+
+		if err := launcher.storage.SetOnlineTime(launcher.onlineTime); err != nil {
+			log.Errorf("Can't set online time: %v", err)
+		}
+	}
+
+	launcher.updateInstancesEnvVars()
+	launcher.updateOfflineTimeouts()
+}
+
+func (launcher *Launcher) isClockSynced() bool {
+	select {
+	case <-launcher.clockSyncedChannel:
+		return true
+
+	default:
+		return false
+	}
+}
+
 func (launcher *Launcher) updateInstancesStatuses(instances []runner.InstanceStatus) {
 	launcher.runMutex.Lock()
 	defer launcher.runMutex.Unlock()
 
 	updateInstancesStatus := &InstancesStatus{Instances: make([]cloudprotocol.InstanceStatus, 0, len(instances))}
+	rollbackRequired := false
 
 	for _, instanceStatus := range instances {
 		currentInstance, ok := launcher.currentInstances[instanceStatus.InstanceID]
@@ -374,9 +574,19 @@ func (launcher *Launcher) updateInstancesStatuses(instances []runner.InstanceSta
 			continue
 		}
 
+		if currentInstance.runStatus.State == instanceStateActivating &&
+			instanceStatus.State == cloudprotocol.InstanceStateActive {
+			continue
+		}
+
 		if currentInstance.runStatus.State != instanceStatus.State {
 			currentInstance.setRunStatus(instanceStatus)
 
+			if instanceStatus.State == cloudprotocol.InstanceStateFailed &&
+				launcher.isRollbackCandidate(currentInstance.ServiceID) {
+				rollbackRequired = true
+			}
+
 			if !launcher.runInstancesInProgress {
 				updateInstancesStatus.Instances = append(updateInstancesStatus.Instances,
 					currentInstance.getCloudStatus())
@@ -387,6 +597,10 @@ func (launcher *Launcher) updateInstancesStatuses(instances []runner.InstanceSta
 	if len(updateInstancesStatus.Instances) > 0 {
 		launcher.runtimeStatusChannel <- RuntimeStatus{UpdateStatus: updateInstancesStatus}
 	}
+
+	if rollbackRequired {
+		go launcher.handleFailedServices()
+	}
 }
 
 func (launcher *Launcher) runInstances(runInstances []InstanceInfo) {
@@ -408,6 +622,41 @@ func (launcher *Launcher) runInstances(runInstances []InstanceInfo) {
 
 	launcher.stopInstances(stopInstances)
 	launcher.startInstances(startInstances)
+	launcher.rollbackFailedServices()
+
+	if err := launcher.removeStaleRuntimeDirs(); err != nil {
+		log.Errorf("Can't remove stale runtime dirs: %v", err)
+	}
+
+	if err := launcher.removeStaleInstancesData(); err != nil {
+		log.Errorf("Can't remove stale instances data: %v", err)
+	}
+}
+
+// removeStaleInstancesData removes persistent data kept between instance runs once instance is removed.
+func (launcher *Launcher) removeStaleInstancesData() error {
+	instances, err := launcher.storage.GetAllInstances()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	instanceIDs := make([]string, 0, len(instances))
+
+	for _, instance := range instances {
+		instanceIDs = append(instanceIDs, instance.InstanceID)
+	}
+
+	if err = launcher.removeStaleUpperLayers(instanceIDs); err != nil {
+		return err
+	}
+
+	if launcher.storageQuota != nil {
+		if err = launcher.storageQuota.RemoveUnusedImages(instanceIDs); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
 }
 
 func (launcher *Launcher) calculateInstances(
@@ -467,6 +716,39 @@ runInstancesLoop:
 	return stopInstances, startInstances
 }
 
+// getCurrentInstances returns started instances matching the filter.
+func (launcher *Launcher) getCurrentInstances(
+	filter func(instance *runtimeInstanceInfo) bool,
+) (instances []*runtimeInstanceInfo) {
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	for _, instance := range launcher.currentInstances {
+		if instance.service != nil && filter(instance) {
+			instances = append(instances, instance)
+		}
+	}
+
+	return instances
+}
+
+// stopFailedInstances stops instances and keeps them as failed with the reason until next run instances request.
+func (launcher *Launcher) stopFailedInstances(instances []*runtimeInstanceInfo, reason error) {
+	launcher.stopInstances(instances)
+
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	for _, instance := range instances {
+		failedInstance := newRuntimeInstanceInfo(instance.InstanceInfo)
+
+		launcher.instanceFailed(failedInstance, reason)
+		launcher.currentInstances[failedInstance.InstanceID] = failedInstance
+	}
+
+	launcher.sendRunInstancesStatuses()
+}
+
 func (launcher *Launcher) stopInstances(instances []*runtimeInstanceInfo) {
 	for _, instance := range instances {
 		launcher.doStopAction(instance)
@@ -544,6 +826,13 @@ func (launcher *Launcher) stopInstance(instance *runtimeInstanceInfo) (err error
 		return nil
 	}
 
+	if launcher.healthChecker != nil {
+		if healthErr := launcher.healthChecker.StopInstanceHealthCheck(
+			instance.InstanceID); healthErr != nil && err == nil {
+			err = aoserrors.Wrap(healthErr)
+		}
+	}
+
 	if monitorErr := launcher.instanceMonitor.StopInstanceMonitor(
 		instance.InstanceID); monitorErr != nil && err == nil {
 		err = aoserrors.Wrap(monitorErr)
@@ -553,6 +842,13 @@ func (launcher *Launcher) stopInstance(instance *runtimeInstanceInfo) (err error
 		err = aoserrors.Wrap(runnerErr)
 	}
 
+	if launcher.storageQuota != nil {
+		if quotaErr := launcher.storageQuota.RemoveInstanceLimits(
+			instance.InstanceIdent); quotaErr != nil && err == nil {
+			err = aoserrors.Wrap(quotaErr)
+		}
+	}
+
 	if releaseErr := launcher.releaseRuntime(instance); releaseErr != nil && err == nil {
 		err = releaseErr
 	}
@@ -567,8 +863,12 @@ func (launcher *Launcher) stopInstance(instance *runtimeInstanceInfo) (err error
 		err = aoserrors.Wrap(errStat)
 	}
 
-	if removeErr := os.RemoveAll(instance.runtimeDir); removeErr != nil && err == nil {
-		err = aoserrors.Wrap(removeErr)
+	if releaseErr := launcher.releaseImageLayers(instance.InstanceID); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+
+	if removeErr := removeInstanceRuntimeDir(instance.runtimeDir); removeErr != nil && err == nil {
+		err = removeErr
 	}
 
 	return err
@@ -678,6 +978,14 @@ func (launcher *Launcher) setupNetwork(instance *runtimeInstanceInfo) (err error
 		params.ExposedPorts = append(params.ExposedPorts, key)
 	}
 
+	params.AllowedConnections = make([]string, 0, len(instance.service.serviceConfig.AllowedConnections))
+
+	for key := range instance.service.serviceConfig.AllowedConnections {
+		params.AllowedConnections = append(params.AllowedConnections, key)
+	}
+
+	sort.Strings(params.AllowedConnections)
+
 	if !slices.Contains(launcher.config.RunnerFeatures, runxRunner) {
 		if err := launcher.networkManager.AddInstanceToNetwork(
 			instance.InstanceID, instance.service.ServiceProvider, params); err != nil {
@@ -750,6 +1058,8 @@ func (launcher *Launcher) startInstance(instance *runtimeInstanceInfo) error {
 
 		instance.service = service
 		instance.runStatus = runner.InstanceStatus{InstanceID: instance.InstanceID}
+		// Runner status may arrive before StartInstance returns, so health pending flag is set in advance
+		instance.healthPending = launcher.healthChecker != nil && service.serviceConfig.HealthCheck != nil
 
 		return nil
 	}(); err != nil {
@@ -773,12 +1083,19 @@ func (launcher *Launcher) startInstance(instance *runtimeInstanceInfo) error {
 		return err
 	}
 
-	runStatus := launcher.instanceRunner.StartInstance(
-		instance.InstanceID, instance.runtimeDir, runner.RunParameters{
-			StartInterval:   instance.service.serviceConfig.RunParameters.StartInterval.Duration,
-			StartBurst:      instance.service.serviceConfig.RunParameters.StartBurst,
-			RestartInterval: instance.service.serviceConfig.RunParameters.RestartInterval.Duration,
-		})
+	runParams := runner.RunParameters{
+		StartInterval:   instance.service.serviceConfig.RunParameters.StartInterval.Duration,
+		StartBurst:      instance.service.serviceConfig.RunParameters.StartBurst,
+		RestartInterval: instance.service.serviceConfig.RunParameters.RestartInterval.Duration,
+		Runner:          instance.service.serviceConfig.Runner,
+	}
+
+	if stopParams := instance.service.serviceConfig.StopParameters; stopParams != nil {
+		runParams.StopSignal = stopParams.Signal
+		runParams.StopTimeout = stopParams.Timeout.Duration
+	}
+
+	runStatus := launcher.instanceRunner.StartInstance(instance.InstanceID, instance.runtimeDir, runParams)
 
 	// Update current status if it is not updated by runner status channel. Instance runner status goes asynchronously
 	// by status channel. And therefore, new status may arrive before returning by StartInstance API. We detect this
@@ -791,6 +1108,10 @@ func (launcher *Launcher) startInstance(instance *runtimeInstanceInfo) error {
 
 	launcher.runMutex.Unlock()
 
+	if err := launcher.startInstanceHealthCheck(instance); err != nil {
+		return err
+	}
+
 	monitorParams := resourcemonitor.ResourceMonitorParams{
 		InstanceIdent: instance.InstanceIdent,
 		UID:           int(instance.UID),
@@ -800,14 +1121,14 @@ func (launcher *Launcher) startInstance(instance *runtimeInstanceInfo) error {
 
 	if instance.StoragePath != "" {
 		monitorParams.Partitions = append(monitorParams.Partitions, resourcemonitor.PartitionParam{
-			Name: "storage",
+			Name: storagePartitionName,
 			Path: launcher.getAbsStoragePath(instance.StoragePath),
 		})
 	}
 
 	if instance.StatePath != "" {
 		monitorParams.Partitions = append(monitorParams.Partitions, resourcemonitor.PartitionParam{
-			Name: "state",
+			Name: statePartitionName,
 			Path: launcher.getAbsStatePath(instance.StatePath),
 		})
 	}
@@ -935,6 +1256,47 @@ func prepareStateFile(path string, uid, gid uint32) error {
 	return nil
 }
 
+func (launcher *Launcher) setStorageLimits(instance *runtimeInstanceInfo) error {
+	if launcher.storageQuota == nil {
+		return nil
+	}
+
+	params := storagequota.InstanceParams{
+		InstanceIdent: instance.InstanceIdent,
+		InstanceID:    instance.InstanceID,
+		UID:           instance.UID,
+		GID:           instance.service.GID,
+	}
+
+	quotas := instance.service.serviceConfig.Quotas
+
+	if instance.StoragePath != "" && quotas.StorageLimit != nil {
+		params.Partitions = append(params.Partitions, storagequota.PartitionLimit{
+			Name:  storagePartitionName,
+			Path:  launcher.getAbsStoragePath(instance.StoragePath),
+			Limit: *quotas.StorageLimit,
+		})
+	}
+
+	if instance.StatePath != "" && quotas.StateLimit != nil {
+		params.Partitions = append(params.Partitions, storagequota.PartitionLimit{
+			Name:  statePartitionName,
+			Path:  launcher.getAbsStatePath(instance.StatePath),
+			Limit: *quotas.StateLimit,
+		})
+	}
+
+	if len(params.Partitions) == 0 {
+		return nil
+	}
+
+	if err := launcher.storageQuota.SetInstanceLimits(params); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
 func (launcher *Launcher) getAbsStoragePath(path string) string {
 	return filepath.Join(launcher.config.StorageDir, path)
 }
@@ -955,7 +1317,11 @@ func (launcher *Launcher) prepareRootFS(instance *runtimeInstanceInfo, runtimeCo
 		return aoserrors.Wrap(err)
 	}
 
-	layersDir := []string{mountPointsDir, imageParts.ServiceFSPath}
+	imageLayers := []imageLayer{{
+		path:           imageParts.ServiceFSPath,
+		hashTreePath:   imageParts.ServiceFSHashTreePath,
+		verityRootHash: instance.service.VerityRootHash,
+	}}
 
 	for _, digest := range imageParts.LayersDigest {
 		layer, err := launcher.layerProvider.GetLayerInfoByDigest(digest)
@@ -963,9 +1329,19 @@ func (launcher *Launcher) prepareRootFS(instance *runtimeInstanceInfo, runtimeCo
 			return aoserrors.Wrap(err)
 		}
 
-		layersDir = append(layersDir, layer.Path)
+		if layer.Quarantined {
+			return aoserrors.Errorf("%w: %s", layermanager.ErrLayerQuarantined, digest)
+		}
+
+		imageLayers = append(imageLayers, imageLayer{path: layer.Path})
+	}
+
+	lowerDirs, err := launcher.mountImageLayers(instance, imageLayers)
+	if err != nil {
+		return err
 	}
 
+	layersDir := append([]string{mountPointsDir}, lowerDirs...)
 	layersDir = append(layersDir, path.Join(launcher.config.WorkingDir, hostFSWiteoutsDir), "/")
 
 	rootfsDir := filepath.Join(instance.runtimeDir, instanceRootFS)
@@ -974,7 +1350,12 @@ func (launcher *Launcher) prepareRootFS(instance *runtimeInstanceInfo, runtimeCo
 		return aoserrors.Wrap(err)
 	}
 
-	if err = MountFunc(rootfsDir, layersDir, "", ""); err != nil {
+	upperDir, workDir, err := launcher.prepareUpperLayer(instance)
+	if err != nil {
+		return err
+	}
+
+	if err = MountFunc(rootfsDir, layersDir, workDir, upperDir); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
@@ -44,12 +44,15 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/healthcheck"
 	"github.com/aoscloud/aos_servicemanager/launcher"
 	"github.com/aoscloud/aos_servicemanager/layermanager"
 	"github.com/aoscloud/aos_servicemanager/networkmanager"
 	"github.com/aoscloud/aos_servicemanager/resourcemanager"
 	"github.com/aoscloud/aos_servicemanager/runner"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/storagequota"
+	"github.com/aoscloud/aos_servicemanager/utils/fsverity"
 )
 
 /***********************************************************************************************************************
@@ -69,6 +72,8 @@ const (
 
 const defaultStatusTimeout = 5 * time.Second
 
+const testVerityRootHash = "sha256:verity"
+
 var defaultEnvVars = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "TERM=xterm"}
 
 /***********************************************************************************************************************
@@ -84,6 +89,7 @@ type testStorage struct {
 
 type testServiceProvider struct {
 	services     map[string]servicemanager.ServiceInfo
+	prevServices map[string]servicemanager.ServiceInfo
 	layerDigests map[string][]string
 }
 
@@ -130,13 +136,22 @@ type serviceInfo struct {
 	gid           uint32
 	imageConfig   *imagespec.Image
 	serviceConfig *aostypes.ServiceConfig
+	healthCheck   *healthcheck.Config
+	writableRoot  *writableRootFS
 	layerDigests  []string
+	verityHash    string
+}
+
+type writableRootFS struct {
+	Persistent bool   `json:"persistent,omitempty"`
+	SizeLimit  uint64 `json:"sizeLimit,omitempty"`
 }
 
 type mountInfo struct {
 	lowerDirs []string
 	upperDir  string
 	workDir   string
+	size      uint64
 }
 
 type testItem struct {
@@ -159,7 +174,24 @@ type testDevice struct {
 }
 
 type testAlertSender struct {
-	alerts []cloudprotocol.DeviceAllocateAlert
+	alerts         []cloudprotocol.DeviceAllocateAlert
+	instanceAlerts []cloudprotocol.ServiceInstanceAlert
+}
+
+type testHealthChecker struct {
+	sync.Mutex
+	checkedInstances map[string]aostypes.InstanceIdent
+	healthChannel    chan healthcheck.InstanceHealth
+}
+
+type testClockProvider struct {
+	syncedChannel chan struct{}
+}
+
+type testStorageQuota struct {
+	sync.Mutex
+	limits      map[aostypes.InstanceIdent][]storagequota.PartitionLimit
+	instanceIDs []string
 }
 
 /***********************************************************************************************************************
@@ -302,7 +334,7 @@ func TestRunInstances(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
 		layerProvider, instanceRunner, newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(),
-		newTestInstanceMonitor(), newTestAlertSender())
+		newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -353,7 +385,7 @@ func TestUpdateInstances(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider, layerProvider,
 		instanceRunner, newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(),
-		newTestInstanceMonitor(), newTestAlertSender())
+		newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -412,6 +444,31 @@ func TestUpdateInstances(t *testing.T) {
 	}, defaultStatusTimeout); err != nil {
 		t.Errorf("Check runtime status error: %v", err)
 	}
+
+	// Check termination info is reported
+
+	oomIdent := aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}
+
+	oomInstance, err := storage.getInstanceByIdent(oomIdent)
+	if err != nil {
+		t.Fatalf("Can't get instance: %v", err)
+	}
+
+	instanceRunner.statusChannel <- []runner.InstanceStatus{{
+		InstanceID: oomInstance.InstanceID, State: cloudprotocol.InstanceStateFailed,
+		ExitCode: 137, Signal: 9, Reason: runner.ReasonOOMKill,
+	}}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		UpdateStatus: &launcher.InstancesStatus{Instances: []cloudprotocol.InstanceStatus{{
+			InstanceIdent: oomIdent, RunState: cloudprotocol.InstanceStateFailed,
+			ErrorInfo: &cloudprotocol.ErrorInfo{
+				ExitCode: 137, Message: "reason: oom-kill, signal: SIGKILL, exit code: 137",
+			},
+		}}},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
 }
 
 func TestRestartInstances(t *testing.T) {
@@ -447,7 +504,7 @@ func TestRestartInstances(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, newTestStorage(), serviceProvider,
 		layerProvider, instanceRunner, newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(),
-		newTestInstanceMonitor(), newTestAlertSender())
+		newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -529,7 +586,7 @@ func TestHostFSDir(t *testing.T) {
 	},
 		newTestStorage(), newTestServiceProvider(), newTestLayerProvider(), newTestRunner(nil, nil),
 		newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(), newTestInstanceMonitor(),
-		newTestAlertSender())
+		newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -643,7 +700,7 @@ func TestRuntimeSpec(t *testing.T) {
 		StateDir:   filepath.Join(tmpDir, "states"),
 	}, storage, serviceProvider,
 		newTestLayerProvider(), newTestRunner(nil, nil), resourceManager, networkManager, testRegistrar,
-		newTestInstanceMonitor(), newTestAlertSender())
+		newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -1084,7 +1141,8 @@ func TestRuntimeEnvironment(t *testing.T) {
 		StorageDir: filepath.Join(tmpDir, "storages"),
 		StateDir:   filepath.Join(tmpDir, "states"),
 	}, storage, serviceProvider, layerProvider,
-		newTestRunner(nil, nil), resourceManager, networkManager, registrar, instanceMonitor, newTestAlertSender())
+		newTestRunner(nil, nil), resourceManager, networkManager, registrar, instanceMonitor, newTestAlertSender(),
+		nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -1593,7 +1651,7 @@ func TestOverrideEnvVars(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider, layerProvider,
 		newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(),
-		newTestInstanceMonitor(), newTestAlertSender())
+		newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -1692,7 +1750,7 @@ func TestRestartStoredInstancesOnStart(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
 		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
-		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender())
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -2133,7 +2191,7 @@ func TestInstancePriorities(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider, layerProvider,
 		instanceRunner, newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(), newTestInstanceMonitor(),
-		newTestAlertSender())
+		newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -2300,7 +2358,7 @@ func TestResourceAlerts(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, newTestStorage(), serviceProvider,
 		newTestLayerProvider(), newTestRunner(nil, nil), resourceManager, newTestNetworkManager(),
-		newTestRegistrar(), newTestInstanceMonitor(), alertSender)
+		newTestRegistrar(), newTestInstanceMonitor(), alertSender, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -2351,7 +2409,7 @@ func TestOfflineTimeout(t *testing.T) {
 
 	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
 		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
-		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender())
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
@@ -2472,7 +2530,7 @@ func TestOfflineTimeout(t *testing.T) {
 
 	if testLauncher, err = launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
 		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
-		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender()); err != nil {
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil); err != nil {
 		t.Fatalf("Can't create launcher: %v", err)
 	}
 	defer testLauncher.Close()
@@ -2499,200 +2557,1212 @@ func TestOfflineTimeout(t *testing.T) {
 	}
 }
 
-/***********************************************************************************************************************
- * testStorage
- **********************************************************************************************************************/
+func TestOfflineTimeoutClockSync(t *testing.T) {
+	launcher.CheckTTLsPeriod = 1 * time.Second
 
-func newTestStorage() *testStorage {
-	return &testStorage{
-		instances: make(map[string]launcher.InstanceInfo),
-	}
-}
+	serviceProvider := newTestServiceProvider()
+	storage := newTestStorage()
+	clockProvider := newTestClockProvider()
 
-func (storage *testStorage) AddInstance(instance launcher.InstanceInfo) error {
-	storage.Lock()
-	defer storage.Unlock()
+	// Online time stored with not synced clock
 
-	if _, ok := storage.instances[instance.InstanceID]; ok {
-		return aoserrors.New("instance exists")
+	if err := storage.SetOnlineTime(time.Unix(0, 0)); err != nil {
+		t.Fatalf("Can't set online time: %v", err)
 	}
 
-	storage.instances[instance.InstanceID] = instance
-
-	return nil
-}
-
-func (storage *testStorage) UpdateInstance(instance launcher.InstanceInfo) error {
-	storage.Lock()
-	defer storage.Unlock()
-
-	if _, ok := storage.instances[instance.InstanceID]; !ok {
-		return launcher.ErrNotExist
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), clockProvider, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
 	}
+	defer testLauncher.Close()
 
-	storage.instances[instance.InstanceID] = instance
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
 
-	return nil
-}
+	item := testItem{
+		services: []serviceInfo{
+			{
+				ServiceInfo: aostypes.ServiceInfo{ID: "service0"},
+				serviceConfig: &aostypes.ServiceConfig{
+					OfflineTTL: aostypes.Duration{Duration: 1 * time.Second},
+				},
+			},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+		},
+	}
 
-func (storage *testStorage) RemoveInstance(instanceID string) error {
-	storage.Lock()
-	defer storage.Unlock()
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
 
-	if _, ok := storage.instances[instanceID]; !ok {
-		return launcher.ErrNotExist
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
 	}
 
-	delete(storage.instances, instanceID)
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
 
-	return nil
-}
+	// Offline timeout should not be applied until clock is synced
 
-func (storage *testStorage) GetAllInstances() (instances []launcher.InstanceInfo, err error) {
-	storage.RLock()
-	defer storage.RUnlock()
+	select {
+	case <-testLauncher.RuntimeStatusChannel():
+		t.Error("Unexpected runtime status")
 
-	for _, instance := range storage.instances {
-		instances = append(instances, instance)
+	case <-time.After(3 * time.Second):
 	}
 
-	return instances, nil
-}
+	close(clockProvider.syncedChannel)
 
-func (storage *testStorage) GetOverrideEnvVars() ([]cloudprotocol.EnvVarsInstanceInfo, error) {
-	storage.RLock()
-	defer storage.RUnlock()
+	errOfflineTimeout := errors.New("offline timeout") //nolint:goerr113
 
-	return storage.envVars, nil
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{UpdateStatus: &launcher.InstancesStatus{
+			Instances: createInstancesStatuses(testItem{
+				instances: item.instances,
+				err:       []error{errOfflineTimeout},
+			}),
+		}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
 }
 
-func (storage *testStorage) SetOverrideEnvVars(envVarsInfo []cloudprotocol.EnvVarsInstanceInfo) error {
-	storage.Lock()
-	defer storage.Unlock()
+func TestHealthCheck(t *testing.T) {
+	var (
+		stopMutex        sync.Mutex
+		stoppedInstances []string
+	)
 
-	storage.envVars = envVarsInfo
+	serviceProvider := newTestServiceProvider()
+	alertSender := newTestAlertSender()
+	healthChecker := newTestHealthChecker()
+	instanceRunner := newTestRunner(nil, func(instanceID string) error {
+		stopMutex.Lock()
+		defer stopMutex.Unlock()
 
-	return nil
-}
+		stoppedInstances = append(stoppedInstances, instanceID)
 
-func (storage *testStorage) GetOnlineTime() (time.Time, error) {
-	storage.Lock()
-	defer storage.Unlock()
+		return nil
+	})
 
-	return storage.onlineTime, nil
-}
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, newTestStorage(), serviceProvider,
+		newTestLayerProvider(), instanceRunner, newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), alertSender, nil, healthChecker, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
 
-func (storage *testStorage) SetOnlineTime(onlineTime time.Time) error {
-	storage.Lock()
-	defer storage.Unlock()
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
 
-	storage.onlineTime = onlineTime
+	item := testItem{
+		services: []serviceInfo{
+			{
+				ServiceInfo: aostypes.ServiceInfo{ID: "service0"},
+				healthCheck: &healthcheck.Config{Type: healthcheck.ProbeTypeTCP, Port: 8080},
+			},
+			{
+				ServiceInfo: aostypes.ServiceInfo{ID: "service1"},
+			},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 1}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}},
+		},
+	}
 
-	return nil
-}
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
 
-func (storage *testStorage) fromTestItem(item testItem) {
-	storage.Lock()
-	defer storage.Unlock()
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
 
-	storage.instances = make(map[string]launcher.InstanceInfo)
+	// Instances with health check config are activating until start probe passes
 
-	for _, instance := range item.instances {
-		instanceID := uuid.New().String()
+	runStatuses := createInstancesStatuses(item)
+	runStatuses[0].RunState = "activating"
+	runStatuses[1].RunState = "activating"
 
-		storage.instances[instanceID] = launcher.InstanceInfo{
-			InstanceInfo: instance,
-			InstanceID:   instanceID,
-		}
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: runStatuses},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
 	}
-}
 
-/***********************************************************************************************************************
- * testServiceProvider
- **********************************************************************************************************************/
+	if len(healthChecker.checkedInstances) != 2 {
+		t.Errorf("Wrong checked instances count: %d", len(healthChecker.checkedInstances))
+	}
 
-func newTestServiceProvider() *testServiceProvider {
-	return &testServiceProvider{}
-}
+	healthyIdent := item.instances[0].InstanceIdent
+	failedIdent := item.instances[1].InstanceIdent
 
-func (provider *testServiceProvider) GetServiceInfo(serviceID string) (servicemanager.ServiceInfo, error) {
-	service, ok := provider.services[serviceID]
-	if !ok {
-		return servicemanager.ServiceInfo{}, servicemanager.ErrNotExist
+	healthyInstanceID, err := healthChecker.getInstanceID(healthyIdent)
+	if err != nil {
+		t.Fatalf("Can't get instance ID: %v", err)
 	}
 
-	return service, nil
-}
-
-func (provider *testServiceProvider) GetImageParts(
-	service servicemanager.ServiceInfo,
-) (servicemanager.ImageParts, error) {
-	if _, ok := provider.services[service.ServiceID]; !ok {
-		return servicemanager.ImageParts{}, servicemanager.ErrNotExist
+	failedInstanceID, err := healthChecker.getInstanceID(failedIdent)
+	if err != nil {
+		t.Fatalf("Can't get instance ID: %v", err)
 	}
 
-	return servicemanager.ImageParts{
-		ImageConfigPath:   filepath.Join(service.ImagePath, imageConfigFile),
-		ServiceConfigPath: filepath.Join(service.ImagePath, serviceConfigFile),
-		ServiceFSPath:     filepath.Join(service.ImagePath, instanceRootFS),
-		LayersDigest:      provider.layerDigests[service.ServiceID],
-	}, nil
-}
+	// Instance passed start probe should be reported as active
 
-func (provider *testServiceProvider) ValidateService(service servicemanager.ServiceInfo) error {
-	return nil
-}
+	healthChecker.healthChannel <- healthcheck.InstanceHealth{InstanceID: healthyInstanceID}
 
-func (provider *testServiceProvider) installServices(services []serviceInfo) error {
-	if err := os.RemoveAll(filepath.Join(tmpDir, servicesDir)); err != nil {
-		return aoserrors.Wrap(err)
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		UpdateStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(testItem{
+			services:  item.services,
+			instances: []aostypes.InstanceInfo{{InstanceIdent: healthyIdent}},
+		})},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
 	}
 
-	provider.services = make(map[string]servicemanager.ServiceInfo)
-	provider.layerDigests = map[string][]string{}
+	// Instance failed start probe should be stopped and reported as failed
 
-	for _, service := range services {
-		servicePath := filepath.Join(tmpDir, servicesDir, service.ID)
+	errStartProbe := errors.New("health check timeout") //nolint:goerr113
 
-		provider.services[service.ID] = servicemanager.ServiceInfo{
-			VersionInfo:     service.VersionInfo,
-			ServiceID:       service.ID,
-			ServiceProvider: service.ProviderID,
-			ImagePath:       servicePath,
-			GID:             service.gid,
-		}
+	healthChecker.healthChannel <- healthcheck.InstanceHealth{InstanceID: failedInstanceID, Err: errStartProbe}
 
-		provider.layerDigests[service.ID] = service.layerDigests
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		UpdateStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(testItem{
+			services:  item.services,
+			instances: []aostypes.InstanceInfo{{InstanceIdent: failedIdent}},
+			err:       []error{errStartProbe},
+		})},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
 
-		if err := os.MkdirAll(filepath.Join(servicePath, instanceRootFS), 0o755); err != nil {
-			return aoserrors.Wrap(err)
-		}
+	stopMutex.Lock()
 
-		imageConfig := &imagespec.Image{OS: "linux"}
+	if !reflect.DeepEqual(stoppedInstances, []string{failedInstanceID}) {
+		t.Errorf("Wrong stopped instances: %v", stoppedInstances)
+	}
 
-		if service.imageConfig != nil {
-			imageConfig = service.imageConfig
-		}
+	stopMutex.Unlock()
 
-		if err := writeConfig(filepath.Join(tmpDir, servicesDir, service.ID, imageConfigFile),
-			imageConfig); err != nil {
-			return err
-		}
+	// Unhealthy instance should be restarted and alert should be sent
 
-		if service.serviceConfig != nil {
-			if err := writeConfig(filepath.Join(tmpDir, servicesDir, service.ID, serviceConfigFile),
-				service.serviceConfig); err != nil {
-				return err
-			}
-		}
+	healthChecker.healthChannel <- healthcheck.InstanceHealth{
+		InstanceID: healthyInstanceID, Err: errors.New("health check failed"), //nolint:goerr113
 	}
 
-	return nil
-}
+	restartStatuses := createInstancesStatuses(testItem{
+		services:  item.services,
+		instances: []aostypes.InstanceInfo{{InstanceIdent: healthyIdent}},
+	})
+	restartStatuses[0].RunState = "activating"
 
-func (storage *testStorage) getInstanceByIdent(instanceIdent aostypes.InstanceIdent) (launcher.InstanceInfo, error) {
-	for _, instance := range storage.instances {
-		if instance.InstanceIdent == instanceIdent {
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		UpdateStatus: &launcher.InstancesStatus{Instances: restartStatuses},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	if _, err = healthChecker.getInstanceID(healthyIdent); err != nil {
+		t.Errorf("Health check should be restarted: %v", err)
+	}
+
+	healthChecker.healthChannel <- healthcheck.InstanceHealth{InstanceID: healthyInstanceID}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		UpdateStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(testItem{
+			services:  item.services,
+			instances: []aostypes.InstanceInfo{{InstanceIdent: healthyIdent}},
+		})},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	if !reflect.DeepEqual(alertSender.instanceAlerts, []cloudprotocol.ServiceInstanceAlert{
+		{InstanceIdent: failedIdent, Message: "health check timeout"},
+		{InstanceIdent: healthyIdent, Message: "health check failed"},
+	}) {
+		t.Errorf("Wrong instance alerts: %v", alertSender.instanceAlerts)
+	}
+}
+
+func TestServiceRollback(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+	alertSender := newTestAlertSender()
+
+	// Version 2 of any service fails to start
+	instanceRunner := newTestRunner(func(instanceID string) runner.InstanceStatus {
+		storage.RLock()
+		instance := storage.instances[instanceID]
+		storage.RUnlock()
+
+		if serviceProvider.services[instance.ServiceID].AosVersion == 2 {
+			return runner.InstanceStatus{
+				InstanceID: instanceID, State: cloudprotocol.InstanceStateFailed,
+				Err: errors.New("start failed"), //nolint:goerr113
+			}
+		}
+
+		return runner.InstanceStatus{InstanceID: instanceID, State: cloudprotocol.InstanceStateActive}
+	}, nil)
+
+	testLauncher, err := launcher.New(&config.Config{
+		WorkingDir: tmpDir,
+		Rollback:   config.Rollback{Enabled: true, Window: aostypes.Duration{Duration: time.Minute}},
+	}, storage, serviceProvider, newTestLayerProvider(), instanceRunner, newTestResourceManager(),
+		newTestNetworkManager(), newTestRegistrar(), newTestInstanceMonitor(), alertSender, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	instances := []aostypes.InstanceInfo{
+		{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+		{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 1}},
+		{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}},
+	}
+
+	goodServices := []serviceInfo{
+		{ServiceInfo: aostypes.ServiceInfo{ID: "service0", VersionInfo: aostypes.VersionInfo{AosVersion: 1}}},
+		{ServiceInfo: aostypes.ServiceInfo{ID: "service1", VersionInfo: aostypes.VersionInfo{AosVersion: 1}}},
+	}
+
+	badServices := []serviceInfo{
+		{ServiceInfo: aostypes.ServiceInfo{ID: "service0", VersionInfo: aostypes.VersionInfo{AosVersion: 2}}},
+		{ServiceInfo: aostypes.ServiceInfo{ID: "service1", VersionInfo: aostypes.VersionInfo{AosVersion: 1}}},
+	}
+
+	for _, services := range [][]serviceInfo{goodServices, badServices} {
+		if err = serviceProvider.installServices(services); err != nil {
+			t.Fatalf("Can't install services: %v", err)
+		}
+
+		if err = testLauncher.RunInstances(instances, false); err != nil {
+			t.Fatalf("Can't run instances: %v", err)
+		}
+
+		// Instances of failed version should be restarted on previous version
+
+		if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+			RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(testItem{
+				services: goodServices, instances: instances,
+			})},
+		}, defaultStatusTimeout); err != nil {
+			t.Errorf("Check runtime status error: %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(alertSender.instanceAlerts, []cloudprotocol.ServiceInstanceAlert{
+		{
+			InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0"},
+			AosVersion:    2,
+			Message:       "Service version 2 failed and rolled back to version 1: start failed",
+		},
+	}) {
+		t.Errorf("Wrong instance alerts: %v", alertSender.instanceAlerts)
+	}
+}
+
+func TestStorageQuota(t *testing.T) {
+	serviceProvider := newTestServiceProvider()
+	storageQuota := newTestStorageQuota()
+
+	testLauncher, err := launcher.New(&config.Config{
+		WorkingDir: tmpDir,
+		StorageDir: filepath.Join(tmpDir, "storages"),
+		StateDir:   filepath.Join(tmpDir, "states"),
+	}, newTestStorage(), serviceProvider, newTestLayerProvider(), newTestRunner(nil, nil),
+		newTestResourceManager(), newTestNetworkManager(), newTestRegistrar(), newTestInstanceMonitor(),
+		newTestAlertSender(), nil, nil, storageQuota)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	limitedIdent := aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}
+
+	item := testItem{
+		services: []serviceInfo{
+			{
+				ServiceInfo: aostypes.ServiceInfo{ID: "service0"},
+				serviceConfig: &aostypes.ServiceConfig{
+					Quotas: aostypes.ServiceQuotas{StorageLimit: newUint64(2048), StateLimit: newUint64(1024)},
+				},
+			},
+			{
+				ServiceInfo: aostypes.ServiceInfo{ID: "service1"},
+			},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: limitedIdent, StoragePath: "storage0", StatePath: "state0.dat"},
+			{
+				InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0},
+				StoragePath:   "storage1", StatePath: "state1.dat",
+			},
+		},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	expectedLimits := map[aostypes.InstanceIdent][]storagequota.PartitionLimit{
+		limitedIdent: {
+			{Name: "storage", Path: filepath.Join(tmpDir, "storages", "storage0"), Limit: 2048},
+			{Name: "state", Path: filepath.Join(tmpDir, "states", "state0.dat"), Limit: 1024},
+		},
+	}
+
+	storageQuota.Lock()
+
+	if !reflect.DeepEqual(storageQuota.limits, expectedLimits) {
+		t.Errorf("Wrong instance limits: %v", storageQuota.limits)
+	}
+
+	if len(storageQuota.instanceIDs) != len(item.instances) {
+		t.Errorf("Images of running instances should be kept: %v", storageQuota.instanceIDs)
+	}
+
+	storageQuota.Unlock()
+
+	if err = testLauncher.RunInstances(nil, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	storageQuota.Lock()
+	defer storageQuota.Unlock()
+
+	if len(storageQuota.limits) != 0 {
+		t.Errorf("Instance limits should be removed: %v", storageQuota.limits)
+	}
+
+	if len(storageQuota.instanceIDs) != 0 {
+		t.Errorf("Images of removed instances should be removed: %v", storageQuota.instanceIDs)
+	}
+}
+
+func TestWritableRootFS(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+
+	defer func() {
+		testLauncher.Close()
+	}()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	ephemeralIdent := aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}
+	persistentIdent := aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}
+
+	item := testItem{
+		services: []serviceInfo{
+			{
+				ServiceInfo:  aostypes.ServiceInfo{ID: "service0"},
+				writableRoot: &writableRootFS{SizeLimit: 1024},
+			},
+			{
+				ServiceInfo:  aostypes.ServiceInfo{ID: "service1"},
+				writableRoot: &writableRootFS{Persistent: true, SizeLimit: 2048},
+			},
+		},
+		instances: []aostypes.InstanceInfo{{InstanceIdent: ephemeralIdent}, {InstanceIdent: persistentIdent}},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	upperLayerFiles := make(map[aostypes.InstanceIdent]string)
+	persistentImage := ""
+
+	for ident, size := range map[aostypes.InstanceIdent]uint64{ephemeralIdent: 1024, persistentIdent: 2048} {
+		instance, err := storage.getInstanceByIdent(ident)
+		if err != nil {
+			t.Fatalf("Can't get instance: %v", err)
+		}
+
+		upperLayerDir := filepath.Join(launcher.RuntimeDir, instance.InstanceID, "upperlayer")
+
+		mounter.Lock()
+
+		upperLayerMount, ok := mounter.mounts[upperLayerDir]
+		if !ok || upperLayerMount.size != size {
+			t.Errorf("Wrong upper layer mount: %v", upperLayerMount)
+		}
+
+		if ident == persistentIdent {
+			if len(upperLayerMount.lowerDirs) != 1 ||
+				upperLayerMount.lowerDirs[0] != filepath.Join(tmpDir, "upperlayers", instance.InstanceID+".img") {
+				t.Errorf("Persistent upper layer should be stored in working dir: %v", upperLayerMount)
+			}
+
+			persistentImage = upperLayerMount.lowerDirs[0]
+		}
+
+		rootFSMount := mounter.mounts[filepath.Join(launcher.RuntimeDir, instance.InstanceID, instanceRootFS)]
+
+		mounter.Unlock()
+
+		if rootFSMount.upperDir != filepath.Join(upperLayerDir, "upper") ||
+			rootFSMount.workDir != filepath.Join(upperLayerDir, "work") {
+			t.Errorf("Wrong root FS mount: %v", rootFSMount)
+		}
+
+		upperLayerFiles[ident] = filepath.Join(rootFSMount.upperDir, "file")
+
+		if err = os.WriteFile(upperLayerFiles[ident], []byte("data"), 0o600); err != nil {
+			t.Fatalf("Can't write upper layer file: %v", err)
+		}
+	}
+
+	// Restart instances: only persistent upper layer should be kept
+
+	if err = testLauncher.RunInstances(item.instances, true); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	if _, err = os.Stat(upperLayerFiles[ephemeralIdent]); !os.IsNotExist(err) {
+		t.Errorf("Ephemeral upper layer should be wiped: %v", err)
+	}
+
+	if _, err = os.Stat(upperLayerFiles[persistentIdent]); err != nil {
+		t.Errorf("Persistent upper layer should be kept: %v", err)
+	}
+
+	// Restart launcher: runtime dir is removed but persistent upper layer should be kept
+
+	if err = testLauncher.Close(); err != nil {
+		t.Fatalf("Can't close launcher: %v", err)
+	}
+
+	if testLauncher, err = launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil); err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	if _, err = os.Stat(upperLayerFiles[persistentIdent]); err != nil {
+		t.Errorf("Persistent upper layer should be kept: %v", err)
+	}
+
+	// Remove instances: all upper layers should be released
+
+	if err = testLauncher.RunInstances(nil, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	if _, err = os.Stat(persistentImage); !os.IsNotExist(err) {
+		t.Errorf("Persistent upper layer image should be removed: %v", err)
+	}
+
+	mounter.Lock()
+	defer mounter.Unlock()
+
+	for mountPoint := range mounter.mounts {
+		if strings.HasPrefix(mountPoint, launcher.RuntimeDir) {
+			t.Errorf("Mount point should be released: %s", mountPoint)
+		}
+	}
+}
+
+func TestImageLayers(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+	layerProvider := newTestLayerProvider()
+
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		layerProvider, newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	imageLayerDigest := "imageLayer"
+	treeLayerDigest := "treeLayer"
+
+	item := testItem{
+		layers: []aostypes.LayerInfo{{Digest: imageLayerDigest}, {Digest: treeLayerDigest}},
+		services: []serviceInfo{
+			{
+				ServiceInfo:  aostypes.ServiceInfo{ID: "service0"},
+				layerDigests: []string{imageLayerDigest, treeLayerDigest},
+			},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 1}},
+		},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = layerProvider.installLayers(item.layers); err != nil {
+		t.Fatalf("Can't install layers: %v", err)
+	}
+
+	// Replace layer tree with image file
+
+	imageLayer, err := layerProvider.GetLayerInfoByDigest(imageLayerDigest)
+	if err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	if err = os.RemoveAll(imageLayer.Path); err != nil {
+		t.Fatalf("Can't remove layer dir: %v", err)
+	}
+
+	if err = os.WriteFile(imageLayer.Path, []byte("hsqs"), 0o600); err != nil {
+		t.Fatalf("Can't create layer image: %v", err)
+	}
+
+	treeLayer, err := layerProvider.GetLayerInfoByDigest(treeLayerDigest)
+	if err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	instance, err := storage.getInstanceByIdent(item.instances[0].InstanceIdent)
+	if err != nil {
+		t.Fatalf("Can't get instance: %v", err)
+	}
+
+	service, err := serviceProvider.GetServiceInfo(instance.ServiceID)
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	imageParts, err := serviceProvider.GetImageParts(service)
+	if err != nil {
+		t.Fatalf("Can't get image parts: %v", err)
+	}
+
+	// Layer image is mounted once and shared by all instances
+
+	mounter.Lock()
+
+	imageMountPoints := getImageMountPoints(imageLayer.Path)
+	rootFSMount := mounter.mounts[filepath.Join(launcher.RuntimeDir, instance.InstanceID, instanceRootFS)]
+
+	mounter.Unlock()
+
+	if len(imageMountPoints) != 1 {
+		t.Fatalf("Wrong layer image mounts: %v", imageMountPoints)
+	}
+
+	imageMountPoint := imageMountPoints[0]
+
+	expectedLowerDirs := []string{
+		filepath.Join(launcher.RuntimeDir, instance.InstanceID, "mounts"), imageParts.ServiceFSPath,
+		imageMountPoint, treeLayer.Path, filepath.Join(tmpDir, "hostfs", "whiteouts"), "/",
+	}
+
+	if !reflect.DeepEqual(rootFSMount.lowerDirs, expectedLowerDirs) {
+		t.Errorf("Wrong lower dirs value: %v", rootFSMount.lowerDirs)
+	}
+
+	// Stop one instance: layer image should be kept for another one
+
+	item.instances = item.instances[1:]
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	mounter.Lock()
+
+	if _, ok := mounter.mounts[imageMountPoint]; !ok {
+		t.Error("Layer image should be mounted")
+	}
+
+	mounter.Unlock()
+
+	// Stop all instances: layer image should be unmounted
+
+	if err = testLauncher.RunInstances(nil, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	mounter.Lock()
+	defer mounter.Unlock()
+
+	if _, ok := mounter.mounts[imageMountPoint]; ok {
+		t.Error("Layer image should be unmounted")
+	}
+}
+
+func TestQuarantinedLayer(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+	layerProvider := newTestLayerProvider()
+
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		layerProvider, newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	layerDigest := "quarantinedLayer"
+
+	item := testItem{
+		layers: []aostypes.LayerInfo{{Digest: layerDigest}},
+		services: []serviceInfo{
+			{ServiceInfo: aostypes.ServiceInfo{ID: "service0"}, layerDigests: []string{layerDigest}},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+		},
+		err: []error{layermanager.ErrLayerQuarantined},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = layerProvider.installLayers(item.layers); err != nil {
+		t.Fatalf("Can't install layers: %v", err)
+	}
+
+	layer := layerProvider.layers[layerDigest]
+	layer.Quarantined = true
+	layerProvider.layers[layerDigest] = layer
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+}
+
+func TestStopServiceInstances(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	item := testItem{
+		services: []serviceInfo{
+			{ServiceInfo: aostypes.ServiceInfo{ID: "service0", VersionInfo: aostypes.VersionInfo{AosVersion: 1}}},
+			{ServiceInfo: aostypes.ServiceInfo{ID: "service1", VersionInfo: aostypes.VersionInfo{AosVersion: 1}}},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 1}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}},
+		},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	// Instances of other service version should not be stopped
+
+	testLauncher.StopServiceInstances("service0", 2, servicemanager.ErrServiceQuarantined)
+
+	select {
+	case status := <-testLauncher.RuntimeStatusChannel():
+		t.Errorf("Unexpected runtime status: %v", status)
+
+	case <-time.After(time.Second):
+	}
+
+	testLauncher.StopServiceInstances("service0", 1, servicemanager.ErrServiceQuarantined)
+
+	stoppedStatuses := createInstancesStatuses(testItem{
+		services:  item.services[1:],
+		instances: item.instances,
+		err:       []error{servicemanager.ErrServiceQuarantined, servicemanager.ErrServiceQuarantined},
+	})
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: stoppedStatuses},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	// Stopped instances should be started on next run request
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+}
+
+func TestStopLayerInstances(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+	layerProvider := newTestLayerProvider()
+
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		layerProvider, newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	item := testItem{
+		layers: []aostypes.LayerInfo{{Digest: "layer0"}, {Digest: "layer1"}},
+		services: []serviceInfo{
+			{
+				ServiceInfo:  aostypes.ServiceInfo{ID: "service0", VersionInfo: aostypes.VersionInfo{AosVersion: 1}},
+				layerDigests: []string{"layer0"},
+			},
+			{
+				ServiceInfo:  aostypes.ServiceInfo{ID: "service1", VersionInfo: aostypes.VersionInfo{AosVersion: 1}},
+				layerDigests: []string{"layer1"},
+			},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}},
+		},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = layerProvider.installLayers(item.layers); err != nil {
+		t.Fatalf("Can't install layers: %v", err)
+	}
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	// Only instances using the layer should be stopped
+
+	testLauncher.StopLayerInstances("layer0", layermanager.ErrLayerQuarantined)
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(testItem{
+			services:  item.services[1:],
+			instances: item.instances,
+			err:       []error{layermanager.ErrLayerQuarantined},
+		})},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+}
+
+func TestServiceVerity(t *testing.T) {
+	storage := newTestStorage()
+	serviceProvider := newTestServiceProvider()
+
+	testLauncher, err := launcher.New(&config.Config{WorkingDir: tmpDir}, storage, serviceProvider,
+		newTestLayerProvider(), newTestRunner(nil, nil), newTestResourceManager(), newTestNetworkManager(),
+		newTestRegistrar(), newTestInstanceMonitor(), newTestAlertSender(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create launcher: %v", err)
+	}
+	defer testLauncher.Close()
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(),
+		launcher.RuntimeStatus{RunStatus: &launcher.InstancesStatus{}}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+
+	item := testItem{
+		services: []serviceInfo{
+			{ServiceInfo: aostypes.ServiceInfo{ID: "service0"}, verityHash: testVerityRootHash},
+			{ServiceInfo: aostypes.ServiceInfo{ID: "service1"}, verityHash: "sha256:corrupted"},
+		},
+		instances: []aostypes.InstanceInfo{
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}},
+			{InstanceIdent: aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}},
+		},
+		err: []error{nil, fsverity.ErrRootHashMismatch},
+	}
+
+	if err = serviceProvider.installServices(item.services); err != nil {
+		t.Fatalf("Can't install services: %v", err)
+	}
+
+	if err = testLauncher.RunInstances(item.instances, false); err != nil {
+		t.Fatalf("Can't run instances: %v", err)
+	}
+
+	if err = checkRuntimeStatus(testLauncher.RuntimeStatusChannel(), launcher.RuntimeStatus{
+		RunStatus: &launcher.InstancesStatus{Instances: createInstancesStatuses(item)},
+	}, defaultStatusTimeout); err != nil {
+		t.Errorf("Check runtime status error: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * testStorage
+ **********************************************************************************************************************/
+
+func newTestStorage() *testStorage {
+	return &testStorage{
+		instances: make(map[string]launcher.InstanceInfo),
+	}
+}
+
+func (storage *testStorage) AddInstance(instance launcher.InstanceInfo) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	if _, ok := storage.instances[instance.InstanceID]; ok {
+		return aoserrors.New("instance exists")
+	}
+
+	storage.instances[instance.InstanceID] = instance
+
+	return nil
+}
+
+func (storage *testStorage) UpdateInstance(instance launcher.InstanceInfo) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	if _, ok := storage.instances[instance.InstanceID]; !ok {
+		return launcher.ErrNotExist
+	}
+
+	storage.instances[instance.InstanceID] = instance
+
+	return nil
+}
+
+func (storage *testStorage) RemoveInstance(instanceID string) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	if _, ok := storage.instances[instanceID]; !ok {
+		return launcher.ErrNotExist
+	}
+
+	delete(storage.instances, instanceID)
+
+	return nil
+}
+
+func (storage *testStorage) GetAllInstances() (instances []launcher.InstanceInfo, err error) {
+	storage.RLock()
+	defer storage.RUnlock()
+
+	for _, instance := range storage.instances {
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func (storage *testStorage) GetOverrideEnvVars() ([]cloudprotocol.EnvVarsInstanceInfo, error) {
+	storage.RLock()
+	defer storage.RUnlock()
+
+	return storage.envVars, nil
+}
+
+func (storage *testStorage) SetOverrideEnvVars(envVarsInfo []cloudprotocol.EnvVarsInstanceInfo) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.envVars = envVarsInfo
+
+	return nil
+}
+
+func (storage *testStorage) GetOnlineTime() (time.Time, error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return storage.onlineTime, nil
+}
+
+func (storage *testStorage) SetOnlineTime(onlineTime time.Time) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.onlineTime = onlineTime
+
+	return nil
+}
+
+func (storage *testStorage) fromTestItem(item testItem) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.instances = make(map[string]launcher.InstanceInfo)
+
+	for _, instance := range item.instances {
+		instanceID := uuid.New().String()
+
+		storage.instances[instanceID] = launcher.InstanceInfo{
+			InstanceInfo: instance,
+			InstanceID:   instanceID,
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * testServiceProvider
+ **********************************************************************************************************************/
+
+func newTestServiceProvider() *testServiceProvider {
+	return &testServiceProvider{}
+}
+
+func (provider *testServiceProvider) GetServiceInfo(serviceID string) (servicemanager.ServiceInfo, error) {
+	service, ok := provider.services[serviceID]
+	if !ok {
+		return servicemanager.ServiceInfo{}, servicemanager.ErrNotExist
+	}
+
+	return service, nil
+}
+
+func (provider *testServiceProvider) GetImageParts(
+	service servicemanager.ServiceInfo,
+) (servicemanager.ImageParts, error) {
+	if _, ok := provider.services[service.ServiceID]; !ok {
+		return servicemanager.ImageParts{}, servicemanager.ErrNotExist
+	}
+
+	return servicemanager.ImageParts{
+		ImageConfigPath:   filepath.Join(service.ImagePath, imageConfigFile),
+		ServiceConfigPath: filepath.Join(service.ImagePath, serviceConfigFile),
+		ServiceFSPath:     filepath.Join(service.ImagePath, instanceRootFS),
+		LayersDigest:      provider.layerDigests[service.ServiceID],
+	}, nil
+}
+
+func (provider *testServiceProvider) ValidateService(service servicemanager.ServiceInfo) error {
+	return nil
+}
+
+func (provider *testServiceProvider) RollbackService(
+	serviceID string, aosVersion uint64,
+) (servicemanager.ServiceInfo, error) {
+	prevService, ok := provider.prevServices[serviceID]
+	if !ok || prevService.AosVersion >= aosVersion {
+		return servicemanager.ServiceInfo{}, servicemanager.ErrNoRollbackVersion
+	}
+
+	provider.services[serviceID] = prevService
+
+	return prevService, nil
+}
+
+func (provider *testServiceProvider) installServices(services []serviceInfo) error {
+	if err := os.RemoveAll(filepath.Join(tmpDir, servicesDir)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	provider.prevServices = provider.services
+	provider.services = make(map[string]servicemanager.ServiceInfo)
+	provider.layerDigests = map[string][]string{}
+
+	for _, service := range services {
+		servicePath := filepath.Join(tmpDir, servicesDir, service.ID)
+
+		provider.services[service.ID] = servicemanager.ServiceInfo{
+			VersionInfo:     service.VersionInfo,
+			ServiceID:       service.ID,
+			ServiceProvider: service.ProviderID,
+			ImagePath:       servicePath,
+			GID:             service.gid,
+			VerityRootHash:  service.verityHash,
+		}
+
+		provider.layerDigests[service.ID] = service.layerDigests
+
+		if err := os.MkdirAll(filepath.Join(servicePath, instanceRootFS), 0o755); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		imageConfig := &imagespec.Image{OS: "linux"}
+
+		if service.imageConfig != nil {
+			imageConfig = service.imageConfig
+		}
+
+		if err := writeConfig(filepath.Join(tmpDir, servicesDir, service.ID, imageConfigFile),
+			imageConfig); err != nil {
+			return err
+		}
+
+		if service.serviceConfig != nil || service.healthCheck != nil || service.writableRoot != nil {
+			serviceConfig := struct {
+				aostypes.ServiceConfig
+				HealthCheck    *healthcheck.Config `json:"healthCheck,omitempty"`
+				WritableRootFS *writableRootFS     `json:"writableRootfs,omitempty"`
+			}{HealthCheck: service.healthCheck, WritableRootFS: service.writableRoot}
+
+			if service.serviceConfig != nil {
+				serviceConfig.ServiceConfig = *service.serviceConfig
+			}
+
+			if err := writeConfig(filepath.Join(tmpDir, servicesDir, service.ID, serviceConfigFile),
+				serviceConfig); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (storage *testStorage) getInstanceByIdent(instanceIdent aostypes.InstanceIdent) (launcher.InstanceInfo, error) {
+	for _, instance := range storage.instances {
+		if instance.InstanceIdent == instanceIdent {
 			return instance, nil
 		}
 	}
@@ -3074,6 +4144,74 @@ func (mounter *testMounter) Mount(mountPoint string, lowerDirs []string, workDir
 	return nil
 }
 
+func (mounter *testMounter) MountImage(imagePath, mountPoint string) error {
+	mounter.Lock()
+	defer mounter.Unlock()
+
+	if _, ok := mounter.mounts[mountPoint]; ok {
+		return aoserrors.Errorf("folder %s already mounted", mountPoint)
+	}
+
+	if err := os.MkdirAll(mountPoint, 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	mounter.mounts[mountPoint] = mountInfo{lowerDirs: []string{imagePath}}
+
+	return nil
+}
+
+func (mounter *testMounter) MountVerityImage(imagePath, hashTreePath, rootHash, mountPoint string) error {
+	if rootHash != testVerityRootHash {
+		return aoserrors.New("can't open verity device")
+	}
+
+	return mounter.MountImage(imagePath, mountPoint)
+}
+
+func (mounter *testMounter) CloseVerityImage(mountPoint string) error {
+	return nil
+}
+
+func (mounter *testMounter) MountUpperLayer(mountPoint string, size uint64) error {
+	mounter.Lock()
+	defer mounter.Unlock()
+
+	if _, ok := mounter.mounts[mountPoint]; ok {
+		return aoserrors.Errorf("folder %s already mounted", mountPoint)
+	}
+
+	mounter.mounts[mountPoint] = mountInfo{size: size}
+
+	return nil
+}
+
+func (mounter *testMounter) MountUpperLayerImage(imagePath, mountPoint string, size uint64) error {
+	mounter.Lock()
+	defer mounter.Unlock()
+
+	if _, ok := mounter.mounts[mountPoint]; ok {
+		return aoserrors.Errorf("folder %s already mounted", mountPoint)
+	}
+
+	// Image is emulated by dir linked to mount point to keep data between mounts
+	if err := os.MkdirAll(imagePath, 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err := os.Remove(mountPoint); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err := os.Symlink(imagePath, mountPoint); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	mounter.mounts[mountPoint] = mountInfo{lowerDirs: []string{imagePath}, size: size}
+
+	return nil
+}
+
 func (mounter *testMounter) Unmount(mountPoint string) error {
 	mounter.Lock()
 	defer mounter.Unlock()
@@ -3095,6 +4233,109 @@ func (sender *testAlertSender) SendAlert(alertItem cloudprotocol.AlertItem) {
 	if alert, ok := alertItem.Payload.(cloudprotocol.DeviceAllocateAlert); ok {
 		sender.alerts = append(sender.alerts, alert)
 	}
+
+	if alert, ok := alertItem.Payload.(cloudprotocol.ServiceInstanceAlert); ok {
+		sender.instanceAlerts = append(sender.instanceAlerts, alert)
+	}
+}
+
+/***********************************************************************************************************************
+ * testHealthChecker
+ **********************************************************************************************************************/
+
+func newTestHealthChecker() *testHealthChecker {
+	return &testHealthChecker{
+		checkedInstances: make(map[string]aostypes.InstanceIdent),
+		healthChannel:    make(chan healthcheck.InstanceHealth, 10),
+	}
+}
+
+func (checker *testHealthChecker) StartInstanceHealthCheck(
+	instanceID string, params healthcheck.InstanceParams,
+) error {
+	checker.Lock()
+	defer checker.Unlock()
+
+	if _, ok := checker.checkedInstances[instanceID]; ok {
+		return aoserrors.New("instance health check already started")
+	}
+
+	checker.checkedInstances[instanceID] = params.InstanceIdent
+
+	return nil
+}
+
+func (checker *testHealthChecker) StopInstanceHealthCheck(instanceID string) error {
+	checker.Lock()
+	defer checker.Unlock()
+
+	delete(checker.checkedInstances, instanceID)
+
+	return nil
+}
+
+func (checker *testHealthChecker) GetInstancesHealthChannel() (channel <-chan healthcheck.InstanceHealth) {
+	return checker.healthChannel
+}
+
+func (checker *testHealthChecker) getInstanceID(instanceIdent aostypes.InstanceIdent) (string, error) {
+	checker.Lock()
+	defer checker.Unlock()
+
+	for instanceID, checkedIdent := range checker.checkedInstances {
+		if checkedIdent == instanceIdent {
+			return instanceID, nil
+		}
+	}
+
+	return "", aoserrors.New("instance health check not started")
+}
+
+/***********************************************************************************************************************
+ * testStorageQuota
+ **********************************************************************************************************************/
+
+func newTestStorageQuota() *testStorageQuota {
+	return &testStorageQuota{limits: make(map[aostypes.InstanceIdent][]storagequota.PartitionLimit)}
+}
+
+func (quota *testStorageQuota) SetInstanceLimits(params storagequota.InstanceParams) error {
+	quota.Lock()
+	defer quota.Unlock()
+
+	quota.limits[params.InstanceIdent] = params.Partitions
+
+	return nil
+}
+
+func (quota *testStorageQuota) RemoveInstanceLimits(instanceIdent aostypes.InstanceIdent) error {
+	quota.Lock()
+	defer quota.Unlock()
+
+	delete(quota.limits, instanceIdent)
+
+	return nil
+}
+
+func (quota *testStorageQuota) RemoveUnusedImages(instanceIDs []string) error {
+	quota.Lock()
+	defer quota.Unlock()
+
+	quota.instanceIDs = instanceIDs
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * testClockProvider
+ **********************************************************************************************************************/
+
+func newTestClockProvider() *testClockProvider {
+	return &testClockProvider{syncedChannel: make(chan struct{})}
+}
+
+func (clockProvider *testClockProvider) GetClockSyncedChannel() (channel <-chan struct{}) {
+	return clockProvider.syncedChannel
 }
 
 /***********************************************************************************************************************
@@ -3110,6 +4351,20 @@ func setup() (err error) {
 	launcher.RuntimeDir = filepath.Join(tmpDir, "runtime")
 	launcher.MountFunc = mounter.Mount
 	launcher.UnmountFunc = mounter.Unmount
+	launcher.MountUpperLayerFunc = mounter.MountUpperLayer
+	launcher.MountUpperLayerImageFunc = mounter.MountUpperLayerImage
+	launcher.MountImageFunc = mounter.MountImage
+	launcher.MountVerityImageFunc = mounter.MountVerityImage
+	launcher.CloseVerityImageFunc = mounter.CloseVerityImage
+	launcher.VerifyFSVerityFunc = verifyTestFSVerity
+
+	return nil
+}
+
+func verifyTestFSVerity(dir, rootHash string) error {
+	if rootHash != testVerityRootHash {
+		return aoserrors.Wrap(fsverity.ErrRootHashMismatch)
+	}
 
 	return nil
 }
@@ -3262,6 +4517,16 @@ func checkInstancesByPriority(compInstances, refInstances []aostypes.InstanceInf
 	return nil
 }
 
+func getImageMountPoints(imagePath string) (mountPoints []string) {
+	for mountPoint, mount := range mounter.mounts {
+		if reflect.DeepEqual(mount.lowerDirs, []string{imagePath}) {
+			mountPoints = append(mountPoints, mountPoint)
+		}
+	}
+
+	return mountPoints
+}
+
 func createInstancesStatuses(item testItem) (instancesStatuses []cloudprotocol.InstanceStatus) {
 	for i, instance := range item.instances {
 		instanceStatus := cloudprotocol.InstanceStatus{
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package launcher
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// updatedService service updated to new version which is rolled back if its instances fail within rollback window.
+type updatedService struct {
+	aosVersion uint64
+	updateTime time.Time
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (launcher *Launcher) updateServiceVersions(prevServices map[string]*serviceInfo) {
+	if !launcher.config.Rollback.Enabled {
+		return
+	}
+
+	for serviceID, service := range launcher.currentServices {
+		if updated, ok := launcher.updatedServices[serviceID]; ok && updated.aosVersion == service.AosVersion {
+			continue
+		}
+
+		delete(launcher.updatedServices, serviceID)
+
+		prevService, ok := prevServices[serviceID]
+		if !ok || prevService.AosVersion == 0 || service.AosVersion <= prevService.AosVersion {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"serviceID":   serviceID,
+			"aosVersion":  service.AosVersion,
+			"prevVersion": prevService.AosVersion,
+		}).Debug("Service updated")
+
+		launcher.updatedServices[serviceID] = updatedService{aosVersion: service.AosVersion, updateTime: time.Now()}
+	}
+
+	for serviceID := range launcher.updatedServices {
+		if _, ok := launcher.currentServices[serviceID]; !ok {
+			delete(launcher.updatedServices, serviceID)
+		}
+	}
+}
+
+func (launcher *Launcher) isRollbackCandidate(serviceID string) bool {
+	updated, ok := launcher.updatedServices[serviceID]
+	if !ok {
+		return false
+	}
+
+	// Zero window means updated service is rolled back whenever it fails
+	if window := launcher.config.Rollback.Window.Duration; window > 0 && time.Since(updated.updateTime) > window {
+		delete(launcher.updatedServices, serviceID)
+
+		return false
+	}
+
+	return true
+}
+
+func (launcher *Launcher) handleFailedServices() {
+	launcher.Lock()
+	defer launcher.Unlock()
+
+	instances := launcher.rollbackFailedServices()
+
+	if len(instances) == 0 {
+		return
+	}
+
+	launcher.sendInstancesUpdateStatus(instances)
+}
+
+func (launcher *Launcher) rollbackFailedServices() (instances []*runtimeInstanceInfo) {
+	failedServices := make(map[string]string)
+
+	launcher.runMutex.Lock()
+
+	for _, instance := range launcher.currentInstances {
+		if instance.runStatus.State != cloudprotocol.InstanceStateFailed ||
+			!launcher.isRollbackCandidate(instance.ServiceID) {
+			continue
+		}
+
+		if _, ok := failedServices[instance.ServiceID]; ok {
+			continue
+		}
+
+		reason := "instance failed"
+
+		if errorInfo := instance.getCloudStatus().ErrorInfo; errorInfo != nil && errorInfo.Message != "" {
+			reason = errorInfo.Message
+		}
+
+		failedServices[instance.ServiceID] = reason
+	}
+
+	launcher.runMutex.Unlock()
+
+	for serviceID, reason := range failedServices {
+		startedInstances, err := launcher.rollbackService(serviceID, reason)
+		if err != nil {
+			log.WithField("serviceID", serviceID).Errorf("Can't rollback service: %v", err)
+
+			continue
+		}
+
+		instances = append(instances, startedInstances...)
+	}
+
+	return instances
+}
+
+func (launcher *Launcher) rollbackService(
+	serviceID, reason string,
+) (startedInstances []*runtimeInstanceInfo, err error) {
+	var stopInstances []*runtimeInstanceInfo
+
+	launcher.runMutex.Lock()
+
+	failedVersion := launcher.updatedServices[serviceID].aosVersion
+
+	// Rollback is done once per update: failure of rolled back version is handled as usual
+	delete(launcher.updatedServices, serviceID)
+
+	for _, instance := range launcher.currentInstances {
+		if instance.ServiceID == serviceID {
+			stopInstances = append(stopInstances, instance)
+		}
+	}
+
+	launcher.runMutex.Unlock()
+
+	previousService, err := launcher.serviceProvider.RollbackService(serviceID, failedVersion)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	log.WithFields(log.Fields{
+		"serviceID":     serviceID,
+		"aosVersion":    previousService.AosVersion,
+		"failedVersion": failedVersion,
+	}).Warnf("Rollback failed service: %s", reason)
+
+	launcher.alertSender.SendAlert(serviceRollbackAlert(serviceID, failedVersion, previousService.AosVersion, reason))
+
+	service := launcher.getServiceInfo(serviceID)
+
+	launcher.runMutex.Lock()
+	launcher.currentServices[serviceID] = service
+	launcher.runMutex.Unlock()
+
+	launcher.stopInstances(stopInstances)
+
+	startedInstances = make([]*runtimeInstanceInfo, 0, len(stopInstances))
+
+	for _, instance := range stopInstances {
+		startedInstances = append(startedInstances, newRuntimeInstanceInfo(instance.InstanceInfo))
+	}
+
+	sort.Slice(startedInstances, func(i, j int) bool {
+		return startedInstances[i].Priority > startedInstances[j].Priority
+	})
+
+	launcher.startInstances(startedInstances)
+
+	return startedInstances, nil
+}
+
+func (launcher *Launcher) sendInstancesUpdateStatus(instances []*runtimeInstanceInfo) {
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	updateInstancesStatus := &InstancesStatus{Instances: make([]cloudprotocol.InstanceStatus, 0, len(instances))}
+
+	for _, instance := range instances {
+		updateInstancesStatus.Instances = append(updateInstancesStatus.Instances, instance.getCloudStatus())
+	}
+
+	launcher.runtimeStatusChannel <- RuntimeStatus{UpdateStatus: updateInstancesStatus}
+}
+
+func serviceRollbackAlert(serviceID string, failedVersion, aosVersion uint64, reason string) cloudprotocol.AlertItem {
+	return cloudprotocol.AlertItem{
+		Timestamp: time.Now(),
+		Tag:       cloudprotocol.AlertTagServiceInstance,
+		Payload: cloudprotocol.ServiceInstanceAlert{
+			InstanceIdent: aostypes.InstanceIdent{ServiceID: serviceID},
+			AosVersion:    failedVersion,
+			Message: fmt.Sprintf("Service version %d failed and rolled back to version %d: %s",
+				failedVersion, aosVersion, reason),
+		},
+	}
+}
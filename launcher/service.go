@@ -25,6 +25,7 @@ import (
 	"github.com/aoscloud/aos_common/aostypes"
 	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 
+	"github.com/aoscloud/aos_servicemanager/healthcheck"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
 )
 
@@ -32,9 +33,23 @@ import (
  * Types
  **********************************************************************************************************************/
 
+// serviceConfig extends Aos service config with SM specific parameters.
+type serviceConfig struct {
+	aostypes.ServiceConfig
+	HealthCheck    *healthcheck.Config   `json:"healthCheck,omitempty"`
+	WritableRootFS *writableRootFSConfig `json:"writableRootfs,omitempty"`
+	StopParameters *stopParametersConfig `json:"stopParameters,omitempty"`
+}
+
+// stopParametersConfig instance graceful stop parameters.
+type stopParametersConfig struct {
+	Signal  string            `json:"signal,omitempty"`
+	Timeout aostypes.Duration `json:"timeout,omitempty"`
+}
+
 type serviceInfo struct {
 	servicemanager.ServiceInfo
-	serviceConfig *aostypes.ServiceConfig
+	serviceConfig *serviceConfig
 	imageConfig   *imagespec.Image
 	err           error
 }
@@ -53,7 +68,7 @@ func (launcher *Launcher) cacheCurrentServices(instances []InstanceInfo) {
 	launcher.runMutex.Lock()
 	defer launcher.runMutex.Unlock()
 
-	now := time.Now()
+	prevServices := launcher.currentServices
 
 	launcher.currentServices = make(map[string]*serviceInfo)
 
@@ -62,35 +77,41 @@ func (launcher *Launcher) cacheCurrentServices(instances []InstanceInfo) {
 			continue
 		}
 
-		var service serviceInfo
+		launcher.currentServices[instance.ServiceID] = launcher.getServiceInfo(instance.ServiceID)
+	}
 
-		if service.ServiceInfo, service.err = launcher.serviceProvider.GetServiceInfo(
-			instance.ServiceID); errors.Is(service.err, servicemanager.ErrNotExist) {
-			service.ServiceID = instance.ServiceID
-			service.AosVersion = 0
-		}
+	launcher.updateServiceVersions(prevServices)
+}
 
-		if service.err == nil {
-			service.serviceConfig, service.err = launcher.getServiceConfig(service.ServiceInfo)
-		}
+func (launcher *Launcher) getServiceInfo(serviceID string) *serviceInfo {
+	var service serviceInfo
 
-		if service.err == nil {
-			if service.serviceConfig.OfflineTTL.Duration != 0 &&
-				launcher.onlineTime.Add(service.serviceConfig.OfflineTTL.Duration).Before(now) {
-				service.err = errOfflineTimeout
-			}
-		}
+	if service.ServiceInfo, service.err = launcher.serviceProvider.GetServiceInfo(
+		serviceID); errors.Is(service.err, servicemanager.ErrNotExist) {
+		service.ServiceID = serviceID
+		service.AosVersion = 0
+	}
 
-		if service.err == nil {
-			service.imageConfig, service.err = launcher.getImageConfig(service.ServiceInfo)
-		}
+	if service.err == nil {
+		service.serviceConfig, service.err = launcher.getServiceConfig(service.ServiceInfo)
+	}
 
-		if service.err == nil {
-			service.err = launcher.serviceProvider.ValidateService(service.ServiceInfo)
+	if service.err == nil && launcher.isClockSynced() {
+		if service.serviceConfig.OfflineTTL.Duration != 0 &&
+			launcher.onlineTime.Add(service.serviceConfig.OfflineTTL.Duration).Before(time.Now()) {
+			service.err = errOfflineTimeout
 		}
+	}
+
+	if service.err == nil {
+		service.imageConfig, service.err = launcher.getImageConfig(service.ServiceInfo)
+	}
 
-		launcher.currentServices[instance.ServiceID] = &service
+	if service.err == nil {
+		service.err = launcher.serviceProvider.ValidateService(service.ServiceInfo)
 	}
+
+	return &service
 }
 
 func (launcher *Launcher) getCurrentServiceInfo(serviceID string) (*serviceInfo, error) {
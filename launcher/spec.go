@@ -513,22 +513,22 @@ func (launcher *Launcher) getImageConfig(service servicemanager.ServiceInfo) (*i
 	return &imageConfig, nil
 }
 
-func (launcher *Launcher) getServiceConfig(service servicemanager.ServiceInfo) (*aostypes.ServiceConfig, error) {
+func (launcher *Launcher) getServiceConfig(service servicemanager.ServiceInfo) (*serviceConfig, error) {
 	imageParts, err := launcher.serviceProvider.GetImageParts(service)
 	if err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
-	var serviceConfig aostypes.ServiceConfig
+	var config serviceConfig
 
 	if imageParts.ServiceConfigPath != "" {
 		if err = getJSONFromFile(
-			imageParts.ServiceConfigPath, &serviceConfig); err != nil && !errors.Is(err, os.ErrNotExist) {
+			imageParts.ServiceConfigPath, &config); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return nil, err
 		}
 	}
 
-	return &serviceConfig, nil
+	return &config, nil
 }
 
 func (launcher *Launcher) createRuntimeSpec(instance *runtimeInstanceInfo) (*runtimeSpec, error) {
@@ -569,6 +569,10 @@ func (launcher *Launcher) createRuntimeSpec(instance *runtimeInstanceInfo) (*run
 		}
 	}
 
+	if err := launcher.setStorageLimits(instance); err != nil {
+		return nil, err
+	}
+
 	if err := spec.setUserUIDGID(instance.UID, instance.service.GID); err != nil {
 		return nil, err
 	}
@@ -577,7 +581,7 @@ func (launcher *Launcher) createRuntimeSpec(instance *runtimeInstanceInfo) (*run
 		return nil, err
 	}
 
-	if err := spec.applyServiceConfig(instance.service.serviceConfig); err != nil {
+	if err := spec.applyServiceConfig(&instance.service.serviceConfig.ServiceConfig); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/utils/fs"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	instanceUpperLayerDir = "upperlayer"
+	upperLayerUpperDir    = "upper"
+	upperLayerWorkDir     = "work"
+	upperLayersDir        = "upperlayers"
+	upperLayerImageExt    = ".img"
+)
+
+const defaultUpperLayerSize = 64 * 1024 * 1024
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// writableRootFSConfig writable root FS service config.
+type writableRootFSConfig struct {
+	Persistent bool   `json:"persistent,omitempty"`
+	SizeLimit  uint64 `json:"sizeLimit,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// MountUpperLayerFunc mounts size limited FS for instance ephemeral upper layer.
+//
+//nolint:gochecknoglobals
+var MountUpperLayerFunc = mountTmpFS
+
+// MountUpperLayerImageFunc mounts disk image of instance persistent upper layer. Image is created if it doesn't exist.
+//
+//nolint:gochecknoglobals
+var MountUpperLayerImageFunc = mountUpperLayerImage
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (launcher *Launcher) prepareUpperLayer(instance *runtimeInstanceInfo) (upperDir, workDir string, err error) {
+	writableConfig := instance.service.serviceConfig.WritableRootFS
+	upperLayerDir := filepath.Join(instance.runtimeDir, instanceUpperLayerDir)
+
+	// Upper layer which is left from previous run is always remounted, persistent data is kept on disk image only
+	if err = releaseUpperLayer(upperLayerDir); err != nil {
+		return "", "", err
+	}
+
+	imagePath := launcher.getUpperLayerImagePath(instance.InstanceID)
+
+	if writableConfig == nil || !writableConfig.Persistent {
+		if err = os.RemoveAll(imagePath); err != nil {
+			return "", "", aoserrors.Wrap(err)
+		}
+	}
+
+	if writableConfig == nil {
+		return "", "", nil
+	}
+
+	sizeLimit := writableConfig.SizeLimit
+	if sizeLimit == 0 {
+		sizeLimit = defaultUpperLayerSize
+	}
+
+	log.WithFields(instanceLogFields(instance, nil)).WithFields(log.Fields{
+		"persistent": writableConfig.Persistent, "sizeLimit": sizeLimit,
+	}).Debug("Mount upper layer")
+
+	if err = os.MkdirAll(upperLayerDir, 0o755); err != nil {
+		return "", "", aoserrors.Wrap(err)
+	}
+
+	if writableConfig.Persistent {
+		err = MountUpperLayerImageFunc(imagePath, upperLayerDir, sizeLimit)
+	} else {
+		err = MountUpperLayerFunc(upperLayerDir, sizeLimit)
+	}
+
+	if err != nil {
+		os.RemoveAll(upperLayerDir)
+
+		return "", "", aoserrors.Wrap(err)
+	}
+
+	upperDir = filepath.Join(upperLayerDir, upperLayerUpperDir)
+	workDir = filepath.Join(upperLayerDir, upperLayerWorkDir)
+
+	for _, dir := range []string{upperDir, workDir} {
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			return "", "", aoserrors.Wrap(err)
+		}
+	}
+
+	return upperDir, workDir, nil
+}
+
+func (launcher *Launcher) getUpperLayerImagePath(instanceID string) string {
+	return filepath.Join(launcher.config.WorkingDir, upperLayersDir, instanceID+upperLayerImageExt)
+}
+
+// removeStaleUpperLayers removes persistent upper layer images of instances which are not in the list.
+func (launcher *Launcher) removeStaleUpperLayers(instanceIDs []string) error {
+	imagesDir := filepath.Join(launcher.config.WorkingDir, upperLayersDir)
+
+	entries, err := os.ReadDir(imagesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		instanceID := strings.TrimSuffix(entry.Name(), upperLayerImageExt)
+
+		if slices.Contains(instanceIDs, instanceID) {
+			continue
+		}
+
+		log.WithField("instanceID", instanceID).Debug("Remove persistent upper layer")
+
+		if err = os.RemoveAll(filepath.Join(imagesDir, entry.Name())); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func (launcher *Launcher) removeStaleRuntimeDirs() (err error) {
+	entries, readErr := os.ReadDir(RuntimeDir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil
+		}
+
+		return aoserrors.Wrap(readErr)
+	}
+
+	launcher.runMutex.Lock()
+	defer launcher.runMutex.Unlock()
+
+	for _, entry := range entries {
+		if _, ok := launcher.currentInstances[entry.Name()]; ok || !entry.IsDir() || entry.Name() == sharedImagesDir {
+			continue
+		}
+
+		log.WithField("instanceID", entry.Name()).Debug("Remove stale runtime dir")
+
+		if releaseErr := launcher.releaseImageLayers(entry.Name()); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+
+		if removeErr := removeInstanceRuntimeDir(
+			filepath.Join(RuntimeDir, entry.Name())); removeErr != nil && err == nil {
+			err = removeErr
+		}
+	}
+
+	if removeErr := launcher.removeStaleImageMounts(); removeErr != nil && err == nil {
+		err = removeErr
+	}
+
+	return err
+}
+
+func removeInstanceRuntimeDir(runtimeDir string) (err error) {
+	if releaseErr := releaseUpperLayer(
+		filepath.Join(runtimeDir, instanceUpperLayerDir)); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+
+	if removeErr := os.RemoveAll(runtimeDir); removeErr != nil && err == nil {
+		err = aoserrors.Wrap(removeErr)
+	}
+
+	return err
+}
+
+func releaseUpperLayer(upperLayerDir string) error {
+	if _, err := os.Stat(upperLayerDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	if err := UnmountFunc(upperLayerDir); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.RemoveAll(upperLayerDir))
+}
+
+func mountTmpFS(mountPoint string, size uint64) error {
+	return aoserrors.Wrap(fs.Mount("tmpfs", mountPoint, "tmpfs", 0, fmt.Sprintf("size=%d", size)))
+}
+
+func mountUpperLayerImage(imagePath, mountPoint string, size uint64) error {
+	createImage := false
+
+	fileInfo, err := os.Stat(imagePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return aoserrors.Wrap(err)
+		}
+
+		createImage = true
+	} else if uint64(fileInfo.Size()) != size {
+		// Size limit is changed by service update, ext4 image can't be shrunk in place, so it is recreated
+		log.WithFields(log.Fields{
+			"image": imagePath, "size": fileInfo.Size(), "sizeLimit": size,
+		}).Warn("Upper layer size limit changed, recreate image")
+
+		if err = os.Remove(imagePath); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		createImage = true
+	}
+
+	if createImage {
+		if err = os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if err = fsimage.CreateWritable(imagePath, size); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return aoserrors.Wrap(fsimage.MountWritable(imagePath, mountPoint))
+}
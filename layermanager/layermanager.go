@@ -22,20 +22,37 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	"github.com/aoscloud/aos_common/image"
 	"github.com/aoscloud/aos_common/spaceallocator"
+	"github.com/aoscloud/aos_common/utils/action"
+	"github.com/opencontainers/go-digest"
 	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
+	"github.com/aoscloud/aos_servicemanager/blobstore"
+	"github.com/aoscloud/aos_servicemanager/clocksync"
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/contentreceiver"
+	"github.com/aoscloud/aos_servicemanager/downloader"
+	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/signature"
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+	"github.com/aoscloud/aos_servicemanager/utils/ocilayer"
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
 	"github.com/aoscloud/aos_servicemanager/utils/whiteouts"
 )
 
@@ -45,13 +62,19 @@ import (
 
 const (
 	layerOCIDescriptor = "layer.json"
+	layerTreeSuffix    = ".tree"
 )
 
 /***********************************************************************************************************************
  * Vars
  **********************************************************************************************************************/
 
-var ErrNotExist = errors.New("layer does not exist")
+var (
+	// ErrNotExist layer does not exist.
+	ErrNotExist = errors.New("layer does not exist")
+	// ErrLayerQuarantined layer content is corrupted and waits for re-delivery.
+	ErrLayerQuarantined = errors.New("layer quarantined")
+)
 
 // NewSpaceAllocator space allocator constructor.
 //
@@ -76,13 +99,22 @@ type SpaceAllocator interface {
 type LayerManager struct {
 	sync.Mutex
 	layerStorage           LayerStorage
+	contentProvider        ContentProvider
+	signatureVerifier      SignatureVerifier
+	downloader             Downloader
+	blobStore              BlobStore
+	actionHandler          *action.Handler
 	layersDir              string
 	extractDir             string
+	imageFormat            string
 	downloadDir            string
 	layerTTLDays           uint64
+	remoteNode             bool
+	contentTimeout         time.Duration
 	layerAllocator         spaceallocator.Allocator
 	downloadAllocator      spaceallocator.Allocator
 	extractAllocator       spaceallocator.Allocator
+	clockSyncedChannel     <-chan struct{}
 	validateTTLStopChannel chan struct{}
 }
 
@@ -93,35 +125,92 @@ type LayerStorage interface {
 	GetLayersInfo() ([]LayerInfo, error)
 	GetLayerInfoByDigest(digest string) (LayerInfo, error)
 	SetLayerCached(digest string, cached bool) error
+	SetLayerQuarantined(digest string, quarantined bool) error
+}
+
+// ContentProvider provides image content received from CM.
+type ContentProvider interface {
+	GetImageContent(ctx context.Context, url, contentType, destination string) (fileNames []string, err error)
+}
+
+// ClockProvider provides system clock synchronization state.
+type ClockProvider interface {
+	GetClockSyncedChannel() (channel <-chan struct{})
+}
+
+// SignatureVerifier verifies package signatures.
+type SignatureVerifier interface {
+	VerifyFile(fileName string) (status string, err error)
+}
+
+// Downloader downloads layer packages.
+type Downloader interface {
+	Download(ctx context.Context, url string, target downloader.TargetInfo) (fileName string, err error)
+}
+
+// BlobStore stores layer blobs shared with services.
+type BlobStore interface {
+	AddBlob(blobDigest digest.Digest, owner, sourcePath string) (blobPath string, size uint64, err error)
+	ReleaseBlobs(owner string) error
+	IsBlobPath(path string) bool
+	SetOwnerOutdated(owner string, timestamp time.Time, remover blobstore.OwnerRemover)
+	RestoreOwner(owner string)
 }
 
 // LayerInfo layer information.
 type LayerInfo struct {
 	aostypes.VersionInfo
-	Digest    string
-	LayerID   string
-	Path      string
-	OSVersion string
-	Timestamp time.Time
-	Cached    bool
-	Size      uint64
+	Digest          string
+	LayerID         string
+	Path            string
+	OSVersion       string
+	Timestamp       time.Time
+	Cached          bool
+	Size            uint64
+	SignatureStatus string
+	ContentDigest   string
+	Quarantined     bool
 }
 
 /**********************************************************************************************************************
  * Public
  **********************************************************************************************************************/
 // New creates new layer manager instance.
-func New(config *config.Config, layerStorage LayerStorage) (layermanager *LayerManager, err error) {
+func New(
+	config *config.Config, layerStorage LayerStorage, contentProvider ContentProvider,
+	clockProvider ClockProvider, signatureVerifier SignatureVerifier, packageDownloader Downloader,
+	blobStore BlobStore,
+) (layermanager *LayerManager, err error) {
 	layermanager = &LayerManager{
 		layersDir:              config.LayersDir,
 		layerStorage:           layerStorage,
+		contentProvider:        contentProvider,
+		signatureVerifier:      signatureVerifier,
+		downloader:             packageDownloader,
+		blobStore:              blobStore,
+		actionHandler:          action.New(config.MaxParallelInstalls),
 		extractDir:             config.ExtractDir,
+		imageFormat:            config.ImageFormat,
 		downloadDir:            config.DownloadDir,
 		layerTTLDays:           config.LayerTTLDays,
+		remoteNode:             config.RemoteNode,
+		contentTimeout:         config.Download.Timeout.Duration,
+		clockSyncedChannel:     clocksync.GetSyncedChannel(clockProvider),
 		validateTTLStopChannel: make(chan struct{}),
 	}
 
-	if err := os.RemoveAll(layermanager.downloadDir); err != nil {
+	if layermanager.contentTimeout == 0 {
+		layermanager.contentTimeout = contentreceiver.DefaultRequestTimeout
+	}
+
+	if layermanager.imageFormat != "" {
+		if _, err := fsimage.GetMediaType(layermanager.imageFormat); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	// Keep partial downloads to resume them
+	if err := downloader.CleanDownloadDir(layermanager.downloadDir); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
@@ -142,12 +231,16 @@ func New(config *config.Config, layerStorage LayerStorage) (layermanager *LayerM
 	}
 
 	if layermanager.layerAllocator, err = NewSpaceAllocator(
-		layermanager.layersDir, config.LayersPartLimit, layermanager.removeLayer); err != nil {
+		layermanager.layersDir, config.LayersPartLimit, layermanager.removeOutdatedLayer); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
 	if layermanager.downloadAllocator, err = NewSpaceAllocator(
-		layermanager.downloadDir, 0, nil); err != nil {
+		layermanager.downloadDir, 0, layermanager.removeOutdatedDownload); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = downloader.AddPartialFiles(layermanager.downloadDir, layermanager.downloadAllocator); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
@@ -164,11 +257,16 @@ func New(config *config.Config, layerStorage LayerStorage) (layermanager *LayerM
 		return nil, aoserrors.Wrap(err)
 	}
 
-	if err := layermanager.removeOutdatedLayers(); err != nil {
-		log.Errorf("Can't remove outdated layers: %v", err)
+	// Layers TTL can't be checked until system clock is synchronized
+	clockSynced := layermanager.isClockSynced()
+
+	if clockSynced {
+		if err := layermanager.removeOutdatedLayers(); err != nil {
+			log.Errorf("Can't remove outdated layers: %v", err)
+		}
 	}
 
-	go layermanager.validateTTLs()
+	go layermanager.validateTTLs(clockSynced)
 
 	return layermanager, nil
 }
@@ -199,21 +297,76 @@ func (layermanager *LayerManager) GetLayerInfoByDigest(digest string) (layer Lay
 	return layer, nil
 }
 
-// ProcessDesiredLayers installs, removes, restores desired layers on the system.
-func (layermanager *LayerManager) ProcessDesiredLayers(desiredLayers []aostypes.LayerInfo) error {
+// GetLayersInfo returns information of all installed layers.
+func (layermanager *LayerManager) GetLayersInfo() (layers []LayerInfo, err error) {
+	if layers, err = layermanager.layerStorage.GetLayersInfo(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return layers, nil
+}
+
+// ProcessDesiredLayers installs, removes, restores desired layers on the system. It returns status of each desired
+// layer and error if any of them failed.
+func (layermanager *LayerManager) ProcessDesiredLayers(
+	desiredLayers []aostypes.LayerInfo,
+) (statuses []cloudprotocol.LayerStatus, err error) {
 	layers, err := layermanager.layerStorage.GetLayersInfo()
 	if err != nil {
-		return aoserrors.Wrap(err)
+		return nil, aoserrors.Wrap(err)
 	}
 
-	if desiredLayers, err = layermanager.updateCachedLayers(desiredLayers, layers); err != nil {
-		return err
+	installLayers, err := layermanager.updateCachedLayers(append([]aostypes.LayerInfo(nil), desiredLayers...), layers)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desiredLayer := range desiredLayers {
+		if !slices.ContainsFunc(installLayers, func(layer aostypes.LayerInfo) bool {
+			return layer.Digest == desiredLayer.Digest
+		}) {
+			statuses = append(statuses, cloudprotocol.LayerStatus{
+				ID: desiredLayer.ID, AosVersion: desiredLayer.AosVersion, Digest: desiredLayer.Digest,
+				Status: servicemanager.StatusCached,
+			})
+		}
 	}
 
-	if err = layermanager.installLayers(desiredLayers); err != nil {
+	installStatuses, err := layermanager.installLayers(installLayers)
+
+	return append(statuses, installStatuses...), err
+}
+
+// VerifyLayer verifies stored layer content. Read rate is limited by the limiter.
+func (layermanager *LayerManager) VerifyLayer(layer LayerInfo, limiter *ratelimit.Limiter) error {
+	// Layers installed before content digest was introduced can't be verified
+	if layer.ContentDigest == "" {
+		log.WithField("digest", layer.Digest).Debug("Skip verification of layer without content digest")
+
+		return nil
+	}
+
+	contentDigest, err := calculateContentDigest(layer.Path, limiter)
+	if err != nil {
 		return err
 	}
 
+	if contentDigest.String() != layer.ContentDigest {
+		return aoserrors.New("layer content digest mismatch")
+	}
+
+	return nil
+}
+
+// QuarantineLayer marks layer as corrupted. Quarantined layer can't be used and it is removed on next desired layers
+// processing in order to be installed again.
+func (layermanager *LayerManager) QuarantineLayer(layer LayerInfo) error {
+	log.WithField("digest", layer.Digest).Warn("Quarantine layer")
+
+	if err := layermanager.layerStorage.SetLayerQuarantined(layer.Digest, true); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
 	return nil
 }
 
@@ -221,14 +374,38 @@ func (layermanager *LayerManager) ProcessDesiredLayers(desiredLayers []aostypes.
  * Private
  **********************************************************************************************************************/
 
-func (layermanager *LayerManager) installLayers(desiredLayers []aostypes.LayerInfo) error {
-	for _, desiredLayer := range desiredLayers {
-		if err := layermanager.installLayer(desiredLayer); err != nil {
-			return err
+func (layermanager *LayerManager) installLayers(
+	desiredLayers []aostypes.LayerInfo,
+) (statuses []cloudprotocol.LayerStatus, err error) {
+	statuses = make([]cloudprotocol.LayerStatus, len(desiredLayers))
+	installErrors := make([]error, len(desiredLayers))
+
+	for i, desiredLayer := range desiredLayers {
+		i, desiredLayer := i, desiredLayer
+
+		statuses[i] = cloudprotocol.LayerStatus{
+			ID: desiredLayer.ID, AosVersion: desiredLayer.AosVersion, Digest: desiredLayer.Digest,
+			Status: cloudprotocol.InstalledStatus,
 		}
+
+		// Layers with the same digest share extract and store dirs, so they are installed sequentially
+		layermanager.actionHandler.Execute(desiredLayer.Digest, func(digest string) error {
+			if installErrors[i] = layermanager.installLayer(desiredLayer); installErrors[i] != nil {
+				statuses[i].Status = cloudprotocol.ErrorStatus
+				statuses[i].ErrorInfo = &cloudprotocol.ErrorInfo{Message: installErrors[i].Error()}
+			}
+
+			return installErrors[i]
+		})
 	}
 
-	return nil
+	layermanager.actionHandler.Wait()
+
+	if err = errors.Join(installErrors...); err != nil {
+		return statuses, aoserrors.Wrap(err)
+	}
+
+	return statuses, nil
 }
 
 func (layermanager *LayerManager) updateCachedLayers(
@@ -236,6 +413,22 @@ func (layermanager *LayerManager) updateCachedLayers(
 ) (installLayers []aostypes.LayerInfo, err error) {
 nextLayer:
 	for _, storeLayer := range storeLayers {
+		// Quarantined layer is removed to be installed again if it is still desired. Instances which use it are
+		// stopped on quarantine.
+		if storeLayer.Quarantined {
+			log.WithField("digest", storeLayer.Digest).Warn("Remove quarantined layer")
+
+			if err := layermanager.removeLayer(storeLayer.Digest); err != nil {
+				return desiredLayers, err
+			}
+
+			if storeLayer.Cached {
+				layermanager.layerAllocator.RestoreOutdatedItem(storeLayer.Digest)
+			}
+
+			continue
+		}
+
 		for i, desiredLayer := range desiredLayers {
 			if desiredLayer.Digest != storeLayer.Digest {
 				continue
@@ -294,7 +487,17 @@ func (layermanager *LayerManager) installLayer(
 		return aoserrors.Wrap(err)
 	}
 
-	spaceLayer, err := layermanager.layerAllocator.AllocateSpace(uint64(layerDescriptor.Size))
+	signatureStatus, err := layermanager.verifySignature(layerDescriptor, extractLayerDir, layerPath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	layerSize, err := getLayerSize(layerPath, layerDescriptor.MediaType)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	spaceLayer, err := layermanager.layerAllocator.AllocateSpace(uint64(layerSize))
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -305,6 +508,7 @@ func (layermanager *LayerManager) installLayer(
 	defer func() {
 		if err != nil {
 			releaseAllocatedSpace(storeLayerPath, spaceLayer)
+			layermanager.releaseLayerBlobs(layerInfo.Digest)
 
 			log.WithFields(log.Fields{
 				"id":         layerInfo.ID,
@@ -320,7 +524,22 @@ func (layermanager *LayerManager) installLayer(
 		}
 	}()
 
-	if err = unpackLayer(layerPath, storeLayerPath); err != nil {
+	if layerSize, err = layermanager.storeLayer(layerPath, storeLayerPath, layerDescriptor, layerSize); err != nil {
+		return err
+	}
+
+	if storeLayerPath, err = layermanager.storeLayerBlob(
+		layerInfo.Digest, layerDescriptor.Digest, storeLayerPath); err != nil {
+		return err
+	}
+
+	// Layer space is accounted by the blob store
+	if layermanager.blobStore != nil {
+		layerSize = 0
+	}
+
+	contentDigest, err := calculateContentDigest(storeLayerPath, nil)
+	if err != nil {
 		return err
 	}
 
@@ -331,13 +550,15 @@ func (layermanager *LayerManager) installLayer(
 	}
 
 	if err = layermanager.layerStorage.AddLayer(LayerInfo{
-		LayerID:     layerInfo.ID,
-		Digest:      layerInfo.Digest,
-		Path:        storeLayerPath,
-		OSVersion:   osVersion,
-		Size:        uint64(layerDescriptor.Size),
-		VersionInfo: layerInfo.VersionInfo,
-		Timestamp:   time.Now().UTC(),
+		LayerID:         layerInfo.ID,
+		Digest:          layerInfo.Digest,
+		Path:            storeLayerPath,
+		OSVersion:       osVersion,
+		Size:            uint64(layerSize),
+		VersionInfo:     layerInfo.VersionInfo,
+		Timestamp:       time.Now().UTC(),
+		SignatureStatus: signatureStatus,
+		ContentDigest:   contentDigest.String(),
 	}); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -346,11 +567,34 @@ func (layermanager *LayerManager) installLayer(
 		"id":         layerInfo.ID,
 		"aosVersion": layerInfo.AosVersion,
 		"digest":     layerInfo.Digest,
+		"signature":  signatureStatus,
 	}).Info("Layer successfully installed")
 
 	return nil
 }
 
+func (layermanager *LayerManager) verifySignature(
+	layerDescriptor imagespec.Descriptor, extractDir, layerPath string,
+) (status string, err error) {
+	if layermanager.signatureVerifier == nil {
+		return "", nil
+	}
+
+	if status, err = layermanager.signatureVerifier.VerifyFile(
+		filepath.Join(extractDir, layerOCIDescriptor)); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	// Signed descriptor protects layer content only if layer matches descriptor digest
+	if status == signature.StatusVerified {
+		if err = verifyLayerDigest(layerDescriptor, layerPath); err != nil {
+			return "", err
+		}
+	}
+
+	return status, nil
+}
+
 func (layermanager *LayerManager) setLayerCached(layer LayerInfo, cached bool) error {
 	if err := layermanager.layerStorage.SetLayerCached(layer.Digest, cached); err != nil {
 		return aoserrors.Wrap(err)
@@ -362,21 +606,48 @@ func (layermanager *LayerManager) setLayerCached(layer LayerInfo, cached bool) e
 			return aoserrors.Wrap(err)
 		}
 
+		layermanager.setLayerBlobsOutdated(layer)
+
 		return nil
 	}
 
 	layermanager.layerAllocator.RestoreOutdatedItem(layer.Digest)
 
+	if layermanager.blobStore != nil {
+		layermanager.blobStore.RestoreOwner(getLayerBlobOwner(layer.Digest))
+	}
+
 	return nil
 }
 
-func (layermanager *LayerManager) validateTTLs() {
+func (layermanager *LayerManager) validateTTLs(clockSynced bool) {
 	removeTicker := time.NewTicker(RemoveCachedLayersPeriod)
 	defer removeTicker.Stop()
 
+	clockSyncedChannel := layermanager.clockSyncedChannel
+
+	if clockSynced {
+		clockSyncedChannel = nil
+	}
+
 	for {
 		select {
+		case <-clockSyncedChannel:
+			clockSyncedChannel = nil
+
+			log.Debug("Clock synced, check layers TTL")
+
+			if err := layermanager.removeOutdatedLayers(); err != nil {
+				log.Errorf("Can't remove outdated layers: %v", err)
+			}
+
 		case <-removeTicker.C:
+			if !layermanager.isClockSynced() {
+				log.Warn("Clock is not synced, skip layers TTL check")
+
+				continue
+			}
+
 			if err := layermanager.removeOutdatedLayers(); err != nil {
 				log.Errorf("Can't remove outdated layers: %v", err)
 			}
@@ -387,6 +658,16 @@ func (layermanager *LayerManager) validateTTLs() {
 	}
 }
 
+func (layermanager *LayerManager) isClockSynced() bool {
+	select {
+	case <-layermanager.clockSyncedChannel:
+		return true
+
+	default:
+		return false
+	}
+}
+
 func (layermanager *LayerManager) removeOutdatedLayers() error {
 	layers, err := layermanager.layerStorage.GetLayersInfo()
 	if err != nil {
@@ -407,16 +688,33 @@ func (layermanager *LayerManager) removeOutdatedLayers() error {
 	return nil
 }
 
+// removeOutdatedLayer removes outdated layer. Layer may be already removed together with its blobs by the blob store.
+func (layermanager *LayerManager) removeOutdatedLayer(digest string) error {
+	if err := layermanager.removeLayer(digest); err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (layermanager *LayerManager) removeOutdatedDownload(id string) error {
+	return aoserrors.Wrap(downloader.RemovePartialFile(layermanager.downloadDir, id))
+}
+
 func (layermanager *LayerManager) removeLayer(digest string) error {
 	layer, err := layermanager.layerStorage.GetLayerInfoByDigest(digest)
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
 
-	if err = os.RemoveAll(layer.Path); err != nil {
-		return aoserrors.Wrap(err)
+	if layermanager.blobStore == nil || !layermanager.blobStore.IsBlobPath(layer.Path) {
+		if err = os.RemoveAll(layer.Path); err != nil {
+			return aoserrors.Wrap(err)
+		}
 	}
 
+	layermanager.releaseLayerBlobs(digest)
+
 	if err = layermanager.layerStorage.DeleteLayerByDigest(digest); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -426,6 +724,91 @@ func (layermanager *LayerManager) removeLayer(digest string) error {
 	return nil
 }
 
+// storeLayer stores layer content and returns its size. Layer is stored as unpacked tree or, if image format is
+// configured, as read-only image. Layers delivered as images are stored as is.
+func (layermanager *LayerManager) storeLayer(
+	source, destination string, layerDescriptor imagespec.Descriptor, layerSize int64,
+) (storedSize int64, err error) {
+	if fsimage.IsImageMediaType(layerDescriptor.MediaType) {
+		if err = os.MkdirAll(filepath.Dir(destination), 0o755); err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
+
+		if _, err = image.Copy(destination, source); err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
+
+		return layerSize, nil
+	}
+
+	if layermanager.imageFormat == "" {
+		return layerSize, unpackLayer(source, destination, layerDescriptor)
+	}
+
+	// Layer is unpacked next to the image to have its space accounted by layer allocator
+	treePath := destination + layerTreeSuffix
+	defer os.RemoveAll(treePath)
+
+	if err = unpackLayer(source, treePath, layerDescriptor); err != nil {
+		return 0, err
+	}
+
+	if err = fsimage.Create(layermanager.imageFormat, treePath, destination); err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	fileInfo, err := os.Stat(destination)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	// Space is allocated for unpacked layer, image is usually smaller
+	if fileInfo.Size() < layerSize {
+		layermanager.layerAllocator.FreeSpace(uint64(layerSize - fileInfo.Size()))
+	}
+
+	return fileInfo.Size(), nil
+}
+
+// storeLayerBlob moves unpacked layer into the blob store and returns the layer path in the store.
+func (layermanager *LayerManager) storeLayerBlob(
+	layerDigest string, blobDigest digest.Digest, layerPath string,
+) (storeLayerPath string, err error) {
+	if layermanager.blobStore == nil {
+		return layerPath, nil
+	}
+
+	blobPath, size, err := layermanager.blobStore.AddBlob(blobDigest, getLayerBlobOwner(layerDigest), layerPath)
+	if err != nil {
+		return layerPath, aoserrors.Wrap(err)
+	}
+
+	// Layer is moved to the store or removed as duplicate, its space is accounted by the store
+	layermanager.layerAllocator.FreeSpace(size)
+
+	return blobPath, nil
+}
+
+func (layermanager *LayerManager) setLayerBlobsOutdated(layer LayerInfo) {
+	if layermanager.blobStore == nil {
+		return
+	}
+
+	layermanager.blobStore.SetOwnerOutdated(getLayerBlobOwner(layer.Digest), layer.Timestamp, func() error {
+		return layermanager.removeOutdatedLayer(layer.Digest)
+	})
+}
+
+func (layermanager *LayerManager) releaseLayerBlobs(layerDigest string) {
+	if layermanager.blobStore == nil {
+		return
+	}
+
+	if err := layermanager.blobStore.ReleaseBlobs(getLayerBlobOwner(layerDigest)); err != nil {
+		log.WithField("digest", layerDigest).Errorf("Can't release layer blobs: %v", err)
+	}
+}
+
 func (layermanager *LayerManager) setOutdatedLayers() error {
 	layersInfo, err := layermanager.layerStorage.GetLayersInfo()
 	if err != nil {
@@ -438,6 +821,8 @@ func (layermanager *LayerManager) setOutdatedLayers() error {
 				layer.Digest, layer.Size, layer.Timestamp); err != nil {
 				return aoserrors.Wrap(err)
 			}
+
+			layermanager.setLayerBlobsOutdated(layer)
 		}
 	}
 
@@ -452,12 +837,14 @@ func (layermanager *LayerManager) removeDamagedLayerFolders() error {
 
 	for _, layer := range layersInfo {
 		fi, err := os.Stat(layer.Path)
-		if err != nil || !fi.Mode().IsDir() {
+		if err != nil || (!fi.Mode().IsDir() && !fi.Mode().IsRegular()) {
 			log.Warnf("Layer missing: %v", layer.Path)
 
 			if err = layermanager.layerStorage.DeleteLayerByDigest(layer.Digest); err != nil {
 				return aoserrors.Wrap(err)
 			}
+
+			layermanager.releaseLayerBlobs(layer.Digest)
 		}
 	}
 
@@ -506,7 +893,13 @@ func (layermanager *LayerManager) extractPackageByURL(
 	var sourceFile string
 
 	if urlVal.Scheme != "file" {
-		spaceDownload, err := layermanager.downloadAllocator.AllocateSpace(layerInfo.Size)
+		// Partial download of the layer already takes space on the disk
+		partialSize := downloader.ReservePartialFiles(
+			layermanager.downloadDir, layermanager.downloadAllocator, layerInfo.URL)
+		defer downloader.ReleasePartialFiles(layermanager.downloadDir, layermanager.downloadAllocator, layerInfo.URL)
+
+		spaceDownload, err := layermanager.downloadAllocator.AllocateSpace(
+			layerInfo.Size - min(partialSize, layerInfo.Size))
 		if err != nil {
 			return layerDescriptor, nil, aoserrors.Wrap(err)
 		}
@@ -517,7 +910,12 @@ func (layermanager *LayerManager) extractPackageByURL(
 			}
 		}()
 
-		if sourceFile, err = image.Download(context.Background(), layermanager.downloadDir, layerInfo.URL); err != nil {
+		if sourceFile, err = layermanager.downloadPackage(layerInfo.URL, downloader.TargetInfo{
+			Type:          cloudprotocol.DownloadTargetLayer,
+			ID:            layerInfo.ID,
+			AosVersion:    layerInfo.AosVersion,
+			VendorVersion: layerInfo.VendorVersion,
+		}); err != nil {
 			return layerDescriptor, nil, aoserrors.Wrap(err)
 		}
 
@@ -561,10 +959,176 @@ func (layermanager *LayerManager) extractPackageByURL(
 	return layerDescriptor, spaceExtract, nil
 }
 
+func (layermanager *LayerManager) downloadPackage(
+	url string, target downloader.TargetInfo,
+) (fileName string, err error) {
+	if !layermanager.remoteNode {
+		if layermanager.downloader != nil {
+			return layermanager.downloader.Download(context.Background(), url, target)
+		}
+
+		if fileName, err = image.Download(context.Background(), layermanager.downloadDir, url); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		return fileName, nil
+	}
+
+	// Remote node has no access to the download server, request the package content from CM
+
+	if layermanager.contentProvider == nil {
+		return "", aoserrors.New("content provider is not set")
+	}
+
+	contentDir, err := os.MkdirTemp(layermanager.downloadDir, "")
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+	defer os.RemoveAll(contentDir)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), layermanager.contentTimeout)
+	defer cancelFunc()
+
+	fileNames, err := layermanager.contentProvider.GetImageContent(ctx, url, contentreceiver.ContentTypeLayer, contentDir)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if len(fileNames) != 1 {
+		return "", aoserrors.Errorf("unexpected image content files count: %d", len(fileNames))
+	}
+
+	fileName = contentDir + ".pkg"
+
+	if err = os.Rename(fileNames[0], fileName); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return fileName, nil
+}
+
 func getValidLayerPath(layerDescriptor imagespec.Descriptor, unTarPath string) (layerPath string, err error) {
 	return filepath.Join(unTarPath, layerDescriptor.Digest.Hex()), nil
 }
 
+func verifyLayerDigest(layerDescriptor imagespec.Descriptor, layerPath string) error {
+	if err := layerDescriptor.Digest.Validate(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	file, err := os.Open(layerPath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	verifier := layerDescriptor.Digest.Verifier()
+
+	if _, err = io.Copy(verifier, file); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !verifier.Verified() {
+		return aoserrors.New("layer digest mismatch")
+	}
+
+	return nil
+}
+
+func getLayerSize(layerPath, mediaType string) (size int64, err error) {
+	if !fsimage.IsImageMediaType(mediaType) {
+		size, err = ocilayer.GetUncompressedSize(layerPath, mediaType)
+
+		return size, aoserrors.Wrap(err)
+	}
+
+	fileInfo, err := os.Stat(layerPath)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	return fileInfo.Size(), nil
+}
+
+// calculateContentDigest calculates digest of stored layer. Image layer digest is a digest of the image file. Unpacked
+// layer digest covers path, mode and content of each entry: file data, link target or device number.
+func calculateContentDigest(layerPath string, limiter *ratelimit.Limiter) (contentDigest digest.Digest, err error) {
+	digester := digest.SHA256.Digester()
+
+	fileInfo, err := os.Stat(layerPath)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if !fileInfo.IsDir() {
+		if err = hashFile(digester.Hash(), layerPath, limiter); err != nil {
+			return "", err
+		}
+
+		return digester.Digest(), nil
+	}
+
+	if err = filepath.WalkDir(layerPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		relPath, err := filepath.Rel(layerPath, path)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		fmt.Fprintf(digester.Hash(), "%s\x00%o\x00", relPath, entryInfo.Mode())
+
+		switch {
+		case entryInfo.Mode().IsRegular():
+			return hashFile(digester.Hash(), path, limiter)
+
+		case entryInfo.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			fmt.Fprintf(digester.Hash(), "%s\x00", target)
+
+		case entryInfo.Mode()&fs.ModeDevice != 0:
+			if stat, ok := entryInfo.Sys().(*syscall.Stat_t); ok {
+				fmt.Fprintf(digester.Hash(), "%d\x00", stat.Rdev)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return digester.Digest(), nil
+}
+
+func hashFile(hash io.Writer, fileName string, limiter *ratelimit.Limiter) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(hash, limiter.Reader(file)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func getLayerBlobOwner(layerDigest string) string {
+	return "layer:" + layerDigest
+}
+
 func releaseAllocatedSpace(path string, spaceLayer spaceallocator.Space) {
 	if err := os.RemoveAll(path); err != nil {
 		log.Warnf("Can't remove layer storage dir: %v", err)
@@ -575,8 +1139,12 @@ func releaseAllocatedSpace(path string, spaceLayer spaceallocator.Space) {
 	}
 }
 
-func unpackLayer(source, destination string) error {
-	if err := image.UnpackTarImage(source, destination); err != nil {
+func unpackLayer(source, destination string, layerDescriptor imagespec.Descriptor) error {
+	// DiffID is optional for gzip and tar layers as compressed layer digest is already verified, but it is
+	// required for zstd layers (see ocilayer.Unpack)
+	diffID := digest.Digest(layerDescriptor.Annotations[ocilayer.AnnotationDiffID])
+
+	if err := ocilayer.Unpack(source, destination, layerDescriptor.MediaType, diffID); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
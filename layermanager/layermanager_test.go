@@ -18,6 +18,7 @@
 package layermanager_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/json"
@@ -35,14 +36,20 @@ import (
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	"github.com/aoscloud/aos_common/image"
 	"github.com/aoscloud/aos_common/spaceallocator"
 	"github.com/opencontainers/go-digest"
 	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
+	"github.com/aoscloud/aos_servicemanager/blobstore"
 	"github.com/aoscloud/aos_servicemanager/config"
 	"github.com/aoscloud/aos_servicemanager/layermanager"
+	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
 )
 
 /***********************************************************************************************************************
@@ -59,11 +66,16 @@ const (
  **********************************************************************************************************************/
 
 type testLayerStorage struct {
+	sync.Mutex
 	layers       []layermanager.LayerInfo
 	addLayerFail bool
 	getLayerFail bool
 }
 
+type testBlobStorage struct {
+	refs []blobstore.BlobReference
+}
+
 type testAllocator struct {
 	sync.Mutex
 
@@ -83,6 +95,17 @@ type testOutdatedItem struct {
 	size uint64
 }
 
+type testClockProvider struct {
+	syncedChannel chan struct{}
+}
+
+type testSignatureVerifier struct {
+	sync.Mutex
+	status        string
+	err           error
+	verifiedFiles []string
+}
+
 /***********************************************************************************************************************
  * Vars
  **********************************************************************************************************************/
@@ -146,7 +169,7 @@ func TestProcessDesiredLayers(t *testing.T) {
 			ExtractDir:   filepath.Join(tmpDir, "extract"),
 			DownloadDir:  filepath.Join(tmpDir, "download"),
 			LayerTTLDays: 0,
-		}, testLayerStorage)
+		}, testLayerStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create layer manager: %s", err)
 	}
@@ -196,7 +219,7 @@ func TestProcessDesiredLayers(t *testing.T) {
 	}
 
 	for _, tCase := range cases {
-		if err := layerManager.ProcessDesiredLayers(tCase.desiredLayers); err != nil {
+		if _, err := layerManager.ProcessDesiredLayers(tCase.desiredLayers); err != nil {
 			t.Errorf("Can't process desired layers: %v", err)
 		}
 
@@ -235,6 +258,66 @@ func TestProcessDesiredLayers(t *testing.T) {
 	}
 }
 
+func TestLayerInstallStatuses(t *testing.T) {
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerManager, err := layermanager.New(
+		&config.Config{
+			LayersDir:           layersDir,
+			ExtractDir:          filepath.Join(tmpDir, "extract"),
+			DownloadDir:         filepath.Join(tmpDir, "download"),
+			MaxParallelInstalls: 2,
+		}, &testLayerStorage{}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	var desiredLayers []aostypes.LayerInfo
+
+	for i, layerID := range []string{"layer1", "brokenLayer", "layer2"} {
+		layerInfo, err := createLayer(filepath.Join(tmpDir, "layerdir1"), int64(uint64(i+1)*kilobyte), layerID)
+		if err != nil {
+			t.Fatalf("Can't prepare layer: %v", err)
+		}
+
+		desiredLayers = append(desiredLayers, layerInfo)
+	}
+
+	// Broken layer should not block installation of other layers
+
+	desiredLayers[1].Sha256 = []byte("invalid checksum")
+
+	statuses, err := layerManager.ProcessDesiredLayers(desiredLayers)
+	if err == nil {
+		t.Error("Error expected")
+	}
+
+	checkLayerStatuses(t, statuses, map[string]string{
+		"layer1":      cloudprotocol.InstalledStatus,
+		"brokenLayer": cloudprotocol.ErrorStatus,
+		"layer2":      cloudprotocol.InstalledStatus,
+	})
+
+	// Already installed layers are skipped
+
+	if statuses, err = layerManager.ProcessDesiredLayers(
+		[]aostypes.LayerInfo{desiredLayers[0], desiredLayers[2]}); err != nil {
+		t.Errorf("Can't process desired layers: %v", err)
+	}
+
+	checkLayerStatuses(t, statuses, map[string]string{
+		"layer1": servicemanager.StatusCached,
+		"layer2": servicemanager.StatusCached,
+	})
+}
+
 func TestRemoveDemageLayerFolder(t *testing.T) {
 	testStorage := &testLayerStorage{}
 
@@ -251,7 +334,7 @@ func TestRemoveDemageLayerFolder(t *testing.T) {
 			LayersDir:   layersDir,
 			ExtractDir:  filepath.Join(tmpDir, "extract"),
 			DownloadDir: filepath.Join(tmpDir, "download"),
-		}, testStorage)
+		}, testStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create layer manager: %v", err)
 	}
@@ -263,7 +346,7 @@ func TestRemoveDemageLayerFolder(t *testing.T) {
 		t.Fatalf("Can't create layer: %v", err)
 	}
 
-	if err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
 		t.Fatalf("Can't process desired layers: %v", err)
 	}
 
@@ -283,7 +366,7 @@ func TestRemoveDemageLayerFolder(t *testing.T) {
 			LayersDir:   layersDir,
 			ExtractDir:  filepath.Join(tmpDir, "extract"),
 			DownloadDir: filepath.Join(tmpDir, "download"),
-		}, testStorage)
+		}, testStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create layer manager: %v", err)
 	}
@@ -297,7 +380,7 @@ func TestRemoveDemageLayerFolder(t *testing.T) {
 		t.Fatalf("Can't create layer: %v", err)
 	}
 
-	if err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
 		t.Fatalf("Can't install layer: %v", err)
 	}
 
@@ -319,7 +402,7 @@ func TestRemoveDemageLayerFolder(t *testing.T) {
 			LayersDir:   layersDir,
 			ExtractDir:  filepath.Join(tmpDir, "extract"),
 			DownloadDir: filepath.Join(tmpDir, "download"),
-		}, testStorage)
+		}, testStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create layer manager: %v", err)
 	}
@@ -330,6 +413,70 @@ func TestRemoveDemageLayerFolder(t *testing.T) {
 	}
 }
 
+func TestLayerSignatureVerification(t *testing.T) {
+	testStorage := &testLayerStorage{}
+	signatureVerifier := &testSignatureVerifier{status: "verified"}
+
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerManager, err := layermanager.New(
+		&config.Config{
+			LayersDir:   layersDir,
+			ExtractDir:  filepath.Join(tmpDir, "extract"),
+			DownloadDir: filepath.Join(tmpDir, "download"),
+		}, testStorage, nil, nil, signatureVerifier, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	signedLayer, err := createLayer(filepath.Join(tmpDir, "layerdir1"), int64(1*kilobyte), "signedLayer")
+	if err != nil {
+		t.Fatalf("Can't create layer: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{signedLayer}); err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	layer, err := layerManager.GetLayerInfoByDigest(signedLayer.Digest)
+	if err != nil {
+		t.Fatalf("Can't get layer: %v", err)
+	}
+
+	if layer.SignatureStatus != "verified" {
+		t.Errorf("Wrong signature status: %s", layer.SignatureStatus)
+	}
+
+	if len(signatureVerifier.verifiedFiles) != 1 || filepath.Base(signatureVerifier.verifiedFiles[0]) != "layer.json" {
+		t.Errorf("Wrong verified files: %v", signatureVerifier.verifiedFiles)
+	}
+
+	// Layer with invalid signature should be refused
+
+	signatureVerifier.err = aoserrors.New("invalid signature")
+
+	unsignedLayer, err := createLayer(filepath.Join(tmpDir, "layerdir2"), int64(2*kilobyte), "unsignedLayer")
+	if err != nil {
+		t.Fatalf("Can't create layer: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers(
+		[]aostypes.LayerInfo{signedLayer, unsignedLayer}); err == nil {
+		t.Error("Layer with invalid signature should not be installed")
+	}
+
+	if _, err = layerManager.GetLayerInfoByDigest(unsignedLayer.Digest); err == nil {
+		t.Error("Layer with invalid signature should not exist")
+	}
+}
+
 func TestRemoteDownloadLayer(t *testing.T) {
 	layerAllocator = &testAllocator{}
 
@@ -344,7 +491,7 @@ func TestRemoteDownloadLayer(t *testing.T) {
 			LayersDir:   layersDir,
 			ExtractDir:  filepath.Join(tmpDir, "extract"),
 			DownloadDir: filepath.Join(tmpDir, "download"),
-		}, &testLayerStorage{})
+		}, &testLayerStorage{}, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create layer manager: %v", err)
 	}
@@ -391,11 +538,70 @@ func TestRemoteDownloadLayer(t *testing.T) {
 
 	layerInfo.URL = "http://:9000/downloadImage"
 
-	if err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
 		t.Fatalf("Can't install layer: %v", err)
 	}
 }
 
+func TestLayersTTLClockSync(t *testing.T) {
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerPath := filepath.Join(layersDir, "sha256", "outdated")
+
+	if err := os.MkdirAll(layerPath, 0o755); err != nil {
+		t.Fatalf("Can't create layer dir: %v", err)
+	}
+
+	testStorage := &testLayerStorage{
+		layers: []layermanager.LayerInfo{{
+			Digest: "sha256:outdated", LayerID: "outdated", Path: layerPath, Cached: true,
+			Timestamp: time.Now().Add(-48 * time.Hour),
+		}},
+	}
+
+	clockProvider := &testClockProvider{syncedChannel: make(chan struct{})}
+
+	layerManager, err := layermanager.New(
+		&config.Config{
+			LayersDir:    layersDir,
+			ExtractDir:   filepath.Join(tmpDir, "extract"),
+			DownloadDir:  filepath.Join(tmpDir, "download"),
+			LayerTTLDays: 1,
+		}, testStorage, nil, clockProvider, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	if _, err = layerManager.GetLayerInfoByDigest("sha256:outdated"); err != nil {
+		t.Fatalf("Outdated layer should not be removed until clock is synced: %v", err)
+	}
+
+	close(clockProvider.syncedChannel)
+
+	for i := 0; ; i++ {
+		if _, err = layerManager.GetLayerInfoByDigest("sha256:outdated"); err != nil {
+			break
+		}
+
+		if i >= 50 {
+			t.Fatal("Outdated layer should be removed after clock is synced")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if _, err = os.Stat(layerPath); err == nil {
+		t.Error("Outdated layer folder should be removed")
+	}
+}
+
 func TestInstallLayerNotEnoughSpace(t *testing.T) {
 	layerAllocator = &testAllocator{
 		totalSize: 1 * megabyte,
@@ -413,7 +619,7 @@ func TestInstallLayerNotEnoughSpace(t *testing.T) {
 			ExtractDir:   filepath.Join(tmpDir, "extract"),
 			DownloadDir:  filepath.Join(tmpDir, "download"),
 			LayerTTLDays: 2,
-		}, &testLayerStorage{})
+		}, &testLayerStorage{}, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create layer manager: %v", err)
 	}
@@ -460,16 +666,324 @@ func TestInstallLayerNotEnoughSpace(t *testing.T) {
 	}
 
 	for _, tCase := range cases {
-		if err := layerManager.ProcessDesiredLayers(tCase.desiredLayers); !errors.Is(err, tCase.processDesiredError) {
+		if _, err := layerManager.ProcessDesiredLayers(tCase.desiredLayers); !errors.Is(err, tCase.processDesiredError) {
 			t.Errorf("Can't process desired layers: %v", err)
 		}
 	}
 }
 
+func TestLayerBlobStore(t *testing.T) {
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerConfig := &config.Config{
+		LayersDir:   layersDir,
+		ExtractDir:  filepath.Join(tmpDir, "extract"),
+		DownloadDir: filepath.Join(tmpDir, "download"),
+		BlobsDir:    filepath.Join(tmpDir, "blobs"),
+	}
+
+	blobStore, err := blobstore.New(layerConfig, &testBlobStorage{})
+	if err != nil {
+		t.Fatalf("Can't create blob store: %v", err)
+	}
+	defer blobStore.Close()
+
+	testStorage := &testLayerStorage{}
+
+	layerManager, err := layermanager.New(layerConfig, testStorage, nil, nil, nil, nil, blobStore)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+
+	layerInfo, err := createLayer(filepath.Join(tmpDir, "layerdir"), int64(kilobyte), "layer1")
+	if err != nil {
+		t.Fatalf("Can't prepare layer: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	layer, err := layerManager.GetLayerInfoByDigest(layerInfo.Digest)
+	if err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	if !blobStore.IsBlobPath(layer.Path) {
+		t.Errorf("Layer should be stored in blob store: %s", layer.Path)
+	}
+
+	if layer.Size != 0 {
+		t.Errorf("Layer blob should be accounted by blob store: %d", layer.Size)
+	}
+
+	if _, err = os.Stat(layer.Path); err != nil {
+		t.Errorf("Layer blob should exist: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers(nil); err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	layerManager.Close()
+
+	// Cached layer is removed on start with zero TTL
+
+	if layerManager, err = layermanager.New(layerConfig, testStorage, nil, nil, nil, nil, blobStore); err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	if _, err = layerManager.GetLayerInfoByDigest(layerInfo.Digest); err == nil {
+		t.Error("Cached layer should be removed")
+	}
+
+	if _, err = os.Stat(layer.Path); !os.IsNotExist(err) {
+		t.Error("Layer blob should be removed")
+	}
+}
+
+func TestImageLayer(t *testing.T) {
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerConfig := &config.Config{
+		LayersDir:   layersDir,
+		ExtractDir:  filepath.Join(tmpDir, "extract"),
+		DownloadDir: filepath.Join(tmpDir, "download"),
+		ImageFormat: "unknown",
+	}
+
+	if _, err := layermanager.New(layerConfig, &testLayerStorage{}, nil, nil, nil, nil, nil); err == nil {
+		t.Error("Layer manager should fail on unknown image format")
+	}
+
+	layerConfig.ImageFormat = ""
+
+	// Delivered image is stored as is regardless of configured format
+
+	imageContent := append([]byte("hsqs"), make([]byte, kilobyte)...)
+
+	layerInfo, err := createLayerPackage(filepath.Join(tmpDir, "layerdir"), imageContent,
+		fsimage.MediaTypeSquashFS, int64(len(imageContent)), "layer1")
+	if err != nil {
+		t.Fatalf("Can't prepare layer: %v", err)
+	}
+
+	testStorage := &testLayerStorage{}
+
+	layerManager, err := layermanager.New(layerConfig, testStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	layer, err := layerManager.GetLayerInfoByDigest(layerInfo.Digest)
+	if err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	storedContent, err := os.ReadFile(layer.Path)
+	if err != nil {
+		t.Fatalf("Can't read layer image: %v", err)
+	}
+
+	if !bytes.Equal(storedContent, imageContent) {
+		t.Error("Wrong layer image content")
+	}
+
+	if layer.Size != uint64(len(imageContent)) {
+		t.Errorf("Wrong layer size: %d", layer.Size)
+	}
+
+	layerManager.Close()
+
+	// Image layer should not be treated as damaged on start
+
+	if layerManager, err = layermanager.New(layerConfig, testStorage, nil, nil, nil, nil, nil); err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	if _, err = layerManager.GetLayerInfoByDigest(layerInfo.Digest); err != nil {
+		t.Errorf("Image layer should not be removed: %v", err)
+	}
+}
+
+func TestQuarantineLayer(t *testing.T) {
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerManager, err := layermanager.New(&config.Config{
+		LayersDir:   layersDir,
+		ExtractDir:  filepath.Join(tmpDir, "extract"),
+		DownloadDir: filepath.Join(tmpDir, "download"),
+	}, &testLayerStorage{}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	layerInfo, err := createLayer(filepath.Join(tmpDir, "layerdir"), int64(kilobyte), "layer1")
+	if err != nil {
+		t.Fatalf("Can't prepare layer: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	layer, err := layerManager.GetLayerInfoByDigest(layerInfo.Digest)
+	if err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	if layer.ContentDigest == "" {
+		t.Error("Layer content digest should be set")
+	}
+
+	limiter := ratelimit.New(0)
+	defer limiter.Close()
+
+	if err = layerManager.VerifyLayer(layer, limiter); err != nil {
+		t.Errorf("Can't verify layer: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(layer.Path, "layer.txt"), []byte("corrupted"), 0o600); err != nil {
+		t.Fatalf("Can't corrupt layer: %v", err)
+	}
+
+	if err = layerManager.VerifyLayer(layer, limiter); err == nil {
+		t.Error("Corrupted layer should fail verification")
+	}
+
+	if err = layerManager.QuarantineLayer(layer); err != nil {
+		t.Fatalf("Can't quarantine layer: %v", err)
+	}
+
+	if layer, err = layerManager.GetLayerInfoByDigest(layerInfo.Digest); err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	if !layer.Quarantined {
+		t.Error("Layer should be quarantined")
+	}
+
+	// Quarantined layer should be installed again
+
+	statuses, err := layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo})
+	if err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	checkLayerStatuses(t, statuses, map[string]string{layerInfo.ID: cloudprotocol.InstalledStatus})
+
+	if layer, err = layerManager.GetLayerInfoByDigest(layerInfo.Digest); err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	if layer.Quarantined {
+		t.Error("Layer should not be quarantined")
+	}
+
+	if err = layerManager.VerifyLayer(layer, limiter); err != nil {
+		t.Errorf("Can't verify layer: %v", err)
+	}
+}
+
+func TestConvertLayerToImage(t *testing.T) {
+	if _, err := exec.LookPath("mksquashfs"); err != nil {
+		t.Skip("mksquashfs is not available")
+	}
+
+	layerAllocator = &testAllocator{}
+
+	defer func() {
+		if err := os.RemoveAll(layersDir); err != nil {
+			t.Errorf("Can't remove layers dir: %v", err)
+		}
+	}()
+
+	layerConfig := &config.Config{
+		LayersDir:   layersDir,
+		ExtractDir:  filepath.Join(tmpDir, "extract"),
+		DownloadDir: filepath.Join(tmpDir, "download"),
+		ImageFormat: fsimage.FormatSquashFS,
+	}
+
+	layerManager, err := layermanager.New(layerConfig, &testLayerStorage{}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create layer manager: %v", err)
+	}
+	defer layerManager.Close()
+
+	layerInfo, err := createLayer(filepath.Join(tmpDir, "layerdir"), int64(kilobyte), "layer1")
+	if err != nil {
+		t.Fatalf("Can't prepare layer: %v", err)
+	}
+
+	if _, err = layerManager.ProcessDesiredLayers([]aostypes.LayerInfo{layerInfo}); err != nil {
+		t.Fatalf("Can't process desired layers: %v", err)
+	}
+
+	layer, err := layerManager.GetLayerInfoByDigest(layerInfo.Digest)
+	if err != nil {
+		t.Fatalf("Can't get layer info: %v", err)
+	}
+
+	format, err := fsimage.GetFormat(layer.Path)
+	if err != nil {
+		t.Fatalf("Can't get layer image format: %v", err)
+	}
+
+	if format != fsimage.FormatSquashFS {
+		t.Errorf("Wrong layer image format: %s", format)
+	}
+}
+
 /***********************************************************************************************************************
  * Interfaces
  **********************************************************************************************************************/
 
+func (storage *testBlobStorage) AddBlobReference(ref blobstore.BlobReference) error {
+	if !slices.Contains(storage.refs, ref) {
+		storage.refs = append(storage.refs, ref)
+	}
+
+	return nil
+}
+
+func (storage *testBlobStorage) RemoveBlobReference(ref blobstore.BlobReference) error {
+	if i := slices.Index(storage.refs, ref); i >= 0 {
+		storage.refs = slices.Delete(storage.refs, i, i+1)
+	}
+
+	return nil
+}
+
+func (storage *testBlobStorage) GetBlobReferences() ([]blobstore.BlobReference, error) {
+	return storage.refs, nil
+}
+
 func newSpaceAllocator(
 	path string, partLimit uint, remover spaceallocator.ItemRemover,
 ) (spaceallocator.Allocator, error) {
@@ -554,6 +1068,9 @@ func (space *testSpace) Release() error {
 }
 
 func (infoProvider *testLayerStorage) AddLayer(layerInfo layermanager.LayerInfo) (err error) {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
 	if infoProvider.addLayerFail {
 		return aoserrors.New("can't add layer")
 	}
@@ -570,6 +1087,9 @@ func (infoProvider *testLayerStorage) AddLayer(layerInfo layermanager.LayerInfo)
 }
 
 func (infoProvider *testLayerStorage) DeleteLayerByDigest(digest string) (err error) {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
 	for i, layer := range infoProvider.layers {
 		if layer.Digest == digest {
 			infoProvider.layers = append(infoProvider.layers[:i], infoProvider.layers[i+1:]...)
@@ -582,11 +1102,14 @@ func (infoProvider *testLayerStorage) DeleteLayerByDigest(digest string) (err er
 }
 
 func (infoProvider *testLayerStorage) GetLayersInfo() (layersList []layermanager.LayerInfo, err error) {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
 	if infoProvider.getLayerFail {
 		return nil, aoserrors.New("can't get layers info")
 	}
 
-	layersList = infoProvider.layers
+	layersList = append(layersList, infoProvider.layers...)
 
 	return layersList, nil
 }
@@ -594,6 +1117,9 @@ func (infoProvider *testLayerStorage) GetLayersInfo() (layersList []layermanager
 func (infoProvider *testLayerStorage) GetLayerInfoByDigest(
 	digest string,
 ) (layerInfo layermanager.LayerInfo, err error) {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
 	for _, layer := range infoProvider.layers {
 		if layer.Digest == digest {
 			return layer, nil
@@ -604,6 +1130,9 @@ func (infoProvider *testLayerStorage) GetLayerInfoByDigest(
 }
 
 func (infoProvider *testLayerStorage) SetLayerCached(digest string, cached bool) error {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
 	for i, layer := range infoProvider.layers {
 		if layer.Digest == digest {
 			infoProvider.layers[i].Cached = cached
@@ -615,7 +1144,25 @@ func (infoProvider *testLayerStorage) SetLayerCached(digest string, cached bool)
 	return aoserrors.New("layer not found")
 }
 
+func (infoProvider *testLayerStorage) SetLayerQuarantined(digest string, quarantined bool) error {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
+	for i, layer := range infoProvider.layers {
+		if layer.Digest == digest {
+			infoProvider.layers[i].Quarantined = quarantined
+
+			return nil
+		}
+	}
+
+	return aoserrors.New("layer not found")
+}
+
 func (infoProvider *testLayerStorage) SetLayerTimestamp(digest string, timestamp time.Time) error {
+	infoProvider.Lock()
+	defer infoProvider.Unlock()
+
 	for i, layer := range infoProvider.layers {
 		if layer.Digest == digest {
 			infoProvider.layers[i].Timestamp = timestamp
@@ -639,6 +1186,7 @@ func setup() (err error) {
 	layersDir = filepath.Join(tmpDir, "layers")
 
 	layermanager.NewSpaceAllocator = newSpaceAllocator
+	blobstore.NewSpaceAllocator = newSpaceAllocator
 
 	return nil
 }
@@ -689,15 +1237,26 @@ func createLayer(
 		return layerInfo, aoserrors.Wrap(err)
 	}
 
-	layerDigest, err := generateAndSaveDigest(dir, byteValue)
+	return createLayerPackage(dir, byteValue, "application/vnd.aos.image.layer.v1.tar", sizeLayerContent, layerID)
+}
+
+func createLayerPackage(
+	dir string, content []byte, mediaType string, size int64, layerID string,
+) (layerInfo aostypes.LayerInfo, err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return layerInfo, aoserrors.Wrap(err)
+	}
+	defer os.RemoveAll(dir)
+
+	layerDigest, err := generateAndSaveDigest(dir, content)
 	if err != nil {
 		return layerInfo, aoserrors.Wrap(err)
 	}
 
 	layerDescriptor := imagespec.Descriptor{
-		MediaType: "application/vnd.aos.image.layer.v1.tar+gzip",
+		MediaType: mediaType,
 		Digest:    layerDigest,
-		Size:      sizeLayerContent,
+		Size:      size,
 	}
 
 	dataJSON, err := json.Marshal(layerDescriptor)
@@ -756,6 +1315,24 @@ func generateAndSaveDigest(folder string, data []byte) (retDigest digest.Digest,
 	return retDigest, nil
 }
 
+func checkLayerStatuses(t *testing.T, statuses []cloudprotocol.LayerStatus, expectedStatuses map[string]string) {
+	t.Helper()
+
+	if len(statuses) != len(expectedStatuses) {
+		t.Fatalf("Wrong statuses count: %d", len(statuses))
+	}
+
+	for _, status := range statuses {
+		if expectedStatuses[status.ID] != status.Status {
+			t.Errorf("Wrong layer %s status: %s", status.ID, status.Status)
+		}
+
+		if (status.Status == cloudprotocol.ErrorStatus) != (status.ErrorInfo != nil) {
+			t.Errorf("Wrong layer %s error info: %v", status.ID, status.ErrorInfo)
+		}
+	}
+}
+
 func getDesiredLayers(layers map[string]aostypes.LayerInfo, layersID []string) (desiredLayers []aostypes.LayerInfo) {
 	for _, layerID := range layersID {
 		layer, ok := layers[layerID]
@@ -766,3 +1343,20 @@ func getDesiredLayers(layers map[string]aostypes.LayerInfo, layersID []string) (
 
 	return
 }
+
+func (clockProvider *testClockProvider) GetClockSyncedChannel() (channel <-chan struct{}) {
+	return clockProvider.syncedChannel
+}
+
+func (verifier *testSignatureVerifier) VerifyFile(fileName string) (status string, err error) {
+	verifier.Lock()
+	defer verifier.Unlock()
+
+	verifier.verifiedFiles = append(verifier.verifiedFiles, fileName)
+
+	if verifier.err != nil {
+		return "", verifier.err
+	}
+
+	return verifier.status, nil
+}
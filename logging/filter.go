@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/coreos/go-systemd/v22/sdjournal"
+	"golang.org/x/exp/slices"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Log filter is passed as query parameters of log ID: <log ID>?priority=err&pattern=<regexp>&field=<NAME>=<value>.
+// Log ID is sent back unchanged, so requester gets filtered log with the same ID. Follow mode is requested by follow
+// parameter: <log ID>?follow, and it is stopped by request with cancel parameter: <log ID>?cancel.
+const (
+	filterSeparator = "?"
+	priorityParam   = "priority"
+	patternParam    = "pattern"
+	fieldParam      = "field"
+	followParam     = "follow"
+	cancelParam     = "cancel"
+)
+
+const maxPriority = 7
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type logFilter struct {
+	priority *int
+	pattern  *regexp.Regexp
+	fields   map[string][]string
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func parseLogFilter(logID string) (filter logFilter, err error) {
+	index := strings.Index(logID, filterSeparator)
+	if index == -1 {
+		return filter, nil
+	}
+
+	params, err := url.ParseQuery(logID[index+1:])
+	if err != nil {
+		return filter, aoserrors.Wrap(err)
+	}
+
+	if params.Has(priorityParam) {
+		priority, err := parsePriority(params.Get(priorityParam))
+		if err != nil {
+			return filter, err
+		}
+
+		filter.priority = &priority
+	}
+
+	if params.Has(patternParam) {
+		if filter.pattern, err = regexp.Compile(params.Get(patternParam)); err != nil {
+			return filter, aoserrors.Wrap(err)
+		}
+	}
+
+	for _, field := range params[fieldParam] {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok || name == "" {
+			return filter, aoserrors.Errorf("wrong journal field match: %s", field)
+		}
+
+		if filter.fields == nil {
+			filter.fields = make(map[string][]string)
+		}
+
+		filter.fields[name] = append(filter.fields[name], value)
+	}
+
+	return filter, nil
+}
+
+func parsePriority(value string) (priority int, err error) {
+	for i, name := range priorityNames {
+		if value == name {
+			return i, nil
+		}
+	}
+
+	if priority, err = strconv.Atoi(value); err != nil || priority < 0 || priority > maxPriority {
+		return 0, aoserrors.Errorf("wrong log priority: %s", value)
+	}
+
+	return priority, nil
+}
+
+// addJournalMatches adds priority and field filters as journal matches. Matches of the same field are ORed by journal
+// and matches of different fields are ANDed.
+func (filter *logFilter) addJournalMatches(journal JournalInterface) error {
+	if filter.priority != nil {
+		for priority := 0; priority <= *filter.priority; priority++ {
+			if err := journal.AddMatch(
+				sdjournal.SD_JOURNAL_FIELD_PRIORITY + "=" + strconv.Itoa(priority)); err != nil {
+				return aoserrors.Wrap(err)
+			}
+		}
+	}
+
+	for name, values := range filter.fields {
+		for _, value := range values {
+			if err := journal.AddMatch(name + "=" + value); err != nil {
+				return aoserrors.Wrap(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// match checks log entry in-process. Text pattern can't be applied as journal match, other filters are checked as
+// well for logs which can't use journal matches.
+func (filter *logFilter) match(entry *sdjournal.JournalEntry) bool {
+	if filter.priority != nil {
+		priority, err := strconv.Atoi(entry.Fields[sdjournal.SD_JOURNAL_FIELD_PRIORITY])
+		if err != nil || priority > *filter.priority {
+			return false
+		}
+	}
+
+	for name, values := range filter.fields {
+		if !slices.Contains(values, entry.Fields[name]) {
+			return false
+		}
+	}
+
+	if filter.pattern != nil && !filter.pattern.MatchString(entry.Fields[sdjournal.SD_JOURNAL_FIELD_MESSAGE]) {
+		return false
+	}
+
+	return true
+}
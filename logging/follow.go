@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	"github.com/coreos/go-systemd/v22/sdjournal"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	followWaitTimeout          = 500 * time.Millisecond
+	defaultFollowFlushInterval = 5 * time.Second
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type followRequest struct {
+	cancel context.CancelFunc
+}
+
+// logFollower sends followed log in gzip parts. Parts count is unknown till follow is finished, so intermediate parts
+// are sent with zero parts count and the last part has parts count equal to its part number.
+type logFollower struct {
+	ctx         context.Context //nolint:containedctx // stops sending when logging is closed
+	zw          *gzip.Writer
+	buffer      bytes.Buffer
+	logChannel  chan<- cloudprotocol.PushLog
+	logID       string
+	part        uint64
+	partSize    uint64
+	maxPartSize uint64
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (instance *Logging) startFollowLog(request getLogRequest) {
+	followID := getFollowID(request.logID)
+
+	instance.Lock()
+	defer instance.Unlock()
+
+	// Repeated follow request doesn't interrupt active follow
+	if _, ok := instance.followRequests[followID]; ok {
+		log.WithField("logID", request.logID).Warn("Log is already followed")
+
+		return
+	}
+
+	ctx, cancel := context.WithCancel(instance.closeCtx)
+
+	timeout := instance.config.MaxFollowDuration.Duration
+
+	if request.till != nil && (timeout == 0 || time.Until(*request.till) < timeout) {
+		timeout = time.Until(*request.till)
+	}
+
+	if timeout != 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	follow := &followRequest{cancel: cancel}
+
+	instance.followRequests[followID] = follow
+
+	go func() {
+		defer func() {
+			cancel()
+
+			instance.Lock()
+			defer instance.Unlock()
+
+			if instance.followRequests[followID] == follow {
+				delete(instance.followRequests, followID)
+			}
+		}()
+
+		if err := instance.followLog(ctx, request); err != nil {
+			log.WithField("logID", request.logID).Errorf("Can't follow log: %v", err)
+
+			instance.sendErrorResponse(err.Error(), request.logID)
+		}
+	}()
+}
+
+func (instance *Logging) followLog(ctx context.Context, request getLogRequest) (err error) {
+	journal := SDJournal
+	if journal == nil {
+		if journal, err = sdjournal.NewJournal(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+	defer journal.Close()
+
+	needUnitField := true
+
+	if len(request.instanceIDs) != 0 {
+		needUnitField = false
+
+		if err = instance.addServiceCgroupFilter(journal, request.instanceIDs); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if err = request.filter.addJournalMatches(journal); err != nil {
+		return err
+	}
+
+	// Follow only new entries if from is not set
+	from := request.from
+	if from == nil {
+		now := time.Now()
+		from = &now
+	}
+
+	if err = instance.seekToTime(journal, from); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	follower, err := newLogFollower(instance.closeCtx, instance.logChannel, request.logID, instance.config.MaxPartSize)
+	if err != nil {
+		return err
+	}
+
+	flushInterval := instance.config.FollowFlushInterval.Duration
+	if flushInterval <= 0 {
+		flushInterval = defaultFollowFlushInterval
+	}
+
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	log.WithField("logID", request.logID).Debug("Start log follow")
+
+	for {
+		if err = readNewEntries(journal, follower, &request.filter, needUnitField); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			log.WithField("logID", request.logID).Debug("Stop log follow")
+
+			return follower.close()
+
+		case <-flushTicker.C:
+			if err = follower.flush(); err != nil {
+				return err
+			}
+
+		default:
+		}
+
+		journal.Wait(followWaitTimeout)
+	}
+}
+
+func (instance *Logging) cancelFollowLog(logID string) {
+	instance.Lock()
+	defer instance.Unlock()
+
+	followID := getFollowID(logID)
+
+	follow, ok := instance.followRequests[followID]
+	if !ok {
+		log.WithField("logID", logID).Warn("Log is not followed")
+
+		return
+	}
+
+	log.WithField("logID", logID).Debug("Cancel log follow")
+
+	follow.cancel()
+	delete(instance.followRequests, followID)
+}
+
+func (instance *Logging) cancelAllFollowLogs() {
+	instance.Lock()
+	defer instance.Unlock()
+
+	for logID, follow := range instance.followRequests {
+		follow.cancel()
+		delete(instance.followRequests, logID)
+	}
+}
+
+// getFollowID returns log ID without parameters to match follow and cancel requests of the same log.
+func getFollowID(logID string) string {
+	followID, _, _ := strings.Cut(logID, filterSeparator)
+
+	return followID
+}
+
+func isFollowLogRequest(logID string) bool {
+	return hasLogIDParam(logID, followParam)
+}
+
+func isCancelLogRequest(logID string) bool {
+	return hasLogIDParam(logID, cancelParam)
+}
+
+func hasLogIDParam(logID, param string) bool {
+	_, query, ok := strings.Cut(logID, filterSeparator)
+	if !ok {
+		return false
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+
+	return params.Has(param)
+}
+
+func readNewEntries(journal JournalInterface, follower *logFollower, filter *logFilter, needUnitField bool) error {
+	for {
+		rowCount, err := journal.Next()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if rowCount == 0 {
+			return nil
+		}
+
+		logEntry, err := journal.GetEntry()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if logEntry == nil {
+			return nil
+		}
+
+		if !filter.match(logEntry) {
+			continue
+		}
+
+		if err = follower.addLog(createLogString(logEntry, needUnitField)); err != nil {
+			return err
+		}
+	}
+}
+
+func newLogFollower(
+	ctx context.Context, logChannel chan<- cloudprotocol.PushLog, logID string, maxPartSize uint64,
+) (follower *logFollower, err error) {
+	follower = &logFollower{ctx: ctx, logChannel: logChannel, logID: logID, maxPartSize: maxPartSize}
+
+	if follower.zw, err = gzip.NewWriterLevel(&follower.buffer, gzip.BestCompression); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return follower, nil
+}
+
+func (follower *logFollower) addLog(message string) error {
+	count, err := follower.zw.Write([]byte(message))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	follower.partSize += uint64(count)
+
+	if follower.maxPartSize != 0 && follower.partSize > follower.maxPartSize {
+		return follower.sendPart(false)
+	}
+
+	return nil
+}
+
+func (follower *logFollower) flush() error {
+	if follower.partSize == 0 {
+		return nil
+	}
+
+	return follower.sendPart(false)
+}
+
+func (follower *logFollower) close() error {
+	return follower.sendPart(true)
+}
+
+func (follower *logFollower) sendPart(last bool) error {
+	if err := follower.zw.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	follower.part++
+
+	pushLog := cloudprotocol.PushLog{
+		LogID:   follower.logID,
+		Part:    follower.part,
+		Content: append([]byte{}, follower.buffer.Bytes()...),
+	}
+
+	if last {
+		pushLog.PartsCount = follower.part
+	}
+
+	log.WithFields(log.Fields{
+		"part": pushLog.Part,
+		"size": len(pushLog.Content),
+		"last": last,
+	}).Debugf("Push followed log")
+
+	select {
+	case follower.logChannel <- pushLog:
+
+	case <-follower.ctx.Done():
+		return aoserrors.Wrap(follower.ctx.Err())
+	}
+
+	follower.buffer.Reset()
+	follower.zw.Reset(&follower.buffer)
+	follower.partSize = 0
+
+	return nil
+}
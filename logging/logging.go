@@ -19,11 +19,13 @@
 package logging
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
@@ -57,9 +59,13 @@ type InstanceIDProvider interface {
 
 // Logging instance.
 type Logging struct {
+	sync.Mutex
 	logChannel       chan cloudprotocol.PushLog
 	instanceProvider InstanceIDProvider
 	config           config.Logging
+	followRequests   map[string]*followRequest
+	closeCtx         context.Context //nolint:containedctx // used to stop followed logs sending on close
+	cancelFunction   context.CancelFunc
 }
 
 type JournalInterface interface {
@@ -72,6 +78,7 @@ type JournalInterface interface {
 	Previous() (uint64, error)
 	Next() (uint64, error)
 	GetEntry() (*sdjournal.JournalEntry, error)
+	Wait(timeout time.Duration) int
 }
 
 type getLogRequest struct {
@@ -79,6 +86,8 @@ type getLogRequest struct {
 	logID       string
 	from        *time.Time
 	till        *time.Time
+	filter      logFilter
+	follow      bool
 }
 
 /***********************************************************************************************************************
@@ -100,20 +109,32 @@ func New(config *config.Config, instanceProvider InstanceIDProvider) (instance *
 		instanceProvider: instanceProvider,
 		config:           config.Logging,
 		logChannel:       make(chan cloudprotocol.PushLog, logChannelSize),
+		followRequests:   make(map[string]*followRequest),
 	}
 
+	instance.closeCtx, instance.cancelFunction = context.WithCancel(context.Background())
+
 	return instance, nil
 }
 
 // Close closes logging.
 func (instance *Logging) Close() {
 	log.Debug("Close logging")
+
+	instance.cancelAllFollowLogs()
+	instance.cancelFunction()
 }
 
-// GetInstanceLog returns instance log.
+// GetInstanceLog returns instance log. Log is followed or its follow is canceled if it is requested by log ID.
 func (instance *Logging) GetInstanceLog(request cloudprotocol.RequestLog) error {
 	log.WithField("request", logRequestToString(request)).Debug("Get instance log")
 
+	if isCancelLogRequest(request.LogID) {
+		instance.cancelFollowLog(request.LogID)
+
+		return nil
+	}
+
 	logRequest, err := instance.prepareInstanceLogRequest(request)
 	if err != nil {
 		instance.sendErrorResponse(err.Error(), request.LogID)
@@ -121,6 +142,12 @@ func (instance *Logging) GetInstanceLog(request cloudprotocol.RequestLog) error
 		return err
 	}
 
+	if logRequest.follow {
+		instance.startFollowLog(logRequest)
+
+		return nil
+	}
+
 	go func() {
 		if err := instance.getLog(logRequest); err != nil {
 			log.Errorf("Can't get instanace logs: %s", err)
@@ -154,14 +181,29 @@ func (instance *Logging) GetInstanceCrashLog(request cloudprotocol.RequestLog) e
 	return nil
 }
 
-// GetSystemLog returns system log.
+// GetSystemLog returns system log. Log is followed or its follow is canceled if it is requested by log ID.
 func (instance *Logging) GetSystemLog(request cloudprotocol.RequestLog) {
 	log.WithField("request", logRequestToString(request)).Debug("Get system log")
 
-	logRequest := getLogRequest{
-		logID: request.LogID,
-		from:  request.Filter.From,
-		till:  request.Filter.Till,
+	if isCancelLogRequest(request.LogID) {
+		instance.cancelFollowLog(request.LogID)
+
+		return
+	}
+
+	logRequest, err := prepareSystemLogRequest(request)
+	if err != nil {
+		log.Errorf("Can't get system logs: %s", err)
+
+		instance.sendErrorResponse(err.Error(), request.LogID)
+
+		return
+	}
+
+	if logRequest.follow {
+		instance.startFollowLog(logRequest)
+
+		return
 	}
 
 	go func() {
@@ -201,6 +243,10 @@ func (instance *Logging) getLog(request getLogRequest) (err error) {
 		}
 	}
 
+	if err = request.filter.addJournalMatches(journal); err != nil {
+		return err
+	}
+
 	if err = instance.seekToTime(journal, request.from); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -218,7 +264,8 @@ func (instance *Logging) getLog(request getLogRequest) (err error) {
 		return aoserrors.Wrap(err)
 	}
 
-	if err = instance.processJournalToGetInstanceLog(archInstance, journal, tillRealtime, needUnitField); err != nil {
+	if err = instance.processJournalToGetInstanceLog(
+		archInstance, journal, &request.filter, tillRealtime, needUnitField); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
@@ -230,7 +277,7 @@ func (instance *Logging) getLog(request getLogRequest) (err error) {
 }
 
 func (instance *Logging) processJournalToGetInstanceLog(
-	archInstance *archivator, journal JournalInterface, tillRealtime uint64, needUnitField bool,
+	archInstance *archivator, journal JournalInterface, filter *logFilter, tillRealtime uint64, needUnitField bool,
 ) error {
 	for {
 		rowCount, err := journal.Next()
@@ -257,6 +304,10 @@ func (instance *Logging) processJournalToGetInstanceLog(
 			break
 		}
 
+		if !filter.match(logEntry) {
+			continue
+		}
+
 		if err = archInstance.addLog(createLogString(logEntry, needUnitField)); err != nil {
 			if errors.Is(err, errMaxPartCount) {
 				log.Warn(err)
@@ -312,7 +363,7 @@ func (instance *Logging) getInstanceCrashLog(request getLogRequest) (err error)
 		return aoserrors.Wrap(err)
 	}
 
-	archInstance, err := instance.archivateCrashLog(journal, crashTime, request.instanceIDs)
+	archInstance, err := instance.archivateCrashLog(journal, &request.filter, crashTime, request.instanceIDs)
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -368,7 +419,7 @@ func (instance *Logging) getCrashTime(journal JournalInterface, from *time.Time)
 }
 
 func (instance *Logging) archivateCrashLog(
-	journal JournalInterface, crashTime uint64, instanceIDs []string,
+	journal JournalInterface, filter *logFilter, crashTime uint64, instanceIDs []string,
 ) (archivator *archivator, err error) {
 	archivator, err = newArchivator(instance.logChannel, instance.config.MaxPartSize, instance.config.MaxPartCount)
 	if err != nil {
@@ -397,6 +448,11 @@ func (instance *Logging) archivateCrashLog(
 			break
 		}
 
+		// Crash log uses unit matches to detect crash, so filter is applied in-process only
+		if !filter.match(logEntry) {
+			continue
+		}
+
 		for _, instanceID := range instanceIDs {
 			if strings.Contains(getUnitNameFromLog(logEntry), makeUnitNameFromInstanceID(instanceID)) {
 				if err = archivator.addLog(createLogString(logEntry, false)); err != nil {
@@ -461,6 +517,11 @@ func (instance *Logging) seekToTime(journal JournalInterface, from *time.Time) (
 func (instance *Logging) prepareInstanceLogRequest(
 	request cloudprotocol.RequestLog,
 ) (logRequest getLogRequest, err error) {
+	filter, err := parseLogFilter(request.LogID)
+	if err != nil {
+		return logRequest, err
+	}
+
 	instances, err := instance.instanceProvider.GetInstanceIDs(request.Filter.InstanceFilter)
 	if err != nil {
 		return logRequest, aoserrors.Wrap(err)
@@ -475,6 +536,23 @@ func (instance *Logging) prepareInstanceLogRequest(
 		logID:       request.LogID,
 		from:        request.Filter.From,
 		till:        request.Filter.Till,
+		filter:      filter,
+		follow:      isFollowLogRequest(request.LogID),
+	}, nil
+}
+
+func prepareSystemLogRequest(request cloudprotocol.RequestLog) (logRequest getLogRequest, err error) {
+	filter, err := parseLogFilter(request.LogID)
+	if err != nil {
+		return logRequest, err
+	}
+
+	return getLogRequest{
+		logID:  request.LogID,
+		from:   request.Filter.From,
+		till:   request.Filter.Till,
+		filter: filter,
+		follow: isFollowLogRequest(request.LogID),
 	}, nil
 }
 
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
 	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	"github.com/coreos/go-systemd/v22/sdjournal"
 	log "github.com/sirupsen/logrus"
@@ -471,6 +472,166 @@ func TestLogErrorCases(t *testing.T) {
 	}
 }
 
+func TestFollowSystemLog(t *testing.T) {
+	instanceProvider := testInstanceIDProvider{instances: make(map[string]cloudprotocol.InstanceFilter)}
+	defer instanceProvider.Close()
+
+	testJournal := testSystemdJournal{}
+	logging.SDJournal = &testJournal
+
+	logging, err := logging.New(&config.Config{Logging: config.Logging{
+		MaxPartSize: 1024, MaxPartCount: 10, FollowFlushInterval: aostypes.Duration{Duration: 50 * time.Millisecond},
+	}}, &instanceProvider)
+	if err != nil {
+		t.Fatalf("Can't create logging: %s", err)
+	}
+	defer logging.Close()
+
+	logging.GetSystemLog(cloudprotocol.RequestLog{LogID: "follow0?follow"})
+
+	// New entries should be sent in intermediate parts while log is followed, repeated request doesn't interrupt it
+
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			logging.GetSystemLog(cloudprotocol.RequestLog{LogID: "follow0?follow"})
+		}
+
+		message := fmt.Sprintf("Follow message %d", i)
+
+		testJournal.addMessage(message, "logger", "", "2")
+
+		pushLog := receiveFollowedLog(t, logging.GetLogsDataChannel(), message)
+
+		if pushLog.PartsCount != 0 || pushLog.Part != uint64(i+1) {
+			t.Errorf("Wrong intermediate part: %d/%d", pushLog.Part, pushLog.PartsCount)
+		}
+
+		if pushLog.LogID != "follow0?follow" {
+			t.Errorf("Wrong log ID: %s", pushLog.LogID)
+		}
+	}
+
+	logging.GetSystemLog(cloudprotocol.RequestLog{LogID: "follow0?cancel"})
+
+	if pushLog := receiveFollowedLog(t, logging.GetLogsDataChannel(), ""); pushLog.PartsCount != pushLog.Part {
+		t.Errorf("Wrong last part: %d/%d", pushLog.Part, pushLog.PartsCount)
+	}
+
+	select {
+	case pushLog := <-logging.GetLogsDataChannel():
+		t.Errorf("Unexpected log part: %d", pushLog.Part)
+
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestFollowLogDuration(t *testing.T) {
+	instanceProvider := testInstanceIDProvider{instances: make(map[string]cloudprotocol.InstanceFilter)}
+	defer instanceProvider.Close()
+
+	testJournal := testSystemdJournal{}
+	logging.SDJournal = &testJournal
+
+	logging, err := logging.New(&config.Config{Logging: config.Logging{
+		MaxPartSize: 1024, MaxPartCount: 10, FollowFlushInterval: aostypes.Duration{Duration: time.Minute},
+		MaxFollowDuration: aostypes.Duration{Duration: time.Minute},
+	}}, &instanceProvider)
+	if err != nil {
+		t.Fatalf("Can't create logging: %s", err)
+	}
+	defer logging.Close()
+
+	instanceFilter := cloudprotocol.NewInstanceFilter("followservice0", "subject0", 0)
+	instanceID := instanceProvider.addFilter(instanceFilter)
+	till := time.Now().Add(200 * time.Millisecond)
+
+	testJournal.addMessage("Instance message", aosServicePrefix+instanceID+systemdUnitExt, "", "2")
+
+	if err = logging.GetInstanceLog(cloudprotocol.RequestLog{
+		LogID:  "follow1?follow",
+		Filter: cloudprotocol.LogFilter{InstanceFilter: instanceFilter, Till: &till},
+	}); err != nil {
+		t.Fatalf("Can't follow instance log: %v", err)
+	}
+
+	// Follow should be finished with single part on requested till time
+
+	pushLog := receiveFollowedLog(t, logging.GetLogsDataChannel(), "Instance message")
+
+	if pushLog.PartsCount != 1 || pushLog.Part != 1 {
+		t.Errorf("Wrong last part: %d/%d", pushLog.Part, pushLog.PartsCount)
+	}
+
+	if time.Now().Before(till) {
+		t.Error("Follow log finished before till time")
+	}
+
+	if err = testJournal.isMatchesEqual([]string{aosServiceCGroup + instanceID}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFilteredLog(t *testing.T) {
+	instanceProvider := testInstanceIDProvider{instances: make(map[string]cloudprotocol.InstanceFilter)}
+	defer instanceProvider.Close()
+
+	testJournal := testSystemdJournal{}
+	logging.SDJournal = &testJournal
+
+	logging, err := logging.New(&config.Config{Logging: config.Logging{
+		MaxPartSize: 1024, MaxPartCount: 10,
+	}}, &instanceProvider)
+	if err != nil {
+		t.Fatalf("Can't create logging: %s", err)
+	}
+	defer logging.Close()
+
+	testJournal.addMessage("unit1 failed", "unit1.service", "", "3")
+	testJournal.addMessage("unit1 failed with debug priority", "unit1.service", "", "7")
+	testJournal.addMessage("unit1 started", "unit1.service", "", "2")
+	testJournal.addMessage("unit2 failed", "unit2.service", "", "3")
+
+	logID := "filteredLog?priority=err&pattern=fail&field=_SYSTEMD_UNIT=unit1.service"
+
+	logging.GetSystemLog(cloudprotocol.RequestLog{LogID: logID})
+
+	select {
+	case pushLog := <-logging.GetLogsDataChannel():
+		if pushLog.LogID != logID {
+			t.Errorf("Wrong log ID: %s", pushLog.LogID)
+		}
+
+		data := getLogContent(t, pushLog)
+
+		if !strings.Contains(data, "unit1 failed@@@@") || strings.Count(data, "\n") != 1 {
+			t.Errorf("Wrong filtered log: %s", data)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Receive log timeout")
+	}
+
+	if err = testJournal.isMatchesEqual([]string{
+		"PRIORITY=0", "PRIORITY=1", "PRIORITY=2", "PRIORITY=3", "_SYSTEMD_UNIT=unit1.service",
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err = testJournal.isMatchesEqual([]string{"PRIORITY=4"}); err == nil {
+		t.Error("Priority match should not be added")
+	}
+
+	// Wrong filter should be reported as log error
+
+	logging.GetSystemLog(cloudprotocol.RequestLog{LogID: "wrongFilter?priority=unknown"})
+
+	checkErrorLog(t, logging.GetLogsDataChannel())
+
+	logging.GetSystemLog(cloudprotocol.RequestLog{LogID: "wrongPattern?pattern=("})
+
+	checkErrorLog(t, logging.GetLogsDataChannel())
+}
+
 /***********************************************************************************************************************
  * Interfaces
  **********************************************************************************************************************/
@@ -559,6 +720,9 @@ func (journal *testSystemdJournal) Previous() (uint64, error) {
 }
 
 func (journal *testSystemdJournal) Next() (uint64, error) {
+	journal.Lock()
+	defer journal.Unlock()
+
 	if len(journal.messages) == 0 {
 		return uint64(sdjournal.SD_JOURNAL_NOP), nil
 	}
@@ -573,6 +737,9 @@ func (journal *testSystemdJournal) Next() (uint64, error) {
 }
 
 func (journal *testSystemdJournal) GetEntry() (entry *sdjournal.JournalEntry, err error) {
+	journal.RLock()
+	defer journal.RUnlock()
+
 	if journal.simulateError {
 		return entry, aoserrors.New("simulated error")
 	}
@@ -582,6 +749,16 @@ func (journal *testSystemdJournal) GetEntry() (entry *sdjournal.JournalEntry, er
 	return entry, nil
 }
 
+func (journal *testSystemdJournal) Wait(timeout time.Duration) int {
+	if timeout > 10*time.Millisecond {
+		timeout = 10 * time.Millisecond
+	}
+
+	time.Sleep(timeout)
+
+	return sdjournal.SD_JOURNAL_NOP
+}
+
 func (journal *testSystemdJournal) addMessage(message, systemdUnit, cgroupUnit, priority string) {
 	journalEntry := sdjournal.JournalEntry{Fields: make(map[string]string)}
 
@@ -596,6 +773,9 @@ func (journal *testSystemdJournal) addMessage(message, systemdUnit, cgroupUnit,
 	journalEntry.RealtimeTimestamp = uint64(currentTime.UnixNano() / 1000)
 	journalEntry.MonotonicTimestamp = uint64(currentTime.UnixNano() / 1000)
 
+	journal.Lock()
+	defer journal.Unlock()
+
 	journal.messages = append(journal.messages, &journalEntry)
 }
 
@@ -707,6 +887,46 @@ func checkReceivedLog(t *testing.T, logChannel <-chan cloudprotocol.PushLog, fro
 	}
 }
 
+func receiveFollowedLog(
+	t *testing.T, logChannel <-chan cloudprotocol.PushLog, expectedMessage string,
+) (pushLog cloudprotocol.PushLog) {
+	t.Helper()
+
+	select {
+	case pushLog = <-logChannel:
+		if data := getLogContent(t, pushLog); !strings.Contains(data, expectedMessage) {
+			t.Errorf("Wrong followed log: %s", data)
+		}
+
+		return pushLog
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Receive log timeout")
+	}
+
+	return pushLog
+}
+
+func getLogContent(t *testing.T, pushLog cloudprotocol.PushLog) string {
+	t.Helper()
+
+	if pushLog.ErrorInfo != nil {
+		t.Fatalf("Error log received: %s", pushLog.ErrorInfo.Message)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewBuffer(pushLog.Content))
+	if err != nil {
+		t.Fatalf("gzip error: %s", err)
+	}
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gzip error: %s", err)
+	}
+
+	return string(data)
+}
+
 func checkEmptyLog(t *testing.T, logChannel <-chan cloudprotocol.PushLog) {
 	t.Helper()
 
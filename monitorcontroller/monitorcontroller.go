@@ -18,46 +18,285 @@
 package monitorcontroller
 
 import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
 	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/storagequota"
 )
 
 /***********************************************************************************************************************
  * Consts
  **********************************************************************************************************************/
 
-const monitoringChannelSize = 64
+const (
+	defaultBufferSize = 1440
+	defaultBatchSize  = 32
+)
 
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
 
+// QuotaProvider provides instance partitions usage.
+type QuotaProvider interface {
+	GetInstanceUsage(instanceIdent aostypes.InstanceIdent) (usage []storagequota.PartitionUsage)
+}
+
+// Storage provides API to store monitoring buffer.
+type Storage interface {
+	AddMonitoringData(data StoredMonitoringData) error
+	RemoveMonitoringData(id uint64) error
+	GetMonitoringData() ([]StoredMonitoringData, error)
+}
+
+// StoredMonitoringData monitoring data stored in the buffer storage.
+type StoredMonitoringData struct {
+	ID        uint64
+	Timestamp time.Time
+	Data      []byte
+}
+
+// MonitoringData buffered monitoring data.
+type MonitoringData struct {
+	ID uint64
+	cloudprotocol.NodeMonitoringData
+}
+
 // MonitorController instance.
 type MonitorController struct {
-	monitoringChannel chan cloudprotocol.NodeMonitoringData
+	sync.Mutex
+	quotaProvider    QuotaProvider
+	storage          Storage
+	bufferSize       int
+	batchSize        int
+	downsamplePeriod time.Duration
+	buffer           []MonitoringData
+	nextID           uint64
+	notifyChannel    chan struct{}
 }
 
-// New creates new monitoringcontroller instance.
-func New() (monitor *MonitorController, err error) {
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates monitor controller. Storage is used only if persistent monitoring buffer is configured.
+func New(config *config.Config, quotaProvider QuotaProvider, storage Storage) (monitor *MonitorController, err error) {
 	monitor = &MonitorController{
-		monitoringChannel: make(chan cloudprotocol.NodeMonitoringData, monitoringChannelSize),
+		quotaProvider:    quotaProvider,
+		bufferSize:       config.MonitoringBuffer.Size,
+		batchSize:        config.MonitoringBuffer.BatchSize,
+		downsamplePeriod: config.MonitoringBuffer.DownsamplePeriod.Duration,
+		notifyChannel:    make(chan struct{}, 1),
+	}
+
+	if config.MonitoringBuffer.Persistent {
+		monitor.storage = storage
+	}
+
+	if monitor.bufferSize <= 0 {
+		monitor.bufferSize = defaultBufferSize
+	}
+
+	if monitor.batchSize <= 0 {
+		monitor.batchSize = defaultBatchSize
+	}
+
+	if err = monitor.restoreBuffer(); err != nil {
+		return nil, err
 	}
 
 	return monitor, nil
 }
 
-// SendMonitoringData sends monitoring data.
+// SendMonitoringData puts monitoring data into the buffer. The oldest data is dropped if the buffer is full.
 func (monitor *MonitorController) SendMonitoringData(monitoringData cloudprotocol.NodeMonitoringData) {
-	if len(monitor.monitoringChannel) < cap(monitor.monitoringChannel) {
-		monitor.monitoringChannel <- monitoringData
-	} else {
-		log.Warn("Skip sending monitoring data. Channel full.")
+	if monitor.quotaProvider != nil && len(monitoringData.ServiceInstances) != 0 {
+		monitor.updateInstancesPartitionsUsage(&monitoringData)
+	}
+
+	monitor.Lock()
+	defer monitor.Unlock()
+
+	if monitor.isDownsampled(monitoringData) {
+		return
+	}
+
+	if len(monitor.buffer) >= monitor.bufferSize {
+		log.Warn("Monitoring buffer full, drop the oldest monitoring data")
+
+		if err := monitor.removeData(monitor.buffer[0].ID); err != nil {
+			log.Errorf("Can't drop monitoring data: %v", err)
+		}
+	}
+
+	monitor.pushData(monitoringData)
+	monitor.notify()
+}
+
+// GetMonitoringNotifyChannel returns channel which notifies that there are new monitoring data in the buffer.
+func (monitor *MonitorController) GetMonitoringNotifyChannel() (channel <-chan struct{}) {
+	return monitor.notifyChannel
+}
+
+// GetMonitoringData returns batch of the oldest buffered monitoring data. Data should be removed from the buffer
+// with RemoveMonitoringData once it is delivered.
+func (monitor *MonitorController) GetMonitoringData() (monitoringData []MonitoringData) {
+	monitor.Lock()
+	defer monitor.Unlock()
+
+	count := len(monitor.buffer)
+	if count > monitor.batchSize {
+		count = monitor.batchSize
+	}
+
+	return append(monitoringData, monitor.buffer[:count]...)
+}
+
+// RemoveMonitoringData removes delivered monitoring data up to specified ID from the buffer.
+func (monitor *MonitorController) RemoveMonitoringData(id uint64) error {
+	monitor.Lock()
+	defer monitor.Unlock()
+
+	return monitor.removeData(id)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (monitor *MonitorController) restoreBuffer() error {
+	if monitor.storage == nil {
+		return nil
+	}
+
+	storedData, err := monitor.storage.GetMonitoringData()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, data := range storedData {
+		if data.ID >= monitor.nextID {
+			monitor.nextID = data.ID + 1
+		}
+
+		var monitoringData cloudprotocol.NodeMonitoringData
+
+		if err = json.Unmarshal(data.Data, &monitoringData); err != nil {
+			log.WithField("id", data.ID).Errorf("Can't decode stored monitoring data: %v", err)
+
+			continue
+		}
+
+		monitor.buffer = append(monitor.buffer, MonitoringData{ID: data.ID, NodeMonitoringData: monitoringData})
+	}
+
+	if len(monitor.buffer) > monitor.bufferSize {
+		if err = monitor.removeData(monitor.buffer[len(monitor.buffer)-monitor.bufferSize-1].ID); err != nil {
+			return err
+		}
+	}
+
+	if len(monitor.buffer) != 0 {
+		log.WithField("count", len(monitor.buffer)).Debug("Monitoring buffer restored")
+
+		monitor.notify()
+	}
+
+	return nil
+}
+
+func (monitor *MonitorController) isDownsampled(monitoringData cloudprotocol.NodeMonitoringData) bool {
+	if monitor.downsamplePeriod == 0 || len(monitor.buffer) == 0 {
+		return false
+	}
+
+	return monitoringData.Timestamp.Sub(monitor.buffer[len(monitor.buffer)-1].Timestamp) < monitor.downsamplePeriod
+}
+
+func (monitor *MonitorController) pushData(monitoringData cloudprotocol.NodeMonitoringData) {
+	data := MonitoringData{ID: monitor.nextID, NodeMonitoringData: monitoringData}
+
+	monitor.nextID++
+	monitor.buffer = append(monitor.buffer, data)
+
+	if monitor.storage == nil {
+		return
+	}
+
+	rawData, err := json.Marshal(monitoringData)
+	if err != nil {
+		log.Errorf("Can't encode monitoring data: %v", err)
+
+		return
+	}
+
+	if err = monitor.storage.AddMonitoringData(StoredMonitoringData{
+		ID: data.ID, Timestamp: monitoringData.Timestamp, Data: rawData,
+	}); err != nil {
+		log.Errorf("Can't store monitoring data: %v", err)
 	}
 }
 
-// GetMonitoringDataChannel get monitoring channel.
-func (monitor *MonitorController) GetMonitoringDataChannel() (
-	monitoringChannel <-chan cloudprotocol.NodeMonitoringData,
-) {
-	return monitor.monitoringChannel
+func (monitor *MonitorController) removeData(id uint64) error {
+	// Buffer is ordered by ID, remove all data up to the first newer one
+	index := slices.IndexFunc(monitor.buffer, func(data MonitoringData) bool { return data.ID > id })
+	if index == -1 {
+		index = len(monitor.buffer)
+	}
+
+	if index == 0 {
+		return nil
+	}
+
+	monitor.buffer = monitor.buffer[index:]
+
+	if monitor.storage == nil {
+		return nil
+	}
+
+	return aoserrors.Wrap(monitor.storage.RemoveMonitoringData(id))
+}
+
+func (monitor *MonitorController) notify() {
+	select {
+	case monitor.notifyChannel <- struct{}{}:
+
+	default:
+	}
+}
+
+// Quota usage is more accurate than the user usage reported by resource monitor as it covers files of any owner
+// and loop images. Partition usage message has no limit field, limits come from service quotas which are known to CM,
+// so only used size is updated here.
+func (monitor *MonitorController) updateInstancesPartitionsUsage(monitoringData *cloudprotocol.NodeMonitoringData) {
+	serviceInstances := make([]cloudprotocol.InstanceMonitoringData, len(monitoringData.ServiceInstances))
+
+	for i, instanceData := range monitoringData.ServiceInstances {
+		usage := monitor.quotaProvider.GetInstanceUsage(instanceData.InstanceIdent)
+
+		if len(usage) != 0 {
+			// Disk slice is shared with resource monitor, don't modify it
+			instanceData.Disk = append([]cloudprotocol.PartitionUsage{}, instanceData.Disk...)
+
+			for _, partitionUsage := range usage {
+				for j := range instanceData.Disk {
+					if instanceData.Disk[j].Name == partitionUsage.Name {
+						instanceData.Disk[j].UsedSize = partitionUsage.UsedSize
+					}
+				}
+			}
+		}
+
+		serviceInstances[i] = instanceData
+	}
+
+	monitoringData.ServiceInstances = serviceInstances
 }
@@ -20,15 +20,28 @@ package monitorcontroller_test
 import (
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aoscloud/aos_common/aostypes"
 	"github.com/aoscloud/aos_common/api/cloudprotocol"
-	"github.com/aoscloud/aos_servicemanager/monitorcontroller"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/monitorcontroller"
+	"github.com/aoscloud/aos_servicemanager/storagequota"
 )
 
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	sync.Mutex
+	data []monitorcontroller.StoredMonitoringData
+}
+
 /***********************************************************************************************************************
  * Init
  **********************************************************************************************************************/
@@ -60,7 +73,7 @@ func TestMain(m *testing.M) {
 func TestSendMonitorData(t *testing.T) {
 	duration := 100 * time.Millisecond
 
-	controller, err := monitorcontroller.New()
+	controller, err := monitorcontroller.New(&config.Config{}, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create monitoring controller: %v", err)
 	}
@@ -106,8 +119,11 @@ func TestSendMonitorData(t *testing.T) {
 	controller.SendMonitoringData(nodeMonitoringData)
 
 	select {
-	case receivedNodeMonitoringData := <-controller.GetMonitoringDataChannel():
-		if !reflect.DeepEqual(receivedNodeMonitoringData, nodeMonitoringData) {
+	case <-controller.GetMonitoringNotifyChannel():
+		receivedMonitoringData := controller.GetMonitoringData()
+
+		if len(receivedMonitoringData) != 1 ||
+			!reflect.DeepEqual(receivedMonitoringData[0].NodeMonitoringData, nodeMonitoringData) {
 			t.Errorf("Unexpected node monitoring data")
 		}
 
@@ -115,3 +131,207 @@ func TestSendMonitorData(t *testing.T) {
 		t.Fatal("Monitoring data timeout")
 	}
 }
+
+func TestInstancePartitionsQuotaUsage(t *testing.T) {
+	instanceIdent := aostypes.InstanceIdent{ServiceID: "serviceID", SubjectID: "subjectID", Instance: 0}
+
+	controller, err := monitorcontroller.New(&config.Config{}, &testQuotaProvider{
+		usage: map[aostypes.InstanceIdent][]storagequota.PartitionUsage{
+			instanceIdent: {{Name: "storage", UsedSize: 4096, Limit: 8192}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Can't create monitoring controller: %v", err)
+	}
+
+	instanceDisk := []cloudprotocol.PartitionUsage{{Name: "storage", UsedSize: 1024}, {Name: "state", UsedSize: 512}}
+
+	controller.SendMonitoringData(cloudprotocol.NodeMonitoringData{
+		NodeID: "nodeID",
+		ServiceInstances: []cloudprotocol.InstanceMonitoringData{
+			{InstanceIdent: instanceIdent, MonitoringData: cloudprotocol.MonitoringData{Disk: instanceDisk}},
+		},
+	})
+
+	select {
+	case <-controller.GetMonitoringNotifyChannel():
+		receivedNodeMonitoringData := controller.GetMonitoringData()[0]
+
+		if !reflect.DeepEqual(receivedNodeMonitoringData.ServiceInstances[0].Disk, []cloudprotocol.PartitionUsage{
+			{Name: "storage", UsedSize: 4096}, {Name: "state", UsedSize: 512},
+		}) {
+			t.Errorf("Wrong instance disk usage: %v", receivedNodeMonitoringData.ServiceInstances[0].Disk)
+		}
+
+	case <-time.After(1 * time.Second):
+		t.Fatal("Monitoring data timeout")
+	}
+
+	if instanceDisk[0].UsedSize != 1024 {
+		t.Error("Original monitoring data should not be modified")
+	}
+}
+
+func TestMonitoringBuffer(t *testing.T) {
+	controller, err := monitorcontroller.New(&config.Config{
+		MonitoringBuffer: config.MonitoringBuffer{Size: 10, BatchSize: 4},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create monitoring controller: %v", err)
+	}
+
+	timestamp := time.Now()
+
+	for i := 0; i < 12; i++ {
+		controller.SendMonitoringData(cloudprotocol.NodeMonitoringData{
+			NodeID: "nodeID", Timestamp: timestamp.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	// Two oldest samples are dropped on overflow, rest are delivered in batches with original timestamps
+
+	var receivedMonitoringData []monitorcontroller.MonitoringData
+
+	for batch := controller.GetMonitoringData(); len(batch) != 0; batch = controller.GetMonitoringData() {
+		if len(batch) > 4 {
+			t.Errorf("Wrong batch size: %d", len(batch))
+		}
+
+		receivedMonitoringData = append(receivedMonitoringData, batch...)
+
+		if err = controller.RemoveMonitoringData(batch[len(batch)-1].ID); err != nil {
+			t.Fatalf("Can't remove monitoring data: %v", err)
+		}
+	}
+
+	if len(receivedMonitoringData) != 10 {
+		t.Fatalf("Wrong monitoring data count: %d", len(receivedMonitoringData))
+	}
+
+	for i, data := range receivedMonitoringData {
+		if !data.Timestamp.Equal(timestamp.Add(time.Duration(i+2) * time.Minute)) {
+			t.Errorf("Wrong monitoring data timestamp: %v", data.Timestamp)
+		}
+	}
+}
+
+func TestDownsampledMonitoringBuffer(t *testing.T) {
+	controller, err := monitorcontroller.New(&config.Config{
+		MonitoringBuffer: config.MonitoringBuffer{DownsamplePeriod: aostypes.Duration{Duration: 5 * time.Minute}},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create monitoring controller: %v", err)
+	}
+
+	timestamp := time.Now()
+
+	for i := 0; i < 12; i++ {
+		controller.SendMonitoringData(cloudprotocol.NodeMonitoringData{
+			NodeID: "nodeID", Timestamp: timestamp.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	receivedMonitoringData := controller.GetMonitoringData()
+
+	if len(receivedMonitoringData) != 3 {
+		t.Fatalf("Wrong monitoring data count: %d", len(receivedMonitoringData))
+	}
+
+	for i, data := range receivedMonitoringData {
+		if !data.Timestamp.Equal(timestamp.Add(time.Duration(i*5) * time.Minute)) {
+			t.Errorf("Wrong monitoring data timestamp: %v", data.Timestamp)
+		}
+	}
+}
+
+func TestPersistentMonitoringBuffer(t *testing.T) {
+	storage := &testStorage{}
+	cfg := &config.Config{MonitoringBuffer: config.MonitoringBuffer{Size: 3, Persistent: true}}
+
+	controller, err := monitorcontroller.New(cfg, nil, storage)
+	if err != nil {
+		t.Fatalf("Can't create monitoring controller: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		controller.SendMonitoringData(cloudprotocol.NodeMonitoringData{
+			NodeID: "nodeID", MonitoringData: cloudprotocol.MonitoringData{RAM: uint64(i)},
+		})
+	}
+
+	// Delivered data should not be restored
+
+	if err = controller.RemoveMonitoringData(controller.GetMonitoringData()[0].ID); err != nil {
+		t.Fatalf("Can't remove monitoring data: %v", err)
+	}
+
+	// Restore buffer as after restart
+
+	if controller, err = monitorcontroller.New(cfg, nil, storage); err != nil {
+		t.Fatalf("Can't create monitoring controller: %v", err)
+	}
+
+	select {
+	case <-controller.GetMonitoringNotifyChannel():
+
+	case <-time.After(time.Second):
+		t.Error("Monitoring notification expected")
+	}
+
+	receivedMonitoringData := controller.GetMonitoringData()
+
+	if len(receivedMonitoringData) != 2 {
+		t.Fatalf("Wrong monitoring data count: %d", len(receivedMonitoringData))
+	}
+
+	for i, data := range receivedMonitoringData {
+		if data.RAM != uint64(i+2) {
+			t.Errorf("Wrong restored monitoring data: %v", data)
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * testStorage
+ **********************************************************************************************************************/
+
+func (storage *testStorage) AddMonitoringData(data monitorcontroller.StoredMonitoringData) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.data = append(storage.data, data)
+
+	return nil
+}
+
+func (storage *testStorage) RemoveMonitoringData(id uint64) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	for len(storage.data) != 0 && storage.data[0].ID <= id {
+		storage.data = storage.data[1:]
+	}
+
+	return nil
+}
+
+func (storage *testStorage) GetMonitoringData() ([]monitorcontroller.StoredMonitoringData, error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return append([]monitorcontroller.StoredMonitoringData{}, storage.data...), nil
+}
+
+/***********************************************************************************************************************
+ * testQuotaProvider
+ **********************************************************************************************************************/
+
+type testQuotaProvider struct {
+	usage map[aostypes.InstanceIdent][]storagequota.PartitionUsage
+}
+
+func (provider *testQuotaProvider) GetInstanceUsage(
+	instanceIdent aostypes.InstanceIdent,
+) (usage []storagequota.PartitionUsage) {
+	return provider.usage[instanceIdent]
+}
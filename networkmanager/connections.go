@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkmanager
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	cni "github.com/containernetworking/cni/libcni"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	firewallNetworkSuffix            = "-firewall"
+	allowedConnectionMinLen          = 2
+	allowedConnectionExpectedLen     = 3
+	allowedConnectionDefaultProtocol = "tcp"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type allowedConnection struct {
+	serviceID string
+	port      string
+	proto     string
+}
+
+type connectedInstance struct {
+	instanceID string
+	networkID  string
+	data       netInstanceData
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// AllowedConnections format serviceID/port/protocol, protocol is optional and tcp by default.
+func parseAllowedConnections(connections []string) (allowedConnections []allowedConnection, err error) {
+	for _, connection := range connections {
+		connectionConfig := strings.Split(connection, "/")
+		if len(connectionConfig) < allowedConnectionMinLen || len(connectionConfig) > allowedConnectionExpectedLen ||
+			connectionConfig[0] == "" || connectionConfig[1] == "" {
+			return nil, aoserrors.Errorf("unsupported AllowedConnections format %s", connection)
+		}
+
+		allowed := allowedConnection{
+			serviceID: connectionConfig[0],
+			port:      connectionConfig[1],
+			proto:     allowedConnectionDefaultProtocol,
+		}
+
+		if len(connectionConfig) == allowedConnectionExpectedLen && connectionConfig[2] != "" {
+			allowed.proto = connectionConfig[2]
+		}
+
+		allowedConnections = append(allowedConnections, allowed)
+	}
+
+	return allowedConnections, nil
+}
+
+func (manager *NetworkManager) resolveConnectionRules(
+	instanceID, instanceIP string, connections []allowedConnection,
+) (rules []aostypes.FirewallRule) {
+	if len(connections) == 0 {
+		return nil
+	}
+
+	manager.RLock()
+	defer manager.RUnlock()
+
+	for _, connection := range connections {
+		for _, instances := range manager.instancesData {
+			for dstInstanceID, dstData := range instances {
+				if dstInstanceID == instanceID || dstData.instanceIP == "" ||
+					dstData.serviceID != connection.serviceID {
+					continue
+				}
+
+				rules = append(rules, aostypes.FirewallRule{
+					DstIP:   dstData.instanceIP,
+					DstPort: connection.port,
+					Proto:   connection.proto,
+					SrcIP:   instanceIP,
+				})
+			}
+		}
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].DstIP != rules[j].DstIP {
+			return rules[i].DstIP < rules[j].DstIP
+		}
+
+		if rules[i].DstPort != rules[j].DstPort {
+			return rules[i].DstPort < rules[j].DstPort
+		}
+
+		return rules[i].Proto < rules[j].Proto
+	})
+
+	return rules
+}
+
+func (manager *NetworkManager) getConnectedInstances(serviceID, instanceID string) (instances []connectedInstance) {
+	manager.RLock()
+	defer manager.RUnlock()
+
+	for networkID, networkInstances := range manager.instancesData {
+	nextInstance:
+		for connectedInstanceID, data := range networkInstances {
+			if data.instanceIP == "" {
+				continue
+			}
+
+			if connectedInstanceID == instanceID {
+				instances = append(instances, connectedInstance{connectedInstanceID, networkID, data})
+
+				continue
+			}
+
+			for _, connection := range data.connections {
+				if connection.serviceID == serviceID {
+					instances = append(instances, connectedInstance{connectedInstanceID, networkID, data})
+
+					continue nextInstance
+				}
+			}
+		}
+	}
+
+	return instances
+}
+
+// refreshAllowedConnections updates firewall rules of the instance itself and all instances which are allowed
+// to connect to the specified service.
+func (manager *NetworkManager) refreshAllowedConnections(serviceID, instanceID string) {
+	manager.firewallMutex.Lock()
+	defer manager.firewallMutex.Unlock()
+
+	for _, instance := range manager.getConnectedInstances(serviceID, instanceID) {
+		if err := manager.updateInstanceFirewall(instance); err != nil {
+			log.WithFields(log.Fields{"instanceID": instance.instanceID}).Errorf(
+				"Can't update instance firewall: %v", err)
+		}
+	}
+}
+
+func (manager *NetworkManager) updateInstanceFirewall(instance connectedInstance) error {
+	rules := manager.resolveConnectionRules(instance.instanceID, instance.data.instanceIP, instance.data.connections)
+
+	if firewallRulesEqual(rules, instance.data.connectionRules) {
+		return nil
+	}
+
+	log.WithFields(log.Fields{"instanceID": instance.instanceID}).Debug("Update instance firewall")
+
+	pluginConfig, err := getFirewallPluginConfig(instance.instanceID, instance.data.exposedPorts,
+		append(append([]aostypes.FirewallRule{}, instance.data.firewallRules...), rules...))
+	if err != nil {
+		return err
+	}
+
+	firewallConfig, err := getInstanceFirewallConfig(instance.networkID, pluginConfig, instance.data)
+	if err != nil {
+		return err
+	}
+
+	_, runtimeConfig := getRuntimeNetConfig(instance.instanceID, instance.networkID)
+
+	// Delete previously applied firewall rules. It deletes the instance admin chain created by the network list
+	// as well, so it is always done with the new config.
+	if err = manager.cniInterface.DelNetwork(context.Background(), firewallConfig, runtimeConfig); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = manager.cniInterface.AddNetwork(context.Background(), firewallConfig, runtimeConfig); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	manager.Lock()
+	defer manager.Unlock()
+
+	data, ok := manager.instancesData[instance.networkID][instance.instanceID]
+	if !ok {
+		return aoserrors.Errorf("can't find network instanceID: %s", instance.instanceID)
+	}
+
+	data.connectionRules = rules
+	data.firewallConfig = firewallConfig
+
+	manager.instancesData[instance.networkID][instance.instanceID] = data
+
+	return nil
+}
+
+func (manager *NetworkManager) deleteInstanceFirewall(instanceID, networkID string) error {
+	manager.RLock()
+	firewallConfig := manager.instancesData[networkID][instanceID].firewallConfig
+	manager.RUnlock()
+
+	if firewallConfig == nil {
+		return nil
+	}
+
+	_, runtimeConfig := getRuntimeNetConfig(instanceID, networkID)
+
+	if err := manager.cniInterface.DelNetwork(context.Background(), firewallConfig, runtimeConfig); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func getInstanceFirewallConfig(
+	networkID string, pluginConfig json.RawMessage, data netInstanceData,
+) (firewallConfig *cni.NetworkConfig, err error) {
+	var rawConfig map[string]interface{}
+
+	if err = json.Unmarshal(pluginConfig, &rawConfig); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	// Use separate network name to not override cached config of the instance network list
+	rawConfig["name"] = networkID + firewallNetworkSuffix
+	rawConfig["cniVersion"] = cniVersion
+
+	if data.cniResult != nil {
+		if rawConfig["prevResult"], err = data.cniResult.GetAsVersion(cniVersion); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	configBytes, err := json.Marshal(rawConfig)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if firewallConfig, err = cni.ConfFromBytes(configBytes); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return firewallConfig, nil
+}
+
+func firewallRulesEqual(rules1, rules2 []aostypes.FirewallRule) bool {
+	if len(rules1) != len(rules2) {
+		return false
+	}
+
+	for i := range rules1 {
+		if rules1[i] != rules2[i] {
+			return false
+		}
+	}
+
+	return true
+}
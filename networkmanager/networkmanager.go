@@ -26,6 +26,7 @@ import (
 	"net"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -78,8 +79,15 @@ type Storage interface {
 }
 
 type netInstanceData struct {
-	instanceIP string
-	hosts      []string
+	instanceIP      string
+	hosts           []string
+	serviceID       string
+	exposedPorts    []string
+	firewallRules   []aostypes.FirewallRule
+	connections     []allowedConnection
+	connectionRules []aostypes.FirewallRule
+	cniResult       types.Result
+	firewallConfig  *cni.NetworkConfig
 }
 
 // NetworkManager network manager instance.
@@ -92,6 +100,7 @@ type NetworkManager struct {
 	instancesData     map[string]map[string]netInstanceData
 	providerNetworks  map[string]NetworkParameters
 	vlanIfNames       map[string]string
+	firewallMutex     sync.Mutex
 
 	storage Storage
 }
@@ -105,6 +114,18 @@ type NetworkParameters struct {
 	VlanIfName string
 }
 
+// NetworkInfo provider network info with instances connected to it.
+type NetworkInfo struct {
+	NetworkParameters
+	Instances []NetworkInstanceInfo
+}
+
+// NetworkInstanceInfo instance network info.
+type NetworkInstanceInfo struct {
+	InstanceID string
+	IP         string
+}
+
 // NetworkParams network parameters set for instance.
 type NetworkParams struct {
 	aostypes.InstanceIdent
@@ -114,6 +135,7 @@ type NetworkParams struct {
 	IngressKbit        uint64
 	EgressKbit         uint64
 	ExposedPorts       []string
+	AllowedConnections []string
 	Hosts              []aostypes.Host
 	DNSSevers          []string
 	HostsFilePath      string
@@ -304,6 +326,11 @@ func (manager *NetworkManager) AddInstanceToNetwork(instanceID, networkID string
 		return aoserrors.Errorf("Instance %s already in the network %s", instanceID, networkID)
 	}
 
+	connections, err := parseAllowedConnections(params.AllowedConnections)
+	if err != nil {
+		return err
+	}
+
 	manager.addInstanceNetworkToCache(instanceID, networkID)
 
 	defer func() {
@@ -326,7 +353,9 @@ func (manager *NetworkManager) AddInstanceToNetwork(instanceID, networkID string
 		}
 	}()
 
-	netConfig, runtimeConfig, hosts, err := manager.prepareCNIConfig(instanceID, networkID, params)
+	connectionRules := manager.resolveConnectionRules(instanceID, params.IP, connections)
+
+	netConfig, runtimeConfig, hosts, err := manager.prepareCNIConfig(instanceID, networkID, params, connectionRules)
 	if err != nil {
 		return err
 	}
@@ -339,12 +368,12 @@ func (manager *NetworkManager) AddInstanceToNetwork(instanceID, networkID string
 		}
 	}()
 
-	nameservers, instanceIP, err := manager.addNetwork(instanceID, netConfig, runtimeConfig)
+	result, instanceIP, err := manager.addNetwork(instanceID, netConfig, runtimeConfig)
 	if err != nil {
 		return err
 	}
 
-	if err = createResolvConfAndHostFile(networkID, instanceIP, nameservers, params); err != nil {
+	if err = createResolvConfAndHostFile(networkID, instanceIP, result.DNS.Nameservers, params); err != nil {
 		return err
 	}
 
@@ -355,10 +384,21 @@ func (manager *NetworkManager) AddInstanceToNetwork(instanceID, networkID string
 		}
 	}
 
-	if err = manager.updateInstanceNetworkCache(instanceID, networkID, instanceIP, hosts); err != nil {
+	if err = manager.updateInstanceNetworkCache(instanceID, networkID, netInstanceData{
+		instanceIP:      instanceIP,
+		hosts:           hosts,
+		serviceID:       params.ServiceID,
+		exposedPorts:    params.ExposedPorts,
+		firewallRules:   params.FirewallRules,
+		connections:     connections,
+		connectionRules: connectionRules,
+		cniResult:       result,
+	}); err != nil {
 		return err
 	}
 
+	manager.refreshAllowedConnections(params.ServiceID, instanceID)
+
 	log.WithFields(log.Fields{
 		"instanceID": instanceID,
 		"IP":         instanceIP,
@@ -381,11 +421,14 @@ func (manager *NetworkManager) RemoveInstanceFromNetwork(instanceID, networkID s
 		}
 	}
 
-	if err := manager.removeInstanceFromNetwork(instanceID, networkID); err != nil {
-		return aoserrors.Wrap(err)
+	serviceID, err := manager.removeInstanceWithFirewall(instanceID, networkID)
+	if err != nil {
+		return err
 	}
 
-	return manager.deleteInstanceNetworkFromCache(instanceID, networkID)
+	manager.refreshAllowedConnections(serviceID, "")
+
+	return nil
 }
 
 // GetInstanceIP return instance IP address.
@@ -404,6 +447,34 @@ func (manager *NetworkManager) GetInstanceIP(instanceID, networkID string) (ip s
 	return manager.instancesData[networkID][instanceID].instanceIP, nil
 }
 
+// GetNetworksInfo returns provider networks with connected instances.
+func (manager *NetworkManager) GetNetworksInfo() (networks []NetworkInfo) {
+	manager.RLock()
+	defer manager.RUnlock()
+
+	networks = make([]NetworkInfo, 0, len(manager.providerNetworks))
+
+	for networkID, networkParameters := range manager.providerNetworks {
+		networkInfo := NetworkInfo{NetworkParameters: networkParameters}
+
+		for instanceID, instanceData := range manager.instancesData[networkID] {
+			networkInfo.Instances = append(networkInfo.Instances, NetworkInstanceInfo{
+				InstanceID: instanceID, IP: instanceData.instanceIP,
+			})
+		}
+
+		sort.Slice(networkInfo.Instances, func(i, j int) bool {
+			return networkInfo.Instances[i].InstanceID < networkInfo.Instances[j].InstanceID
+		})
+
+		networks = append(networks, networkInfo)
+	}
+
+	sort.Slice(networks, func(i, j int) bool { return networks[i].NetworkID < networks[j].NetworkID })
+
+	return networks
+}
+
 func (manager *NetworkManager) GetSystemTraffic() (inputTraffic, outputTraffic uint64, err error) {
 	if manager.trafficMonitoring == nil {
 		return 0, 0, errTrafficMonitorDisable
@@ -559,21 +630,40 @@ next:
 	return "", errors.New("failed to generate vlan name")
 }
 
+func (manager *NetworkManager) removeInstanceWithFirewall(instanceID, networkID string) (serviceID string, err error) {
+	manager.firewallMutex.Lock()
+	defer manager.firewallMutex.Unlock()
+
+	manager.RLock()
+	serviceID = manager.instancesData[networkID][instanceID].serviceID
+	manager.RUnlock()
+
+	if err := manager.deleteInstanceFirewall(instanceID, networkID); err != nil {
+		log.WithFields(log.Fields{"instanceID": instanceID}).Errorf("Can't delete instance firewall: %v", err)
+	}
+
+	if err := manager.removeInstanceFromNetwork(instanceID, networkID); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err := manager.deleteInstanceNetworkFromCache(instanceID, networkID); err != nil {
+		return "", err
+	}
+
+	return serviceID, nil
+}
+
 func (manager *NetworkManager) updateInstanceNetworkCache(
-	instanceID, networkID, instanceIP string, hosts []string,
+	instanceID, networkID string, instanceData netInstanceData,
 ) error {
 	manager.Lock()
 	defer manager.Unlock()
 
-	networkInstanceData, ok := manager.instancesData[networkID][instanceID]
-	if !ok {
+	if _, ok := manager.instancesData[networkID][instanceID]; !ok {
 		return aoserrors.Errorf("can't find network instanceID: %s", instanceID)
 	}
 
-	networkInstanceData.hosts = hosts
-	networkInstanceData.instanceIP = instanceIP
-
-	manager.instancesData[networkID][instanceID] = networkInstanceData
+	manager.instancesData[networkID][instanceID] = instanceData
 
 	return nil
 }
@@ -628,15 +718,14 @@ func createResolvConfAndHostFile(networkID, instanceIP string, nameservers []str
 
 func (manager *NetworkManager) addNetwork(
 	instanceID string, netConfig *cni.NetworkConfigList, runtimeConfig *cni.RuntimeConf) (
-	nameservers []string, instanceIP string, err error,
+	result *current.Result, instanceIP string, err error,
 ) {
 	resAdd, err := manager.cniInterface.AddNetworkList(context.Background(), netConfig, runtimeConfig)
 	if err != nil {
 		return nil, "", aoserrors.Wrap(err)
 	}
 
-	result, err := current.GetResult(resAdd)
-	if err != nil {
+	if result, err = current.GetResult(resAdd); err != nil {
 		return nil, "", aoserrors.Wrap(err)
 	}
 
@@ -644,17 +733,19 @@ func (manager *NetworkManager) addNetwork(
 		return nil, "", aoserrors.Errorf("error getting IP address for instance %s", instanceID)
 	}
 
-	return result.DNS.Nameservers, result.IPs[0].Address.IP.String(), nil
+	return result, result.IPs[0].Address.IP.String(), nil
 }
 
 func (manager *NetworkManager) prepareCNIConfig(
-	instanceID, networkID string, params NetworkParams) (
+	instanceID, networkID string, params NetworkParams, connectionRules []aostypes.FirewallRule) (
 	netConfig *cni.NetworkConfigList, runtimeConfig *cni.RuntimeConf, hosts []string, err error,
 ) {
 	if hosts, err = manager.prepareHostnameList(networkID, params); err != nil {
 		return nil, nil, nil, err
 	}
 
+	params.FirewallRules = append(append([]aostypes.FirewallRule{}, params.FirewallRules...), connectionRules...)
+
 	if netConfig, err = prepareNetworkConfigList(manager.networkDir, instanceID, networkID, params); err != nil {
 		return nil, nil, nil, aoserrors.Wrap(err)
 	}
@@ -58,6 +58,8 @@ type testCNIInterface struct {
 	errorAddNetwork      bool
 	emptyIPAddress       bool
 	errorValidateNetwork bool
+	instanceIPs          map[string]string
+	firewallConfigs      map[string]*cni.NetworkConfig
 }
 
 type cniNetwork struct {
@@ -1159,6 +1161,118 @@ func (storage *testStorage) GetNetworksInfo() (netInfos []networkmanager.Network
 	return netInfos, nil
 }
 
+func TestAllowedConnections(t *testing.T) {
+	cniInterface := &testCNIInterface{
+		instanceIPs: map[string]string{
+			"instance0": "172.17.0.10",
+			"instance1": "172.17.0.11",
+			"instance2": "172.18.0.12",
+			"instance3": "172.17.0.13",
+		},
+		firewallConfigs: make(map[string]*cni.NetworkConfig),
+	}
+
+	networkmanager.CNIPlugins = cniInterface
+	networkmanager.IPTables = &testIPTablesInterface{chain: make(map[string]iptablesData)}
+	networkmanager.UpdateIptablesCachePeriod = 1 * time.Minute
+
+	storage := testStorage{chains: make(map[string]trafficData)}
+
+	manager, err := networkmanager.New(&config.Config{}, &storage)
+	if err != nil {
+		t.Fatalf("Can't create network manager: %s", err)
+	}
+	defer manager.Close()
+
+	addInstance := func(instanceID, serviceID, networkID, ip string, allowedConnections []string) {
+		t.Helper()
+
+		if err := manager.AddInstanceToNetwork(instanceID, networkID, networkmanager.NetworkParams{
+			InstanceIdent:      aostypes.InstanceIdent{ServiceID: serviceID},
+			NetworkParameters:  aostypes.NetworkParameters{IP: ip, Subnet: ip + "/16"},
+			AllowedConnections: allowedConnections,
+		}); err != nil {
+			t.Fatalf("Can't add instance to network: %v", err)
+		}
+	}
+
+	checkFirewallRules := func(instanceID string, pluginConfig []byte, expectedRules []aostypes.FirewallRule) {
+		t.Helper()
+
+		var firewallConfig struct {
+			OutputAccess []aostypes.FirewallRule `json:"outputAccess"`
+		}
+
+		if pluginConfig != nil {
+			if err := json.Unmarshal(pluginConfig, &firewallConfig); err != nil {
+				t.Fatalf("Can't parse firewall config: %v", err)
+			}
+		}
+
+		if !reflect.DeepEqual(firewallConfig.OutputAccess, expectedRules) {
+			t.Errorf("Wrong %s firewall rules: %v", instanceID, firewallConfig.OutputAccess)
+		}
+	}
+
+	if err := manager.AddInstanceToNetwork("instance0", "network0", networkmanager.NetworkParams{
+		AllowedConnections: []string{"service1"},
+	}); err == nil {
+		t.Error("Should be error: unsupported AllowedConnections format")
+	}
+
+	addInstance("instance0", "service0", "network0", "172.17.0.10", []string{"service1/8080/udp", "service2/80"})
+
+	checkFirewallRules("instance0", cniInterface.networkConfig.Plugins[1].Bytes, nil)
+
+	addInstance("instance1", "service1", "network0", "172.17.0.11", nil)
+
+	checkFirewallRules("instance0", cniInterface.firewallConfigs["instance0"].Bytes, []aostypes.FirewallRule{
+		{DstIP: "172.17.0.11", DstPort: "8080", Proto: "udp", SrcIP: "172.17.0.10"},
+	})
+
+	addInstance("instance2", "service2", "network1", "172.18.0.12", nil)
+
+	checkFirewallRules("instance0", cniInterface.firewallConfigs["instance0"].Bytes, []aostypes.FirewallRule{
+		{DstIP: "172.17.0.11", DstPort: "8080", Proto: "udp", SrcIP: "172.17.0.10"},
+		{DstIP: "172.18.0.12", DstPort: "80", Proto: "tcp", SrcIP: "172.17.0.10"},
+	})
+
+	addInstance("instance3", "service3", "network0", "172.17.0.13", []string{"service2/443/tcp"})
+
+	checkFirewallRules("instance3", cniInterface.networkConfig.Plugins[1].Bytes, []aostypes.FirewallRule{
+		{DstIP: "172.18.0.12", DstPort: "443", Proto: "tcp", SrcIP: "172.17.0.13"},
+	})
+
+	if _, ok := cniInterface.firewallConfigs["instance3"]; ok {
+		t.Error("Firewall of instance3 should not be updated")
+	}
+
+	if err := manager.RemoveInstanceFromNetwork("instance1", "network0"); err != nil {
+		t.Fatalf("Can't remove instance from network: %v", err)
+	}
+
+	checkFirewallRules("instance0", cniInterface.firewallConfigs["instance0"].Bytes, []aostypes.FirewallRule{
+		{DstIP: "172.18.0.12", DstPort: "80", Proto: "tcp", SrcIP: "172.17.0.10"},
+	})
+
+	if err := manager.RemoveInstanceFromNetwork("instance2", "network1"); err != nil {
+		t.Fatalf("Can't remove instance from network: %v", err)
+	}
+
+	checkFirewallRules("instance0", cniInterface.firewallConfigs["instance0"].Bytes, nil)
+	checkFirewallRules("instance3", cniInterface.firewallConfigs["instance3"].Bytes, nil)
+
+	for _, instanceID := range []string{"instance0", "instance3"} {
+		if err := manager.RemoveInstanceFromNetwork(instanceID, "network0"); err != nil {
+			t.Fatalf("Can't remove instance from network: %v", err)
+		}
+
+		if _, ok := cniInterface.firewallConfigs[instanceID]; ok {
+			t.Errorf("Firewall of %s should be deleted", instanceID)
+		}
+	}
+}
+
 func createPlugins(plugins []string) string {
 	networkConfig := `{"name":"network0","cniVersion":"0.4.0","plugins":[`
 
@@ -1269,8 +1383,14 @@ func (c *testCNIInterface) AddNetworkList(ctx context.Context, list *cni.Network
 	}
 
 	if !c.emptyIPAddress {
+		instanceIP := "192.168.0.1"
+
+		if ip, ok := c.instanceIPs[rt.ContainerID]; ok {
+			instanceIP = ip
+		}
+
 		ipConfig := &current.IPConfig{
-			Address: net.IPNet{IP: net.ParseIP("192.168.0.1")},
+			Address: net.IPNet{IP: net.ParseIP(instanceIP)},
 		}
 
 		result.IPs = append(result.IPs, ipConfig)
@@ -1319,6 +1439,10 @@ func (c *testCNIInterface) DelNetworkList(ctx context.Context, list *cni.Network
 func (c *testCNIInterface) AddNetwork(
 	ctx context.Context, net *cni.NetworkConfig, rt *cni.RuntimeConf,
 ) (types.Result, error) {
+	if c.firewallConfigs != nil {
+		c.firewallConfigs[rt.ContainerID] = net
+	}
+
 	return nil, nil
 }
 
@@ -1333,6 +1457,10 @@ func (c *testCNIInterface) CheckNetworkList(
 }
 
 func (c *testCNIInterface) DelNetwork(ctx context.Context, net *cni.NetworkConfig, rt *cni.RuntimeConf) error {
+	if c.firewallConfigs != nil {
+		delete(c.firewallConfigs, rt.ContainerID)
+	}
+
 	return nil
 }
 
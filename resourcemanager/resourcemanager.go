@@ -280,6 +280,24 @@ func (resourcemanager *ResourceManager) GetDeviceInstances(device string) ([]str
 	return instances, nil
 }
 
+// GetAllocatedDevices returns allocated devices with ID list of instances that allocate them.
+func (resourcemanager *ResourceManager) GetAllocatedDevices() (allocatedDevices map[string][]string) {
+	resourcemanager.Lock()
+	defer resourcemanager.Unlock()
+
+	allocatedDevices = make(map[string][]string)
+
+	for device, instances := range resourcemanager.allocatedDevices {
+		if len(instances) == 0 {
+			continue
+		}
+
+		allocatedDevices[device] = append([]string(nil), instances...)
+	}
+
+	return allocatedDevices
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
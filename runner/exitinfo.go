@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	runtimespec "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Instance termination reasons.
+const (
+	ReasonExitCode      = "exit-code"
+	ReasonSignal        = "signal"
+	ReasonOOMKill       = "oom-kill"
+	ReasonStartLimitHit = "start-limit-hit"
+	ReasonTimeout       = "timeout"
+)
+
+// Values of systemd ExecMainCode property (see waitid(2)).
+const (
+	cldExited = 1
+	cldKilled = 2
+	cldDumped = 3
+)
+
+const (
+	runtimeConfigFile = "config.json"
+	memoryEventsFile  = "memory.events"
+	oomKillEvent      = "oom_kill"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// CgroupsDir cgroup v2 mount point.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var CgroupsDir = "/sys/fs/cgroup"
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// getUnitExitInfo converts systemd unit ExecMainCode, ExecMainStatus and Result properties to instance exit info.
+func getUnitExitInfo(execMainCode, execMainStatus int, result string) (exitCode, signal int, reason string) {
+	switch execMainCode {
+	case cldExited:
+		exitCode = execMainStatus
+		reason = ReasonExitCode
+
+	case cldKilled, cldDumped:
+		signal = execMainStatus
+		exitCode = signalExitCodeBase + signal
+		reason = ReasonSignal
+	}
+
+	switch result {
+	case ReasonOOMKill, ReasonStartLimitHit, ReasonTimeout:
+		reason = result
+	}
+
+	return exitCode, signal, reason
+}
+
+// isContainerOOMKilled checks OOM kill events of container cgroup. Relative or empty cgroups path is nested into
+// the parent cgroup of OCI runtime.
+func isContainerOOMKilled(
+	instanceID, runtimeDir string, getParentCgroup func() (string, error),
+) (oomKilled bool, err error) {
+	cgroupDir, err := getContainerCgroupDir(instanceID, runtimeDir, getParentCgroup)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := getOOMKillCount(cgroupDir)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// getContainerCgroupDir returns container cgroup dir as it is created by OCI runtime with cgroupfs driver.
+func getContainerCgroupDir(instanceID, runtimeDir string, getParentCgroup func() (string, error)) (string, error) {
+	var spec runtimespec.Spec
+
+	data, err := os.ReadFile(filepath.Join(runtimeDir, runtimeConfigFile))
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = json.Unmarshal(data, &spec); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if spec.Linux != nil && filepath.IsAbs(spec.Linux.CgroupsPath) {
+		return filepath.Join(CgroupsDir, spec.Linux.CgroupsPath), nil
+	}
+
+	parentCgroup, err := getParentCgroup()
+	if err != nil {
+		return "", err
+	}
+
+	cgroupsPath := instanceID
+
+	if spec.Linux != nil && spec.Linux.CgroupsPath != "" {
+		cgroupsPath = spec.Linux.CgroupsPath
+	}
+
+	return filepath.Join(CgroupsDir, parentCgroup, cgroupsPath), nil
+}
+
+func getOwnCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if cgroup, ok := strings.CutPrefix(line, "0::"); ok {
+			return cgroup, nil
+		}
+	}
+
+	return "", aoserrors.New("cgroup v2 is not available")
+}
+
+func getOOMKillCount(cgroupDir string) (count uint64, err error) {
+	file, err := os.Open(filepath.Join(cgroupDir, memoryEventsFile))
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 2 && fields[0] == oomKillEvent {
+			if count, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return 0, aoserrors.Wrap(err)
+			}
+
+			return count, nil
+		}
+	}
+
+	return 0, aoserrors.Wrap(scanner.Err())
+}
+
+func getSignalName(signal int) string {
+	if name := unix.SignalName(syscall.Signal(signal)); name != "" {
+		return name
+	}
+
+	return strconv.Itoa(signal)
+}
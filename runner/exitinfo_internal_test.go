@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestGetUnitExitInfo(t *testing.T) {
+	type testData struct {
+		name           string
+		execMainCode   int
+		execMainStatus int
+		result         string
+		exitCode       int
+		signal         int
+		reason         string
+	}
+
+	data := []testData{
+		{
+			name: "clean exit", execMainCode: cldExited, execMainStatus: 0, result: "success",
+			exitCode: 0, signal: 0, reason: ReasonExitCode,
+		},
+		{
+			name: "non-zero exit code", execMainCode: cldExited, execMainStatus: 3, result: "exit-code",
+			exitCode: 3, signal: 0, reason: ReasonExitCode,
+		},
+		{
+			name: "killed by signal", execMainCode: cldKilled, execMainStatus: 9, result: "signal",
+			exitCode: 137, signal: 9, reason: ReasonSignal,
+		},
+		{
+			name: "core dumped", execMainCode: cldDumped, execMainStatus: 11, result: "core-dump",
+			exitCode: 139, signal: 11, reason: ReasonSignal,
+		},
+		{
+			name: "oom kill", execMainCode: cldKilled, execMainStatus: 9, result: "oom-kill",
+			exitCode: 137, signal: 9, reason: ReasonOOMKill,
+		},
+		{
+			name: "timeout", execMainCode: cldKilled, execMainStatus: 15, result: "timeout",
+			exitCode: 143, signal: 15, reason: ReasonTimeout,
+		},
+		{
+			name: "start limit hit", execMainCode: 0, execMainStatus: 0, result: "start-limit-hit",
+			exitCode: 0, signal: 0, reason: ReasonStartLimitHit,
+		},
+	}
+
+	for _, item := range data {
+		t.Run(item.name, func(t *testing.T) {
+			exitCode, signal, reason := getUnitExitInfo(item.execMainCode, item.execMainStatus, item.result)
+
+			if exitCode != item.exitCode || signal != item.signal || reason != item.reason {
+				t.Errorf("Wrong exit info: exitCode %d, signal %d, reason %s", exitCode, signal, reason)
+			}
+		})
+	}
+}
+
+func TestContainerOOMKilledInUnitCgroup(t *testing.T) {
+	tmpDir := t.TempDir()
+	runtimeDir := filepath.Join(tmpDir, "runtime")
+	unitName := "aos-service@instance0.service"
+
+	CgroupsDir = filepath.Join(tmpDir, "cgroup")
+
+	t.Cleanup(func() { CgroupsDir = "/sys/fs/cgroup" })
+
+	if err := os.MkdirAll(runtimeDir, 0o755); err != nil {
+		t.Fatalf("Can't create runtime dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(runtimeDir, runtimeConfigFile), []byte(`{"linux":{}}`), 0o600); err != nil {
+		t.Fatalf("Can't write runtime config: %v", err)
+	}
+
+	getParentCgroup := func() (string, error) { return getUnitCgroup(unitName, ""), nil }
+
+	if _, err := isContainerOOMKilled("instance0", runtimeDir, getParentCgroup); err == nil {
+		t.Error("Error expected if container cgroup doesn't exist")
+	}
+
+	cgroupDir := filepath.Join(CgroupsDir, systemSliceCgroup, unitName, "instance0")
+
+	if err := os.MkdirAll(cgroupDir, 0o755); err != nil {
+		t.Fatalf("Can't create cgroup dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(cgroupDir, memoryEventsFile),
+		[]byte("low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n"), 0o600); err != nil {
+		t.Fatalf("Can't write memory events: %v", err)
+	}
+
+	oomKilled, err := isContainerOOMKilled("instance0", runtimeDir, getParentCgroup)
+	if err != nil {
+		t.Fatalf("Can't check OOM kill: %v", err)
+	}
+
+	if oomKilled {
+		t.Error("Unexpected OOM kill")
+	}
+
+	if err := os.WriteFile(filepath.Join(cgroupDir, memoryEventsFile),
+		[]byte("low 0\nhigh 0\nmax 2\noom 1\noom_kill 1\n"), 0o600); err != nil {
+		t.Fatalf("Can't write memory events: %v", err)
+	}
+
+	if oomKilled, err = isContainerOOMKilled("instance0", runtimeDir, getParentCgroup); err != nil {
+		t.Fatalf("Can't check OOM kill: %v", err)
+	}
+
+	if !oomKilled {
+		t.Error("OOM kill expected")
+	}
+}
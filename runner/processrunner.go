@@ -0,0 +1,495 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Instance runner types.
+const (
+	SystemdRunnerType = "systemd"
+	ProcessRunnerType = "process"
+)
+
+const defaultOCIRuntime = "runc"
+
+const signalExitCodeBase = 128
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ProcessRunner runs service instances as OCI runtime processes supervised directly by SM.
+type ProcessRunner struct {
+	sync.Mutex
+	instanceStatusChan chan []InstanceStatus
+	instances          map[string]*processInstance
+}
+
+type processInstance struct {
+	sync.Mutex
+	status      InstanceStatus
+	runtimeDir  string
+	runtime     string
+	params      RunParameters
+	cmd         *exec.Cmd
+	starting    bool
+	stopped     bool
+	startTimes  []time.Time
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ExecCommand creates OCI runtime command.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var ExecCommand = exec.Command
+
+// StopTimeout time to wait for instance process exit after SIGKILL request.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var StopTimeout = 10 * time.Second
+
+var (
+	errStartLimit = errors.New("start limit reached")
+	errStopped    = errors.New("instance stopped")
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// NewProcessRunner creates new process runner.
+func NewProcessRunner() (runner *ProcessRunner, err error) {
+	log.Debug("New process runner")
+
+	runner = &ProcessRunner{
+		instanceStatusChan: make(chan []InstanceStatus, unitStatusChannelSize),
+		instances:          make(map[string]*processInstance),
+	}
+
+	return runner, nil
+}
+
+// Close closes process runner.
+func (runner *ProcessRunner) Close() {
+	log.Debug("Close process runner")
+
+	runner.Lock()
+
+	instanceIDs := make([]string, 0, len(runner.instances))
+
+	for instanceID := range runner.instances {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+
+	runner.Unlock()
+
+	for _, instanceID := range instanceIDs {
+		if err := runner.StopInstance(instanceID); err != nil {
+			log.WithField("instanceID", instanceID).Errorf("Can't stop instance: %v", err)
+		}
+	}
+}
+
+// InstanceStatusChannel returns instance status channel.
+func (runner *ProcessRunner) InstanceStatusChannel() <-chan []InstanceStatus {
+	return runner.instanceStatusChan
+}
+
+// StartInstance starts service instance as OCI runtime process.
+func (runner *ProcessRunner) StartInstance(instanceID, runtimeDir string, params RunParameters) InstanceStatus {
+	if params.StartInterval == 0 {
+		params.StartInterval = defaultStartInterval
+	}
+
+	if params.StartBurst == 0 {
+		params.StartBurst = defaultStartBurst
+	}
+
+	if params.RestartInterval == 0 {
+		params.RestartInterval = defaultRestartInterval
+	}
+
+	setDefaultStopParameters(&params)
+
+	instance := &processInstance{
+		status:      InstanceStatus{InstanceID: instanceID},
+		runtimeDir:  runtimeDir,
+		runtime:     params.Runner,
+		params:      params,
+		starting:    true,
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+
+	if instance.runtime == "" {
+		instance.runtime = defaultOCIRuntime
+	}
+
+	runner.Lock()
+
+	_, exists := runner.instances[instanceID]
+
+	runner.Unlock()
+
+	if exists {
+		if err := runner.StopInstance(instanceID); err != nil {
+			log.WithField("instanceID", instanceID).Errorf("Can't stop previous instance: %v", err)
+		}
+	}
+
+	runner.Lock()
+	runner.instances[instanceID] = instance
+	runner.Unlock()
+
+	log.WithFields(log.Fields{
+		"instanceID": instanceID, "runtime": instance.runtime,
+	}).Debug("Start instance process")
+
+	go runner.superviseInstance(instance)
+
+	// Instance is considered started if its process is running when start interval is elapsed
+	select {
+	case <-instance.doneChannel:
+
+	case <-time.After(time.Duration(startTimeoutMultiplier * float32(params.StartInterval))):
+	}
+
+	instance.Lock()
+	defer instance.Unlock()
+
+	instance.starting = false
+
+	status := instance.status
+
+	if status.State != cloudprotocol.InstanceStateActive {
+		status.State = cloudprotocol.InstanceStateFailed
+
+		if status.Err == nil {
+			status.Err = aoserrors.Errorf("instance failed")
+		}
+	}
+
+	return status
+}
+
+// StopInstance stops service instance.
+func (runner *ProcessRunner) StopInstance(instanceID string) (err error) {
+	runner.Lock()
+
+	instance, ok := runner.instances[instanceID]
+	delete(runner.instances, instanceID)
+
+	runner.Unlock()
+
+	if !ok {
+		log.WithField("instanceID", instanceID).Warn("Instance process not running")
+
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"instanceID": instanceID, "signal": instance.params.StopSignal, "timeout": instance.params.StopTimeout,
+	}).Debug("Stop instance process")
+
+	stopTime := time.Now()
+
+	instance.Lock()
+
+	instance.stopped = true
+	close(instance.stopChannel)
+
+	instance.Unlock()
+
+	instance.sendSignal(instance.params.StopSignal)
+
+	select {
+	case <-instance.doneChannel:
+
+	case <-time.After(instance.params.StopTimeout):
+		log.WithField("instanceID", instanceID).Warn("Instance process is not stopped in time, send SIGKILL")
+
+		instance.sendSignal("SIGKILL")
+
+		select {
+		case <-instance.doneChannel:
+
+		case <-time.After(StopTimeout):
+			log.WithField("instanceID", instanceID).Warn("Instance process is not killed in time, kill it")
+
+			instance.killProcess()
+
+			<-instance.doneChannel
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"instanceID": instanceID, "duration": time.Since(stopTime),
+	}).Debug("Instance process stopped")
+
+	if deleteErr := instance.deleteContainer(); deleteErr != nil {
+		err = deleteErr
+	}
+
+	return err
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (runner *ProcessRunner) superviseInstance(instance *processInstance) {
+	defer close(instance.doneChannel)
+
+	for {
+		if err := instance.checkStartLimit(); err != nil {
+			runner.setInstanceStatus(instance, InstanceStatus{
+				State: cloudprotocol.InstanceStateFailed, Err: err, ExitCode: instance.status.ExitCode,
+				Signal: instance.status.Signal, Reason: ReasonStartLimitHit,
+			})
+
+			return
+		}
+
+		if err := instance.deleteContainer(); err != nil {
+			log.WithField("instanceID", instance.status.InstanceID).Debugf("Can't delete container: %v", err)
+		}
+
+		cmd, err := instance.startProcess()
+		if err != nil {
+			if errors.Is(err, errStopped) {
+				return
+			}
+
+			runner.setInstanceStatus(instance, InstanceStatus{State: cloudprotocol.InstanceStateFailed, Err: err})
+		} else {
+			runner.setInstanceStatus(instance, InstanceStatus{State: cloudprotocol.InstanceStateActive})
+
+			waitErr := cmd.Wait()
+
+			if instance.isStopped() {
+				return
+			}
+
+			status := instance.getExitStatus(cmd, waitErr)
+
+			// Instance is restarted after clean exit as well, same as systemd unit with Restart=always. Clean exit is
+			// not a failure, so it is not reported.
+			if status.Reason == ReasonExitCode && status.ExitCode == 0 {
+				log.WithField("instanceID", instance.status.InstanceID).Info("Instance process finished")
+			} else {
+				log.WithFields(log.Fields{
+					"instanceID": instance.status.InstanceID, "exitCode": status.ExitCode, "signal": status.Signal,
+					"reason": status.Reason,
+				}).Warn("Instance process exited")
+
+				runner.setInstanceStatus(instance, status)
+			}
+		}
+
+		select {
+		case <-instance.stopChannel:
+			return
+
+		case <-time.After(instance.params.RestartInterval):
+		}
+	}
+}
+
+func (runner *ProcessRunner) setInstanceStatus(instance *processInstance, status InstanceStatus) {
+	instance.Lock()
+
+	if instance.stopped {
+		instance.Unlock()
+
+		return
+	}
+
+	status.InstanceID = instance.status.InstanceID
+	instance.status = status
+	starting := instance.starting
+
+	instance.Unlock()
+
+	// Status during start is returned by StartInstance
+	if starting {
+		return
+	}
+
+	select {
+	case runner.instanceStatusChan <- []InstanceStatus{status}:
+
+	default:
+		log.Error("Instance status channel full")
+	}
+}
+
+func (instance *processInstance) startProcess() (*exec.Cmd, error) {
+	instance.Lock()
+	defer instance.Unlock()
+
+	if instance.stopped {
+		return nil, errStopped
+	}
+
+	// Container is kept after exit to get its cgroup events, it is deleted before next start
+	cmd := ExecCommand(instance.runtime, "run", "--keep", "--bundle", instance.runtimeDir, instance.status.InstanceID)
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	instance.cmd = cmd
+
+	return cmd, nil
+}
+
+func (instance *processInstance) sendSignal(signal string) {
+	if output, err := ExecCommand(
+		instance.runtime, "kill", instance.status.InstanceID, signal).CombinedOutput(); err != nil {
+		log.WithField("instanceID", instance.status.InstanceID).Debugf(
+			"Can't send %s to instance: %v, output: %s", signal, err, output)
+	}
+}
+
+func (instance *processInstance) killProcess() {
+	instance.Lock()
+	defer instance.Unlock()
+
+	if instance.cmd == nil || instance.cmd.Process == nil {
+		return
+	}
+
+	if err := instance.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		log.WithField("instanceID", instance.status.InstanceID).Errorf("Can't kill instance process: %v", err)
+	}
+}
+
+func (instance *processInstance) isStopped() bool {
+	instance.Lock()
+	defer instance.Unlock()
+
+	return instance.stopped
+}
+
+func (instance *processInstance) checkStartLimit() error {
+	now := time.Now()
+	startTimes := make([]time.Time, 0, len(instance.startTimes)+1)
+
+	for _, startTime := range instance.startTimes {
+		if now.Sub(startTime) < instance.params.StartInterval {
+			startTimes = append(startTimes, startTime)
+		}
+	}
+
+	if uint(len(startTimes)) >= instance.params.StartBurst {
+		return aoserrors.Wrap(errStartLimit)
+	}
+
+	instance.startTimes = append(startTimes, now)
+
+	return nil
+}
+
+func (instance *processInstance) deleteContainer() error {
+	if output, err := ExecCommand(
+		instance.runtime, "delete", "-f", instance.status.InstanceID).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("can't delete container: %v, output: %s", err, output)
+	}
+
+	return nil
+}
+
+func (instance *processInstance) getExitStatus(cmd *exec.Cmd, waitErr error) (status InstanceStatus) {
+	status = InstanceStatus{State: cloudprotocol.InstanceStateFailed, Reason: ReasonExitCode}
+
+	switch {
+	case cmd.ProcessState == nil:
+		status.ExitCode = -1
+
+	case cmd.ProcessState.ExitCode() >= signalExitCodeBase:
+		// OCI runtime reports container killed by signal as 128 + signal number
+		status.ExitCode = cmd.ProcessState.ExitCode()
+		status.Signal = status.ExitCode - signalExitCodeBase
+		status.Reason = ReasonSignal
+
+	default:
+		if waitStatus, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && waitStatus.Signaled() {
+			status.Signal = int(waitStatus.Signal())
+			status.ExitCode = signalExitCodeBase + status.Signal
+			status.Reason = ReasonSignal
+		} else {
+			status.ExitCode = cmd.ProcessState.ExitCode()
+		}
+	}
+
+	if instance.isOOMKilled() {
+		status.Reason = ReasonOOMKill
+	}
+
+	switch status.Reason {
+	case ReasonOOMKill:
+		status.Err = aoserrors.New("instance killed by OOM killer")
+
+	case ReasonSignal:
+		status.Err = aoserrors.Errorf("instance killed by signal %s", getSignalName(status.Signal))
+
+	default:
+		if waitErr != nil && cmd.ProcessState == nil {
+			status.Err = aoserrors.Wrap(waitErr)
+		} else {
+			status.Err = aoserrors.Errorf("instance exited with code %d", status.ExitCode)
+		}
+	}
+
+	return status
+}
+
+// isOOMKilled checks container cgroup memory events. Runtime own cgroup is inherited from SM.
+func (instance *processInstance) isOOMKilled() bool {
+	oomKilled, err := isContainerOOMKilled(instance.status.InstanceID, instance.runtimeDir, getOwnCgroup)
+	if err != nil {
+		log.WithField("instanceID", instance.status.InstanceID).Debugf("Can't check OOM kill: %v", err)
+
+		return false
+	}
+
+	return oomKilled
+}
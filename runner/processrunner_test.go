@@ -0,0 +1,440 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/aoscloud/aos_servicemanager/runner"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testRuntime struct {
+	sync.Mutex
+	scripts   map[string]string
+	processes map[string]*exec.Cmd
+	runCount  map[string]int
+	deleted   map[string]int
+	runtimes  map[string]string
+	signals   map[string][]string
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestProcessRunnerStartStop(t *testing.T) {
+	testRuntime := newTestRuntime(map[string]string{})
+
+	processRunner, err := runner.NewProcessRunner()
+	if err != nil {
+		t.Fatalf("Can't create process runner: %v", err)
+	}
+	defer processRunner.Close()
+
+	status := processRunner.StartInstance("instance0", "/run/instance0", runner.RunParameters{
+		StartInterval: 100 * time.Millisecond, Runner: "crun",
+	})
+	if status.State != cloudprotocol.InstanceStateActive || status.Err != nil {
+		t.Errorf("Wrong instance status: %v", status)
+	}
+
+	if runtime := testRuntime.getRuntime("instance0"); runtime != "crun" {
+		t.Errorf("Wrong OCI runtime: %s", runtime)
+	}
+
+	if err = processRunner.StopInstance("instance0"); err != nil {
+		t.Errorf("Can't stop instance: %v", err)
+	}
+
+	if testRuntime.isRunning("instance0") {
+		t.Error("Instance process should be stopped")
+	}
+
+	// Container should be deleted before start and after stop
+	if deleteCount := testRuntime.getDeleteCount("instance0"); deleteCount != 2 {
+		t.Errorf("Wrong delete count: %d", deleteCount)
+	}
+
+	select {
+	case statuses := <-processRunner.InstanceStatusChannel():
+		t.Errorf("Unexpected instance status: %v", statuses)
+
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestProcessRunnerRestart(t *testing.T) {
+	testRuntime := newTestRuntime(map[string]string{"instance1": "sleep 0.2; exit 3"})
+
+	processRunner, err := runner.NewProcessRunner()
+	if err != nil {
+		t.Fatalf("Can't create process runner: %v", err)
+	}
+	defer processRunner.Close()
+
+	status := processRunner.StartInstance("instance1", "/run/instance1", runner.RunParameters{
+		StartInterval: 100 * time.Millisecond, StartBurst: 10, RestartInterval: 10 * time.Millisecond,
+	})
+	if status.State != cloudprotocol.InstanceStateActive {
+		t.Fatalf("Wrong instance status: %v", status)
+	}
+
+	// Exit code should be reported and instance should be restarted
+
+	if err = waitInstanceStatus(processRunner.InstanceStatusChannel(), runner.InstanceStatus{
+		InstanceID: "instance1", State: cloudprotocol.InstanceStateFailed, ExitCode: 3, Reason: runner.ReasonExitCode,
+	}); err != nil {
+		t.Errorf("Wait instance status error: %v", err)
+	}
+
+	if err = waitInstanceStatus(processRunner.InstanceStatusChannel(), runner.InstanceStatus{
+		InstanceID: "instance1", State: cloudprotocol.InstanceStateActive,
+	}); err != nil {
+		t.Errorf("Wait instance status error: %v", err)
+	}
+
+	if runCount := testRuntime.getRunCount("instance1"); runCount != 2 {
+		t.Errorf("Wrong run count: %d", runCount)
+	}
+
+	if err = processRunner.StopInstance("instance1"); err != nil {
+		t.Errorf("Can't stop instance: %v", err)
+	}
+}
+
+func TestProcessRunnerNormalExit(t *testing.T) {
+	testRuntime := newTestRuntime(map[string]string{"instance6": "sleep 0.1; exit 0"})
+
+	processRunner, err := runner.NewProcessRunner()
+	if err != nil {
+		t.Fatalf("Can't create process runner: %v", err)
+	}
+	defer processRunner.Close()
+
+	status := processRunner.StartInstance("instance6", "/run/instance6", runner.RunParameters{
+		StartInterval: time.Second, StartBurst: 10, RestartInterval: 10 * time.Millisecond,
+	})
+	if status.State != cloudprotocol.InstanceStateActive {
+		t.Fatalf("Wrong instance status: %v", status)
+	}
+
+	// Cleanly exited instance should be restarted without reporting failure
+
+	timeout := time.After(2 * time.Second)
+
+	for testRuntime.getRunCount("instance6") < 3 {
+		select {
+		case statuses := <-processRunner.InstanceStatusChannel():
+			for _, status := range statuses {
+				if status.State != cloudprotocol.InstanceStateActive {
+					t.Errorf("Unexpected instance status: %v", status)
+				}
+			}
+
+		case <-time.After(10 * time.Millisecond):
+
+		case <-timeout:
+			t.Fatalf("Instance is not restarted, run count: %d", testRuntime.getRunCount("instance6"))
+		}
+	}
+
+	if err = processRunner.StopInstance("instance6"); err != nil {
+		t.Errorf("Can't stop instance: %v", err)
+	}
+}
+
+func TestProcessRunnerStartLimit(t *testing.T) {
+	testRuntime := newTestRuntime(map[string]string{"instance2": "exit 1"})
+
+	processRunner, err := runner.NewProcessRunner()
+	if err != nil {
+		t.Fatalf("Can't create process runner: %v", err)
+	}
+	defer processRunner.Close()
+
+	status := processRunner.StartInstance("instance2", "/run/instance2", runner.RunParameters{
+		StartInterval: 500 * time.Millisecond, StartBurst: 3, RestartInterval: 10 * time.Millisecond,
+	})
+	if status.State != cloudprotocol.InstanceStateFailed || status.ExitCode != 1 ||
+		status.Reason != runner.ReasonStartLimitHit || status.Err == nil {
+		t.Errorf("Wrong instance status: %v", status)
+	}
+
+	if runCount := testRuntime.getRunCount("instance2"); runCount != 3 {
+		t.Errorf("Wrong run count: %d", runCount)
+	}
+
+	if err = processRunner.StopInstance("instance2"); err != nil {
+		t.Errorf("Can't stop instance: %v", err)
+	}
+}
+
+func TestProcessRunnerOOMKill(t *testing.T) {
+	newTestRuntime(map[string]string{"instance3": "sleep 0.2; kill -9 $$"})
+
+	tmpDir := t.TempDir()
+	runtimeDir := filepath.Join(tmpDir, "runtime")
+	cgroupDir := filepath.Join(tmpDir, "cgroup", "aos", "instance3")
+
+	runner.CgroupsDir = filepath.Join(tmpDir, "cgroup")
+
+	t.Cleanup(func() { runner.CgroupsDir = "/sys/fs/cgroup" })
+
+	if err := os.MkdirAll(runtimeDir, 0o755); err != nil {
+		t.Fatalf("Can't create runtime dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(runtimeDir, "config.json"),
+		[]byte(`{"linux":{"cgroupsPath":"/aos/instance3"}}`), 0o600); err != nil {
+		t.Fatalf("Can't write runtime config: %v", err)
+	}
+
+	if err := os.MkdirAll(cgroupDir, 0o755); err != nil {
+		t.Fatalf("Can't create cgroup dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(cgroupDir, "memory.events"),
+		[]byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0o600); err != nil {
+		t.Fatalf("Can't write memory events: %v", err)
+	}
+
+	processRunner, err := runner.NewProcessRunner()
+	if err != nil {
+		t.Fatalf("Can't create process runner: %v", err)
+	}
+	defer processRunner.Close()
+
+	status := processRunner.StartInstance("instance3", runtimeDir, runner.RunParameters{
+		StartInterval: 100 * time.Millisecond, StartBurst: 10, RestartInterval: time.Second,
+	})
+	if status.State != cloudprotocol.InstanceStateActive {
+		t.Fatalf("Wrong instance status: %v", status)
+	}
+
+	if err = waitInstanceStatus(processRunner.InstanceStatusChannel(), runner.InstanceStatus{
+		InstanceID: "instance3", State: cloudprotocol.InstanceStateFailed, ExitCode: 137, Signal: 9,
+		Reason: runner.ReasonOOMKill,
+	}); err != nil {
+		t.Errorf("Wait instance status error: %v", err)
+	}
+
+	if err = processRunner.StopInstance("instance3"); err != nil {
+		t.Errorf("Can't stop instance: %v", err)
+	}
+}
+
+func TestProcessRunnerGracefulStop(t *testing.T) {
+	testRuntime := newTestRuntime(map[string]string{
+		"instance4": "trap 'exit 0' USR1; while true; do sleep 0.05; done",
+		"instance5": "trap '' TERM; while true; do sleep 0.05; done",
+	})
+
+	processRunner, err := runner.NewProcessRunner()
+	if err != nil {
+		t.Fatalf("Can't create process runner: %v", err)
+	}
+	defer processRunner.Close()
+
+	type testData struct {
+		instanceID      string
+		stopSignal      string
+		stopTimeout     time.Duration
+		expectedSignals []string
+		minStopDuration time.Duration
+	}
+
+	data := []testData{
+		{
+			instanceID: "instance4", stopSignal: "USR1", stopTimeout: 5 * time.Second,
+			expectedSignals: []string{"SIGUSR1"},
+		},
+		{
+			instanceID: "instance5", stopTimeout: 500 * time.Millisecond,
+			expectedSignals: []string{"SIGTERM", "SIGKILL"}, minStopDuration: 500 * time.Millisecond,
+		},
+	}
+
+	for _, item := range data {
+		status := processRunner.StartInstance(item.instanceID, "/run/"+item.instanceID, runner.RunParameters{
+			StartInterval: 100 * time.Millisecond, StopSignal: item.stopSignal, StopTimeout: item.stopTimeout,
+		})
+		if status.State != cloudprotocol.InstanceStateActive {
+			t.Fatalf("Wrong instance status: %v", status)
+		}
+
+		stopTime := time.Now()
+
+		if err = processRunner.StopInstance(item.instanceID); err != nil {
+			t.Errorf("Can't stop instance: %v", err)
+		}
+
+		stopDuration := time.Since(stopTime)
+
+		if stopDuration < item.minStopDuration || stopDuration >= item.stopTimeout+runner.StopTimeout {
+			t.Errorf("Wrong stop duration: %v", stopDuration)
+		}
+
+		if signals := testRuntime.getSignals(item.instanceID); !reflect.DeepEqual(signals, item.expectedSignals) {
+			t.Errorf("Wrong stop signals: %v", signals)
+		}
+
+		if testRuntime.isRunning(item.instanceID) {
+			t.Error("Instance process should be stopped")
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * testRuntime
+ **********************************************************************************************************************/
+
+func newTestRuntime(scripts map[string]string) *testRuntime {
+	testRuntime := &testRuntime{
+		scripts:   scripts,
+		processes: make(map[string]*exec.Cmd),
+		runCount:  make(map[string]int),
+		deleted:   make(map[string]int),
+		runtimes:  make(map[string]string),
+		signals:   make(map[string][]string),
+	}
+
+	runner.ExecCommand = testRuntime.command
+
+	return testRuntime
+}
+
+func (testRuntime *testRuntime) command(name string, args ...string) *exec.Cmd {
+	testRuntime.Lock()
+	defer testRuntime.Unlock()
+
+	switch args[0] {
+	case "run":
+		instanceID := args[len(args)-1]
+
+		script, ok := testRuntime.scripts[instanceID]
+		if !ok {
+			script = "exec sleep 100"
+		}
+
+		cmd := exec.Command("sh", "-c", script)
+
+		testRuntime.processes[instanceID] = cmd
+		testRuntime.runCount[instanceID]++
+		testRuntime.runtimes[instanceID] = name
+
+		return cmd
+
+	case "kill":
+		testRuntime.signals[args[1]] = append(testRuntime.signals[args[1]], args[2])
+
+		if cmd, ok := testRuntime.processes[args[1]]; ok && cmd.Process != nil {
+			_ = cmd.Process.Signal(unix.SignalNum(args[2]))
+		}
+
+	case "delete":
+		testRuntime.deleted[args[len(args)-1]]++
+	}
+
+	return exec.Command("true")
+}
+
+func (testRuntime *testRuntime) getRuntime(instanceID string) string {
+	testRuntime.Lock()
+	defer testRuntime.Unlock()
+
+	return testRuntime.runtimes[instanceID]
+}
+
+func (testRuntime *testRuntime) getRunCount(instanceID string) int {
+	testRuntime.Lock()
+	defer testRuntime.Unlock()
+
+	return testRuntime.runCount[instanceID]
+}
+
+func (testRuntime *testRuntime) getSignals(instanceID string) []string {
+	testRuntime.Lock()
+	defer testRuntime.Unlock()
+
+	return testRuntime.signals[instanceID]
+}
+
+func (testRuntime *testRuntime) getDeleteCount(instanceID string) int {
+	testRuntime.Lock()
+	defer testRuntime.Unlock()
+
+	return testRuntime.deleted[instanceID]
+}
+
+func (testRuntime *testRuntime) isRunning(instanceID string) bool {
+	testRuntime.Lock()
+	defer testRuntime.Unlock()
+
+	cmd, ok := testRuntime.processes[instanceID]
+
+	return ok && cmd.ProcessState == nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func waitInstanceStatus(statusChannel <-chan []runner.InstanceStatus, expectedStatus runner.InstanceStatus) error {
+	select {
+	case statuses := <-statusChannel:
+		if len(statuses) != 1 || statuses[0].InstanceID != expectedStatus.InstanceID ||
+			statuses[0].State != expectedStatus.State || statuses[0].ExitCode != expectedStatus.ExitCode ||
+			statuses[0].Signal != expectedStatus.Signal || statuses[0].Reason != expectedStatus.Reason {
+			return aoserrors.Errorf("wrong instance status: %v", statuses)
+		}
+
+		return nil
+
+	case <-time.After(5 * time.Second):
+		return aoserrors.New("wait instance status timeout")
+	}
+}
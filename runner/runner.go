@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	"github.com/coreos/go-systemd/v22/dbus"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 /***********************************************************************************************************************
@@ -42,11 +44,15 @@ const (
 	defaultStartInterval   = 1 * time.Second
 	defaultStartBurst      = 3
 	defaultRestartInterval = 100 * time.Millisecond
+	defaultStopSignal      = "SIGTERM"
+	defaultStopTimeout     = 10 * time.Second
 	startTimeoutMultiplier = 1.2
 )
 
 const systemdUnitNameTemplate = "aos-service@%s.service"
 
+const systemSliceCgroup = "/system.slice"
+
 const (
 	errNotLoaded  = "not loaded"
 	jobStatusDone = "done"
@@ -68,6 +74,9 @@ type RunParameters struct {
 	StartInterval   time.Duration
 	StartBurst      uint
 	RestartInterval time.Duration
+	Runner          string
+	StopSignal      string
+	StopTimeout     time.Duration
 }
 
 // InstanceStatus service instance status.
@@ -76,6 +85,8 @@ type InstanceStatus struct {
 	State      string
 	Err        error
 	ExitCode   int
+	Signal     int
+	Reason     string
 }
 
 // Runner runner instance.
@@ -84,6 +95,7 @@ type Runner struct {
 	systemd            *dbus.Conn
 	instanceStatusChan chan []InstanceStatus
 	runningUnits       map[string]chan dbus.UnitStatus
+	runtimeDirs        map[string]string
 	stopChan           chan struct{}
 }
 
@@ -96,6 +108,7 @@ func New() (runner *Runner, err error) {
 	runner = &Runner{
 		instanceStatusChan: make(chan []InstanceStatus, unitStatusChannelSize),
 		runningUnits:       make(map[string]chan dbus.UnitStatus),
+		runtimeDirs:        make(map[string]string),
 		stopChan:           make(chan struct{}, 1),
 	}
 
@@ -134,6 +147,7 @@ func (runner *Runner) StartInstance(instanceID, runtimeDir string, params RunPar
 	runner.Lock()
 
 	runner.runningUnits[unitName] = unitStatusChannel
+	runner.runtimeDirs[unitName] = runtimeDir
 
 	runner.Unlock()
 
@@ -146,6 +160,7 @@ func (runner *Runner) StartInstance(instanceID, runtimeDir string, params RunPar
 
 		if status.State == cloudprotocol.InstanceStateFailed {
 			delete(runner.runningUnits, unitName)
+			delete(runner.runtimeDirs, unitName)
 
 			if status.Err == nil {
 				status.Err = aoserrors.Errorf("instance failed")
@@ -167,6 +182,8 @@ func (runner *Runner) StartInstance(instanceID, runtimeDir string, params RunPar
 		params.RestartInterval = defaultRestartInterval
 	}
 
+	setDefaultStopParameters(&params)
+
 	if status.Err = runner.setRunParameters(unitName, params); status.Err != nil {
 		return status
 	}
@@ -185,10 +202,15 @@ func (runner *Runner) StartInstance(instanceID, runtimeDir string, params RunPar
 	}).Debug("Start instance")
 
 	if jobStatus != jobStatusDone {
+		runner.setUnitExitInfo(unitName, runtimeDir, &status)
+
 		return status
 	}
 
-	status.State = runner.getStartingState(unitName, unitStatusChannel, params)
+	if status.State = runner.getStartingState(
+		unitName, unitStatusChannel, params); status.State == cloudprotocol.InstanceStateFailed {
+		runner.setUnitExitInfo(unitName, runtimeDir, &status)
+	}
 
 	return status
 }
@@ -200,9 +222,11 @@ func (runner *Runner) StopInstance(instanceID string) (err error) {
 	runner.Lock()
 
 	delete(runner.runningUnits, fmt.Sprintf(systemdUnitNameTemplate, instanceID))
+	delete(runner.runtimeDirs, fmt.Sprintf(systemdUnitNameTemplate, instanceID))
 
 	runner.Unlock()
 
+	stopTime := time.Now()
 	channel := make(chan string)
 
 	if _, stopErr := runner.systemd.StopUnitContext(
@@ -217,6 +241,7 @@ func (runner *Runner) StopInstance(instanceID string) (err error) {
 
 		log.WithFields(log.Fields{
 			"name": unitName, "jobStatus": jobStatus, "instanceID": instanceID,
+			"duration": time.Since(stopTime),
 		}).Debug("Stop instance")
 
 		if jobStatus != jobStatusDone && err == nil {
@@ -232,6 +257,25 @@ func (runner *Runner) StopInstance(instanceID string) (err error) {
 	return err
 }
 
+// TerminationInfo returns human readable instance termination info.
+func (status InstanceStatus) TerminationInfo() string {
+	if status.Reason == "" {
+		return ""
+	}
+
+	info := "reason: " + status.Reason
+
+	if status.Signal != 0 {
+		info += ", signal: " + getSignalName(status.Signal)
+	}
+
+	if status.ExitCode != 0 {
+		info += ", exit code: " + strconv.Itoa(status.ExitCode)
+	}
+
+	return info
+}
+
 /***********************************************************************************************************************
   Private
  **********************************************************************************************************************/
@@ -257,7 +301,14 @@ func (runner *Runner) monitorUnitStates() {
 					if startChan != nil {
 						startChan <- *unitStatus
 					} else {
-						instancesStatus = append(instancesStatus, unitStatusToInstanceStatus(unitStatus))
+						instanceStatus := unitStatusToInstanceStatus(unitStatus)
+
+						if instanceStatus.State == cloudprotocol.InstanceStateFailed {
+							runner.setUnitExitInfo(
+								unitStatus.Name, runner.runtimeDirs[unitStatus.Name], &instanceStatus)
+						}
+
+						instancesStatus = append(instancesStatus, instanceStatus)
 					}
 				}
 			}
@@ -313,6 +364,41 @@ func (runner *Runner) getStartingState(
 	}
 }
 
+func (runner *Runner) setUnitExitInfo(unitName, runtimeDir string, status *InstanceStatus) {
+	properties, err := runner.systemd.GetUnitTypePropertiesContext(context.Background(), unitName, "Service")
+	if err != nil {
+		log.WithField("name", unitName).Errorf("Can't get unit properties: %v", err)
+
+		return
+	}
+
+	execMainCode, _ := properties["ExecMainCode"].(int32)
+	execMainStatus, _ := properties["ExecMainStatus"].(int32)
+	result, _ := properties["Result"].(string)
+
+	status.ExitCode, status.Signal, status.Reason = getUnitExitInfo(int(execMainCode), int(execMainStatus), result)
+
+	// Service main process is OCI runtime: systemd doesn't detect OOM kill of container process, which is placed into
+	// its own cgroup nested into the unit cgroup.
+	if status.Reason != ReasonOOMKill && runtimeDir != "" {
+		unitCgroup, _ := properties["ControlGroup"].(string)
+
+		oomKilled, err := isContainerOOMKilled(
+			status.InstanceID, runtimeDir, func() (string, error) { return getUnitCgroup(unitName, unitCgroup), nil })
+		if err != nil {
+			log.WithField("name", unitName).Debugf("Can't check OOM kill: %v", err)
+		}
+
+		if oomKilled {
+			status.Reason = ReasonOOMKill
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"name": unitName, "exitCode": status.ExitCode, "signal": status.Signal, "reason": status.Reason,
+	}).Debug("Unit exit info")
+}
+
 func (runner *Runner) isUnitUnderMonitoring(unitName string) bool {
 	runner.RLock()
 	defer runner.RUnlock()
@@ -322,6 +408,15 @@ func (runner *Runner) isUnitUnderMonitoring(unitName string) bool {
 	return !exists
 }
 
+// getUnitCgroup returns unit cgroup. ControlGroup property is empty when unit has no running processes.
+func getUnitCgroup(unitName, controlGroup string) string {
+	if controlGroup != "" {
+		return controlGroup
+	}
+
+	return filepath.Join(systemSliceCgroup, unitName)
+}
+
 func unitStatusToInstanceStatus(unitStatus *dbus.UnitStatus) (runnerStatus InstanceStatus) {
 	runnerStatus.InstanceID = strings.TrimPrefix(strings.TrimSuffix(unitStatus.Name, ".service"), "aos-service@")
 
@@ -357,12 +452,20 @@ StartLimitBurst=%d
 
 [Service]
 RestartSec=%s
+Environment="AOS_STOP_SIGNAL=%s"
+TimeoutStopSec=%s
+SuccessExitStatus=%s
 `
 
-	if params.StartInterval < 1*time.Microsecond || params.RestartInterval < 1*time.Microsecond {
+	if params.StartInterval < 1*time.Microsecond || params.RestartInterval < 1*time.Microsecond ||
+		params.StopTimeout < 1*time.Microsecond {
 		return aoserrors.New("invalid parameters")
 	}
 
+	if unix.SignalNum(params.StopSignal) == 0 {
+		return aoserrors.Errorf("invalid stop signal: %s", params.StopSignal)
+	}
+
 	parametersDir := filepath.Join(systemdDropInsDir, unitName+".d")
 
 	if err := os.MkdirAll(parametersDir, 0o755); err != nil {
@@ -371,7 +474,8 @@ RestartSec=%s
 
 	if err := os.WriteFile( //nolint:gosec // To fix systemd warning, file parameters.conf should be 644
 		filepath.Join(parametersDir, parametersFileName),
-		[]byte(fmt.Sprintf(parametersFormat, params.StartInterval, params.StartBurst, params.RestartInterval)),
+		[]byte(fmt.Sprintf(parametersFormat, params.StartInterval, params.StartBurst, params.RestartInterval,
+			params.StopSignal, params.StopTimeout, params.StopSignal)),
 		0o644); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -379,6 +483,21 @@ RestartSec=%s
 	return nil
 }
 
+func setDefaultStopParameters(params *RunParameters) {
+	if params.StopSignal == "" {
+		params.StopSignal = defaultStopSignal
+	}
+
+	// Accept signal names without SIG prefix as systemd does
+	if !strings.HasPrefix(params.StopSignal, "SIG") {
+		params.StopSignal = "SIG" + params.StopSignal
+	}
+
+	if params.StopTimeout == 0 {
+		params.StopTimeout = defaultStopTimeout
+	}
+}
+
 func (runner *Runner) removeRunParameters(unitName string) error {
 	if err := os.RemoveAll(filepath.Join(systemdDropInsDir, unitName+".d")); err != nil {
 		return aoserrors.Wrap(err)
@@ -37,9 +37,16 @@ import (
 	"golang.org/x/exp/slices"
 
 	"github.com/aoscloud/aos_servicemanager/alerts"
+	"github.com/aoscloud/aos_servicemanager/blobstore"
+	"github.com/aoscloud/aos_servicemanager/clocksync"
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/contentreceiver"
 	"github.com/aoscloud/aos_servicemanager/database"
+	"github.com/aoscloud/aos_servicemanager/diagnostics"
+	"github.com/aoscloud/aos_servicemanager/downloader"
+	"github.com/aoscloud/aos_servicemanager/healthcheck"
 	"github.com/aoscloud/aos_servicemanager/iamclient"
+	"github.com/aoscloud/aos_servicemanager/integrity"
 	"github.com/aoscloud/aos_servicemanager/launcher"
 	"github.com/aoscloud/aos_servicemanager/layermanager"
 	"github.com/aoscloud/aos_servicemanager/logging"
@@ -48,7 +55,10 @@ import (
 	resource "github.com/aoscloud/aos_servicemanager/resourcemanager"
 	"github.com/aoscloud/aos_servicemanager/runner"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/signature"
 	"github.com/aoscloud/aos_servicemanager/smclient"
+	"github.com/aoscloud/aos_servicemanager/storagequota"
+	"github.com/aoscloud/aos_servicemanager/utils/ocilayer"
 )
 
 /***********************************************************************************************************************
@@ -65,6 +75,15 @@ type serviceManager struct {
 	cryptoContext     *cryptutils.CryptoContext
 	journalAlerts     *journalalerts.JournalAlerts
 	alerts            *alerts.Alerts
+	contentReceiver   *contentreceiver.ContentReceiver
+	diagnostics       *diagnostics.Server
+	clockSync         *clocksync.ClockSync
+	healthChecker     *healthcheck.HealthChecker
+	integrityVerifier *integrity.Verifier
+	storageQuota      *storagequota.StorageQuota
+	signatureVerifier *signature.Verifier
+	downloader        *downloader.Downloader
+	blobStore         *blobstore.BlobStore
 	cfg               *config.Config
 	db                *database.Database
 	launcher          *launcher.Launcher
@@ -77,7 +96,12 @@ type serviceManager struct {
 	client            *smclient.SMClient
 	layerMgr          *layermanager.LayerManager
 	serviceMgr        *servicemanager.ServiceManager
-	runner            *runner.Runner
+	runner            instanceRunner
+}
+
+type instanceRunner interface {
+	launcher.InstanceRunner
+	Close()
 }
 
 type journalHook struct {
@@ -119,6 +143,10 @@ func cleanup(cfg *config.Config, dbFile string) {
 		log.Errorf("Can't remove services dir: %v", err)
 	}
 
+	if err := os.RemoveAll(cfg.BlobsDir); err != nil {
+		log.Errorf("Can't remove blobs dir: %v", err)
+	}
+
 	log.WithField("file", dbFile).Debug("Delete DB file")
 
 	if err := os.RemoveAll(dbFile); err != nil {
@@ -176,23 +204,49 @@ func newServiceManager(cfg *config.Config) (sm *serviceManager, err error) {
 		}
 	}
 
-	if sm.layerMgr, err = layermanager.New(cfg, sm.db); err != nil {
+	if sm.clockSync, err = clocksync.New(); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
-	if sm.serviceMgr, err = servicemanager.New(cfg, sm.db); err != nil {
+	if sm.contentReceiver, err = contentreceiver.New(); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
-	if sm.cryptoContext, err = cryptutils.NewCryptoContext(cfg.CACert); err != nil {
+	if sm.signatureVerifier, err = signature.New(cfg); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
-	if sm.iam, err = iamclient.New(cfg, sm.cryptoContext, false); err != nil {
+	if sm.alerts, err = alerts.New(cfg, sm.db); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.downloader, err = downloader.New(cfg, sm.alerts); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.blobStore, err = blobstore.New(cfg, sm.db); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
-	if sm.alerts, err = alerts.New(); err != nil {
+	if err = ocilayer.CheckZstd(); err != nil {
+		log.Warnf("zstd compressed layers are not supported: %v", err)
+	}
+
+	if sm.layerMgr, err = layermanager.New(
+		cfg, sm.db, sm.contentReceiver, sm.clockSync, sm.signatureVerifier, sm.downloader, sm.blobStore); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.serviceMgr, err = servicemanager.New(
+		cfg, sm.db, sm.contentReceiver, sm.clockSync, sm.signatureVerifier, sm.downloader, sm.blobStore); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.cryptoContext, err = cryptutils.NewCryptoContext(cfg.CACert); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.iam, err = iamclient.New(cfg, sm.cryptoContext, false); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
@@ -204,7 +258,11 @@ func newServiceManager(cfg *config.Config) (sm *serviceManager, err error) {
 		return sm, aoserrors.Wrap(err)
 	}
 
-	if sm.monitorController, err = monitorcontroller.New(); err != nil {
+	if sm.storageQuota, err = storagequota.New(cfg, sm.alerts); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.monitorController, err = monitorcontroller.New(cfg, sm.storageQuota, sm.db); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
@@ -224,15 +282,34 @@ func newServiceManager(cfg *config.Config) (sm *serviceManager, err error) {
 		return sm, aoserrors.Wrap(err)
 	}
 
-	if sm.runner, err = runner.New(); err != nil {
+	if sm.runner, err = newInstanceRunner(cfg); err != nil {
+		return sm, aoserrors.Wrap(err)
+	}
+
+	if sm.healthChecker, err = healthcheck.New(cfg); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
 	if sm.launcher, err = launcher.New(cfg, sm.db, sm.serviceMgr, sm.layerMgr, sm.runner, sm.resourcemanager,
-		sm.network, sm.iam, sm.monitor, sm.alerts); err != nil {
+		sm.network, sm.iam, sm.monitor, sm.alerts, sm.clockSync, sm.healthChecker,
+		sm.storageQuota); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
+	if cfg.Integrity.Enabled {
+		if sm.integrityVerifier, err = integrity.New(
+			cfg, sm.serviceMgr, sm.layerMgr, sm.launcher, sm.alerts); err != nil {
+			return sm, aoserrors.Wrap(err)
+		}
+	}
+
+	if cfg.DiagnosticsSocket != "" {
+		if sm.diagnostics, err = diagnostics.New(cfg, sm.launcher, sm.serviceMgr, sm.layerMgr, sm.network,
+			sm.resourcemanager); err != nil {
+			return sm, aoserrors.Wrap(err)
+		}
+	}
+
 	if sm.logging, err = logging.New(cfg, sm.db); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
@@ -242,7 +319,7 @@ func newServiceManager(cfg *config.Config) (sm *serviceManager, err error) {
 		NodeType:   sm.iam.GetNodeType(),
 		SystemInfo: sm.monitor.GetSystemInfo(),
 	}, sm.iam, sm.serviceMgr, sm.layerMgr, sm.launcher, sm.resourcemanager, sm.alerts, sm.monitorController, sm.logging,
-		sm.network, sm.cryptoContext, false); err != nil {
+		sm.network, sm.contentReceiver, sm.clockSync, sm.cryptoContext, false); err != nil {
 		return sm, aoserrors.Wrap(err)
 	}
 
@@ -250,6 +327,14 @@ func newServiceManager(cfg *config.Config) (sm *serviceManager, err error) {
 }
 
 func (sm *serviceManager) close() {
+	if sm.diagnostics != nil {
+		sm.diagnostics.Close()
+	}
+
+	if sm.integrityVerifier != nil {
+		sm.integrityVerifier.Close()
+	}
+
 	if sm.serviceMgr != nil {
 		sm.serviceMgr.Close()
 	}
@@ -258,6 +343,10 @@ func (sm *serviceManager) close() {
 		sm.layerMgr.Close()
 	}
 
+	if sm.blobStore != nil {
+		sm.blobStore.Close()
+	}
+
 	if sm.logging != nil {
 		sm.logging.Close()
 	}
@@ -266,10 +355,18 @@ func (sm *serviceManager) close() {
 		sm.launcher.Close()
 	}
 
+	if sm.healthChecker != nil {
+		sm.healthChecker.Close()
+	}
+
 	if sm.runner != nil {
 		sm.runner.Close()
 	}
 
+	if sm.storageQuota != nil {
+		sm.storageQuota.Close()
+	}
+
 	if sm.monitor != nil {
 		sm.monitor.Close()
 	}
@@ -282,6 +379,10 @@ func (sm *serviceManager) close() {
 		sm.journalAlerts.Close()
 	}
 
+	if sm.alerts != nil {
+		sm.alerts.Close()
+	}
+
 	if sm.iam != nil {
 		sm.iam.Close()
 	}
@@ -295,6 +396,29 @@ func (sm *serviceManager) close() {
 	}
 }
 
+func newInstanceRunner(cfg *config.Config) (instanceRunner, error) {
+	switch cfg.InstanceRunner {
+	case "", runner.SystemdRunnerType:
+		systemdRunner, err := runner.New()
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		return systemdRunner, nil
+
+	case runner.ProcessRunnerType:
+		processRunner, err := runner.NewProcessRunner()
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		return processRunner, nil
+
+	default:
+		return nil, aoserrors.Errorf("unsupported instance runner: %s", cfg.InstanceRunner)
+	}
+}
+
 func newJournalHook() (hook *journalHook) {
 	hook = &journalHook{
 		severityMap: map[log.Level]journal.Priority{
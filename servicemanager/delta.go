@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicemanager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	"github.com/aoscloud/aos_common/image"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/downloader"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Delta package parameters are passed in service URL fragment:
+// <full package URL>#deltaBase=<base Aos version>&deltaUrl=<escaped delta package URL>.
+const (
+	deltaBaseParam = "deltaBase"
+	deltaURLParam  = "deltaUrl"
+)
+
+// basePackageFile original service package kept in folder of the latest service version to be used as delta base.
+const basePackageFile = "package"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type deltaInfo struct {
+	baseVersion uint64
+	url         string
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// applyDeltaPackage reconstructs full service package from delta package and package of the base service version.
+// Reconstructed package is verified against full package checksums.
+func (sm *ServiceManager) applyDeltaPackage(
+	serviceInfo *aostypes.ServiceInfo, delta deltaInfo,
+) (fileName string, err error) {
+	basePackage, err := sm.getBasePackage(serviceInfo.ID, delta.baseVersion)
+	if err != nil {
+		return "", err
+	}
+
+	log.WithFields(log.Fields{
+		"id": serviceInfo.ID, "aosVersion": serviceInfo.AosVersion, "baseVersion": delta.baseVersion,
+	}).Debug("Apply delta package")
+
+	deltaURL, err := url.Parse(delta.url)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	deltaFile := deltaURL.Path
+
+	if deltaURL.Scheme != "file" {
+		if deltaFile, err = sm.downloadPackage(delta.url, downloader.TargetInfo{
+			Type:          cloudprotocol.DownloadTargetService,
+			ID:            serviceInfo.ID,
+			AosVersion:    serviceInfo.AosVersion,
+			VendorVersion: serviceInfo.VendorVersion,
+		}); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		defer os.RemoveAll(deltaFile)
+	}
+
+	packageFile, err := os.CreateTemp(sm.downloadDir, "*.pkg")
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	fileName = packageFile.Name()
+	packageFile.Close()
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(fileName)
+		}
+	}()
+
+	if output, err := exec.Command("zstd", "-d", "-q", "-f", "--long=31", "--patch-from="+basePackage,
+		deltaFile, "-o", fileName).CombinedOutput(); err != nil {
+		return "", aoserrors.Errorf("can't apply delta package: %v, %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if err = image.CheckFileInfo(context.Background(), fileName, image.FileInfo{
+		Sha256: serviceInfo.Sha256,
+		Sha512: serviceInfo.Sha512,
+		Size:   serviceInfo.Size,
+	}); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return fileName, nil
+}
+
+// getBasePackage returns package of installed base service version.
+func (sm *ServiceManager) getBasePackage(serviceID string, baseVersion uint64) (fileName string, err error) {
+	services, err := sm.serviceInfoProvider.GetAllServiceVersions(serviceID)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	for _, service := range services {
+		if service.AosVersion != baseVersion || service.Quarantined {
+			continue
+		}
+
+		fileName = filepath.Join(service.ImagePath, basePackageFile)
+
+		if _, err = os.Stat(fileName); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		return fileName, nil
+	}
+
+	return "", aoserrors.Errorf("base version %d not found", baseVersion)
+}
+
+// removeOutdatedBasePackages keeps base package only for the latest service version as delta packages are
+// expected to be created against it. Space of removed packages is taken into account on next space allocation.
+func (sm *ServiceManager) removeOutdatedBasePackages(serviceID string) {
+	services, err := sm.serviceInfoProvider.GetAllServiceVersions(serviceID)
+	if err != nil {
+		log.WithField("id", serviceID).Errorf("Can't get service versions: %v", err)
+
+		return
+	}
+
+	var latestVersion uint64
+
+	for _, service := range services {
+		if service.AosVersion > latestVersion {
+			latestVersion = service.AosVersion
+		}
+	}
+
+	for _, service := range services {
+		if service.AosVersion == latestVersion {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(service.ImagePath, basePackageFile)); err != nil &&
+			!errors.Is(err, os.ErrNotExist) {
+			log.WithFields(log.Fields{
+				"id": service.ServiceID, "aosVersion": service.AosVersion,
+			}).Errorf("Can't remove base package: %v", err)
+		}
+	}
+}
+
+// parsePackageURL splits service URL to full package URL and optional delta package info.
+func parsePackageURL(packageURL string) (fullURL string, delta *deltaInfo, err error) {
+	urlVal, err := url.Parse(packageURL)
+	if err != nil {
+		return "", nil, aoserrors.Wrap(err)
+	}
+
+	params, err := url.ParseQuery(urlVal.Fragment)
+	if err != nil || !params.Has(deltaBaseParam) || !params.Has(deltaURLParam) {
+		return packageURL, nil, nil
+	}
+
+	baseVersion, err := strconv.ParseUint(params.Get(deltaBaseParam), 10, 64)
+	if err != nil {
+		return "", nil, aoserrors.Wrap(err)
+	}
+
+	urlVal.Fragment = ""
+	urlVal.RawFragment = ""
+
+	return urlVal.String(), &deltaInfo{baseVersion: baseVersion, url: params.Get(deltaURLParam)}, nil
+}
+
+// linkOrCopyFile hard links package to avoid keeping the second copy of it and falls back to copying if source
+// file is located on another partition.
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer dstFile.Close()
+
+	if _, err = io.Copy(dstFile, srcFile); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(dstFile.Sync())
+}
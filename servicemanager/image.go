@@ -24,6 +24,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -32,6 +33,8 @@ import (
 	"github.com/opencontainers/go-digest"
 	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/mod/sumdb/dirhash"
+
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
 )
 
 /***********************************************************************************************************************
@@ -44,16 +47,24 @@ const buffSize = 1024 * 1024
 
 const blobsFolder = "blobs"
 
+const verityHashTreeFile = "rootfs.verity"
+
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
 
 // ImageParts struct with paths for image parts.
 type ImageParts struct {
-	ImageConfigPath   string
-	ServiceConfigPath string
-	ServiceFSPath     string
-	LayersDigest      []string
+	ImageConfigPath       string
+	ServiceConfigPath     string
+	ServiceFSPath         string
+	ServiceFSHashTreePath string
+	LayersDigest          []string
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
 }
 
 type serviceManifest struct {
@@ -65,20 +76,20 @@ type serviceManifest struct {
  * Private
  **********************************************************************************************************************/
 
-func validateUnpackedImage(installDir string) (err error) {
+func validateUnpackedImage(installDir string, limiter *ratelimit.Limiter) (err error) {
 	manifest, err := getImageManifest(installDir)
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
 
 	// validate image config
-	if err = validateDigest(installDir, manifest.Config.Digest); err != nil {
+	if err = validateDigest(installDir, manifest.Config.Digest, limiter); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
 	// validate aos service config
 	if manifest.AosService != nil {
-		if err = validateDigest(installDir, manifest.AosService.Digest); err != nil {
+		if err = validateDigest(installDir, manifest.AosService.Digest, limiter); err != nil {
 			return aoserrors.Wrap(err)
 		}
 
@@ -110,10 +121,25 @@ func validateUnpackedImage(installDir string) (err error) {
 	}
 
 	if !fi.Mode().IsDir() {
-		return aoserrors.Wrap(validateDigest(installDir, manifest.Layers[0].Digest))
+		return aoserrors.Wrap(validateDigest(installDir, manifest.Layers[0].Digest, limiter))
+	}
+
+	// Installed service rootfs is a link to the blob store
+	if rootfsPath, err = filepath.EvalSymlinks(rootfsPath); err != nil {
+		return aoserrors.Wrap(err)
 	}
 
-	rootfsHash, err := dirhash.HashDir(rootfsPath, rootfsPath, dirDigest)
+	rootfsHash, err := dirhash.HashDir(rootfsPath, rootfsPath,
+		func(files []string, open func(string) (io.ReadCloser, error)) (string, error) {
+			return dirDigest(files, func(name string) (io.ReadCloser, error) {
+				file, err := open(name)
+				if err != nil {
+					return nil, err
+				}
+
+				return limitedReadCloser{Reader: limiter.Reader(file), Closer: file}, nil
+			})
+		})
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -153,7 +179,7 @@ func saveImageManifest(manifest *serviceManifest, installDir string) (err error)
 	return nil
 }
 
-func validateDigest(installDir string, digest digest.Digest) (err error) {
+func validateDigest(installDir string, digest digest.Digest, limiter *ratelimit.Limiter) (err error) {
 	if err = digest.Validate(); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -166,9 +192,10 @@ func validateDigest(installDir string, digest digest.Digest) (err error) {
 
 	buffer := make([]byte, buffSize)
 	verifier := digest.Verifier()
+	reader := limiter.Reader(file)
 
 	for {
-		count, readErr := file.Read(buffer)
+		count, readErr := reader.Read(buffer)
 		if readErr != nil && readErr != io.EOF {
 			return aoserrors.Wrap(readErr)
 		}
@@ -212,9 +239,44 @@ func getImageParts(installDir string) (parts ImageParts, err error) {
 	parts.ServiceFSPath = path.Join(installDir, blobsFolder, string(rootFSDigest.Algorithm()), rootFSDigest.Hex())
 	parts.LayersDigest = getLayersFromManifest(manifest)
 
+	// Hash tree exists only for image rootfs protected by dm-verity
+	if _, err = os.Stat(path.Join(installDir, verityHashTreeFile)); err == nil {
+		parts.ServiceFSHashTreePath = path.Join(installDir, verityHashTreeFile)
+	}
+
 	return parts, nil
 }
 
+// getRootFSLayer returns service rootfs layer descriptor and its DiffID if image config provides it.
+func getRootFSLayer(installDir string) (layer imagespec.Descriptor, diffID digest.Digest, err error) {
+	manifest, err := getImageManifest(installDir)
+	if err != nil {
+		return layer, "", aoserrors.Wrap(err)
+	}
+
+	if len(manifest.Layers) == 0 {
+		return layer, "", aoserrors.New("no layers in image")
+	}
+
+	configJSON, err := os.ReadFile(path.Join(installDir, blobsFolder, string(manifest.Config.Digest.Algorithm()),
+		manifest.Config.Digest.Hex()))
+	if err != nil {
+		return layer, "", aoserrors.Wrap(err)
+	}
+
+	var imageConfig imagespec.Image
+
+	if err = json.Unmarshal(configJSON, &imageConfig); err != nil {
+		return layer, "", aoserrors.Wrap(err)
+	}
+
+	if len(imageConfig.RootFS.DiffIDs) > 0 {
+		diffID = imageConfig.RootFS.DiffIDs[0]
+	}
+
+	return manifest.Layers[0], diffID, nil
+}
+
 func getLayersFromManifest(manifest *serviceManifest) (layers []string) {
 	manifest.Layers = manifest.Layers[1:]
 
@@ -225,13 +287,18 @@ func getLayersFromManifest(manifest *serviceManifest) (layers []string) {
 	return layers
 }
 
-func updateRootFSDigestInManifest(installDir string, digest digest.Digest) (err error) {
+func updateRootFSInManifest(installDir string, rootFS imagespec.Descriptor) (err error) {
 	manifest, err := getImageManifest(installDir)
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
 
-	manifest.Layers[0].Digest = digest
+	manifest.Layers[0].Digest = rootFS.Digest
+	manifest.Layers[0].MediaType = rootFS.MediaType
+
+	if rootFS.Size != 0 {
+		manifest.Layers[0].Size = rootFS.Size
+	}
 
 	return aoserrors.Wrap(saveImageManifest(manifest, installDir))
 }
@@ -34,13 +34,25 @@ import (
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	"github.com/aoscloud/aos_common/image"
 	"github.com/aoscloud/aos_common/spaceallocator"
+	"github.com/aoscloud/aos_common/utils/action"
 	"github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 	"golang.org/x/mod/sumdb/dirhash"
 
+	"github.com/aoscloud/aos_servicemanager/blobstore"
+	"github.com/aoscloud/aos_servicemanager/clocksync"
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/contentreceiver"
+	"github.com/aoscloud/aos_servicemanager/downloader"
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+	"github.com/aoscloud/aos_servicemanager/utils/fsverity"
+	"github.com/aoscloud/aos_servicemanager/utils/ocilayer"
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
 	"github.com/aoscloud/aos_servicemanager/utils/whiteouts"
 )
 
@@ -48,8 +60,14 @@ import (
  * Consts
  **********************************************************************************************************************/
 
+// StatusCached status of desired service or layer which is already installed or restored from cache, so its download
+// and installation are skipped.
+const StatusCached = "cached"
+
 const tmpRootFSDir = "tmprootfs"
 
+const rootFSImageSuffix = ".img"
+
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
@@ -61,6 +79,36 @@ type ServiceStorage interface {
 	AddService(info ServiceInfo) error
 	RemoveService(serviceID string, aosVersion uint64) error
 	SetServiceCached(serviceID string, aosVersion uint64, cached bool) error
+	SetServiceRejected(serviceID string, aosVersion uint64, rejected bool) error
+	SetServiceQuarantined(serviceID string, aosVersion uint64, quarantined bool) error
+}
+
+// ContentProvider provides image content received from CM.
+type ContentProvider interface {
+	GetImageContent(ctx context.Context, url, contentType, destination string) (fileNames []string, err error)
+}
+
+// ClockProvider provides system clock synchronization state.
+type ClockProvider interface {
+	GetClockSyncedChannel() (channel <-chan struct{})
+}
+
+// SignatureVerifier verifies package signatures.
+type SignatureVerifier interface {
+	VerifyFile(fileName string) (status string, err error)
+}
+
+// Downloader downloads service packages.
+type Downloader interface {
+	Download(ctx context.Context, url string, target downloader.TargetInfo) (fileName string, err error)
+}
+
+// BlobStore stores service blobs shared between services.
+type BlobStore interface {
+	AddBlob(blobDigest digest.Digest, owner, sourcePath string) (blobPath string, size uint64, err error)
+	ReleaseBlobs(owner string) error
+	SetOwnerOutdated(owner string, timestamp time.Time, remover blobstore.OwnerRemover)
+	RestoreOwner(owner string)
 }
 
 // ServiceManager instance.
@@ -68,10 +116,21 @@ type ServiceManager struct {
 	sync.Mutex
 	servicesDir            string
 	downloadDir            string
+	imageFormat            string
+	verity                 bool
+	deltaUpdates           bool
 	serviceTTLDays         uint64
+	remoteNode             bool
+	contentTimeout         time.Duration
 	serviceInfoProvider    ServiceStorage
+	contentProvider        ContentProvider
+	signatureVerifier      SignatureVerifier
+	downloader             Downloader
+	blobStore              BlobStore
+	actionHandler          *action.Handler
 	serviceAllocator       spaceallocator.Allocator
 	downloadAllocator      spaceallocator.Allocator
+	clockSyncedChannel     <-chan struct{}
 	validateTTLStopChannel chan struct{}
 }
 
@@ -86,6 +145,10 @@ type ServiceInfo struct {
 	Cached          bool
 	Size            uint64
 	GID             uint32
+	SignatureStatus string
+	Rejected        bool
+	Quarantined     bool
+	VerityRootHash  string
 }
 
 /***********************************************************************************************************************
@@ -97,6 +160,12 @@ var (
 	ErrNotExist = errors.New("service not exist")
 	// ErrVersionMismatch new service version <= existing one.
 	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrServiceRejected service version is rejected after failed update.
+	ErrServiceRejected = errors.New("service version rejected")
+	// ErrNoRollbackVersion no previous service version to roll back to.
+	ErrNoRollbackVersion = errors.New("no previous service version")
+	// ErrServiceQuarantined service content is corrupted and waits for re-delivery.
+	ErrServiceQuarantined = errors.New("service quarantined")
 )
 
 // NewSpaceAllocator space allocator constructor.
@@ -115,21 +184,54 @@ var RemoveCachedServicesPeriod = 24 * time.Hour
 
 // New creates new service manager object.
 func New(
-	config *config.Config, serviceInfoProvider ServiceStorage,
+	config *config.Config, serviceInfoProvider ServiceStorage, contentProvider ContentProvider,
+	clockProvider ClockProvider, signatureVerifier SignatureVerifier, packageDownloader Downloader,
+	blobStore BlobStore,
 ) (sm *ServiceManager, err error) {
 	sm = &ServiceManager{
 		servicesDir:            config.ServicesDir,
 		downloadDir:            config.DownloadDir,
+		imageFormat:            config.ImageFormat,
+		verity:                 config.Verity,
+		deltaUpdates:           config.DeltaUpdates,
 		serviceTTLDays:         config.ServiceTTLDays,
+		remoteNode:             config.RemoteNode,
+		contentTimeout:         config.Download.Timeout.Duration,
 		serviceInfoProvider:    serviceInfoProvider,
+		contentProvider:        contentProvider,
+		signatureVerifier:      signatureVerifier,
+		downloader:             packageDownloader,
+		blobStore:              blobStore,
+		actionHandler:          action.New(config.MaxParallelInstalls),
+		clockSyncedChannel:     clocksync.GetSyncedChannel(clockProvider),
 		validateTTLStopChannel: make(chan struct{}),
 	}
 
+	if sm.contentTimeout == 0 {
+		sm.contentTimeout = contentreceiver.DefaultRequestTimeout
+	}
+
+	if sm.imageFormat != "" {
+		if _, err = fsimage.GetMediaType(sm.imageFormat); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	// Delta packages are applied by zstd tool
+	if sm.deltaUpdates {
+		if err = ocilayer.CheckZstd(); err != nil {
+			log.Warnf("Delta updates are disabled: %v", err)
+
+			sm.deltaUpdates = false
+		}
+	}
+
 	if err = os.MkdirAll(sm.servicesDir, 0o755); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
-	if err := os.RemoveAll(sm.downloadDir); err != nil {
+	// Keep partial downloads to resume them
+	if err := downloader.CleanDownloadDir(sm.downloadDir); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
@@ -142,7 +244,11 @@ func New(
 		return nil, aoserrors.Wrap(err)
 	}
 
-	if sm.downloadAllocator, err = NewSpaceAllocator(sm.downloadDir, 0, nil); err != nil {
+	if sm.downloadAllocator, err = NewSpaceAllocator(sm.downloadDir, 0, sm.removeOutdatedDownload); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = downloader.AddPartialFiles(sm.downloadDir, sm.downloadAllocator); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
@@ -160,17 +266,24 @@ func New(
 			fmt.Sprintf("%s_%d", service.ServiceID, service.AosVersion), service.Size, service.Timestamp); err != nil {
 			return nil, aoserrors.Wrap(err)
 		}
+
+		sm.setServiceBlobsOutdated(service)
 	}
 
 	if err := sm.removeDamagedServiceFolders(services); err != nil {
 		log.Errorf("Can't remove damaged service folders: %v", err)
 	}
 
-	if err := sm.removeOutdatedServices(services); err != nil {
-		log.Errorf("Can't remove outdated services: %v", err)
+	// Services TTL can't be checked until system clock is synchronized
+	clockSynced := sm.isClockSynced()
+
+	if clockSynced {
+		if err := sm.removeOutdatedServices(services); err != nil {
+			log.Errorf("Can't remove outdated services: %v", err)
+		}
 	}
 
-	go sm.validateTTLs()
+	go sm.validateTTLs(clockSynced)
 
 	return sm, nil
 }
@@ -204,41 +317,169 @@ func (sm *ServiceManager) GetServiceInfo(serviceID string) (serviceInfo ServiceI
 	return serviceInfo, ErrNotExist
 }
 
+// GetServicesInfo returns information of all installed services.
+func (sm *ServiceManager) GetServicesInfo() (services []ServiceInfo, err error) {
+	if services, err = sm.serviceInfoProvider.GetServices(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return services, nil
+}
+
 // GetImageParts gets image parts for the service.
 func (sm *ServiceManager) GetImageParts(service ServiceInfo) (parts ImageParts, err error) {
 	return getImageParts(service.ImagePath)
 }
 
-// ProcessDesiredServices installs, removes, restores desired services on the system.
-func (sm *ServiceManager) ProcessDesiredServices(desiredServices []aostypes.ServiceInfo) error {
+// ProcessDesiredServices installs, removes, restores desired services on the system. It returns status of each
+// desired service and error if any of them failed.
+func (sm *ServiceManager) ProcessDesiredServices(
+	desiredServices []aostypes.ServiceInfo,
+) (statuses []cloudprotocol.ServiceStatus, err error) {
+	sm.Lock()
+
 	services, err := sm.serviceInfoProvider.GetServices()
 	if err != nil {
-		return aoserrors.Wrap(err)
+		sm.Unlock()
+
+		return nil, aoserrors.Wrap(err)
 	}
 
-	if desiredServices, err = sm.updateCachedServices(desiredServices, services); err != nil {
-		return err
+	keepServices := make([]aostypes.ServiceInfo, 0, len(desiredServices))
+
+	for _, desiredService := range desiredServices {
+		if !isServiceRejected(services, desiredService.ID, desiredService.AosVersion) {
+			keepServices = append(keepServices, desiredService)
+
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"id": desiredService.ID, "aosVersion": desiredService.AosVersion,
+		}).Warn("Skip rejected service version")
+
+		statuses = append(statuses, cloudprotocol.ServiceStatus{
+			ID: desiredService.ID, AosVersion: desiredService.AosVersion, Status: cloudprotocol.ErrorStatus,
+			ErrorInfo: &cloudprotocol.ErrorInfo{Message: ErrServiceRejected.Error()},
+		})
+
+		// Keep running the version the rejected one was rolled back to
+		if previousService, ok := getRollbackService(services, desiredService.ID, desiredService.AosVersion); ok {
+			keepServices = append(keepServices, aostypes.ServiceInfo{
+				ID: previousService.ServiceID, VersionInfo: previousService.VersionInfo,
+			})
+		}
 	}
 
-	if err = sm.installServices(desiredServices); err != nil {
-		return err
+	installServices, err := sm.updateCachedServices(keepServices, services)
+
+	sm.Unlock()
+
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	for _, desiredService := range desiredServices {
+		if isServiceRejected(services, desiredService.ID, desiredService.AosVersion) {
+			continue
+		}
+
+		if !slices.ContainsFunc(installServices, func(service aostypes.ServiceInfo) bool {
+			return service.ID == desiredService.ID && service.AosVersion == desiredService.AosVersion
+		}) {
+			statuses = append(statuses, cloudprotocol.ServiceStatus{
+				ID: desiredService.ID, AosVersion: desiredService.AosVersion, Status: StatusCached,
+			})
+		}
+	}
+
+	installStatuses, err := sm.installServices(installServices)
+
+	return append(statuses, installStatuses...), err
+}
+
+// RollbackService rejects failed service version and restores the newest previous not rejected version of the
+// service from cache.
+func (sm *ServiceManager) RollbackService(
+	serviceID string, aosVersion uint64,
+) (previousService ServiceInfo, err error) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	log.WithFields(log.Fields{"id": serviceID, "aosVersion": aosVersion}).Debug("Rollback service")
+
+	services, err := sm.serviceInfoProvider.GetAllServiceVersions(serviceID)
+	if err != nil {
+		return previousService, aoserrors.Wrap(err)
+	}
+
+	index := slices.IndexFunc(services, func(service ServiceInfo) bool { return service.AosVersion == aosVersion })
+	if index < 0 {
+		return previousService, ErrNotExist
+	}
+
+	failedService := services[index]
+
+	previousService, ok := getRollbackService(services, serviceID, aosVersion)
+	if !ok {
+		return previousService, ErrNoRollbackVersion
+	}
+
+	if err = sm.serviceInfoProvider.SetServiceRejected(serviceID, aosVersion, true); err != nil {
+		return previousService, aoserrors.Wrap(err)
+	}
+
+	if !failedService.Cached {
+		if err = sm.setServiceCached(failedService, true); err != nil {
+			return previousService, err
+		}
+	}
+
+	if previousService.Cached {
+		if err = sm.setServiceCached(previousService, false); err != nil {
+			return previousService, err
+		}
+
+		previousService.Cached = false
+	}
+
+	log.WithFields(log.Fields{
+		"id":              serviceID,
+		"aosVersion":      previousService.AosVersion,
+		"failedVersion":   aosVersion,
+		"failedImagePath": failedService.ImagePath,
+	}).Info("Service rolled back")
+
+	return previousService, nil
 }
 
 // ValidateService validate service.
 func (sm *ServiceManager) ValidateService(service ServiceInfo) error {
-	manifestCheckSum, err := getManifestChecksum(service.ImagePath)
-	if err != nil {
-		return aoserrors.Wrap(err)
+	if service.Quarantined {
+		return aoserrors.Wrap(ErrServiceQuarantined)
 	}
 
-	if !bytes.Equal(service.ManifestDigest, manifestCheckSum) {
-		return aoserrors.New("manifest checksum mismatch")
+	return verifyService(service, nil)
+}
+
+// VerifyService verifies service content. Read rate is limited by the limiter.
+func (sm *ServiceManager) VerifyService(service ServiceInfo, limiter *ratelimit.Limiter) error {
+	return verifyService(service, limiter)
+}
+
+// QuarantineService marks service as corrupted. Quarantined service can't be started and it is removed on next desired
+// services processing in order to be installed again.
+func (sm *ServiceManager) QuarantineService(service ServiceInfo) error {
+	sm.Lock()
+	defer sm.Unlock()
+
+	log.WithFields(log.Fields{"id": service.ServiceID, "aosVersion": service.AosVersion}).Warn("Quarantine service")
+
+	if err := sm.serviceInfoProvider.SetServiceQuarantined(service.ServiceID, service.AosVersion, true); err != nil {
+		return aoserrors.Wrap(err)
 	}
 
-	return validateUnpackedImage(service.ImagePath)
+	return nil
 }
 
 /***********************************************************************************************************************
@@ -250,6 +491,20 @@ func (sm *ServiceManager) updateCachedServices(
 ) (installServices []aostypes.ServiceInfo, err error) {
 nextService:
 	for _, storeService := range storeServices {
+		// Quarantined service is removed to be installed again if it is still desired. Its instances are stopped
+		// on quarantine, so image is not used anymore. Rejected versions are kept as they are never started.
+		if storeService.Quarantined && !storeService.Rejected {
+			log.WithFields(log.Fields{
+				"id": storeService.ServiceID, "aosVersion": storeService.AosVersion,
+			}).Warn("Remove quarantined service")
+
+			if err := sm.removeService(storeService); err != nil {
+				return desiredServices, err
+			}
+
+			continue
+		}
+
 		for i, desiredService := range desiredServices {
 			if desiredService.ID == storeService.ServiceID && desiredService.AosVersion == storeService.AosVersion {
 				if storeService.Cached {
@@ -274,14 +529,37 @@ nextService:
 	return desiredServices, nil
 }
 
-func (sm *ServiceManager) installServices(desiredServices []aostypes.ServiceInfo) error {
-	for _, desiredService := range desiredServices {
-		if err := sm.installService(desiredService); err != nil {
-			return err
+func (sm *ServiceManager) installServices(
+	desiredServices []aostypes.ServiceInfo,
+) (statuses []cloudprotocol.ServiceStatus, err error) {
+	statuses = make([]cloudprotocol.ServiceStatus, len(desiredServices))
+	installErrors := make([]error, len(desiredServices))
+
+	for i, desiredService := range desiredServices {
+		i, desiredService := i, desiredService
+
+		statuses[i] = cloudprotocol.ServiceStatus{
+			ID: desiredService.ID, AosVersion: desiredService.AosVersion, Status: cloudprotocol.InstalledStatus,
 		}
+
+		// Versions of the same service are installed sequentially
+		sm.actionHandler.Execute(desiredService.ID, func(serviceID string) error {
+			if installErrors[i] = sm.installService(desiredService); installErrors[i] != nil {
+				statuses[i].Status = cloudprotocol.ErrorStatus
+				statuses[i].ErrorInfo = &cloudprotocol.ErrorInfo{Message: installErrors[i].Error()}
+			}
+
+			return installErrors[i]
+		})
 	}
 
-	return nil
+	sm.actionHandler.Wait()
+
+	if err = errors.Join(installErrors...); err != nil {
+		return statuses, aoserrors.Wrap(err)
+	}
+
+	return statuses, nil
 }
 
 func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error {
@@ -290,7 +568,7 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 		"AosVersion": serviceInfo.AosVersion,
 	}).Debug("Install service")
 
-	var spacePackage, spaceService spaceallocator.Space
+	var spacePackage, spaceService, spaceVerity spaceallocator.Space
 
 	imagePath, size, spacePackage, err := sm.extractPackageByURL(&serviceInfo)
 	if err != nil {
@@ -299,7 +577,8 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 
 	defer func() {
 		if err != nil {
-			releaseAllocatedSpace(imagePath, spaceService, spacePackage)
+			releaseAllocatedSpace(imagePath, spaceService, spacePackage, spaceVerity)
+			sm.releaseServiceBlobs(serviceInfo.ID, serviceInfo.AosVersion)
 
 			log.WithFields(log.Fields{
 				"id":         serviceInfo.ID,
@@ -310,14 +589,19 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 			return
 		}
 
-		acceptAllocatedSpace(spaceService, spacePackage)
+		acceptAllocatedSpace(spaceService, spacePackage, spaceVerity)
 	}()
 
-	if err = validateUnpackedImage(imagePath); err != nil {
+	signatureStatus, err := sm.verifySignature(imagePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = validateUnpackedImage(imagePath, nil); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
-	serviceSize, space, rootFSDigest, err := sm.prepareServiceFS(imagePath, int(serviceInfo.GID))
+	serviceSize, space, rootFS, err := sm.prepareServiceFS(imagePath, int(serviceInfo.GID))
 	if err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -325,7 +609,7 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 	spaceService = space
 	size += uint64(serviceSize)
 
-	if err = updateRootFSDigestInManifest(imagePath, rootFSDigest); err != nil {
+	if err = updateRootFSInManifest(imagePath, rootFS); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
@@ -334,6 +618,25 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 		return aoserrors.Wrap(err)
 	}
 
+	blobsSize, err := sm.storeServiceBlobs(serviceInfo.ID, serviceInfo.AosVersion, imagePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	// Blobs space is accounted by the blob store
+	if blobsSize < size {
+		size -= blobsSize
+	} else {
+		size = 0
+	}
+
+	verityRootHash, hashTreeSize, spaceVerity, err := sm.enableVerity(imagePath)
+	if err != nil {
+		return err
+	}
+
+	size += hashTreeSize
+
 	if err = sm.serviceInfoProvider.AddService(ServiceInfo{
 		VersionInfo:     serviceInfo.VersionInfo,
 		ServiceID:       serviceInfo.ID,
@@ -343,6 +646,8 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 		ManifestDigest:  manifestDigest,
 		Timestamp:       time.Now().UTC(),
 		GID:             serviceInfo.GID,
+		SignatureStatus: signatureStatus,
+		VerityRootHash:  verityRootHash,
 	}); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -351,28 +656,122 @@ func (sm *ServiceManager) installService(serviceInfo aostypes.ServiceInfo) error
 		"id":         serviceInfo.ID,
 		"aosVersion": serviceInfo.AosVersion,
 		"imagePath":  imagePath,
+		"signature":  signatureStatus,
 	}).Info("Service successfully installed")
 
+	if sm.deltaUpdates {
+		sm.removeOutdatedBasePackages(serviceInfo.ID)
+	}
+
 	return nil
 }
 
-func (sm *ServiceManager) validateTTLs() {
+// enableVerity protects service rootfs: fs-verity is enabled on unpacked rootfs files and dm-verity hash tree is created
+// for rootfs image. It returns the rootfs root hash to be checked before the rootfs is mounted and space allocated for
+// the hash tree.
+func (sm *ServiceManager) enableVerity(installDir string) (
+	rootHash string, hashTreeSize uint64, hashTreeSpace spaceallocator.Space, err error,
+) {
+	if !sm.verity {
+		return "", 0, nil, nil
+	}
+
+	imageParts, err := getImageParts(installDir)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	rootFSPath, err := filepath.EvalSymlinks(imageParts.ServiceFSPath)
+	if err != nil {
+		return "", 0, nil, aoserrors.Wrap(err)
+	}
+
+	fileInfo, err := os.Stat(rootFSPath)
+	if err != nil {
+		return "", 0, nil, aoserrors.Wrap(err)
+	}
+
+	if fileInfo.IsDir() {
+		if rootHash, err = fsverity.Enable(rootFSPath); err != nil {
+			return "", 0, nil, aoserrors.Wrap(err)
+		}
+
+		return rootHash, 0, nil, nil
+	}
+
+	// Hash tree is stored on services partition, its space should be allocated before it is created
+	allocatedSize := fsimage.GetVerityHashTreeSize(uint64(fileInfo.Size()))
+
+	allocatedSpace, err := sm.serviceAllocator.AllocateSpace(allocatedSize)
+	if err != nil {
+		return "", 0, nil, aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			if releaseErr := allocatedSpace.Release(); releaseErr != nil {
+				log.Errorf("Can't release memory: %v", releaseErr)
+			}
+		}
+	}()
+
+	hashTreePath := filepath.Join(installDir, verityHashTreeFile)
+
+	if rootHash, err = fsimage.FormatVerity(rootFSPath, hashTreePath); err != nil {
+		return "", 0, nil, aoserrors.Wrap(err)
+	}
+
+	if hashTreeSize, err = getFileSize(hashTreePath); err != nil {
+		return "", 0, nil, err
+	}
+
+	if hashTreeSize < allocatedSize {
+		sm.serviceAllocator.FreeSpace(allocatedSize - hashTreeSize)
+	}
+
+	return rootHash, hashTreeSize, allocatedSpace, nil
+}
+
+func (sm *ServiceManager) verifySignature(imagePath string) (status string, err error) {
+	if sm.signatureVerifier == nil {
+		return "", nil
+	}
+
+	// Manifest refers all image blobs by digest, so it is enough to verify manifest signature
+	if status, err = sm.signatureVerifier.VerifyFile(filepath.Join(imagePath, manifestFileName)); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return status, nil
+}
+
+func (sm *ServiceManager) validateTTLs(clockSynced bool) {
 	removeTicker := time.NewTicker(RemoveCachedServicesPeriod)
 	defer removeTicker.Stop()
 
+	clockSyncedChannel := sm.clockSyncedChannel
+
+	if clockSynced {
+		clockSyncedChannel = nil
+	}
+
 	for {
 		select {
+		case <-clockSyncedChannel:
+			clockSyncedChannel = nil
+
+			log.Debug("Clock synced, check services TTL")
+
+			sm.checkServicesTTL()
+
 		case <-removeTicker.C:
-			services, err := sm.serviceInfoProvider.GetServices()
-			if err != nil {
-				log.Errorf("Can't get services: %v", err)
+			if !sm.isClockSynced() {
+				log.Warn("Clock is not synced, skip services TTL check")
 
 				continue
 			}
 
-			if err := sm.removeOutdatedServices(services); err != nil {
-				log.Errorf("Can't remove outdated services: %v", err)
-			}
+			sm.checkServicesTTL()
 
 		case <-sm.validateTTLStopChannel:
 			return
@@ -380,6 +779,29 @@ func (sm *ServiceManager) validateTTLs() {
 	}
 }
 
+func (sm *ServiceManager) checkServicesTTL() {
+	services, err := sm.serviceInfoProvider.GetServices()
+	if err != nil {
+		log.Errorf("Can't get services: %v", err)
+
+		return
+	}
+
+	if err := sm.removeOutdatedServices(services); err != nil {
+		log.Errorf("Can't remove outdated services: %v", err)
+	}
+}
+
+func (sm *ServiceManager) isClockSynced() bool {
+	select {
+	case <-sm.clockSyncedChannel:
+		return true
+
+	default:
+		return false
+	}
+}
+
 func (sm *ServiceManager) removeOutdatedServices(services []ServiceInfo) error {
 	for _, service := range services {
 		if service.Cached {
@@ -422,6 +844,8 @@ func (sm *ServiceManager) removeOutdatedService(id string) error {
 				return aoserrors.Wrap(err)
 			}
 
+			sm.releaseServiceBlobs(service.ServiceID, service.AosVersion)
+
 			if err := sm.serviceInfoProvider.RemoveService(service.ServiceID, service.AosVersion); err != nil {
 				return aoserrors.Wrap(err)
 			}
@@ -431,6 +855,10 @@ func (sm *ServiceManager) removeOutdatedService(id string) error {
 	return nil
 }
 
+func (sm *ServiceManager) removeOutdatedDownload(id string) error {
+	return aoserrors.Wrap(downloader.RemovePartialFile(sm.downloadDir, id))
+}
+
 func (sm *ServiceManager) setServiceCached(service ServiceInfo, cached bool) error {
 	if err := sm.serviceInfoProvider.SetServiceCached(service.ServiceID, service.AosVersion, cached); err != nil {
 		return aoserrors.Wrap(err)
@@ -444,11 +872,17 @@ func (sm *ServiceManager) setServiceCached(service ServiceInfo, cached bool) err
 			return aoserrors.Wrap(err)
 		}
 
+		sm.setServiceBlobsOutdated(service)
+
 		return nil
 	}
 
 	sm.serviceAllocator.RestoreOutdatedItem(id)
 
+	if sm.blobStore != nil {
+		sm.blobStore.RestoreOwner(getServiceBlobOwner(service.ServiceID, service.AosVersion))
+	}
+
 	return nil
 }
 
@@ -457,6 +891,8 @@ func (sm *ServiceManager) removeService(service ServiceInfo) error {
 		return aoserrors.Wrap(err)
 	}
 
+	sm.releaseServiceBlobs(service.ServiceID, service.AosVersion)
+
 	if service.Cached {
 		sm.serviceAllocator.RestoreOutdatedItem(fmt.Sprintf("%s_%d", service.ServiceID, service.AosVersion))
 	}
@@ -477,18 +913,30 @@ func (sm *ServiceManager) removeService(service ServiceInfo) error {
 
 func (sm *ServiceManager) prepareServiceFS(
 	imagePath string, gid int,
-) (serviceSize int64, space spaceallocator.Space, rootFSDigest digest.Digest, err error) {
+) (serviceSize int64, space spaceallocator.Space, rootFS imagespec.Descriptor, err error) {
 	imageParts, err := getImageParts(imagePath)
 	if err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+		return 0, nil, rootFS, aoserrors.Wrap(err)
+	}
+
+	rootFSLayer, diffID, err := getRootFSLayer(imagePath)
+	if err != nil {
+		return 0, nil, rootFS, aoserrors.Wrap(err)
+	}
+
+	// Rootfs files should be owned by service GID and OCI whiteouts should be converted to overlay ones. It can't be
+	// done for read-only image, so rootfs is accepted only as layer archive and converted to image if configured.
+	if fsimage.IsImageMediaType(rootFSLayer.MediaType) {
+		return 0, nil, rootFS, aoserrors.Errorf("service rootfs of %s media type is not supported",
+			rootFSLayer.MediaType)
 	}
 
-	if serviceSize, err = image.GetUncompressedTarContentSize(imageParts.ServiceFSPath); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+	if serviceSize, err = ocilayer.GetUncompressedSize(imageParts.ServiceFSPath, rootFSLayer.MediaType); err != nil {
+		return 0, nil, rootFS, aoserrors.Wrap(err)
 	}
 
 	if space, err = sm.serviceAllocator.AllocateSpace(uint64(serviceSize)); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+		return 0, nil, rootFS, aoserrors.Wrap(err)
 	}
 
 	originRootFSPath := imageParts.ServiceFSPath
@@ -496,13 +944,13 @@ func (sm *ServiceManager) prepareServiceFS(
 	tmpRootFS := filepath.Join(imagePath, tmpRootFSDir)
 
 	// unpack rootfs layer
-	if err = image.UnpackTarImage(imageParts.ServiceFSPath, tmpRootFS); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+	if err = ocilayer.Unpack(imageParts.ServiceFSPath, tmpRootFS, rootFSLayer.MediaType, diffID); err != nil {
+		return 0, nil, rootFS, aoserrors.Wrap(err)
 	}
 
 	serviceFSArchiveSize, err := getFileSize(imageParts.ServiceFSPath)
 	if err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+		return 0, nil, rootFS, aoserrors.Wrap(err)
 	}
 
 	sm.serviceAllocator.FreeSpace(serviceFSArchiveSize)
@@ -522,27 +970,154 @@ func (sm *ServiceManager) prepareServiceFS(
 
 		return nil
 	}); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+		return 0, nil, rootFS, aoserrors.Wrap(err)
 	}
 
 	if err := whiteouts.OCIWhiteoutsToOverlay(tmpRootFS, 0, gid); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+		return 0, nil, rootFS, aoserrors.Wrap(err)
+	}
+
+	if sm.imageFormat != "" {
+		var imageSize int64
+
+		if imageSize, rootFS, err = sm.createRootFSImage(tmpRootFS, path.Dir(originRootFSPath)); err != nil {
+			return 0, nil, rootFS, err
+		}
+
+		// Space is allocated for unpacked rootfs, image is usually smaller
+		if imageSize < serviceSize {
+			sm.serviceAllocator.FreeSpace(uint64(serviceSize - imageSize))
+			serviceSize = imageSize
+		}
+
+		return serviceSize, space, rootFS, nil
 	}
 
 	rootFSHash, err := dirhash.HashDir(tmpRootFS, tmpRootFS, dirDigest)
 	if err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+		return 0, nil, rootFS, aoserrors.Wrap(err)
+	}
+
+	rootFS.MediaType = rootFSLayer.MediaType
+
+	if rootFS.Digest, err = digest.Parse(rootFSHash); err != nil {
+		return 0, nil, rootFS, aoserrors.Wrap(err)
+	}
+
+	if err = os.Rename(tmpRootFS, filepath.Join(path.Dir(originRootFSPath), rootFS.Digest.Hex())); err != nil {
+		return 0, nil, rootFS, aoserrors.Wrap(err)
+	}
+
+	return serviceSize, space, rootFS, nil
+}
+
+// createRootFSImage converts unpacked rootfs to read-only image and stores it as blob named by image digest.
+func (sm *ServiceManager) createRootFSImage(
+	rootFSDir, blobsDir string,
+) (imageSize int64, rootFS imagespec.Descriptor, err error) {
+	imageFile := rootFSDir + rootFSImageSuffix
+
+	if err = fsimage.Create(sm.imageFormat, rootFSDir, imageFile); err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+
+	if err = os.RemoveAll(rootFSDir); err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+
+	file, err := os.Open(imageFile)
+	if err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if rootFS.Digest, err = digest.FromReader(file); err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+
+	if rootFS.MediaType, err = fsimage.GetMediaType(sm.imageFormat); err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+
+	rootFS.Size = fileInfo.Size()
+
+	if err = os.Rename(imageFile, filepath.Join(blobsDir, rootFS.Digest.Hex())); err != nil {
+		return 0, rootFS, aoserrors.Wrap(err)
+	}
+
+	return fileInfo.Size(), rootFS, nil
+}
+
+// storeServiceBlobs moves image config, service config and rootfs blobs into the blob store and replaces them with
+// links to the store. It returns size of the blobs moved out of the service dir.
+func (sm *ServiceManager) storeServiceBlobs(
+	serviceID string, aosVersion uint64, imagePath string,
+) (blobsSize uint64, err error) {
+	if sm.blobStore == nil {
+		return 0, nil
+	}
+
+	manifest, err := getImageManifest(imagePath)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	blobDigests := []digest.Digest{manifest.Config.Digest, manifest.Layers[0].Digest}
+
+	if manifest.AosService != nil && !slices.Contains(blobDigests, manifest.AosService.Digest) {
+		blobDigests = append(blobDigests, manifest.AosService.Digest)
+	}
+
+	for _, blobDigest := range blobDigests {
+		sourcePath := filepath.Join(imagePath, blobsFolder, string(blobDigest.Algorithm()), blobDigest.Hex())
+
+		blobPath, size, err := sm.blobStore.AddBlob(blobDigest, getServiceBlobOwner(serviceID, aosVersion), sourcePath)
+		if err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
+
+		// Blob is moved to the store or removed as duplicate, its space is accounted by the store
+		sm.serviceAllocator.FreeSpace(size)
+
+		blobsSize += size
+
+		if err = os.Symlink(blobPath, sourcePath); err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
 	}
 
-	if rootFSDigest, err = digest.Parse(rootFSHash); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+	return blobsSize, nil
+}
+
+func (sm *ServiceManager) setServiceBlobsOutdated(service ServiceInfo) {
+	if sm.blobStore == nil {
+		return
 	}
 
-	if err = os.Rename(tmpRootFS, filepath.Join(path.Dir(originRootFSPath), rootFSDigest.Hex())); err != nil {
-		return 0, nil, "", aoserrors.Wrap(err)
+	id := fmt.Sprintf("%s_%d", service.ServiceID, service.AosVersion)
+
+	sm.blobStore.SetOwnerOutdated(getServiceBlobOwner(service.ServiceID, service.AosVersion), service.Timestamp,
+		func() error {
+			return sm.removeOutdatedService(id)
+		})
+}
+
+func (sm *ServiceManager) releaseServiceBlobs(serviceID string, aosVersion uint64) {
+	if sm.blobStore == nil {
+		return
 	}
 
-	return serviceSize, space, rootFSDigest, nil
+	if err := sm.blobStore.ReleaseBlobs(getServiceBlobOwner(serviceID, aosVersion)); err != nil {
+		log.WithFields(log.Fields{
+			"serviceID":  serviceID,
+			"aosVersion": aosVersion,
+		}).Errorf("Can't release service blobs: %v", err)
+	}
 }
 
 func (sm *ServiceManager) removeDamagedServiceFolders(services []ServiceInfo) error {
@@ -554,6 +1129,8 @@ func (sm *ServiceManager) removeDamagedServiceFolders(services []ServiceInfo) er
 			if err = sm.serviceInfoProvider.RemoveService(service.ServiceID, service.AosVersion); err != nil {
 				return aoserrors.Wrap(err)
 			}
+
+			sm.releaseServiceBlobs(service.ServiceID, service.AosVersion)
 		}
 	}
 
@@ -582,35 +1159,116 @@ filesLoop:
 	return nil
 }
 
+// allocateDownloadSpace allocates download dir space for package download or for delta package download and full
+// package rebuild. Delta package size is unknown and is limited by the full package size. Full package fallback
+// download is done after the delta files are removed. Space is nil if nothing is stored in download dir.
+func (sm *ServiceManager) allocateDownloadSpace(
+	serviceInfo *aostypes.ServiceInfo, fullURL string, delta *deltaInfo,
+) (space spaceallocator.Space, downloadURLs []string, err error) {
+	downloadFull, err := isRemoteURL(fullURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if delta == nil && !downloadFull {
+		return nil, nil, nil
+	}
+
+	var size uint64
+
+	if downloadFull {
+		downloadURLs = append(downloadURLs, fullURL)
+
+		// Partial download of the package already takes space on the disk
+		partialSize := downloader.ReservePartialFiles(sm.downloadDir, sm.downloadAllocator, fullURL)
+
+		size = serviceInfo.Size - min(partialSize, serviceInfo.Size)
+	}
+
+	if delta != nil {
+		downloadDelta, err := isRemoteURL(delta.url)
+		if err != nil {
+			downloader.ReleasePartialFiles(sm.downloadDir, sm.downloadAllocator, downloadURLs...)
+
+			return nil, nil, err
+		}
+
+		// Full package is rebuilt in download dir, fallback full package download takes the same space
+		size = serviceInfo.Size
+
+		if downloadDelta {
+			downloadURLs = append(downloadURLs, delta.url)
+
+			partialSize := downloader.ReservePartialFiles(sm.downloadDir, sm.downloadAllocator, delta.url)
+
+			size += serviceInfo.Size - min(partialSize, serviceInfo.Size)
+		}
+	}
+
+	if space, err = sm.downloadAllocator.AllocateSpace(size); err != nil {
+		downloader.ReleasePartialFiles(sm.downloadDir, sm.downloadAllocator, downloadURLs...)
+
+		return nil, nil, aoserrors.Wrap(err)
+	}
+
+	return space, downloadURLs, nil
+}
+
 func (sm *ServiceManager) extractPackageByURL(
 	serviceInfo *aostypes.ServiceInfo,
 ) (imagePath string, serviceSize uint64, space spaceallocator.Space, err error) {
-	urlVal, err := url.Parse(serviceInfo.URL)
+	fullURL, delta, err := parsePackageURL(serviceInfo.URL)
 	if err != nil {
 		return "", 0, nil, aoserrors.Wrap(err)
 	}
 
-	var sourceFile string
+	urlVal, err := url.Parse(fullURL)
+	if err != nil {
+		return "", 0, nil, aoserrors.Wrap(err)
+	}
 
-	if urlVal.Scheme != "file" {
-		space, err := sm.downloadAllocator.AllocateSpace(serviceInfo.Size)
-		if err != nil {
-			return "", 0, nil, aoserrors.Wrap(err)
-		}
+	downloadSpace, downloadURLs, err := sm.allocateDownloadSpace(serviceInfo, fullURL, delta)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	defer downloader.ReleasePartialFiles(sm.downloadDir, sm.downloadAllocator, downloadURLs...)
 
+	if downloadSpace != nil {
 		defer func() {
-			if err := space.Release(); err != nil {
+			if err := downloadSpace.Release(); err != nil {
 				log.Errorf("Can't release memory: %v", err)
 			}
 		}()
+	}
 
-		if sourceFile, err = image.Download(context.Background(), sm.downloadDir, serviceInfo.URL); err != nil {
-			return "", 0, nil, aoserrors.Wrap(err)
+	var sourceFile string
+
+	if delta != nil {
+		if sourceFile, err = sm.applyDeltaPackage(serviceInfo, *delta); err != nil {
+			log.WithFields(log.Fields{
+				"id": serviceInfo.ID, "aosVersion": serviceInfo.AosVersion, "baseVersion": delta.baseVersion,
+			}).Warnf("Can't apply delta package, use full package: %v", err)
+		} else {
+			defer os.RemoveAll(sourceFile)
 		}
+	}
 
-		defer os.RemoveAll(sourceFile)
-	} else {
-		sourceFile = urlVal.Path
+	if sourceFile == "" {
+		if urlVal.Scheme != "file" {
+			if sourceFile, err = sm.downloadPackage(fullURL, downloader.TargetInfo{
+				Type:          cloudprotocol.DownloadTargetService,
+				ID:            serviceInfo.ID,
+				AosVersion:    serviceInfo.AosVersion,
+				VendorVersion: serviceInfo.VendorVersion,
+			}); err != nil {
+				return "", 0, nil, aoserrors.Wrap(err)
+			}
+
+			defer os.RemoveAll(sourceFile)
+		} else {
+			sourceFile = urlVal.Path
+		}
 	}
 
 	if err = image.CheckFileInfo(context.Background(), sourceFile, image.FileInfo{
@@ -621,12 +1279,19 @@ func (sm *ServiceManager) extractPackageByURL(
 		return "", 0, nil, aoserrors.Wrap(err)
 	}
 
-	size, err := image.GetUncompressedTarContentSize(sourceFile)
+	tarSize, err := image.GetUncompressedTarContentSize(sourceFile)
 	if err != nil {
 		return "", 0, nil, aoserrors.Wrap(err)
 	}
 
-	if space, err = sm.serviceAllocator.AllocateSpace(uint64(size)); err != nil {
+	size := uint64(tarSize)
+
+	// Package is kept as base for delta updates of next versions
+	if sm.deltaUpdates {
+		size += serviceInfo.Size
+	}
+
+	if space, err = sm.serviceAllocator.AllocateSpace(size); err != nil {
 		return "", 0, nil, aoserrors.Wrap(err)
 	}
 
@@ -639,32 +1304,125 @@ func (sm *ServiceManager) extractPackageByURL(
 		return "", 0, nil, aoserrors.Wrap(err)
 	}
 
-	return imagePath, uint64(size), space, nil
+	if sm.deltaUpdates {
+		if err = linkOrCopyFile(sourceFile, filepath.Join(imagePath, basePackageFile)); err != nil {
+			return "", 0, nil, err
+		}
+	}
+
+	return imagePath, size, space, nil
 }
 
-func acceptAllocatedSpace(spaceService spaceallocator.Space, spacePackage spaceallocator.Space) {
-	if err := spacePackage.Accept(); err != nil {
-		log.Errorf("Can't accept memory: %v", err)
+func (sm *ServiceManager) downloadPackage(
+	url string, target downloader.TargetInfo,
+) (fileName string, err error) {
+	if !sm.remoteNode {
+		if sm.downloader != nil {
+			return sm.downloader.Download(context.Background(), url, target)
+		}
+
+		if fileName, err = image.Download(context.Background(), sm.downloadDir, url); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		return fileName, nil
+	}
+
+	// Remote node has no access to the download server, request the package content from CM
+
+	if sm.contentProvider == nil {
+		return "", aoserrors.New("content provider is not set")
 	}
 
-	if err := spaceService.Accept(); err != nil {
-		log.Errorf("Can't accept memory: %v", err)
+	contentDir, err := os.MkdirTemp(sm.downloadDir, "")
+	if err != nil {
+		return "", aoserrors.Wrap(err)
 	}
+	defer os.RemoveAll(contentDir)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), sm.contentTimeout)
+	defer cancelFunc()
+
+	fileNames, err := sm.contentProvider.GetImageContent(ctx, url, contentreceiver.ContentTypeService, contentDir)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if len(fileNames) != 1 {
+		return "", aoserrors.Errorf("unexpected image content files count: %d", len(fileNames))
+	}
+
+	fileName = contentDir + ".pkg"
+
+	if err = os.Rename(fileNames[0], fileName); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return fileName, nil
 }
 
-func releaseAllocatedSpace(
-	imagePath string, spaceService spaceallocator.Space, spacePackage spaceallocator.Space,
-) {
+func verifyService(service ServiceInfo, limiter *ratelimit.Limiter) error {
+	manifestCheckSum, err := getManifestChecksum(service.ImagePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !bytes.Equal(service.ManifestDigest, manifestCheckSum) {
+		return aoserrors.New("manifest checksum mismatch")
+	}
+
+	return validateUnpackedImage(service.ImagePath, limiter)
+}
+
+func isServiceRejected(services []ServiceInfo, serviceID string, aosVersion uint64) bool {
+	return slices.ContainsFunc(services, func(service ServiceInfo) bool {
+		return service.ServiceID == serviceID && service.AosVersion == aosVersion && service.Rejected
+	})
+}
+
+func getRollbackService(
+	services []ServiceInfo, serviceID string, aosVersion uint64,
+) (previousService ServiceInfo, found bool) {
+	for _, service := range services {
+		if service.ServiceID != serviceID || service.Rejected || service.AosVersion >= aosVersion {
+			continue
+		}
+
+		if !found || service.AosVersion > previousService.AosVersion {
+			previousService, found = service, true
+		}
+	}
+
+	return previousService, found
+}
+
+func getServiceBlobOwner(serviceID string, aosVersion uint64) string {
+	return fmt.Sprintf("service:%s_%d", serviceID, aosVersion)
+}
+
+func acceptAllocatedSpace(spaces ...spaceallocator.Space) {
+	for _, space := range spaces {
+		if space == nil {
+			continue
+		}
+
+		if err := space.Accept(); err != nil {
+			log.Errorf("Can't accept memory: %v", err)
+		}
+	}
+}
+
+func releaseAllocatedSpace(imagePath string, spaces ...spaceallocator.Space) {
 	if err := os.RemoveAll(imagePath); err != nil {
 		log.Errorf("Can't remove service image: %v", err)
 	}
 
-	if err := spacePackage.Release(); err != nil {
-		log.Errorf("Can't release memory: %v", err)
-	}
+	for _, space := range spaces {
+		if space == nil {
+			continue
+		}
 
-	if spaceService != nil {
-		if err := spaceService.Release(); err != nil {
+		if err := space.Release(); err != nil {
 			log.Errorf("Can't release memory: %v", err)
 		}
 	}
@@ -683,3 +1441,13 @@ func getFileSize(fileName string) (size uint64, err error) {
 
 	return uint64(stat.Size), nil
 }
+
+// isRemoteURL returns true if package should be downloaded, local files are used in place.
+func isRemoteURL(rawURL string) (bool, error) {
+	urlVal, err := url.Parse(rawURL)
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	return urlVal.Scheme != "file", nil
+}
@@ -22,27 +22,35 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
 	"github.com/aoscloud/aos_common/image"
 	"github.com/aoscloud/aos_common/spaceallocator"
 	"github.com/aoscloud/aos_common/utils/fs"
 	"github.com/opencontainers/go-digest"
 	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
+	"github.com/aoscloud/aos_servicemanager/blobstore"
 	"github.com/aoscloud/aos_servicemanager/config"
 	"github.com/aoscloud/aos_servicemanager/servicemanager"
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+	"github.com/aoscloud/aos_servicemanager/utils/fsverity"
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
 )
 
 func init() {
@@ -73,6 +81,7 @@ const (
  **********************************************************************************************************************/
 
 type testServiceStorage struct {
+	sync.Mutex
 	getAllError bool
 	Services    []servicemanager.ServiceInfo
 }
@@ -85,6 +94,7 @@ type testAllocator struct {
 	partLimit     uint
 	remover       spaceallocator.ItemRemover
 	outdatedItems []testOutdatedItem
+	allocations   []uint64
 }
 
 type expectedService struct {
@@ -102,6 +112,17 @@ type testOutdatedItem struct {
 	size uint64
 }
 
+type testBlobStorage struct {
+	refs []blobstore.BlobReference
+}
+
+type testSignatureVerifier struct {
+	sync.Mutex
+	status        string
+	err           error
+	verifiedFiles []string
+}
+
 /***********************************************************************************************************************
  * Vars
  **********************************************************************************************************************/
@@ -156,7 +177,7 @@ func TestInstallService(t *testing.T) {
 
 	serviceAllocator = &testAllocator{}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %s", err)
 	}
@@ -243,17 +264,17 @@ func TestInstallService(t *testing.T) {
 				{serviceID: "service2", version: 2},
 			},
 			removedServices: []expectedService{
+				{serviceID: "service2", version: 1},
 				{serviceID: "service3", version: 1},
 			},
 			restoreServices: []expectedService{
-				{serviceID: "service2", version: 1},
 				{serviceID: "service2", version: 2},
 			},
 		},
 	}
 
 	for _, tCase := range cases {
-		if err := sm.ProcessDesiredServices(tCase.desiredServices); err != nil {
+		if _, err := sm.ProcessDesiredServices(tCase.desiredServices); err != nil {
 			t.Errorf("Can't process desired services: %v", err)
 		}
 
@@ -306,7 +327,7 @@ func TestRemoteDownloadLayer(t *testing.T) {
 
 	serviceAllocator = &testAllocator{}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %s", err)
 	}
@@ -348,7 +369,7 @@ func TestRemoteDownloadLayer(t *testing.T) {
 
 	serviceInfo.URL = "http://:9000/downloadImage"
 
-	if err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
+	if _, err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
 		t.Errorf("Can't process desired services: %v", err)
 	}
 
@@ -368,7 +389,7 @@ func TestImageParts(t *testing.T) {
 
 	serviceAllocator = &testAllocator{}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %s", err)
 	}
@@ -381,7 +402,7 @@ func TestImageParts(t *testing.T) {
 		t.Fatalf("Can't prepare test service: %s", err)
 	}
 
-	if err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
+	if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
 		t.Errorf("Can't install service: %s", err)
 	}
 
@@ -423,7 +444,7 @@ func TestValidateService(t *testing.T) {
 
 	serviceAllocator = &testAllocator{}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %s", err)
 	}
@@ -436,7 +457,7 @@ func TestValidateService(t *testing.T) {
 		t.Errorf("Can't prepare test service: %s", err)
 	}
 
-	if err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{service}); err != nil {
+	if _, err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{service}); err != nil {
 		t.Errorf("Can't process desired services: %v", err)
 	}
 
@@ -450,6 +471,66 @@ func TestValidateService(t *testing.T) {
 	}
 }
 
+func TestSignatureVerification(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+	signatureVerifier := &testSignatureVerifier{status: "verified"}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		LayersDir:   filepath.Join(tmpDir, "layers"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, signatureVerifier, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %s", err)
+	}
+	defer sm.Close()
+
+	signedService, err := prepareService("Signed content", "signedService", 1, defaultServiceSize)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{signedService}); err != nil {
+		t.Errorf("Can't process desired services: %v", err)
+	}
+
+	serviceInfo, err := sm.GetServiceInfo("signedService")
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	if serviceInfo.SignatureStatus != "verified" {
+		t.Errorf("Wrong signature status: %s", serviceInfo.SignatureStatus)
+	}
+
+	if len(signatureVerifier.verifiedFiles) != 1 ||
+		filepath.Base(signatureVerifier.verifiedFiles[0]) != "manifest.json" {
+		t.Errorf("Wrong verified files: %v", signatureVerifier.verifiedFiles)
+	}
+
+	// Service with invalid signature should be refused
+
+	signatureVerifier.err = aoserrors.New("invalid signature")
+
+	unsignedService, err := prepareService("Unsigned content", "unsignedService", 1, defaultServiceSize)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	if _, err = sm.ProcessDesiredServices(
+		[]aostypes.ServiceInfo{signedService, unsignedService}); err == nil {
+		t.Error("Service with invalid signature should not be installed")
+	}
+
+	if _, err = sm.GetServiceInfo("unsignedService"); !errors.Is(err, servicemanager.ErrNotExist) {
+		t.Errorf("Service with invalid signature should not exist: %v", err)
+	}
+}
+
 func TestAllocateMemoryInstallService(t *testing.T) {
 	serviceStorage := &testServiceStorage{}
 
@@ -463,7 +544,7 @@ func TestAllocateMemoryInstallService(t *testing.T) {
 		totalSize: 1 * megabyte,
 	}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %v", err)
 	}
@@ -521,7 +602,7 @@ func TestAllocateMemoryInstallService(t *testing.T) {
 	}
 
 	for _, tCase := range cases {
-		if err := sm.ProcessDesiredServices(tCase.desiredServices); !errors.Is(err, tCase.processDesiredError) {
+		if _, err := sm.ProcessDesiredServices(tCase.desiredServices); !errors.Is(err, tCase.processDesiredError) {
 			t.Errorf("Can't process desired service: %v", err)
 		}
 	}
@@ -540,7 +621,7 @@ func TestCachedServiceOnStart(t *testing.T) {
 		totalSize: 1 * megabyte,
 	}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %v", err)
 	}
@@ -596,14 +677,14 @@ func TestCachedServiceOnStart(t *testing.T) {
 	}
 
 	for _, tCase := range cases {
-		if err := sm.ProcessDesiredServices(tCase.desiredServices); !errors.Is(err, tCase.processDesiredError) {
+		if _, err := sm.ProcessDesiredServices(tCase.desiredServices); !errors.Is(err, tCase.processDesiredError) {
 			t.Errorf("Can't process desired service: %v", err)
 		}
 	}
 
 	sm.Close()
 
-	if sm, err = servicemanager.New(config, serviceStorage); err != nil {
+	if sm, err = servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil); err != nil {
 		t.Fatalf("Can't create SM: %v", err)
 	}
 	defer sm.Close()
@@ -612,7 +693,7 @@ func TestCachedServiceOnStart(t *testing.T) {
 		t.Fatalf("Should be error not exist: %v", err)
 	}
 
-	if err := sm.ProcessDesiredServices(getDesiredServices(services, []expectedService{
+	if _, err := sm.ProcessDesiredServices(getDesiredServices(services, []expectedService{
 		{serviceID: "service2", version: 1},
 		{serviceID: "service3", version: 1},
 	})); err != nil {
@@ -620,6 +701,595 @@ func TestCachedServiceOnStart(t *testing.T) {
 	}
 }
 
+func TestInstallStatuses(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir:         filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir:         filepath.Join(tmpDir, "downloads"),
+		MaxParallelInstalls: 2,
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	var desiredServices []aostypes.ServiceInfo
+
+	for _, serviceID := range []string{"service1", errorAddServiceID, "service2", "service3"} {
+		serviceInfo, err := prepareService(serviceID, serviceID, 1, defaultServiceSize)
+		if err != nil {
+			t.Fatalf("Can't prepare service: %v", err)
+		}
+
+		desiredServices = append(desiredServices, serviceInfo)
+	}
+
+	// Broken service should not block installation of other services
+
+	statuses, err := sm.ProcessDesiredServices(desiredServices[:3])
+	if err == nil {
+		t.Error("Error expected")
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{
+		"service1":        cloudprotocol.InstalledStatus,
+		errorAddServiceID: cloudprotocol.ErrorStatus,
+		"service2":        cloudprotocol.InstalledStatus,
+	})
+
+	// Already installed services are skipped
+
+	if statuses, err = sm.ProcessDesiredServices(
+		[]aostypes.ServiceInfo{desiredServices[0], desiredServices[2], desiredServices[3]}); err != nil {
+		t.Errorf("Can't process desired services: %v", err)
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{
+		"service1": servicemanager.StatusCached,
+		"service2": servicemanager.StatusCached,
+		"service3": cloudprotocol.InstalledStatus,
+	})
+}
+
+func TestQuarantineService(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	service, err := prepareService("Service content", "serviceQuarantine", 1, defaultServiceSize)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{service}); err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	serviceInfo, err := sm.GetServiceInfo(service.ID)
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	limiter := ratelimit.New(0)
+	defer limiter.Close()
+
+	if err = sm.VerifyService(serviceInfo, limiter); err != nil {
+		t.Errorf("Can't verify service: %v", err)
+	}
+
+	imageParts, err := sm.GetImageParts(serviceInfo)
+	if err != nil {
+		t.Fatalf("Can't get image parts: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(imageParts.ServiceFSPath, "corrupted"), []byte("corrupted"),
+		0o600); err != nil {
+		t.Fatalf("Can't corrupt service rootfs: %v", err)
+	}
+
+	if err = sm.VerifyService(serviceInfo, limiter); err == nil {
+		t.Error("Corrupted service should fail verification")
+	}
+
+	if err = sm.QuarantineService(serviceInfo); err != nil {
+		t.Fatalf("Can't quarantine service: %v", err)
+	}
+
+	if serviceInfo, err = sm.GetServiceInfo(service.ID); err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	if err = sm.ValidateService(serviceInfo); !errors.Is(err, servicemanager.ErrServiceQuarantined) {
+		t.Errorf("Wrong validate error: %v", err)
+	}
+
+	// Quarantined service should be installed again
+
+	statuses, err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{service})
+	if err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{service.ID: cloudprotocol.InstalledStatus})
+
+	if serviceInfo, err = sm.GetServiceInfo(service.ID); err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	if err = sm.ValidateService(serviceInfo); err != nil {
+		t.Errorf("Error service validation: %v", err)
+	}
+}
+
+func TestRollbackService(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	var desiredServices []aostypes.ServiceInfo
+
+	for version := 1; version <= 2; version++ {
+		serviceInfo, err := prepareService(
+			fmt.Sprintf("service1_v%d", version), "service1", uint64(version), defaultServiceSize)
+		if err != nil {
+			t.Fatalf("Can't prepare service: %v", err)
+		}
+
+		desiredServices = append(desiredServices, serviceInfo)
+
+		if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
+			t.Fatalf("Can't process desired services: %v", err)
+		}
+	}
+
+	previousService, err := sm.RollbackService("service1", 2)
+	if err != nil {
+		t.Fatalf("Can't rollback service: %v", err)
+	}
+
+	if previousService.AosVersion != 1 || previousService.Cached {
+		t.Errorf("Wrong previous service: %v", previousService)
+	}
+
+	checkCurrentServiceVersion(t, sm, "service1", 1)
+
+	// Rejected version should not be restored
+
+	statuses, err := sm.ProcessDesiredServices(desiredServices[1:])
+	if err != nil {
+		t.Errorf("Can't process desired services: %v", err)
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{"service1": cloudprotocol.ErrorStatus})
+	checkCurrentServiceVersion(t, sm, "service1", 1)
+
+	if _, err = sm.RollbackService("service1", 1); !errors.Is(err, servicemanager.ErrNoRollbackVersion) {
+		t.Errorf("Wrong rollback error: %v", err)
+	}
+}
+
+func TestServiceBlobStore(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+		BlobsDir:    filepath.Join(tmpDir, "blobs"),
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	blobStore, err := blobstore.New(config, &testBlobStorage{})
+	if err != nil {
+		t.Fatalf("Can't create blob store: %v", err)
+	}
+	defer blobStore.Close()
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, blobStore)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+
+	// Image config and service config are equal, so each service has two blobs: config and rootfs
+	cases := []struct {
+		serviceSize       int64
+		expectedBlobCount int
+	}{
+		{serviceSize: defaultServiceSize, expectedBlobCount: 2},
+		{serviceSize: defaultServiceSize, expectedBlobCount: 2},
+		{serviceSize: 2 * defaultServiceSize, expectedBlobCount: 3},
+		{serviceSize: 2 * defaultServiceSize, expectedBlobCount: 3},
+	}
+
+	for i, tCase := range cases {
+		serviceInfo, err := prepareService(
+			fmt.Sprintf("service1_v%d", i+1), "service1", uint64(i+1), tCase.serviceSize)
+		if err != nil {
+			t.Fatalf("Can't prepare service: %v", err)
+		}
+
+		if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
+			t.Fatalf("Can't process desired services: %v", err)
+		}
+
+		blobs, err := os.ReadDir(filepath.Join(config.BlobsDir, string(digest.SHA256)))
+		if err != nil {
+			t.Fatalf("Can't read blobs dir: %v", err)
+		}
+
+		if len(blobs) != tCase.expectedBlobCount {
+			t.Errorf("Wrong blob count: %d", len(blobs))
+		}
+
+		service, err := sm.GetServiceInfo("service1")
+		if err != nil {
+			t.Fatalf("Can't get service info: %v", err)
+		}
+
+		if err = sm.ValidateService(service); err != nil {
+			t.Errorf("Service validation error: %v", err)
+		}
+	}
+
+	sm.Close()
+
+	// Cached versions are removed on start with zero TTL and first rootfs blob is not referenced anymore
+
+	if sm, err = servicemanager.New(config, serviceStorage, nil, nil, nil, nil, blobStore); err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	blobs, err := os.ReadDir(filepath.Join(config.BlobsDir, string(digest.SHA256)))
+	if err != nil {
+		t.Fatalf("Can't read blobs dir: %v", err)
+	}
+
+	if len(blobs) != 2 {
+		t.Errorf("Wrong blob count: %d", len(blobs))
+	}
+
+	service, err := sm.GetServiceInfo("service1")
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	if err = sm.ValidateService(service); err != nil {
+		t.Errorf("Service validation error: %v", err)
+	}
+}
+
+func TestImageRootFS(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+		ImageFormat: "unknown",
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	if _, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil); err == nil {
+		t.Error("SM should fail on unknown image format")
+	}
+
+	config.ImageFormat = ""
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	// Delivered rootfs image is rejected as its ownership and whiteouts can't be adjusted
+
+	serviceInfo, err := prepareImageService(append([]byte("hsqs"), make([]byte, 1024)...), "imageService", 1)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	statuses, err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo})
+	if err == nil {
+		t.Error("Service with rootfs image should not be installed")
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{serviceInfo.ID: cloudprotocol.ErrorStatus})
+}
+
+func TestServiceFSVerity(t *testing.T) {
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+		Verity:      true,
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	serviceInfo, err := prepareService("Service content", "verityService", 1, defaultServiceSize)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	statuses, err := sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo})
+
+	if !isFSVeritySupported(t) {
+		// Service should not be installed unprotected
+		if err == nil {
+			t.Error("Service install should fail if fs-verity is not supported")
+		}
+
+		checkServiceStatuses(t, statuses, map[string]string{serviceInfo.ID: cloudprotocol.ErrorStatus})
+
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	service, err := sm.GetServiceInfo(serviceInfo.ID)
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	imageParts, err := sm.GetImageParts(service)
+	if err != nil {
+		t.Fatalf("Can't get image parts: %v", err)
+	}
+
+	if err = fsverity.Verify(imageParts.ServiceFSPath, service.VerityRootHash); err != nil {
+		t.Errorf("Can't verify service rootfs: %v", err)
+	}
+}
+
+func TestServiceImageVerity(t *testing.T) {
+	if _, err := exec.LookPath("veritysetup"); err != nil {
+		t.Skip("veritysetup is not available")
+	}
+
+	if _, err := exec.LookPath("mksquashfs"); err != nil {
+		t.Skip("mksquashfs is not available")
+	}
+
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+		ImageFormat: fsimage.FormatSquashFS,
+		Verity:      true,
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	serviceInfo, err := prepareService("Service content", "verityImage", 1, defaultServiceSize)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	service, err := sm.GetServiceInfo(serviceInfo.ID)
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	if service.VerityRootHash == "" {
+		t.Error("Verity root hash should be set")
+	}
+
+	imageParts, err := sm.GetImageParts(service)
+	if err != nil {
+		t.Fatalf("Can't get image parts: %v", err)
+	}
+
+	if imageParts.ServiceFSHashTreePath == "" {
+		t.Error("Hash tree path should be set")
+	}
+}
+
+func TestConvertRootFSToImage(t *testing.T) {
+	if _, err := exec.LookPath("mksquashfs"); err != nil {
+		t.Skip("mksquashfs is not available")
+	}
+
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir: filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir: filepath.Join(tmpDir, "downloads"),
+		ImageFormat: fsimage.FormatSquashFS,
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	serviceInfo, err := prepareService("Service content", "convertService", 1, defaultServiceSize)
+	if err != nil {
+		t.Fatalf("Can't prepare test service: %v", err)
+	}
+
+	if _, err = sm.ProcessDesiredServices([]aostypes.ServiceInfo{serviceInfo}); err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	service, err := sm.GetServiceInfo(serviceInfo.ID)
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	imageParts, err := sm.GetImageParts(service)
+	if err != nil {
+		t.Fatalf("Can't get image parts: %v", err)
+	}
+
+	format, err := fsimage.GetFormat(imageParts.ServiceFSPath)
+	if err != nil {
+		t.Fatalf("Can't get rootfs image format: %v", err)
+	}
+
+	if format != fsimage.FormatSquashFS {
+		t.Errorf("Wrong rootfs image format: %s", format)
+	}
+
+	if err = sm.ValidateService(service); err != nil {
+		t.Errorf("Service validation error: %v", err)
+	}
+}
+
+func TestDeltaUpdate(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not available")
+	}
+
+	serviceStorage := &testServiceStorage{}
+
+	config := &config.Config{
+		ServicesDir:  filepath.Join(tmpDir, "servicemanager", "services"),
+		DownloadDir:  filepath.Join(tmpDir, "downloads"),
+		DeltaUpdates: true,
+	}
+
+	serviceAllocator = &testAllocator{}
+
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create SM: %v", err)
+	}
+	defer sm.Close()
+
+	services := make([]aostypes.ServiceInfo, 3)
+
+	for i := range services {
+		if services[i], err = prepareService(
+			"Service content", "deltaService", uint64(i+1), int64(i+1)*defaultServiceSize); err != nil {
+			t.Fatalf("Can't prepare test service: %v", err)
+		}
+	}
+
+	statuses, err := sm.ProcessDesiredServices(services[:1])
+	if err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{"deltaService": cloudprotocol.InstalledStatus})
+
+	// Local full package is not downloaded: only service package and rootfs space is allocated
+
+	if allocations := serviceAllocator.getAllocations(); len(allocations) != 2 {
+		t.Errorf("Wrong allocations: %v", allocations)
+	}
+
+	// Version 2 is reconstructed from delta package as full package is not available
+
+	deltaFile := filepath.Join(tmpDir, "service.delta")
+
+	if output, err := exec.Command("zstd", "-q", "-f", "--long=31",
+		"--patch-from="+strings.TrimPrefix(services[0].URL, "file://"), strings.TrimPrefix(services[1].URL, "file://"),
+		"-o", deltaFile).CombinedOutput(); err != nil {
+		t.Fatalf("Can't create delta package: %v, %s", err, output)
+	}
+
+	if err = os.Remove(strings.TrimPrefix(services[1].URL, "file://")); err != nil {
+		t.Fatalf("Can't remove full package: %v", err)
+	}
+
+	services[1].URL += "#" + url.Values{"deltaBase": {"1"}, "deltaUrl": {"file://" + deltaFile}}.Encode()
+
+	if statuses, err = sm.ProcessDesiredServices(services[1:2]); err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{"deltaService": cloudprotocol.InstalledStatus})
+	checkCurrentServiceVersion(t, sm, "deltaService", 2)
+
+	// Local delta package is not downloaded: download space is allocated for rebuilt full package only
+
+	if allocations := serviceAllocator.getAllocations(); len(allocations) != 3 ||
+		allocations[0] != services[1].Size {
+		t.Errorf("Wrong allocations: %v", allocations)
+	}
+
+	// Version 3 is installed from full package as base version is not available
+
+	services[2].URL += "#" + url.Values{"deltaBase": {"5"}, "deltaUrl": {"file://" + deltaFile}}.Encode()
+
+	if statuses, err = sm.ProcessDesiredServices(services[2:]); err != nil {
+		t.Fatalf("Can't process desired services: %v", err)
+	}
+
+	checkServiceStatuses(t, statuses, map[string]string{"deltaService": cloudprotocol.InstalledStatus})
+	checkCurrentServiceVersion(t, sm, "deltaService", 3)
+
+	// Base package is kept only for the latest version
+
+	serviceVersions, err := serviceStorage.GetAllServiceVersions("deltaService")
+	if err != nil {
+		t.Fatalf("Can't get service versions: %v", err)
+	}
+
+	for _, service := range serviceVersions {
+		_, err := os.Stat(filepath.Join(service.ImagePath, "package"))
+
+		if service.AosVersion == 3 && err != nil {
+			t.Errorf("Base package of latest version should be kept: %v", err)
+		}
+
+		if service.AosVersion != 3 && !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Base package of version %d should be removed", service.AosVersion)
+		}
+	}
+}
+
 func TestFailCreateAllocator(t *testing.T) {
 	serviceAllocator = &testAllocator{
 		partLimit: 100,
@@ -633,7 +1303,7 @@ func TestFailCreateAllocator(t *testing.T) {
 		ServicesPartLimit: 110,
 	}
 
-	if _, err := servicemanager.New(config, serviceStorage); err == nil {
+	if _, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil); err == nil {
 		t.Fatal("Should be error creating allocator")
 	}
 }
@@ -649,7 +1319,7 @@ func TestRemoveServiceVersionOnInstall(t *testing.T) {
 
 	serviceAllocator = &testAllocator{}
 
-	sm, err := servicemanager.New(config, serviceStorage)
+	sm, err := servicemanager.New(config, serviceStorage, nil, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create SM: %v", err)
 	}
@@ -706,7 +1376,7 @@ func TestRemoveServiceVersionOnInstall(t *testing.T) {
 	}
 
 	for _, tCase := range cases {
-		if err := sm.ProcessDesiredServices(tCase.desiredServices); err != nil {
+		if _, err := sm.ProcessDesiredServices(tCase.desiredServices); err != nil {
 			t.Errorf("Can't process desired service: %v", err)
 		}
 
@@ -732,7 +1402,8 @@ func newSpaceAllocator(
 		return nil, aoserrors.New("can't create space allocator")
 	}
 
-	if remover != nil {
+	// All allocators share one test allocator: keep remover of outdated services and blobs
+	if remover != nil && filepath.Base(path) != "downloads" {
 		serviceAllocator.remover = remover
 	}
 
@@ -764,10 +1435,20 @@ func (allocator *testAllocator) AllocateSpace(size uint64) (spaceallocator.Space
 	}
 
 	allocator.allocatedSize += size
+	allocator.allocations = append(allocator.allocations, size)
 
 	return &testSpace{allocator: allocator, size: size}, nil
 }
 
+func (allocator *testAllocator) getAllocations() (allocations []uint64) {
+	allocator.Lock()
+	defer allocator.Unlock()
+
+	allocations, allocator.allocations = allocator.allocations, nil
+
+	return allocations
+}
+
 func (allocator *testAllocator) FreeSpace(size uint64) {
 	allocator.Lock()
 	defer allocator.Unlock()
@@ -809,9 +1490,45 @@ func (space *testSpace) Release() error {
 	return nil
 }
 
+func (storage *testBlobStorage) AddBlobReference(ref blobstore.BlobReference) error {
+	if !slices.Contains(storage.refs, ref) {
+		storage.refs = append(storage.refs, ref)
+	}
+
+	return nil
+}
+
+func (storage *testBlobStorage) RemoveBlobReference(ref blobstore.BlobReference) error {
+	if i := slices.Index(storage.refs, ref); i >= 0 {
+		storage.refs = slices.Delete(storage.refs, i, i+1)
+	}
+
+	return nil
+}
+
+func (storage *testBlobStorage) GetBlobReferences() ([]blobstore.BlobReference, error) {
+	return storage.refs, nil
+}
+
+func (verifier *testSignatureVerifier) VerifyFile(fileName string) (status string, err error) {
+	verifier.Lock()
+	defer verifier.Unlock()
+
+	verifier.verifiedFiles = append(verifier.verifiedFiles, fileName)
+
+	if verifier.err != nil {
+		return "", verifier.err
+	}
+
+	return verifier.status, nil
+}
+
 func (storage *testServiceStorage) GetAllServiceVersions(
 	serviceID string,
 ) (service []servicemanager.ServiceInfo, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
 	if serviceID == errorGetServicID {
 		return service, aoserrors.New("can't get service")
 	}
@@ -830,14 +1547,20 @@ func (storage *testServiceStorage) GetAllServiceVersions(
 }
 
 func (storage *testServiceStorage) GetServices() (services []servicemanager.ServiceInfo, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
 	if storage.getAllError {
 		return nil, aoserrors.New("can't get services")
 	}
 
-	return storage.Services, nil
+	return append([]servicemanager.ServiceInfo(nil), storage.Services...), nil
 }
 
 func (storage *testServiceStorage) AddService(service servicemanager.ServiceInfo) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
 	if service.ServiceID == errorAddServiceID {
 		return aoserrors.New("can't add service")
 	}
@@ -848,6 +1571,9 @@ func (storage *testServiceStorage) AddService(service servicemanager.ServiceInfo
 }
 
 func (storage *testServiceStorage) RemoveService(serviceID string, aosVersion uint64) error {
+	storage.Lock()
+	defer storage.Unlock()
+
 	for i, outService := range storage.Services {
 		if outService.ServiceID == serviceID && outService.AosVersion == aosVersion {
 			storage.Services = append(storage.Services[:i], storage.Services[i+1:]...)
@@ -860,10 +1586,13 @@ func (storage *testServiceStorage) RemoveService(serviceID string, aosVersion ui
 }
 
 func (storage *testServiceStorage) SetServiceCached(serviceID string, aosVersion uint64, cached bool) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
 	var found bool
 
 	for i, serviceInfo := range storage.Services {
-		if serviceInfo.ServiceID == serviceID {
+		if serviceInfo.ServiceID == serviceID && serviceInfo.AosVersion == aosVersion {
 			storage.Services[i].Cached = cached
 
 			found = true
@@ -877,16 +1606,68 @@ func (storage *testServiceStorage) SetServiceCached(serviceID string, aosVersion
 	return err
 }
 
+func (storage *testServiceStorage) SetServiceRejected(serviceID string, aosVersion uint64, rejected bool) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	for i, serviceInfo := range storage.Services {
+		if serviceInfo.ServiceID == serviceID && serviceInfo.AosVersion == aosVersion {
+			storage.Services[i].Rejected = rejected
+
+			return nil
+		}
+	}
+
+	return servicemanager.ErrNotExist
+}
+
+func (storage *testServiceStorage) SetServiceQuarantined(
+	serviceID string, aosVersion uint64, quarantined bool,
+) error {
+	storage.Lock()
+	defer storage.Unlock()
+
+	for i, serviceInfo := range storage.Services {
+		if serviceInfo.ServiceID == serviceID && serviceInfo.AosVersion == aosVersion {
+			storage.Services[i].Quarantined = quarantined
+
+			return nil
+		}
+	}
+
+	return servicemanager.ErrNotExist
+}
+
 /***********************************************************************************************************************
 * Private
 ***********************************************************************************************************************/
 
+func isFSVeritySupported(t *testing.T) bool {
+	t.Helper()
+
+	probeDir := filepath.Join(tmpDir, "verityProbe")
+
+	if err := os.MkdirAll(probeDir, 0o755); err != nil {
+		t.Fatalf("Can't create probe dir: %v", err)
+	}
+	defer os.RemoveAll(probeDir)
+
+	if err := os.WriteFile(filepath.Join(probeDir, "probe"), []byte("probe"), 0o600); err != nil {
+		t.Fatalf("Can't create probe file: %v", err)
+	}
+
+	_, err := fsverity.Enable(probeDir)
+
+	return err == nil
+}
+
 func setup() (err error) {
 	if tmpDir, err = os.MkdirTemp("", "aos_"); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
 	servicemanager.NewSpaceAllocator = newSpaceAllocator
+	blobstore.NewSpaceAllocator = newSpaceAllocator
 
 	return nil
 }
@@ -931,6 +1712,38 @@ func prepareService(
 		return serviceInfo, aoserrors.Wrap(err)
 	}
 
+	return packService(imageDir, imagespec.Descriptor{
+		MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+		Digest:    fsDigest,
+		Size:      serviceSize,
+	}, serviceID, aosVersion)
+}
+
+func prepareImageService(
+	imageContent []byte, serviceID string, aosVersion uint64,
+) (serviceInfo aostypes.ServiceInfo, err error) {
+	imageDir, err := os.MkdirTemp("", "aos_")
+	if err != nil {
+		return serviceInfo, aoserrors.Wrap(err)
+	}
+
+	defer os.RemoveAll(imageDir)
+
+	fsDigest, err := generateAndSaveDigest(filepath.Join(imageDir, blobsFolder), imageContent)
+	if err != nil {
+		return serviceInfo, aoserrors.Wrap(err)
+	}
+
+	return packService(imageDir, imagespec.Descriptor{
+		MediaType: fsimage.MediaTypeSquashFS,
+		Digest:    fsDigest,
+		Size:      int64(len(imageContent)),
+	}, serviceID, aosVersion)
+}
+
+func packService(
+	imageDir string, rootfsLayer imagespec.Descriptor, serviceID string, aosVersion uint64,
+) (serviceInfo aostypes.ServiceInfo, err error) {
 	aosSrvConfigDigest, err := generateAndSaveDigest(filepath.Join(imageDir, blobsFolder), []byte("{}"))
 	if err != nil {
 		return serviceInfo, aoserrors.Wrap(err)
@@ -947,8 +1760,8 @@ func prepareService(
 	}
 
 	if err := genarateImageManfest(
-		imageDir, &imgSpecDigestDigest, &aosSrvConfigDigest, &fsDigest,
-		serviceSize, []digest.Digest{imgAosLayerDigest}); err != nil {
+		imageDir, &imgSpecDigestDigest, &aosSrvConfigDigest, rootfsLayer,
+		[]digest.Digest{imgAosLayerDigest}); err != nil {
 		return serviceInfo, aoserrors.Wrap(err)
 	}
 
@@ -1039,8 +1852,8 @@ func generateAndSaveDigest(folder string, data []byte) (retDigest digest.Digest,
 	return retDigest, nil
 }
 
-func genarateImageManfest(folderPath string, imgConfig, aosSrvConfig, rootfsLayer *digest.Digest,
-	rootfsLayerSize int64, srvLayers []digest.Digest,
+func genarateImageManfest(folderPath string, imgConfig, aosSrvConfig *digest.Digest,
+	rootfsLayer imagespec.Descriptor, srvLayers []digest.Digest,
 ) (err error) {
 	type serviceManifest struct {
 		imagespec.Manifest
@@ -1062,13 +1875,7 @@ func genarateImageManfest(folderPath string, imgConfig, aosSrvConfig, rootfsLaye
 		}
 	}
 
-	layerDescriptor := imagespec.Descriptor{
-		MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
-		Digest:    *rootfsLayer,
-		Size:      rootfsLayerSize,
-	}
-
-	manifest.Layers = append(manifest.Layers, layerDescriptor)
+	manifest.Layers = append(manifest.Layers, rootfsLayer)
 
 	for _, layerDigest := range srvLayers {
 		layerDescriptor := imagespec.Descriptor{
@@ -1106,6 +1913,41 @@ func packImage(source, name string) (err error) {
 	return nil
 }
 
+func checkServiceStatuses(
+	t *testing.T, statuses []cloudprotocol.ServiceStatus, expectedStatuses map[string]string,
+) {
+	t.Helper()
+
+	if len(statuses) != len(expectedStatuses) {
+		t.Fatalf("Wrong statuses count: %d", len(statuses))
+	}
+
+	for _, status := range statuses {
+		if expectedStatuses[status.ID] != status.Status {
+			t.Errorf("Wrong service %s status: %s", status.ID, status.Status)
+		}
+
+		if (status.Status == cloudprotocol.ErrorStatus) != (status.ErrorInfo != nil) {
+			t.Errorf("Wrong service %s error info: %v", status.ID, status.ErrorInfo)
+		}
+	}
+}
+
+func checkCurrentServiceVersion(
+	t *testing.T, sm *servicemanager.ServiceManager, serviceID string, aosVersion uint64,
+) {
+	t.Helper()
+
+	service, err := sm.GetServiceInfo(serviceID)
+	if err != nil {
+		t.Fatalf("Can't get service info: %v", err)
+	}
+
+	if service.AosVersion != aosVersion {
+		t.Errorf("Wrong service version: %d", service.AosVersion)
+	}
+}
+
 func getDesiredServices(
 	services map[string][]aostypes.ServiceInfo, expectedServices []expectedService,
 ) (desiredServices []aostypes.ServiceInfo) {
@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signature verifies detached signatures of service and layer packages
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Signature verification statuses.
+const (
+	StatusVerified    = "verified"
+	StatusUnsigned    = "unsigned"
+	StatusNotVerified = "not-verified"
+)
+
+// FileExtension extension of detached signature file.
+const FileExtension = ".sig"
+
+const (
+	pemTypeCertificate = "CERTIFICATE"
+	pemTypePublicKey   = "PUBLIC KEY"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Verifier verifies package signatures against trust store.
+type Verifier struct {
+	strict bool
+	keys   []trustedKey
+}
+
+type trustedKey struct {
+	name string
+	key  crypto.PublicKey
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var (
+	// ErrUnsigned package has no signature while strict mode is on.
+	ErrUnsigned = errors.New("package is not signed")
+	// ErrInvalidSignature package signature doesn't match any trusted key.
+	ErrInvalidSignature = errors.New("invalid package signature")
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new signature verifier.
+func New(config *config.Config) (verifier *Verifier, err error) {
+	log.Debug("New signature verifier")
+
+	verifier = &Verifier{strict: config.SignatureVerification.Strict}
+
+	if config.SignatureVerification.TrustStore == "" {
+		if verifier.strict {
+			return nil, aoserrors.New("trust store is required in strict mode")
+		}
+
+		log.Warn("Trust store is not configured, package signatures will not be verified")
+
+		return verifier, nil
+	}
+
+	if verifier.keys, err = loadTrustStore(config.SignatureVerification.TrustStore); err != nil {
+		return nil, err
+	}
+
+	if len(verifier.keys) == 0 {
+		return nil, aoserrors.New("no trusted keys found")
+	}
+
+	return verifier, nil
+}
+
+// VerifyFile verifies file against its detached signature stored next to it.
+func (verifier *Verifier) VerifyFile(fileName string) (status string, err error) {
+	if len(verifier.keys) == 0 {
+		return StatusNotVerified, nil
+	}
+
+	encodedSignature, err := os.ReadFile(fileName + FileExtension)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", aoserrors.Wrap(err)
+		}
+
+		if verifier.strict {
+			return StatusUnsigned, aoserrors.Wrap(ErrUnsigned)
+		}
+
+		log.WithField("file", fileName).Warn("Package is not signed")
+
+		return StatusUnsigned, nil
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encodedSignature)))
+	if err != nil {
+		return "", aoserrors.Errorf("can't decode signature: %v", err)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	for _, trusted := range verifier.keys {
+		if verifySignature(trusted.key, data, signature) {
+			log.WithFields(log.Fields{"file": fileName, "signer": trusted.name}).Debug("Signature verified")
+
+			return StatusVerified, nil
+		}
+	}
+
+	return "", aoserrors.Wrap(ErrInvalidSignature)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func loadTrustStore(trustStore string) (keys []trustedKey, err error) {
+	entries, err := os.ReadDir(trustStore)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(trustStore, entry.Name()))
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		fileKeys, err := parseKeys(entry.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, fileKeys...)
+	}
+
+	return keys, nil
+}
+
+func parseKeys(fileName string, data []byte) (keys []trustedKey, err error) {
+	for {
+		var block *pem.Block
+
+		if block, data = pem.Decode(data); block == nil {
+			return keys, nil
+		}
+
+		switch block.Type {
+		case pemTypeCertificate:
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, aoserrors.Errorf("can't parse certificate %s: %v", fileName, err)
+			}
+
+			keys = append(keys, trustedKey{name: cert.Subject.String(), key: cert.PublicKey})
+
+		case pemTypePublicKey:
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, aoserrors.Errorf("can't parse public key %s: %v", fileName, err)
+			}
+
+			keys = append(keys, trustedKey{name: fileName, key: key})
+
+		default:
+			log.WithFields(log.Fields{"file": fileName, "type": block.Type}).Warn("Skip unsupported PEM block")
+		}
+	}
+}
+
+func verifySignature(key crypto.PublicKey, data, signature []byte) bool {
+	digest := sha256.Sum256(data)
+
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil ||
+			rsa.VerifyPSS(publicKey, crypto.SHA256, digest[:], signature, nil) == nil
+
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(publicKey, digest[:], signature)
+
+	case ed25519.PublicKey:
+		return ed25519.Verify(publicKey, data, signature)
+
+	default:
+		return false
+	}
+}
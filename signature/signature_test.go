@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/signature"
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestVerifyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	trustStore := filepath.Join(tmpDir, "truststore")
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate ECDSA key: %v", err)
+	}
+
+	ed25519PublicKey, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate ed25519 key: %v", err)
+	}
+
+	_, untrustedKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate ed25519 key: %v", err)
+	}
+
+	if err = os.MkdirAll(trustStore, 0o755); err != nil {
+		t.Fatalf("Can't create trust store: %v", err)
+	}
+
+	if err = writeCertificate(filepath.Join(trustStore, "signer.crt"), ecdsaKey); err != nil {
+		t.Fatalf("Can't write certificate: %v", err)
+	}
+
+	if err = writePublicKey(filepath.Join(trustStore, "signer.pub"), ed25519PublicKey); err != nil {
+		t.Fatalf("Can't write public key: %v", err)
+	}
+
+	type testData struct {
+		fileName       string
+		signer         crypto.Signer
+		strict         bool
+		expectedStatus string
+		expectedErr    error
+	}
+
+	data := []testData{
+		{fileName: "ecdsa.json", signer: ecdsaKey, expectedStatus: signature.StatusVerified},
+		{fileName: "ed25519.json", signer: ed25519Key, expectedStatus: signature.StatusVerified},
+		{fileName: "untrusted.json", signer: untrustedKey, expectedErr: signature.ErrInvalidSignature},
+		{fileName: "unsigned.json", expectedStatus: signature.StatusUnsigned},
+		{fileName: "unsigned.json", strict: true, expectedErr: signature.ErrUnsigned},
+	}
+
+	for _, item := range data {
+		fileName := filepath.Join(tmpDir, item.fileName)
+
+		if err = os.WriteFile(fileName, []byte(`{"name": "`+item.fileName+`"}`), 0o600); err != nil {
+			t.Fatalf("Can't write file: %v", err)
+		}
+
+		if item.signer != nil {
+			if err = signFile(fileName, item.signer); err != nil {
+				t.Fatalf("Can't sign file: %v", err)
+			}
+		}
+
+		verifier, err := signature.New(&config.Config{SignatureVerification: config.SignatureVerification{
+			TrustStore: trustStore, Strict: item.strict,
+		}})
+		if err != nil {
+			t.Fatalf("Can't create signature verifier: %v", err)
+		}
+
+		status, err := verifier.VerifyFile(fileName)
+		if !errors.Is(err, item.expectedErr) {
+			t.Errorf("Wrong verify error for %s: %v", item.fileName, err)
+		}
+
+		if err == nil && status != item.expectedStatus {
+			t.Errorf("Wrong signature status for %s: %s", item.fileName, status)
+		}
+	}
+
+	// Tampered file should not pass verification
+
+	fileName := filepath.Join(tmpDir, "ecdsa.json")
+
+	if err = os.WriteFile(fileName, []byte(`{"name": "tampered"}`), 0o600); err != nil {
+		t.Fatalf("Can't write file: %v", err)
+	}
+
+	verifier, err := signature.New(&config.Config{SignatureVerification: config.SignatureVerification{
+		TrustStore: trustStore,
+	}})
+	if err != nil {
+		t.Fatalf("Can't create signature verifier: %v", err)
+	}
+
+	if _, err = verifier.VerifyFile(fileName); !errors.Is(err, signature.ErrInvalidSignature) {
+		t.Errorf("Wrong verify error for tampered file: %v", err)
+	}
+}
+
+func TestNoTrustStore(t *testing.T) {
+	verifier, err := signature.New(&config.Config{})
+	if err != nil {
+		t.Fatalf("Can't create signature verifier: %v", err)
+	}
+
+	status, err := verifier.VerifyFile(filepath.Join(t.TempDir(), "manifest.json"))
+	if err != nil {
+		t.Errorf("Can't verify file: %v", err)
+	}
+
+	if status != signature.StatusNotVerified {
+		t.Errorf("Wrong signature status: %s", status)
+	}
+
+	if _, err = signature.New(&config.Config{
+		SignatureVerification: config.SignatureVerification{Strict: true},
+	}); err == nil {
+		t.Error("Strict mode without trust store should fail")
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func signFile(fileName string, signer crypto.Signer) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	// ed25519 signs the message itself, other algorithms sign its digest
+	message, opts := data, crypto.SignerOpts(crypto.Hash(0))
+
+	if _, ok := signer.(ed25519.PrivateKey); !ok {
+		digest := sha256.Sum256(data)
+		message = digest[:]
+		opts = crypto.SHA256
+	}
+
+	sign, err := signer.Sign(rand.Reader, message, opts)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName+signature.FileExtension,
+		[]byte(base64.StdEncoding.EncodeToString(sign)), 0o600)
+}
+
+func writeCertificate(fileName string, key *ecdsa.PrivateKey) error {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Aos signer"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+}
+
+func writePublicKey(fileName string, key crypto.PublicKey) error {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+}
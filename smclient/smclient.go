@@ -20,6 +20,7 @@ package smclient
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 	"time"
@@ -39,8 +40,11 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/aoscloud/aos_servicemanager/alerts"
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/contentreceiver"
 	"github.com/aoscloud/aos_servicemanager/launcher"
+	"github.com/aoscloud/aos_servicemanager/monitorcontroller"
 )
 
 /***********************************************************************************************************************
@@ -60,24 +64,32 @@ const (
 type SMClient struct {
 	sync.Mutex
 
-	config               *config.Config
-	connection           *grpc.ClientConn
-	stream               pb.SMService_RegisterSMClient
-	closeChannel         chan struct{}
-	servicesProcessor    ServicesProcessor
-	layersProcessor      LayersProcessor
-	launcher             InstanceLauncher
-	unitConfigProcessor  UnitConfigProcessor
-	monitoringProvider   MonitoringDataProvider
-	logsProvider         LogsProvider
-	networkManager       NetworkProvider
-	runtimeStatusChannel <-chan launcher.RuntimeStatus
-	alertChannel         <-chan cloudprotocol.AlertItem
-	monitoringChannel    <-chan cloudprotocol.NodeMonitoringData
-	logsChannel          <-chan cloudprotocol.PushLog
-	nodeDescription      NodeDescription
-	nodeMonitoringData   cloudprotocol.NodeMonitoringData
-	runStatus            *launcher.InstancesStatus
+	config                  *config.Config
+	connection              *grpc.ClientConn
+	stream                  pb.SMService_RegisterSMClient
+	closeChannel            chan struct{}
+	servicesProcessor       ServicesProcessor
+	layersProcessor         LayersProcessor
+	launcher                InstanceLauncher
+	unitConfigProcessor     UnitConfigProcessor
+	monitoringProvider      MonitoringDataProvider
+	logsProvider            LogsProvider
+	networkManager          NetworkProvider
+	contentHandler          ContentHandler
+	clockSyncHandler        ClockSyncHandler
+	runtimeStatusChannel    <-chan launcher.RuntimeStatus
+	alertsProvider          AlertsProvider
+	alertsNotifyChannel     <-chan struct{}
+	monitoringNotifyChannel <-chan struct{}
+	logsChannel             <-chan cloudprotocol.PushLog
+	contentRequestChannel   <-chan contentreceiver.ContentRequest
+	nodeDescription         NodeDescription
+	nodeMonitoringData      cloudprotocol.NodeMonitoringData
+	runStatus               *launcher.InstancesStatus
+
+	runInstancesMutex   sync.Mutex
+	runInstances        *pb.RunInstances
+	runInstancesChannel chan struct{}
 }
 
 type NodeDescription struct {
@@ -100,12 +112,12 @@ type UnitConfigProcessor interface {
 
 // ServicesProcessor process desired services list.
 type ServicesProcessor interface {
-	ProcessDesiredServices(services []aostypes.ServiceInfo) error
+	ProcessDesiredServices(services []aostypes.ServiceInfo) (statuses []cloudprotocol.ServiceStatus, err error)
 }
 
 // LayersProcessor process desired layer list.
 type LayersProcessor interface {
-	ProcessDesiredLayers(layers []aostypes.LayerInfo) error
+	ProcessDesiredLayers(layers []aostypes.LayerInfo) (statuses []cloudprotocol.LayerStatus, err error)
 }
 
 // InstanceLauncher service instances launcher interface.
@@ -116,9 +128,13 @@ type InstanceLauncher interface {
 	CloudConnection(connected bool) error
 }
 
-// AlertsProvider alert data provider interface.
+// AlertsProvider alert data provider interface. SM own alerts are queued with SendAlert as well to be persisted and
+// delivered in order.
 type AlertsProvider interface {
-	GetAlertsChannel() (channel <-chan cloudprotocol.AlertItem)
+	SendAlert(alert cloudprotocol.AlertItem)
+	GetAlertsNotifyChannel() (channel <-chan struct{})
+	GetAlerts() []alerts.Alert
+	RemoveAlert(id uint64) error
 }
 
 type NetworkProvider interface {
@@ -127,7 +143,9 @@ type NetworkProvider interface {
 
 // MonitoringDataProvider monitoring data provider interface.
 type MonitoringDataProvider interface {
-	GetMonitoringDataChannel() (monitoringChannel <-chan cloudprotocol.NodeMonitoringData)
+	GetMonitoringNotifyChannel() (channel <-chan struct{})
+	GetMonitoringData() (monitoringData []monitorcontroller.MonitoringData)
+	RemoveMonitoringData(id uint64) error
 }
 
 // LogsProvider logs data provider interface.
@@ -138,6 +156,18 @@ type LogsProvider interface {
 	GetLogsDataChannel() (channel <-chan cloudprotocol.PushLog)
 }
 
+// ContentHandler handles image content received from CM.
+type ContentHandler interface {
+	GetContentRequestChannel() (channel <-chan contentreceiver.ContentRequest)
+	ProcessContentInfo(info contentreceiver.ContentInfo) error
+	ProcessContentPart(part contentreceiver.ContentPart) error
+}
+
+// ClockSyncHandler handles clock sync received from CM.
+type ClockSyncHandler interface {
+	SyncClock(currentTime time.Time) error
+}
+
 /***********************************************************************************************************************
  * Vars
  **********************************************************************************************************************/
@@ -152,35 +182,43 @@ var errIncorrectAlertType = errors.New("incorrect alert type")
 func New(config *config.Config, nodeDescription NodeDescription, certificateProvider CertificateProvider,
 	servicesProcessor ServicesProcessor, layersProcessor LayersProcessor, launcher InstanceLauncher,
 	unitConfigProcessor UnitConfigProcessor, alertsProvider AlertsProvider, monitoringProvider MonitoringDataProvider,
-	logsProvider LogsProvider, networkManager NetworkProvider, cryptcoxontext *cryptutils.CryptoContext, insecure bool,
+	logsProvider LogsProvider, networkManager NetworkProvider, contentHandler ContentHandler,
+	clockSyncHandler ClockSyncHandler, cryptcoxontext *cryptutils.CryptoContext, insecure bool,
 ) (*SMClient, error) {
 	cmClient := &SMClient{
 		config: config, nodeDescription: nodeDescription, servicesProcessor: servicesProcessor,
 		layersProcessor: layersProcessor, launcher: launcher, unitConfigProcessor: unitConfigProcessor,
-		monitoringProvider: monitoringProvider, logsProvider: logsProvider,
-		networkManager: networkManager, closeChannel: make(chan struct{}, 1),
+		alertsProvider: alertsProvider, monitoringProvider: monitoringProvider, logsProvider: logsProvider,
+		networkManager: networkManager, contentHandler: contentHandler, clockSyncHandler: clockSyncHandler,
+		closeChannel: make(chan struct{}, 1), runInstancesChannel: make(chan struct{}, 1),
 	}
 
 	if err := cmClient.createConnection(config, certificateProvider, cryptcoxontext, insecure); err != nil {
 		return nil, aoserrors.Wrap(err)
 	}
 
+	go cmClient.handleRunInstances()
+
 	if cmClient.launcher != nil {
 		cmClient.runtimeStatusChannel = launcher.RuntimeStatusChannel()
 	}
 
 	if alertsProvider != nil {
-		cmClient.alertChannel = alertsProvider.GetAlertsChannel()
+		cmClient.alertsNotifyChannel = alertsProvider.GetAlertsNotifyChannel()
 	}
 
 	if monitoringProvider != nil {
-		cmClient.monitoringChannel = monitoringProvider.GetMonitoringDataChannel()
+		cmClient.monitoringNotifyChannel = monitoringProvider.GetMonitoringNotifyChannel()
 	}
 
 	if logsProvider != nil {
 		cmClient.logsChannel = logsProvider.GetLogsDataChannel()
 	}
 
+	if contentHandler != nil {
+		cmClient.contentRequestChannel = contentHandler.GetContentRequestChannel()
+	}
+
 	return cmClient, nil
 }
 
@@ -305,6 +343,15 @@ func (client *SMClient) register(config *config.Config) (err error) {
 		return aoserrors.Wrap(err)
 	}
 
+	if client.clockSyncHandler != nil {
+		if err := client.stream.Send(
+			&pb.SMOutgoingMessages{
+				SMOutgoingMessage: &pb.SMOutgoingMessages_ClockSyncRequest{ClockSyncRequest: &pb.ClockSyncRequest{}},
+			}); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
 	if client.runStatus != nil {
 		if err := client.sendRuntimeInstanceNotifications(launcher.RuntimeStatus{
 			RunStatus: client.runStatus,
@@ -345,7 +392,7 @@ func (client *SMClient) processMessages() (err error) {
 			client.processSetUnitConfig(data.SetUnitConfig)
 
 		case *pb.SMIncomingMessages_RunInstances:
-			client.processRunInstances(data.RunInstances)
+			client.queueRunInstances(data.RunInstances)
 
 		case *pb.SMIncomingMessages_UpdateNetworks:
 			client.processUpdateNetworks(data.UpdateNetworks)
@@ -367,6 +414,15 @@ func (client *SMClient) processMessages() (err error) {
 
 		case *pb.SMIncomingMessages_ConnectionStatus:
 			client.processConnectionStatus(data.ConnectionStatus)
+
+		case *pb.SMIncomingMessages_ImageContentInfo:
+			client.processImageContentInfo(data.ImageContentInfo)
+
+		case *pb.SMIncomingMessages_ImageContent:
+			client.processImageContent(data.ImageContent)
+
+		case *pb.SMIncomingMessages_ClockSync:
+			client.processClockSync(data.ClockSync)
 		}
 	}
 }
@@ -426,6 +482,45 @@ func (client *SMClient) processUpdateNetworks(updateNetworks *pb.UpdateNetworks)
 	}
 }
 
+// queueRunInstances queues run instances request to be processed outside of receive loop. Processing of desired
+// services and layers on remote node requests image content from CM, which is received by the receive loop.
+// Run instances request contains whole desired state, so only the latest pending request is kept.
+func (client *SMClient) queueRunInstances(runInstances *pb.RunInstances) {
+	client.runInstancesMutex.Lock()
+	defer client.runInstancesMutex.Unlock()
+
+	if client.runInstances != nil && client.runInstances.GetForceRestart() {
+		runInstances.ForceRestart = true
+	}
+
+	client.runInstances = runInstances
+
+	select {
+	case client.runInstancesChannel <- struct{}{}:
+
+	default:
+	}
+}
+
+func (client *SMClient) handleRunInstances() {
+	for {
+		select {
+		case <-client.runInstancesChannel:
+			client.runInstancesMutex.Lock()
+			runInstances := client.runInstances
+			client.runInstances = nil
+			client.runInstancesMutex.Unlock()
+
+			if runInstances != nil {
+				client.processRunInstances(runInstances)
+			}
+
+		case <-client.closeChannel:
+			return
+		}
+	}
+}
+
 func (client *SMClient) processRunInstances(runInstances *pb.RunInstances) {
 	services := make([]aostypes.ServiceInfo, len(runInstances.GetServices()))
 
@@ -442,10 +537,27 @@ func (client *SMClient) processRunInstances(runInstances *pb.RunInstances) {
 		}
 	}
 
-	if err := client.servicesProcessor.ProcessDesiredServices(services); err != nil {
+	serviceStatuses, err := client.servicesProcessor.ProcessDesiredServices(services)
+	if err != nil {
 		log.Errorf("Can't process desired services list %v", err)
 	}
 
+	// SM protocol has no service and layer status messages: RunInstancesStatus carries instance statuses only.
+	// Installed and cached items are reported to CM by statuses of their instances, install errors are reported as
+	// core alerts as instances of failed items may not exist.
+	for _, status := range serviceStatuses {
+		if status.ErrorInfo == nil {
+			log.WithFields(log.Fields{
+				"id": status.ID, "aosVersion": status.AosVersion, "status": status.Status,
+			}).Debug("Service status")
+
+			continue
+		}
+
+		client.sendInstallErrorAlert(fmt.Sprintf("Can't install service %s v%d: %s",
+			status.ID, status.AosVersion, status.ErrorInfo.Message))
+	}
+
 	layers := make([]aostypes.LayerInfo, len(runInstances.GetLayers()))
 
 	for i, pbLayer := range runInstances.GetLayers() {
@@ -460,10 +572,24 @@ func (client *SMClient) processRunInstances(runInstances *pb.RunInstances) {
 		}
 	}
 
-	if err := client.layersProcessor.ProcessDesiredLayers(layers); err != nil {
+	layerStatuses, err := client.layersProcessor.ProcessDesiredLayers(layers)
+	if err != nil {
 		log.Errorf("Can't process desired layer list %v", err)
 	}
 
+	for _, status := range layerStatuses {
+		if status.ErrorInfo == nil {
+			log.WithFields(log.Fields{
+				"id": status.ID, "aosVersion": status.AosVersion, "digest": status.Digest, "status": status.Status,
+			}).Debug("Layer status")
+
+			continue
+		}
+
+		client.sendInstallErrorAlert(fmt.Sprintf("Can't install layer %s v%d (%s): %s",
+			status.ID, status.AosVersion, status.Digest, status.ErrorInfo.Message))
+	}
+
 	instances := make([]aostypes.InstanceInfo, len(runInstances.GetInstances()))
 
 	for i, pbInstance := range runInstances.GetInstances() {
@@ -480,6 +606,22 @@ func (client *SMClient) processRunInstances(runInstances *pb.RunInstances) {
 	}
 }
 
+// sendInstallErrorAlert queues install error alert to be sent in order with other alerts and kept while CM is
+// disconnected.
+func (client *SMClient) sendInstallErrorAlert(message string) {
+	if client.alertsProvider == nil {
+		log.Errorf("Can't send install error alert: %s", message)
+
+		return
+	}
+
+	client.alertsProvider.SendAlert(cloudprotocol.AlertItem{
+		Timestamp: time.Now(),
+		Tag:       cloudprotocol.AlertTagAosCore,
+		Payload:   cloudprotocol.CoreAlert{CoreComponent: alerts.CoreComponentSM, Message: message},
+	})
+}
+
 func (client *SMClient) processGetSystemLogRequest(logRequest *pb.SystemLogRequest) {
 	getSystemLogRequest := cloudprotocol.RequestLog{LogID: logRequest.GetLogId()}
 
@@ -576,7 +718,72 @@ func (client *SMClient) processConnectionStatus(status *pb.ConnectionStatus) {
 	}
 }
 
+func (client *SMClient) processImageContentInfo(contentInfo *pb.ImageContentInfo) {
+	if client.contentHandler == nil {
+		log.Error("Image content handler is not set")
+
+		return
+	}
+
+	info := contentreceiver.ContentInfo{
+		RequestID: contentInfo.GetRequestId(),
+		Files:     make([]contentreceiver.ContentFile, len(contentInfo.GetImageFiles())),
+		Error:     contentInfo.GetError(),
+	}
+
+	for i, file := range contentInfo.GetImageFiles() {
+		info.Files[i] = contentreceiver.ContentFile{
+			RelativePath: file.GetRelativePath(), Sha256: file.GetSha256(), Size: file.GetSize(),
+		}
+	}
+
+	if err := client.contentHandler.ProcessContentInfo(info); err != nil {
+		log.Errorf("Can't process image content info: %v", err)
+	}
+}
+
+func (client *SMClient) processImageContent(content *pb.ImageContent) {
+	if client.contentHandler == nil {
+		log.Error("Image content handler is not set")
+
+		return
+	}
+
+	if err := client.contentHandler.ProcessContentPart(contentreceiver.ContentPart{
+		RequestID: content.GetRequestId(), RelativePath: content.GetRelativePath(),
+		PartsCount: content.GetPartsCount(), Part: content.GetPart(), Data: content.GetData(),
+	}); err != nil {
+		log.Errorf("Can't process image content: %v", err)
+	}
+}
+
+func (client *SMClient) processClockSync(clockSync *pb.ClockSync) {
+	if client.clockSyncHandler == nil {
+		log.Error("Clock sync handler is not set")
+
+		return
+	}
+
+	if err := client.clockSyncHandler.SyncClock(clockSync.GetCurrentTime().AsTime()); err != nil {
+		log.Errorf("Can't sync clock: %v", err)
+	}
+}
+
 func (client *SMClient) handleChannels() {
+	// Send alerts queued while CM was not connected
+	if err := client.sendAlerts(); err != nil {
+		log.Errorf("Can't send alert: %v", err)
+
+		return
+	}
+
+	// Send monitoring data buffered while CM was not connected
+	if err := client.sendMonitoringData(); err != nil {
+		log.Errorf("Can't send monitoring notification: %v", err)
+
+		return
+	}
+
 	for {
 		select {
 		case runtimeStatus := <-client.runtimeStatusChannel:
@@ -590,42 +797,40 @@ func (client *SMClient) handleChannels() {
 				return
 			}
 
-		case alert := <-client.alertChannel:
-			pbAlert, err := cloudprotocolAlertToPB(&alert)
-			if err != nil {
-				log.Errorf("Can't convert alert to pb: %v", err)
+		case <-client.alertsNotifyChannel:
+			if err := client.sendAlerts(); err != nil {
+				log.Errorf("Can't send alert: %v", err)
 
-				continue
+				return
 			}
 
-			alertNtf := &pb.SMOutgoingMessages_Alert{Alert: pbAlert}
-
-			if err := client.stream.Send(&pb.SMOutgoingMessages{SMOutgoingMessage: alertNtf}); err != nil {
-				log.Errorf("Can't send alert: %v", err)
+		case <-client.monitoringNotifyChannel:
+			if err := client.sendMonitoringData(); err != nil {
+				log.Errorf("Can't send monitoring notification: %v", err)
 
 				return
 			}
 
-		case monitoringData := <-client.monitoringChannel:
-			client.nodeMonitoringData = monitoringData
-
+		case logs := <-client.logsChannel:
 			if err := client.stream.Send(
 				&pb.SMOutgoingMessages{
-					SMOutgoingMessage: &pb.SMOutgoingMessages_NodeMonitoring{
-						NodeMonitoring: cloudprotocolMonitoringToPB(monitoringData),
-					},
+					SMOutgoingMessage: &pb.SMOutgoingMessages_Log{Log: cloudprotocolLogToPB(logs)},
 				}); err != nil {
-				log.Errorf("Can't send monitoring notification: %v", err)
+				log.Errorf("Can't send logs: %v", err)
 
 				return
 			}
 
-		case logs := <-client.logsChannel:
+		case request := <-client.contentRequestChannel:
 			if err := client.stream.Send(
 				&pb.SMOutgoingMessages{
-					SMOutgoingMessage: &pb.SMOutgoingMessages_Log{Log: cloudprotocolLogToPB(logs)},
+					SMOutgoingMessage: &pb.SMOutgoingMessages_ImageContentRequest{
+						ImageContentRequest: &pb.ImageContentRequest{
+							Url: request.URL, RequestId: request.RequestID, ContentType: request.ContentType,
+						},
+					},
 				}); err != nil {
-				log.Errorf("Can't send logs: %v", err)
+				log.Errorf("Can't send image content request: %v", err)
 
 				return
 			}
@@ -636,6 +841,63 @@ func (client *SMClient) handleChannels() {
 	}
 }
 
+// sendAlerts sends queued alerts in order. Alert is removed from the queue only if it is sent successfully.
+func (client *SMClient) sendAlerts() error {
+	if client.alertsProvider == nil {
+		return nil
+	}
+
+	for _, alert := range client.alertsProvider.GetAlerts() {
+		pbAlert, err := cloudprotocolAlertToPB(&alert.AlertItem)
+		if err != nil {
+			log.Errorf("Can't convert alert to pb: %v", err)
+		} else if err = client.stream.Send(&pb.SMOutgoingMessages{
+			SMOutgoingMessage: &pb.SMOutgoingMessages_Alert{Alert: pbAlert},
+		}); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if err = client.alertsProvider.RemoveAlert(alert.ID); err != nil {
+			log.Errorf("Can't remove alert: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// sendMonitoringData sends buffered monitoring data in batches. Batch is removed from the buffer only if it is sent
+// successfully. Error is returned on send failure only.
+func (client *SMClient) sendMonitoringData() error {
+	if client.monitoringProvider == nil {
+		return nil
+	}
+
+	for {
+		batch := client.monitoringProvider.GetMonitoringData()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, monitoringData := range batch {
+			if err := client.stream.Send(
+				&pb.SMOutgoingMessages{
+					SMOutgoingMessage: &pb.SMOutgoingMessages_NodeMonitoring{
+						NodeMonitoring: cloudprotocolMonitoringToPB(monitoringData.NodeMonitoringData),
+					},
+				}); err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			client.nodeMonitoringData = monitoringData.NodeMonitoringData
+		}
+
+		// Sent data is removed from the memory buffer even if storage fails, so sending is continued
+		if err := client.monitoringProvider.RemoveMonitoringData(batch[len(batch)-1].ID); err != nil {
+			log.Errorf("Can't remove sent monitoring data: %v", err)
+		}
+	}
+}
+
 func (client *SMClient) sendRuntimeInstanceNotifications(runtimeStatus launcher.RuntimeStatus) error {
 	if runtimeStatus.RunStatus != nil {
 		runStatusNtf := &pb.SMOutgoingMessages_RunInstancesStatus{
@@ -819,6 +1081,11 @@ func cloudprotocolAlertToPB(alert *cloudprotocol.AlertItem) (pbAlert *pb.Alert,
 		if pbAlert.Payload, err = getPBInstanceAlertFromPayload(alert.Payload); err != nil {
 			return nil, err
 		}
+
+	case cloudprotocol.AlertTagDownloadProgress:
+		if pbAlert.Payload, err = getPBDownloadAlertFromPayload(alert.Payload); err != nil {
+			return nil, err
+		}
 	}
 
 	return pbAlert, nil
@@ -845,6 +1112,26 @@ func getPBCoreAlertFromPayload(payload interface{}) (*pb.Alert_CoreAlert, error)
 	}}, nil
 }
 
+func getPBDownloadAlertFromPayload(payload interface{}) (*pb.Alert_CoreAlert, error) {
+	downloadAlert, ok := payload.(cloudprotocol.DownloadAlert)
+	if !ok {
+		return nil, aoserrors.Wrap(errIncorrectAlertType)
+	}
+
+	message := fmt.Sprintf("%s: %s %s v%d, url: %s, downloaded: %s", downloadAlert.Message,
+		downloadAlert.TargetType, downloadAlert.TargetID, downloadAlert.TargetAosVersion, downloadAlert.URL,
+		downloadAlert.DownloadedBytes)
+
+	if downloadAlert.TotalBytes != "" {
+		message += fmt.Sprintf("/%s (%s)", downloadAlert.TotalBytes, downloadAlert.Progress)
+	}
+
+	return &pb.Alert_CoreAlert{CoreAlert: &pb.CoreAlert{
+		CoreComponent: alerts.CoreComponentSM,
+		Message:       message,
+	}}, nil
+}
+
 func getPBResourceValidateAlertFromPayload(payload interface{}) (*pb.Alert_ResourceValidateAlert, error) {
 	resAlert, ok := payload.(cloudprotocol.ResourceValidateAlert)
 	if !ok {
@@ -18,6 +18,8 @@
 package smclient_test
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
 	"io"
 	"net"
@@ -35,8 +37,11 @@ import (
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/aoscloud/aos_servicemanager/alerts"
 	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/contentreceiver"
 	"github.com/aoscloud/aos_servicemanager/launcher"
+	"github.com/aoscloud/aos_servicemanager/monitorcontroller"
 	"github.com/aoscloud/aos_servicemanager/smclient"
 )
 
@@ -60,13 +65,11 @@ type testServer struct {
 	monitoringChannel chan *pb.SMOutgoingMessages_NodeMonitoring
 	logChannel        chan *pb.SMOutgoingMessages_Log
 	envVarsChannel    chan *pb.SMOutgoingMessages_OverrideEnvVarStatus
+	contentChannel    chan *pb.ImageContentRequest
+	clockSyncChannel  chan *pb.ClockSyncRequest
 	pb.UnimplementedSMServiceServer
 }
 
-type testMonitoringProvider struct {
-	monitoringChannel chan cloudprotocol.NodeMonitoringData
-}
-
 type testLogProvider struct {
 	currentLogRequest cloudprotocol.RequestLog
 	testLogs          []testLogData
@@ -78,12 +81,16 @@ type testLogData struct {
 	internalLog   cloudprotocol.PushLog
 	expectedPBLog pb.LogData
 }
-type testAlertProvider struct {
-	alertsChannel chan cloudprotocol.AlertItem
-}
-
 type testServiceManager struct {
 	services []aostypes.ServiceInfo
+	statuses []cloudprotocol.ServiceStatus
+}
+
+type testContentServiceManager struct {
+	receiver  *contentreceiver.ContentReceiver
+	destDir   string
+	fileNames []string
+	err       error
 }
 
 type testNetworkUpdates struct {
@@ -92,7 +99,8 @@ type testNetworkUpdates struct {
 }
 
 type testLayerManager struct {
-	layers []aostypes.LayerInfo
+	layers   []aostypes.LayerInfo
+	statuses []cloudprotocol.LayerStatus
 }
 
 type testLauncher struct {
@@ -105,6 +113,16 @@ type testLauncher struct {
 	connectionChannel chan bool
 }
 
+type testContentHandler struct {
+	requestChannel chan contentreceiver.ContentRequest
+	infoChannel    chan contentreceiver.ContentInfo
+	partChannel    chan contentreceiver.ContentPart
+}
+
+type testClockSyncHandler struct {
+	syncChannel chan time.Time
+}
+
 /***********************************************************************************************************************
  * Init
  **********************************************************************************************************************/
@@ -131,8 +149,9 @@ func TestSMRegistration(t *testing.T) {
 
 	defer server.close()
 
-	testMonitoring := &testMonitoringProvider{
-		monitoringChannel: make(chan cloudprotocol.NodeMonitoringData, 10),
+	testMonitoring, err := monitorcontroller.New(&config.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create monitor controller: %v", err)
 	}
 
 	systemInfo := cloudprotocol.SystemInfo{
@@ -142,7 +161,7 @@ func TestSMRegistration(t *testing.T) {
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL, RunnerFeatures: []string{"crun"}},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: systemInfo},
-		nil, nil, nil, nil, nil, nil, testMonitoring, nil, nil, nil, true)
+		nil, nil, nil, nil, nil, nil, testMonitoring, nil, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -167,8 +186,9 @@ func TestMonitoringNotifications(t *testing.T) {
 
 	defer server.close()
 
-	testMonitoring := &testMonitoringProvider{
-		monitoringChannel: make(chan cloudprotocol.NodeMonitoringData, 10),
+	testMonitoring, err := monitorcontroller.New(&config.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create monitor controller: %v", err)
 	}
 
 	systemInfo := cloudprotocol.SystemInfo{
@@ -179,7 +199,7 @@ func TestMonitoringNotifications(t *testing.T) {
 	client, err := smclient.New(&config.Config{
 		CMServerURL: serverURL, RemoteNode: true, RunnerFeatures: []string{"crun"},
 	}, smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: systemInfo},
-		nil, nil, nil, nil, nil, nil, testMonitoring, nil, nil, nil, true)
+		nil, nil, nil, nil, nil, nil, testMonitoring, nil, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -264,7 +284,7 @@ func TestMonitoringNotifications(t *testing.T) {
 	}
 
 	for i := range testMonitoringData {
-		testMonitoring.monitoringChannel <- testMonitoringData[i].sendMonitoring
+		testMonitoring.SendMonitoringData(testMonitoringData[i].sendMonitoring)
 
 		receivedMonitoring := <-server.monitoringChannel
 
@@ -290,7 +310,7 @@ func TestLogsNotification(t *testing.T) {
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
-		nil, nil, nil, nil, nil, nil, nil, &logProvider, nil, nil, true)
+		nil, nil, nil, nil, nil, nil, nil, &logProvider, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -388,11 +408,14 @@ func TestAlertNotifications(t *testing.T) {
 
 	defer server.close()
 
-	testAlerts := &testAlertProvider{alertsChannel: make(chan cloudprotocol.AlertItem, 10)}
+	testAlerts, err := alerts.New(&config.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %v", err)
+	}
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
-		nil, nil, nil, nil, nil, testAlerts, nil, nil, nil, nil, true)
+		nil, nil, nil, nil, nil, testAlerts, nil, nil, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -434,6 +457,26 @@ func TestAlertNotifications(t *testing.T) {
 				},
 			},
 		},
+		{
+			sendAlert: cloudprotocol.AlertItem{
+				Tag: cloudprotocol.AlertTagDownloadProgress,
+				Payload: cloudprotocol.DownloadAlert{
+					TargetType: cloudprotocol.DownloadTargetLayer, TargetID: "layer1", TargetAosVersion: 2,
+					Message: "Download progress", URL: "http://download/layer1", DownloadedBytes: "512",
+					TotalBytes: "1024", Progress: "50%",
+				},
+			},
+			expectedAlert: pb.Alert{
+				Tag: cloudprotocol.AlertTagDownloadProgress,
+				Payload: &pb.Alert_CoreAlert{
+					CoreAlert: &pb.CoreAlert{
+						CoreComponent: "SM",
+						Message: "Download progress: layer layer1 v2, url: http://download/layer1, " +
+							"downloaded: 512/1024 (50%)",
+					},
+				},
+			},
+		},
 		{
 			sendAlert: cloudprotocol.AlertItem{
 				Tag: cloudprotocol.AlertTagResourceValidate,
@@ -525,7 +568,7 @@ func TestAlertNotifications(t *testing.T) {
 	}
 
 	for i := range testAlertItems {
-		testAlerts.alertsChannel <- testAlertItems[i].sendAlert
+		testAlerts.SendAlert(testAlertItems[i].sendAlert)
 
 		receivedAlert := <-server.alertChannel
 
@@ -586,7 +629,7 @@ func TestAlertNotifications(t *testing.T) {
 	}
 
 	for i := range invalidAlerts {
-		testAlerts.alertsChannel <- invalidAlerts[i]
+		testAlerts.SendAlert(invalidAlerts[i])
 	}
 
 	select {
@@ -597,6 +640,121 @@ func TestAlertNotifications(t *testing.T) {
 	}
 }
 
+func TestQueuedAlerts(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %v", err)
+	}
+
+	defer server.close()
+
+	testAlerts, err := alerts.New(&config.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %v", err)
+	}
+
+	// Alerts sent before registration should be delivered in order once SM is registered
+
+	messages := []string{"alert0", "alert1", "alert2"}
+
+	for _, message := range messages {
+		testAlerts.SendAlert(cloudprotocol.AlertItem{
+			Tag: cloudprotocol.AlertTagSystemError, Payload: cloudprotocol.SystemAlert{Message: message},
+		})
+	}
+
+	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
+		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
+		nil, nil, nil, nil, nil, testAlerts, nil, nil, nil, nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %v", err)
+	}
+	defer client.Close()
+
+	if err = server.waitClientRegistered(&pb.NodeConfiguration{NodeId: "mainSM", NodeType: "model1"}); err != nil {
+		t.Fatalf("SM registration error: %v", err)
+	}
+
+	for _, message := range messages {
+		select {
+		case receivedAlert := <-server.alertChannel:
+			if receivedAlert.GetSystemAlert().GetMessage() != message {
+				t.Errorf("Wrong alert message: %s", receivedAlert.GetSystemAlert().GetMessage())
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait alert timeout")
+		}
+	}
+
+	// Delivered alerts should be removed from the queue
+
+	for start := time.Now(); len(testAlerts.GetAlerts()) != 0; time.Sleep(100 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Alerts should be removed from the queue: %v", testAlerts.GetAlerts())
+		}
+	}
+}
+
+func TestBufferedMonitoring(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %v", err)
+	}
+
+	defer server.close()
+
+	testMonitoring, err := monitorcontroller.New(&config.Config{
+		MonitoringBuffer: config.MonitoringBuffer{BatchSize: 2},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create monitor controller: %v", err)
+	}
+
+	// Monitoring data sent before registration should be delivered with original timestamps once SM is registered
+
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		testMonitoring.SendMonitoringData(cloudprotocol.NodeMonitoringData{
+			Timestamp: timestamp.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
+		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
+		nil, nil, nil, nil, nil, nil, testMonitoring, nil, nil, nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %v", err)
+	}
+	defer client.Close()
+
+	if err = server.waitClientRegistered(&pb.NodeConfiguration{NodeId: "mainSM", NodeType: "model1"}); err != nil {
+		t.Fatalf("SM registration error: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		select {
+		case receivedMonitoring := <-server.monitoringChannel:
+			if !receivedMonitoring.NodeMonitoring.GetTimestamp().AsTime().Equal(
+				timestamp.Add(time.Duration(i) * time.Minute)) {
+				t.Errorf("Wrong monitoring timestamp: %v", receivedMonitoring.NodeMonitoring.GetTimestamp().AsTime())
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait monitoring timeout")
+		}
+	}
+
+	// Delivered monitoring data should be removed from the buffer
+
+	for start := time.Now(); len(testMonitoring.GetMonitoringData()) != 0; time.Sleep(100 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Monitoring data should be removed from the buffer: %v", testMonitoring.GetMonitoringData())
+		}
+	}
+}
+
 func TestRunInstances(t *testing.T) {
 	data := []*pb.RunInstances{
 		{},
@@ -739,7 +897,7 @@ func TestRunInstances(t *testing.T) {
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
-		nil, serviceManager, layerManager, launcher, nil, nil, nil, nil, nil, nil, true)
+		nil, serviceManager, layerManager, launcher, nil, nil, nil, nil, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -784,6 +942,76 @@ func TestRunInstances(t *testing.T) {
 	}
 }
 
+func TestInstallErrorAlerts(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %v", err)
+	}
+
+	defer server.close()
+
+	serviceManager := &testServiceManager{statuses: []cloudprotocol.ServiceStatus{
+		{ID: "service1", AosVersion: 1, Status: cloudprotocol.InstalledStatus},
+		{
+			ID: "service2", AosVersion: 2, Status: cloudprotocol.ErrorStatus,
+			ErrorInfo: &cloudprotocol.ErrorInfo{Message: "no space"},
+		},
+	}}
+	layerManager := &testLayerManager{statuses: []cloudprotocol.LayerStatus{
+		{
+			ID: "layer1", AosVersion: 3, Digest: "sha256:1", Status: cloudprotocol.ErrorStatus,
+			ErrorInfo: &cloudprotocol.ErrorInfo{Message: "invalid signature"},
+		},
+	}}
+	launcher := newTestLauncher()
+
+	testAlerts, err := alerts.New(&config.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Can't create alerts: %v", err)
+	}
+	defer testAlerts.Close()
+
+	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
+		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
+		nil, serviceManager, layerManager, launcher, nil, testAlerts, nil, nil, nil, nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %v", err)
+	}
+	defer client.Close()
+
+	if err := server.waitClientRegistered(&pb.NodeConfiguration{NodeId: "mainSM", NodeType: "model1"}); err != nil {
+		t.Fatalf("SM registration error: %v", err)
+	}
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_RunInstances{RunInstances: &pb.RunInstances{}},
+	}); err != nil {
+		t.Fatalf("Can't send request: %v", err)
+	}
+
+	if err := launcher.waitCall(); err != nil {
+		t.Fatalf("Error waiting call: %v", err)
+	}
+
+	expectedMessages := []string{
+		"Can't install service service2 v2: no space",
+		"Can't install layer layer1 v3 (sha256:1): invalid signature",
+	}
+
+	for _, expectedMessage := range expectedMessages {
+		select {
+		case alert := <-server.alertChannel:
+			if alert.GetTag() != cloudprotocol.AlertTagAosCore ||
+				alert.GetCoreAlert().GetMessage() != expectedMessage {
+				t.Errorf("Wrong install error alert: %v", alert)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait install error alert timeout")
+		}
+	}
+}
+
 func TestNetworkUpdate(t *testing.T) {
 	data := &pb.UpdateNetworks{
 		Networks: []*pb.NetworkParameters{
@@ -830,7 +1058,7 @@ func TestNetworkUpdate(t *testing.T) {
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
-		nil, nil, nil, nil, nil, nil, nil, nil, netManager, nil, true)
+		nil, nil, nil, nil, nil, nil, nil, nil, netManager, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -920,7 +1148,7 @@ func TestOverrideEnvVars(t *testing.T) {
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
-		nil, nil, nil, launcher, nil, nil, nil, nil, nil, nil, true)
+		nil, nil, nil, launcher, nil, nil, nil, nil, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -967,7 +1195,7 @@ func TestCloudConnection(t *testing.T) {
 
 	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
 		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
-		nil, nil, nil, launcher, nil, nil, nil, nil, nil, nil, true)
+		nil, nil, nil, launcher, nil, nil, nil, nil, nil, nil, nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %v", err)
 	}
@@ -1015,6 +1243,230 @@ func TestCloudConnection(t *testing.T) {
 	}
 }
 
+func TestImageContent(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %v", err)
+	}
+
+	defer server.close()
+
+	contentHandler := newTestContentHandler()
+
+	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
+		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, contentHandler, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %v", err)
+	}
+	defer client.Close()
+
+	if err := server.waitClientRegistered(&pb.NodeConfiguration{NodeId: "mainSM", NodeType: "model1"}); err != nil {
+		t.Fatalf("SM registration error: %v", err)
+	}
+
+	contentHandler.requestChannel <- contentreceiver.ContentRequest{
+		URL: "file:///service1", RequestID: 1, ContentType: contentreceiver.ContentTypeService,
+	}
+
+	select {
+	case request := <-server.contentChannel:
+		if !proto.Equal(request, &pb.ImageContentRequest{
+			Url: "file:///service1", RequestId: 1, ContentType: contentreceiver.ContentTypeService,
+		}) {
+			t.Errorf("Wrong image content request: %v", request)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait image content request timeout")
+	}
+
+	expectedInfo := contentreceiver.ContentInfo{
+		RequestID: 1,
+		Files: []contentreceiver.ContentFile{
+			{RelativePath: "service.tar.gz", Sha256: []byte{1, 2, 3}, Size: 4},
+		},
+	}
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_ImageContentInfo{ImageContentInfo: &pb.ImageContentInfo{
+			RequestId: 1,
+			ImageFiles: []*pb.ImageFile{
+				{RelativePath: "service.tar.gz", Sha256: []byte{1, 2, 3}, Size: 4},
+			},
+		}},
+	}); err != nil {
+		t.Fatalf("Can't send image content info: %v", err)
+	}
+
+	select {
+	case info := <-contentHandler.infoChannel:
+		if !reflect.DeepEqual(info, expectedInfo) {
+			t.Errorf("Wrong image content info: %v", info)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait image content info timeout")
+	}
+
+	expectedPart := contentreceiver.ContentPart{
+		RequestID: 1, RelativePath: "service.tar.gz", PartsCount: 1, Part: 1, Data: []byte{1, 2, 3, 4},
+	}
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_ImageContent{ImageContent: &pb.ImageContent{
+			RequestId: 1, RelativePath: "service.tar.gz", PartsCount: 1, Part: 1, Data: []byte{1, 2, 3, 4},
+		}},
+	}); err != nil {
+		t.Fatalf("Can't send image content: %v", err)
+	}
+
+	select {
+	case part := <-contentHandler.partChannel:
+		if !reflect.DeepEqual(part, expectedPart) {
+			t.Errorf("Wrong image content part: %v", part)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait image content part timeout")
+	}
+}
+
+func TestRunInstancesWithImageContent(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %v", err)
+	}
+
+	defer server.close()
+
+	receiver, err := contentreceiver.New()
+	if err != nil {
+		t.Fatalf("Can't create content receiver: %v", err)
+	}
+
+	serviceManager := &testContentServiceManager{receiver: receiver, destDir: t.TempDir()}
+	launcher := newTestLauncher()
+
+	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
+		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
+		nil, serviceManager, &testLayerManager{}, launcher, nil, nil, nil, nil, nil, receiver, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %v", err)
+	}
+	defer client.Close()
+
+	if err := server.waitClientRegistered(&pb.NodeConfiguration{NodeId: "mainSM", NodeType: "model1"}); err != nil {
+		t.Fatalf("SM registration error: %v", err)
+	}
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_RunInstances{RunInstances: &pb.RunInstances{
+			Services: []*pb.ServiceInfo{{
+				VersionInfo: &pb.VersionInfo{AosVersion: 1}, Url: "file:///service1", ServiceId: "service1",
+			}},
+		}},
+	}); err != nil {
+		t.Fatalf("Can't send request: %v", err)
+	}
+
+	var request *pb.ImageContentRequest
+
+	select {
+	case request = <-server.contentChannel:
+		if request.GetUrl() != "file:///service1" || request.GetContentType() != contentreceiver.ContentTypeService {
+			t.Errorf("Wrong image content request: %v", request)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait image content request timeout")
+	}
+
+	data := []byte("service content")
+	sha := sha256.Sum256(data)
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_ImageContentInfo{ImageContentInfo: &pb.ImageContentInfo{
+			RequestId: request.GetRequestId(),
+			ImageFiles: []*pb.ImageFile{
+				{RelativePath: "service.tar.gz", Sha256: sha[:], Size: uint64(len(data))},
+			},
+		}},
+	}); err != nil {
+		t.Fatalf("Can't send image content info: %v", err)
+	}
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_ImageContent{ImageContent: &pb.ImageContent{
+			RequestId: request.GetRequestId(), RelativePath: "service.tar.gz", PartsCount: 1, Part: 1, Data: data,
+		}},
+	}); err != nil {
+		t.Fatalf("Can't send image content: %v", err)
+	}
+
+	if err := launcher.waitCall(); err != nil {
+		t.Fatalf("Error waiting call: %v", err)
+	}
+
+	if serviceManager.err != nil {
+		t.Errorf("Can't get image content: %v", serviceManager.err)
+	}
+
+	if len(serviceManager.fileNames) != 1 {
+		t.Errorf("Wrong image content files: %v", serviceManager.fileNames)
+	}
+}
+
+func TestClockSync(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %v", err)
+	}
+
+	defer server.close()
+
+	clockSyncHandler := &testClockSyncHandler{syncChannel: make(chan time.Time, 1)}
+
+	client, err := smclient.New(&config.Config{CMServerURL: serverURL},
+		smclient.NodeDescription{NodeID: "mainSM", NodeType: "model1", SystemInfo: cloudprotocol.SystemInfo{}},
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, clockSyncHandler, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %v", err)
+	}
+	defer client.Close()
+
+	if err := server.waitClientRegistered(&pb.NodeConfiguration{NodeId: "mainSM", NodeType: "model1"}); err != nil {
+		t.Fatalf("SM registration error: %v", err)
+	}
+
+	select {
+	case <-server.clockSyncChannel:
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait clock sync request timeout")
+	}
+
+	currentTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := server.stream.Send(&pb.SMIncomingMessages{
+		SMIncomingMessage: &pb.SMIncomingMessages_ClockSync{
+			ClockSync: &pb.ClockSync{CurrentTime: timestamppb.New(currentTime)},
+		},
+	}); err != nil {
+		t.Fatalf("Can't send clock sync: %v", err)
+	}
+
+	select {
+	case syncTime := <-clockSyncHandler.syncChannel:
+		if !syncTime.Equal(currentTime) {
+			t.Errorf("Wrong sync time: %v", syncTime)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait clock sync timeout")
+	}
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
@@ -1026,6 +1478,8 @@ func newTestServer(url string) (server *testServer, err error) {
 		monitoringChannel: make(chan *pb.SMOutgoingMessages_NodeMonitoring, 10),
 		logChannel:        make(chan *pb.SMOutgoingMessages_Log, 10),
 		envVarsChannel:    make(chan *pb.SMOutgoingMessages_OverrideEnvVarStatus, 10),
+		contentChannel:    make(chan *pb.ImageContentRequest, 10),
+		clockSyncChannel:  make(chan *pb.ClockSyncRequest, 10),
 	}
 
 	listener, err := net.Listen("tcp", url)
@@ -1094,6 +1548,12 @@ func (server *testServer) RegisterSM(stream pb.SMService_RegisterSMServer) error
 
 		case *pb.SMOutgoingMessages_OverrideEnvVarStatus:
 			server.envVarsChannel <- data
+
+		case *pb.SMOutgoingMessages_ImageContentRequest:
+			server.contentChannel <- data.ImageContentRequest
+
+		case *pb.SMOutgoingMessages_ClockSyncRequest:
+			server.clockSyncChannel <- data.ClockSyncRequest
 		}
 	}
 }
@@ -1246,12 +1706,6 @@ func convertEnvVarsReq(req *pb.OverrideEnvVars) []cloudprotocol.EnvVarsInstanceI
  * Interfaces
  **********************************************************************************************************************/
 
-func (monitoring *testMonitoringProvider) GetMonitoringDataChannel() (
-	channel <-chan cloudprotocol.NodeMonitoringData,
-) {
-	return monitoring.monitoringChannel
-}
-
 func (logProvider *testLogProvider) GetInstanceLog(request cloudprotocol.RequestLog) error {
 	logProvider.currentLogRequest = request
 	logProvider.channel <- logProvider.testLogs[logProvider.sentIndex].internalLog
@@ -1276,20 +1730,34 @@ func (logProvider *testLogProvider) GetLogsDataChannel() (channel <-chan cloudpr
 	return logProvider.channel
 }
 
-func (alerts *testAlertProvider) GetAlertsChannel() (channel <-chan cloudprotocol.AlertItem) {
-	return alerts.alertsChannel
+func (processor *testServiceManager) ProcessDesiredServices(
+	services []aostypes.ServiceInfo,
+) ([]cloudprotocol.ServiceStatus, error) {
+	processor.services = services
+
+	return processor.statuses, nil
 }
 
-func (processor *testServiceManager) ProcessDesiredServices(services []aostypes.ServiceInfo) error {
-	processor.services = services
+func (processor *testContentServiceManager) ProcessDesiredServices(
+	services []aostypes.ServiceInfo,
+) ([]cloudprotocol.ServiceStatus, error) {
+	for _, service := range services {
+		ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFunc()
 
-	return nil
+		processor.fileNames, processor.err = processor.receiver.GetImageContent(
+			ctx, service.URL, contentreceiver.ContentTypeService, processor.destDir)
+	}
+
+	return nil, nil
 }
 
-func (processor *testLayerManager) ProcessDesiredLayers(layers []aostypes.LayerInfo) error {
+func (processor *testLayerManager) ProcessDesiredLayers(
+	layers []aostypes.LayerInfo,
+) ([]cloudprotocol.LayerStatus, error) {
 	processor.layers = layers
 
-	return nil
+	return processor.statuses, nil
 }
 
 func (networkmanager *testNetworkUpdates) UpdateNetworks(networkParameters []aostypes.NetworkParameters) error {
@@ -1361,3 +1829,33 @@ func (launcher *testLauncher) waitCloudConnection() (bool, error) {
 		return false, aoserrors.New("wait cloud connection timeout")
 	}
 }
+
+func newTestContentHandler() *testContentHandler {
+	return &testContentHandler{
+		requestChannel: make(chan contentreceiver.ContentRequest, 1),
+		infoChannel:    make(chan contentreceiver.ContentInfo, 1),
+		partChannel:    make(chan contentreceiver.ContentPart, 1),
+	}
+}
+
+func (handler *testContentHandler) GetContentRequestChannel() (channel <-chan contentreceiver.ContentRequest) {
+	return handler.requestChannel
+}
+
+func (handler *testContentHandler) ProcessContentInfo(info contentreceiver.ContentInfo) error {
+	handler.infoChannel <- info
+
+	return nil
+}
+
+func (handler *testContentHandler) ProcessContentPart(part contentreceiver.ContentPart) error {
+	handler.partChannel <- part
+
+	return nil
+}
+
+func (handler *testClockSyncHandler) SyncClock(currentTime time.Time) error {
+	handler.syncChannel <- currentTime
+
+	return nil
+}
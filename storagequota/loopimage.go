@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagequota
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	aosfs "github.com/aoscloud/aos_common/utils/fs"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	loopControlPath = "/dev/loop-control"
+	loopRetryCount  = 3
+	imageFSType     = "ext4"
+	mkfsCommand     = "mkfs." + imageFSType
+)
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func formatImage(imagePath string, size uint64) (err error) {
+	log.WithFields(log.Fields{"image": imagePath, "size": size}).Debug("Format loop image")
+
+	file, err := os.OpenFile(imagePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(imagePath)
+		}
+	}()
+
+	if err = file.Truncate(int64(size)); err != nil {
+		file.Close()
+
+		return aoserrors.Wrap(err)
+	}
+
+	if err = file.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if output, err := exec.Command(mkfsCommand, "-q", "-F", "-m", "0", imagePath).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("can't format image: %s, %v", string(output), err)
+	}
+
+	return nil
+}
+
+func mountLoopImage(imagePath, mountPoint string) (err error) {
+	imageFile, err := os.OpenFile(imagePath, os.O_RDWR, 0)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer imageFile.Close()
+
+	loopFile, err := attachLoopDevice(imageFile)
+	if err != nil {
+		return err
+	}
+	defer loopFile.Close()
+
+	if err = aosfs.Mount(loopFile.Name(), mountPoint, imageFSType, 0, ""); err != nil {
+		// Loop device is attached with autoclear flag, it is detached on unmount only
+		if clearErr := unix.IoctlSetInt(int(loopFile.Fd()), unix.LOOP_CLR_FD, 0); clearErr != nil {
+			log.Errorf("Can't detach loop device: %v", clearErr)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func attachLoopDevice(imageFile *os.File) (loopFile *os.File, err error) {
+	controlFile, err := os.OpenFile(loopControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer controlFile.Close()
+
+	for i := 0; i < loopRetryCount; i++ {
+		loopNumber, err := unix.IoctlRetInt(int(controlFile.Fd()), unix.LOOP_CTL_GET_FREE)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if loopFile, err = os.OpenFile(fmt.Sprintf("/dev/loop%d", loopNumber), os.O_RDWR, 0); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		err = unix.IoctlLoopConfigure(int(loopFile.Fd()), &unix.LoopConfig{
+			Fd:   uint32(imageFile.Fd()),
+			Info: unix.LoopInfo64{Flags: unix.LO_FLAGS_AUTOCLEAR},
+		})
+		if err == nil {
+			return loopFile, nil
+		}
+
+		loopFile.Close()
+
+		// Loop device is taken by someone else, try next one
+		if !errors.Is(err, unix.EBUSY) {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	return nil, aoserrors.New("can't find free loop device")
+}
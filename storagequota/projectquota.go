@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagequota
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	aosfs "github.com/aoscloud/aos_common/utils/fs"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	cmdGetInfo         = 0x800005
+	cmdGetQuota        = 0x800007
+	cmdSetQuota        = 0x800008
+	projectQuota       = 2
+	subCmdShift        = 8
+	subCmdMask         = 0x00ff
+	qifBLimits         = 1
+	quotaBlockSize     = 1024
+	fsIOCGetXAttr      = 0x801c581f
+	fsIOCSetXAttr      = 0x401c5820
+	fsXFlagProjInherit = 0x200
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type dqblk struct {
+	bHardLimit uint64
+	bSoftLimit uint64
+	curSpace   uint64
+	iHardLimit uint64
+	iSoftLimit uint64
+	curInodes  uint64
+	bTime      uint64
+	iTime      uint64
+	valid      uint32
+}
+
+type dqinfo struct {
+	bGrace uint64
+	iGrace uint64
+	flags  uint32
+	valid  uint32
+}
+
+type fsxattr struct {
+	xFlags     uint32
+	extSize    uint32
+	nExtents   uint32
+	projID     uint32
+	cowExtSize uint32
+	pad        [8]byte
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func setProjectLimit(path string, projectID uint32, limit uint64) error {
+	device, err := getDevice(path)
+	if err != nil {
+		return err
+	}
+
+	var info dqinfo
+
+	if err = quotactl(cmdGetInfo, device, 0, unsafe.Pointer(&info)); err != nil {
+		return ErrNotSupported
+	}
+
+	if err = setProjectID(path, projectID); err != nil {
+		return err
+	}
+
+	quota := dqblk{
+		bHardLimit: (limit + quotaBlockSize - 1) / quotaBlockSize,
+		bSoftLimit: (limit + quotaBlockSize - 1) / quotaBlockSize,
+		valid:      qifBLimits,
+	}
+
+	return quotactl(cmdSetQuota, device, projectID, unsafe.Pointer(&quota))
+}
+
+func getProjectUsage(path string, projectID uint32) (usedSize uint64, err error) {
+	device, err := getDevice(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var quota dqblk
+
+	if err = quotactl(cmdGetQuota, device, projectID, unsafe.Pointer(&quota)); err != nil {
+		return 0, err
+	}
+
+	return quota.curSpace, nil
+}
+
+func setProjectID(path string, projectID uint32) error {
+	return aoserrors.Wrap(filepath.WalkDir(path, func(itemPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(itemPath)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer file.Close()
+
+		var attr fsxattr
+
+		if err = ioctl(file.Fd(), fsIOCGetXAttr, unsafe.Pointer(&attr)); err != nil {
+			return err
+		}
+
+		attr.projID = projectID
+
+		if entry.IsDir() {
+			attr.xFlags |= fsXFlagProjInherit
+		}
+
+		return ioctl(file.Fd(), fsIOCSetXAttr, unsafe.Pointer(&attr))
+	}))
+}
+
+func getDevice(path string) (device string, err error) {
+	mountPoint, err := aosfs.GetMountPoint(path)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) >= 2 && fields[1] == mountPoint {
+			device = fields[0]
+		}
+	}
+
+	if device == "" {
+		return "", aoserrors.Errorf("can't find device for %s", path)
+	}
+
+	return device, nil
+}
+
+func quotactl(cmd uintptr, device string, id uint32, addr unsafe.Pointer) error {
+	devicePtr, err := unix.BytePtrFromString(device)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, _, errno := unix.Syscall6(unix.SYS_QUOTACTL, cmd<<subCmdShift|projectQuota&subCmdMask,
+		uintptr(unsafe.Pointer(devicePtr)), uintptr(id), uintptr(addr), 0, 0); errno != 0 {
+		return aoserrors.Wrap(errno)
+	}
+
+	return nil
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return aoserrors.Wrap(errno)
+	}
+
+	return nil
+}
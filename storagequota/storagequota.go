@@ -0,0 +1,668 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storagequota provides instance storage and state size limits
+package storagequota
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	quotaDirName   = "quotas"
+	imagesDirName  = "images"
+	mountsDirName  = "mounts"
+	stateFileName  = "state"
+	imageExt       = ".img"
+	oldImageSuffix = ".old"
+)
+
+const (
+	// quota alert is raised when usage reaches this percent of the limit.
+	quotaAlertThreshold = 90
+	// project ID of each next instance partition is shifted by this value.
+	projectIDPartitionShift = 24
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// AlertSender provides alert sender interface.
+type AlertSender interface {
+	SendAlert(alert cloudprotocol.AlertItem)
+}
+
+// PartitionLimit partition limit parameters.
+type PartitionLimit struct {
+	Name  string
+	Path  string
+	Limit uint64
+}
+
+// InstanceParams instance quota parameters.
+type InstanceParams struct {
+	aostypes.InstanceIdent
+	InstanceID string
+	UID        uint32
+	GID        uint32
+	Partitions []PartitionLimit
+}
+
+// PartitionUsage partition usage information.
+type PartitionUsage struct {
+	Name     string
+	UsedSize uint64
+	Limit    uint64
+}
+
+// StorageQuota storage quota instance.
+type StorageQuota struct {
+	sync.Mutex
+
+	imagesDir      string
+	mountsDir      string
+	alertSender    AlertSender
+	instances      map[aostypes.InstanceIdent][]*partitionQuota
+	cancelFunction context.CancelFunc
+}
+
+type partitionQuota struct {
+	PartitionLimit
+	projectID uint32
+	imageName string
+	mountDir  string
+	alertSent bool
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ErrNotSupported project quotas are not supported error.
+var ErrNotSupported = errors.New("project quotas are not supported")
+
+// QuotaCheckPeriod period of checking instance partitions usage.
+//
+//nolint:gochecknoglobals // used to be overridden in unit tests
+var QuotaCheckPeriod = 10 * time.Second
+
+// These global variables are used to be able to mock file system quota and mount functionality in tests.
+//
+//nolint:gochecknoglobals
+var (
+	SetProjectLimit = setProjectLimit
+	GetProjectUsage = getProjectUsage
+	FormatImage     = formatImage
+	MountLoopImage  = mountLoopImage
+	BindMount       = bindMount
+	Unmount         = unmount
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates storage quota instance.
+func New(config *config.Config, alertSender AlertSender) (quota *StorageQuota, err error) {
+	log.Debug("Create storage quota")
+
+	quotaDir := filepath.Join(config.WorkingDir, quotaDirName)
+
+	quota = &StorageQuota{
+		imagesDir:   filepath.Join(quotaDir, imagesDirName),
+		mountsDir:   filepath.Join(quotaDir, mountsDirName),
+		alertSender: alertSender,
+		instances:   make(map[aostypes.InstanceIdent][]*partitionQuota),
+	}
+
+	if err = os.MkdirAll(quota.imagesDir, 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	// Mounts can't survive restart, clean up leftovers
+	if err = os.RemoveAll(quota.mountsDir); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = os.MkdirAll(quota.mountsDir, 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	quota.cancelFunction = cancelFunc
+
+	go quota.checkQuotas(ctx)
+
+	return quota, nil
+}
+
+// Close closes storage quota.
+func (quota *StorageQuota) Close() {
+	log.Debug("Close storage quota")
+
+	if quota.cancelFunction != nil {
+		quota.cancelFunction()
+	}
+
+	quota.Lock()
+	defer quota.Unlock()
+
+	for instanceIdent, partitions := range quota.instances {
+		quota.releasePartitions(instanceIdent, partitions)
+		delete(quota.instances, instanceIdent)
+	}
+}
+
+// SetInstanceLimits sets instance partitions limits.
+func (quota *StorageQuota) SetInstanceLimits(params InstanceParams) (err error) {
+	quota.Lock()
+	defer quota.Unlock()
+
+	if partitions, ok := quota.instances[params.InstanceIdent]; ok {
+		quota.releasePartitions(params.InstanceIdent, partitions)
+		delete(quota.instances, params.InstanceIdent)
+	}
+
+	partitions := make([]*partitionQuota, 0, len(params.Partitions))
+
+	defer func() {
+		if err != nil {
+			quota.releasePartitions(params.InstanceIdent, partitions)
+		}
+	}()
+
+	for i, partitionLimit := range params.Partitions {
+		if partitionLimit.Limit == 0 {
+			continue
+		}
+
+		partition := &partitionQuota{
+			PartitionLimit: partitionLimit,
+			projectID:      params.UID + uint32(i)<<projectIDPartitionShift,
+			// Image is bound to instance ID as UID and project ID may be reused by another instance
+			imageName: params.InstanceID + "_" + partitionLimit.Name,
+		}
+
+		log.WithFields(partitionLogFields(params.InstanceIdent, partition)).Debug("Set partition limit")
+
+		if err = quota.setPartitionLimit(partition, params.UID, params.GID); err != nil {
+			return err
+		}
+
+		partitions = append(partitions, partition)
+	}
+
+	if len(partitions) != 0 {
+		quota.instances[params.InstanceIdent] = partitions
+	}
+
+	return nil
+}
+
+// RemoveInstanceLimits removes instance partitions limits.
+func (quota *StorageQuota) RemoveInstanceLimits(instanceIdent aostypes.InstanceIdent) error {
+	quota.Lock()
+	defer quota.Unlock()
+
+	partitions, ok := quota.instances[instanceIdent]
+	if !ok {
+		return nil
+	}
+
+	delete(quota.instances, instanceIdent)
+
+	return quota.releasePartitions(instanceIdent, partitions)
+}
+
+// RemoveUnusedImages removes loop images of instances which are not in the list.
+func (quota *StorageQuota) RemoveUnusedImages(instanceIDs []string) error {
+	quota.Lock()
+	defer quota.Unlock()
+
+	entries, err := os.ReadDir(quota.imagesDir)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		instanceID, _, _ := strings.Cut(entry.Name(), "_")
+
+		if slices.Contains(instanceIDs, instanceID) || quota.isImageUsed(entry.Name()) {
+			continue
+		}
+
+		log.WithField("image", entry.Name()).Debug("Remove unused loop image")
+
+		if err = os.RemoveAll(filepath.Join(quota.imagesDir, entry.Name())); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// GetInstanceUsage returns instance partitions usage.
+func (quota *StorageQuota) GetInstanceUsage(instanceIdent aostypes.InstanceIdent) (usage []PartitionUsage) {
+	quota.Lock()
+	defer quota.Unlock()
+
+	for _, partition := range quota.instances[instanceIdent] {
+		usedSize, err := partition.getUsage()
+		if err != nil {
+			log.WithFields(partitionLogFields(instanceIdent, partition)).Errorf("Can't get partition usage: %v", err)
+
+			continue
+		}
+
+		usage = append(usage, PartitionUsage{Name: partition.Name, UsedSize: usedSize, Limit: partition.Limit})
+	}
+
+	return usage
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (quota *StorageQuota) setPartitionLimit(partition *partitionQuota, uid, gid uint32) error {
+	err := SetProjectLimit(partition.Path, partition.projectID, partition.Limit)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+
+	log.WithField("path", partition.Path).Debug("Project quotas are not supported, use loop image")
+
+	return quota.setLoopLimit(partition, uid, gid)
+}
+
+func (quota *StorageQuota) setLoopLimit(partition *partitionQuota, uid, gid uint32) (err error) {
+	imagePath := filepath.Join(quota.imagesDir, partition.imageName+imageExt)
+	mountDir := filepath.Join(quota.mountsDir, partition.imageName)
+
+	info, err := os.Stat(partition.Path)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	imageInfo, err := os.Stat(imagePath)
+	if err != nil && !os.IsNotExist(err) {
+		return aoserrors.Wrap(err)
+	}
+
+	newImage := err != nil
+
+	if newImage {
+		if err = FormatImage(imagePath, partition.Limit); err != nil {
+			return err
+		}
+	} else if uint64(imageInfo.Size()) != partition.Limit {
+		log.WithFields(log.Fields{
+			"path": partition.Path, "limit": partition.Limit, "imageSize": imageInfo.Size(),
+		}).Warn("Partition limit differs from existing image size, recreate image")
+
+		if err = quota.recreateImage(imagePath, mountDir, partition.Limit); err != nil {
+			return err
+		}
+	}
+
+	if err = MountLoopImage(imagePath, mountDir); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if unmountErr := Unmount(mountDir); unmountErr != nil {
+				log.Errorf("Can't unmount loop image: %v", unmountErr)
+			}
+		}
+	}()
+
+	source := mountDir
+
+	if info.IsDir() {
+		if newImage {
+			if err = copyDir(partition.Path, mountDir); err != nil {
+				return err
+			}
+		}
+
+		if err = os.Chown(mountDir, int(uid), int(gid)); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	} else {
+		source = filepath.Join(mountDir, stateFileName)
+
+		if _, err = os.Stat(source); os.IsNotExist(err) {
+			if err = copyFile(partition.Path, source, info); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = BindMount(source, partition.Path); err != nil {
+		return err
+	}
+
+	partition.mountDir = mountDir
+
+	return nil
+}
+
+// recreateImage creates new image of specified size and moves content of existing image into it. Existing image is
+// kept if its content doesn't fit new size.
+func (quota *StorageQuota) recreateImage(imagePath, mountDir string, size uint64) (err error) {
+	oldImagePath := imagePath + oldImageSuffix
+	oldMountDir := mountDir + oldImageSuffix
+
+	if err = os.Rename(imagePath, oldImagePath); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(imagePath)
+
+			if renameErr := os.Rename(oldImagePath, imagePath); renameErr != nil {
+				log.Errorf("Can't restore loop image: %v", renameErr)
+			}
+
+			return
+		}
+
+		if removeErr := os.Remove(oldImagePath); removeErr != nil {
+			log.Errorf("Can't remove old loop image: %v", removeErr)
+		}
+	}()
+
+	if err = FormatImage(imagePath, size); err != nil {
+		return err
+	}
+
+	if err = MountLoopImage(oldImagePath, oldMountDir); err != nil {
+		return err
+	}
+
+	defer releaseLoopImage(oldMountDir)
+
+	if err = MountLoopImage(imagePath, mountDir); err != nil {
+		return err
+	}
+
+	defer releaseLoopImage(mountDir)
+
+	return copyDir(oldMountDir, mountDir)
+}
+
+func (quota *StorageQuota) isImageUsed(imageName string) bool {
+	for _, partitions := range quota.instances {
+		for _, partition := range partitions {
+			if partition.mountDir != "" && partition.imageName+imageExt == imageName {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (quota *StorageQuota) releasePartitions(
+	instanceIdent aostypes.InstanceIdent, partitions []*partitionQuota,
+) (err error) {
+	for _, partition := range partitions {
+		if partition.mountDir == "" {
+			continue
+		}
+
+		log.WithFields(partitionLogFields(instanceIdent, partition)).Debug("Release partition loop image")
+
+		if unmountErr := Unmount(partition.Path); unmountErr != nil && err == nil {
+			err = unmountErr
+		}
+
+		if unmountErr := Unmount(partition.mountDir); unmountErr != nil && err == nil {
+			err = unmountErr
+		}
+
+		if removeErr := os.RemoveAll(partition.mountDir); removeErr != nil && err == nil {
+			err = aoserrors.Wrap(removeErr)
+		}
+	}
+
+	return err
+}
+
+func (quota *StorageQuota) checkQuotas(ctx context.Context) {
+	ticker := time.NewTicker(QuotaCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			quota.checkPartitionsUsage()
+		}
+	}
+}
+
+func (quota *StorageQuota) checkPartitionsUsage() {
+	quota.Lock()
+	defer quota.Unlock()
+
+	for instanceIdent, partitions := range quota.instances {
+		for _, partition := range partitions {
+			usedSize, err := partition.getUsage()
+			if err != nil {
+				log.WithFields(partitionLogFields(instanceIdent, partition)).Errorf(
+					"Can't get partition usage: %v", err)
+
+				continue
+			}
+
+			if usedSize < partition.Limit*quotaAlertThreshold/100 {
+				partition.alertSent = false
+
+				continue
+			}
+
+			if partition.alertSent {
+				continue
+			}
+
+			log.WithFields(partitionLogFields(instanceIdent, partition)).WithField("usedSize", usedSize).Warn(
+				"Partition usage is close to the limit")
+
+			partition.alertSent = true
+
+			if quota.alertSender != nil {
+				quota.alertSender.SendAlert(cloudprotocol.AlertItem{
+					Timestamp: time.Now(),
+					Tag:       cloudprotocol.AlertTagInstanceQuota,
+					Payload: cloudprotocol.InstanceQuotaAlert{
+						InstanceIdent: instanceIdent,
+						Parameter:     partition.Name,
+						Value:         usedSize,
+					},
+				})
+			}
+		}
+	}
+}
+
+func (partition *partitionQuota) getUsage() (usedSize uint64, err error) {
+	if partition.mountDir == "" {
+		return GetProjectUsage(partition.Path, partition.projectID)
+	}
+
+	var stat syscall.Statfs_t
+
+	if err = syscall.Statfs(partition.mountDir, &stat); err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	return (stat.Blocks - stat.Bfree) * uint64(stat.Bsize), nil
+}
+
+func releaseLoopImage(mountDir string) {
+	if err := Unmount(mountDir); err != nil {
+		log.Errorf("Can't unmount loop image: %v", err)
+	}
+
+	if err := os.RemoveAll(mountDir); err != nil {
+		log.Errorf("Can't remove loop image mount dir: %v", err)
+	}
+}
+
+func bindMount(source, mountPoint string) error {
+	log.WithFields(log.Fields{"source": source, "mountPoint": mountPoint}).Debug("Bind mount")
+
+	if err := syscall.Mount(source, mountPoint, "", syscall.MS_BIND, ""); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func unmount(mountPoint string) error {
+	log.WithFields(log.Fields{"mountPoint": mountPoint}).Debug("Unmount")
+
+	syscall.Sync()
+
+	if err := syscall.Unmount(mountPoint, 0); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func copyDir(src, dst string) error {
+	return aoserrors.Wrap(filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		dstPath := filepath.Join(dst, relPath)
+
+		switch {
+		case info.IsDir():
+			if err = os.Mkdir(dstPath, info.Mode().Perm()); err != nil && !os.IsExist(err) {
+				return aoserrors.Wrap(err)
+			}
+
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			if err = os.Symlink(target, dstPath); err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+		case info.Mode().IsRegular():
+			return copyFile(path, dstPath, info)
+
+		default:
+			log.WithField("path", path).Warn("Skip copying special file")
+
+			return nil
+		}
+
+		return chownAsSource(dstPath, info)
+	}))
+}
+
+func copyFile(src, dst string, info os.FileInfo) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer dstFile.Close()
+
+	if _, err = io.Copy(dstFile, srcFile); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return chownAsSource(dst, info)
+}
+
+func chownAsSource(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return aoserrors.Wrap(os.Lchown(path, int(stat.Uid), int(stat.Gid)))
+}
+
+func partitionLogFields(instanceIdent aostypes.InstanceIdent, partition *partitionQuota) log.Fields {
+	return log.Fields{
+		"serviceID": instanceIdent.ServiceID,
+		"subjectID": instanceIdent.SubjectID,
+		"instance":  instanceIdent.Instance,
+		"partition": partition.Name,
+		"limit":     partition.Limit,
+	}
+}
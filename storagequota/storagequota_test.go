@@ -0,0 +1,472 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagequota_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/api/cloudprotocol"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/config"
+	"github.com/aoscloud/aos_servicemanager/storagequota"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testAlertSender struct {
+	alertsChannel chan cloudprotocol.InstanceQuotaAlert
+}
+
+type testFS struct {
+	sync.Mutex
+	projectSupported bool
+	projectLimits    map[string]uint64
+	projectUsage     map[string]uint64
+	bindMounts       map[string]string
+	loopMounts       map[string]string
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "sm_"); err != nil {
+		log.Fatalf("Error creating tmp dir: %v", err)
+	}
+
+	storagequota.QuotaCheckPeriod = 100 * time.Millisecond
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Errorf("Error removing tmp dir: %v", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestProjectQuota(t *testing.T) {
+	testFS := newTestFS(true)
+	alertSender := &testAlertSender{alertsChannel: make(chan cloudprotocol.InstanceQuotaAlert, 1)}
+
+	quota, err := storagequota.New(&config.Config{WorkingDir: tmpDir}, alertSender)
+	if err != nil {
+		t.Fatalf("Can't create storage quota: %v", err)
+	}
+	defer quota.Close()
+
+	instanceIdent := aostypes.InstanceIdent{ServiceID: "service0", SubjectID: "subject0", Instance: 0}
+	storagePath := filepath.Join(tmpDir, "storage0")
+	statePath := filepath.Join(tmpDir, "state0.dat")
+
+	if err = quota.SetInstanceLimits(storagequota.InstanceParams{
+		InstanceIdent: instanceIdent,
+		Partitions: []storagequota.PartitionLimit{
+			{Name: "storage", Path: storagePath, Limit: 1000},
+			{Name: "state", Path: statePath, Limit: 100},
+		},
+	}); err != nil {
+		t.Fatalf("Can't set instance limits: %v", err)
+	}
+
+	if !reflect.DeepEqual(testFS.projectLimits, map[string]uint64{storagePath: 1000, statePath: 100}) {
+		t.Errorf("Wrong project limits: %v", testFS.projectLimits)
+	}
+
+	testFS.setUsage(storagePath, 500)
+	testFS.setUsage(statePath, 10)
+
+	if usage := quota.GetInstanceUsage(instanceIdent); !reflect.DeepEqual(usage, []storagequota.PartitionUsage{
+		{Name: "storage", UsedSize: 500, Limit: 1000},
+		{Name: "state", UsedSize: 10, Limit: 100},
+	}) {
+		t.Errorf("Wrong instance usage: %v", usage)
+	}
+
+	// Alert should be sent once when usage reaches the threshold
+
+	testFS.setUsage(storagePath, 950)
+
+	if err = alertSender.waitAlert(cloudprotocol.InstanceQuotaAlert{
+		InstanceIdent: instanceIdent, Parameter: "storage", Value: 950,
+	}); err != nil {
+		t.Errorf("Wait alert error: %v", err)
+	}
+
+	if err = alertSender.waitAlert(cloudprotocol.InstanceQuotaAlert{}); err == nil {
+		t.Error("Alert should not be sent twice")
+	}
+
+	// Alert should be sent again after usage drops below the threshold
+
+	testFS.setUsage(storagePath, 100)
+
+	time.Sleep(2 * storagequota.QuotaCheckPeriod)
+
+	testFS.setUsage(storagePath, 1000)
+
+	if err = alertSender.waitAlert(cloudprotocol.InstanceQuotaAlert{
+		InstanceIdent: instanceIdent, Parameter: "storage", Value: 1000,
+	}); err != nil {
+		t.Errorf("Wait alert error: %v", err)
+	}
+
+	if err = quota.RemoveInstanceLimits(instanceIdent); err != nil {
+		t.Fatalf("Can't remove instance limits: %v", err)
+	}
+
+	if usage := quota.GetInstanceUsage(instanceIdent); len(usage) != 0 {
+		t.Errorf("Wrong instance usage: %v", usage)
+	}
+}
+
+func TestLoopImageQuota(t *testing.T) {
+	testFS := newTestFS(false)
+
+	quota, err := storagequota.New(&config.Config{WorkingDir: tmpDir}, nil)
+	if err != nil {
+		t.Fatalf("Can't create storage quota: %v", err)
+	}
+	defer quota.Close()
+
+	instanceIdent := aostypes.InstanceIdent{ServiceID: "service1", SubjectID: "subject0", Instance: 0}
+	storagePath := filepath.Join(tmpDir, "storage1")
+	statePath := filepath.Join(tmpDir, "state1.dat")
+
+	if err = os.MkdirAll(filepath.Join(storagePath, "data"), 0o755); err != nil {
+		t.Fatalf("Can't create storage dir: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(storagePath, "data", "file"), []byte("storage"), 0o600); err != nil {
+		t.Fatalf("Can't create storage file: %v", err)
+	}
+
+	if err = os.WriteFile(statePath, []byte("state"), 0o600); err != nil {
+		t.Fatalf("Can't create state file: %v", err)
+	}
+
+	params := storagequota.InstanceParams{
+		InstanceIdent: instanceIdent,
+		InstanceID:    "instance1",
+		UID:           5000,
+		GID:           5000,
+		Partitions: []storagequota.PartitionLimit{
+			{Name: "storage", Path: storagePath, Limit: 4096},
+			{Name: "state", Path: statePath, Limit: 1024},
+		},
+	}
+
+	if err = quota.SetInstanceLimits(params); err != nil {
+		t.Fatalf("Can't set instance limits: %v", err)
+	}
+
+	if len(testFS.projectLimits) != 0 {
+		t.Errorf("Project limits should not be set: %v", testFS.projectLimits)
+	}
+
+	storageMountDir := filepath.Join(tmpDir, "quotas", "mounts", "instance1_storage")
+	stateMountDir := filepath.Join(tmpDir, "quotas", "mounts", "instance1_state")
+	storageImage := filepath.Join(tmpDir, "quotas", "images", "instance1_storage.img")
+	stateImage := filepath.Join(tmpDir, "quotas", "images", "instance1_state.img")
+
+	if !reflect.DeepEqual(testFS.loopMounts, map[string]string{
+		storageMountDir: storageImage,
+		stateMountDir:   stateImage,
+	}) {
+		t.Errorf("Wrong loop mounts: %v", testFS.loopMounts)
+	}
+
+	if !reflect.DeepEqual(testFS.bindMounts, map[string]string{
+		storagePath: storageMountDir,
+		statePath:   filepath.Join(stateMountDir, "state"),
+	}) {
+		t.Errorf("Wrong bind mounts: %v", testFS.bindMounts)
+	}
+
+	// Existing content should be copied into the image
+
+	if data, err := os.ReadFile(filepath.Join(storageMountDir, "data", "file")); err != nil || string(data) != "storage" {
+		t.Errorf("Wrong storage content: %s, %v", string(data), err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(stateMountDir, "state")); err != nil || string(data) != "state" {
+		t.Errorf("Wrong state content: %s, %v", string(data), err)
+	}
+
+	if err = quota.RemoveInstanceLimits(instanceIdent); err != nil {
+		t.Fatalf("Can't remove instance limits: %v", err)
+	}
+
+	if len(testFS.bindMounts) != 0 || len(testFS.loopMounts) != 0 {
+		t.Errorf("Mounts should be released: %v, %v", testFS.bindMounts, testFS.loopMounts)
+	}
+
+	// Image should be recreated with new size and keep its content
+
+	params.Partitions[0].Limit = 8192
+
+	if err = quota.SetInstanceLimits(params); err != nil {
+		t.Fatalf("Can't set instance limits: %v", err)
+	}
+
+	if info, err := os.Stat(storageImage); err != nil || info.Size() != 8192 {
+		t.Errorf("Storage image should be recreated: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(storageMountDir, "data", "file")); err != nil || string(data) != "storage" {
+		t.Errorf("Wrong storage content: %s, %v", string(data), err)
+	}
+
+	// Images of used instances should be kept
+
+	if err = quota.RemoveUnusedImages(nil); err != nil {
+		t.Fatalf("Can't remove unused images: %v", err)
+	}
+
+	if _, err = os.Stat(storageImage); err != nil {
+		t.Errorf("Used image should be kept: %v", err)
+	}
+
+	if err = quota.RemoveInstanceLimits(instanceIdent); err != nil {
+		t.Fatalf("Can't remove instance limits: %v", err)
+	}
+
+	if err = quota.RemoveUnusedImages([]string{"instance1"}); err != nil {
+		t.Fatalf("Can't remove unused images: %v", err)
+	}
+
+	if _, err = os.Stat(storageImage); err != nil {
+		t.Errorf("Image of existing instance should be kept: %v", err)
+	}
+
+	// Images of removed instance should be deleted
+
+	if err = quota.RemoveUnusedImages(nil); err != nil {
+		t.Fatalf("Can't remove unused images: %v", err)
+	}
+
+	for _, image := range []string{storageImage, stateImage} {
+		if _, err = os.Stat(image); !os.IsNotExist(err) {
+			t.Errorf("Image of removed instance should be deleted: %v", err)
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * testAlertSender
+ **********************************************************************************************************************/
+
+func (sender *testAlertSender) SendAlert(alert cloudprotocol.AlertItem) {
+	if payload, ok := alert.Payload.(cloudprotocol.InstanceQuotaAlert); ok {
+		sender.alertsChannel <- payload
+	}
+}
+
+func (sender *testAlertSender) waitAlert(expectedAlert cloudprotocol.InstanceQuotaAlert) error {
+	select {
+	case alert := <-sender.alertsChannel:
+		if alert != expectedAlert {
+			return aoserrors.Errorf("wrong alert: %v", alert)
+		}
+
+		return nil
+
+	case <-time.After(5 * storagequota.QuotaCheckPeriod):
+		return aoserrors.New("wait alert timeout")
+	}
+}
+
+/***********************************************************************************************************************
+ * testFS
+ **********************************************************************************************************************/
+
+func newTestFS(projectSupported bool) *testFS {
+	testFS := &testFS{
+		projectSupported: projectSupported,
+		projectLimits:    make(map[string]uint64),
+		projectUsage:     make(map[string]uint64),
+		bindMounts:       make(map[string]string),
+		loopMounts:       make(map[string]string),
+	}
+
+	storagequota.SetProjectLimit = testFS.setProjectLimit
+	storagequota.GetProjectUsage = testFS.getProjectUsage
+	storagequota.FormatImage = testFS.formatImage
+	storagequota.MountLoopImage = testFS.mountLoopImage
+	storagequota.BindMount = testFS.bindMount
+	storagequota.Unmount = testFS.unmount
+
+	return testFS
+}
+
+func (testFS *testFS) setUsage(path string, usage uint64) {
+	testFS.Lock()
+	defer testFS.Unlock()
+
+	testFS.projectUsage[path] = usage
+}
+
+func (testFS *testFS) setProjectLimit(path string, projectID uint32, limit uint64) error {
+	testFS.Lock()
+	defer testFS.Unlock()
+
+	if !testFS.projectSupported {
+		return storagequota.ErrNotSupported
+	}
+
+	testFS.projectLimits[path] = limit
+
+	return nil
+}
+
+func (testFS *testFS) getProjectUsage(path string, projectID uint32) (uint64, error) {
+	testFS.Lock()
+	defer testFS.Unlock()
+
+	return testFS.projectUsage[path], nil
+}
+
+func (testFS *testFS) formatImage(imagePath string, size uint64) error {
+	file, err := os.Create(imagePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if err = file.Truncate(int64(size)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	contentDir, err := getImageContentDir(imagePath)
+	if err != nil {
+		return err
+	}
+
+	return aoserrors.Wrap(os.RemoveAll(contentDir))
+}
+
+// Image content is emulated by dir which is moved to mount point on mount and back on unmount. Dir is bound to image
+// inode to keep content on image rename.
+func (testFS *testFS) mountLoopImage(imagePath, mountPoint string) error {
+	testFS.Lock()
+	defer testFS.Unlock()
+
+	contentDir, err := getImageContentDir(imagePath)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(mountPoint), 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	testFS.loopMounts[mountPoint] = imagePath
+
+	if _, err = os.Stat(contentDir); err != nil {
+		return aoserrors.Wrap(os.MkdirAll(mountPoint, 0o755))
+	}
+
+	return aoserrors.Wrap(os.Rename(contentDir, mountPoint))
+}
+
+func getImageContentDir(imagePath string) (string, error) {
+	info, err := os.Stat(imagePath)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", aoserrors.New("can't get image inode")
+	}
+
+	return filepath.Join(tmpDir, "imagecontent", strconv.FormatUint(stat.Ino, 10)), nil
+}
+
+func (testFS *testFS) bindMount(source, mountPoint string) error {
+	testFS.Lock()
+	defer testFS.Unlock()
+
+	testFS.bindMounts[mountPoint] = source
+
+	return nil
+}
+
+func (testFS *testFS) unmount(mountPoint string) error {
+	testFS.Lock()
+	defer testFS.Unlock()
+
+	delete(testFS.bindMounts, mountPoint)
+
+	imagePath, ok := testFS.loopMounts[mountPoint]
+	if !ok {
+		return nil
+	}
+
+	delete(testFS.loopMounts, mountPoint)
+
+	contentDir, err := getImageContentDir(imagePath)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(contentDir), 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.Rename(mountPoint, contentDir))
+}
@@ -0,0 +1,400 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsimage creates and mounts read-only squashfs and EROFS images of layers and service rootfs. Images may be
+// protected by dm-verity. Writable ext4 images are used to store size limited instance data on disk.
+package fsimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/utils/fs"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Supported image formats.
+const (
+	FormatSquashFS = "squashfs"
+	FormatEROFS    = "erofs"
+)
+
+// Image media types.
+const (
+	MediaTypeSquashFS = "application/vnd.aos.image.layer.v1.squashfs"
+	MediaTypeEROFS    = "application/vnd.aos.image.layer.v1.erofs"
+)
+
+const (
+	writableFSType      = "ext4"
+	loopControlPath     = "/dev/loop-control"
+	loopRetryCount      = 3
+	erofsMagicOffset    = 1024
+	deviceMapperDir     = "/dev/mapper"
+	verityDevicePrefix  = "aos-verity-"
+	verityDeviceHashLen = 16
+)
+
+// Default veritysetup format parameters: 4K data and hash blocks, sha256 hashes and superblock in hash device.
+const (
+	verityBlockSize      = 4096
+	verityHashSize       = 32
+	veritySuperblockSize = verityBlockSize
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ErrUnknownFormat image format is not supported.
+var ErrUnknownFormat = errors.New("unknown image format")
+
+//nolint:gochecknoglobals // magic numbers of supported formats
+var (
+	squashFSMagic = []byte("hsqs")
+	erofsMagic    = []byte{0xe2, 0xe1, 0xf5, 0xe0}
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// IsImageMediaType checks if media type is one of supported image media types.
+func IsImageMediaType(mediaType string) bool {
+	return mediaType == MediaTypeSquashFS || mediaType == MediaTypeEROFS
+}
+
+// GetMediaType returns media type of image format.
+func GetMediaType(format string) (string, error) {
+	switch format {
+	case FormatSquashFS:
+		return MediaTypeSquashFS, nil
+
+	case FormatEROFS:
+		return MediaTypeEROFS, nil
+
+	default:
+		return "", aoserrors.Wrap(ErrUnknownFormat)
+	}
+}
+
+// Create creates image of specified format from source dir.
+func Create(format, sourceDir, imagePath string) error {
+	log.WithFields(log.Fields{"format": format, "source": sourceDir, "image": imagePath}).Debug("Create image")
+
+	var cmd *exec.Cmd
+
+	switch format {
+	case FormatSquashFS:
+		cmd = exec.Command("mksquashfs", sourceDir, imagePath, "-noappend", "-quiet", "-no-progress")
+
+	case FormatEROFS:
+		cmd = exec.Command("mkfs.erofs", "--quiet", imagePath, sourceDir)
+
+	default:
+		return aoserrors.Wrap(ErrUnknownFormat)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(imagePath)
+
+		return aoserrors.Errorf("can't create %s image: %v, %s", format, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// GetFormat detects image format by its content.
+func GetFormat(imagePath string) (format string, err error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(squashFSMagic))
+
+	if _, err = io.ReadFull(file, magic); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if bytes.Equal(magic, squashFSMagic) {
+		return FormatSquashFS, nil
+	}
+
+	if _, err = file.ReadAt(magic, erofsMagicOffset); err != nil && !errors.Is(err, io.EOF) {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if bytes.Equal(magic, erofsMagic) {
+		return FormatEROFS, nil
+	}
+
+	return "", aoserrors.Wrap(ErrUnknownFormat)
+}
+
+// Mount attaches image to read-only loop device and mounts it. Loop device is detached on unmount.
+func Mount(imagePath, mountPoint string) (err error) {
+	format, err := GetFormat(imagePath)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"image": imagePath, "format": format, "mountPoint": mountPoint}).Debug("Mount image")
+
+	imageFile, err := os.Open(imagePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer imageFile.Close()
+
+	loopFile, err := attachLoopDevice(imageFile, true)
+	if err != nil {
+		return err
+	}
+	defer loopFile.Close()
+
+	if err = fs.Mount(loopFile.Name(), mountPoint, format, unix.MS_RDONLY, ""); err != nil {
+		// Loop device is attached with autoclear flag, it is detached on unmount only
+		if clearErr := unix.IoctlSetInt(int(loopFile.Fd()), unix.LOOP_CLR_FD, 0); clearErr != nil {
+			log.Errorf("Can't detach loop device: %v", clearErr)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// CreateWritable creates empty writable ext4 image of specified size.
+func CreateWritable(imagePath string, size uint64) (err error) {
+	log.WithFields(log.Fields{"image": imagePath, "size": size}).Debug("Create writable image")
+
+	file, err := os.OpenFile(imagePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(imagePath)
+		}
+	}()
+
+	if err = file.Truncate(int64(size)); err != nil {
+		file.Close()
+
+		return aoserrors.Wrap(err)
+	}
+
+	if err = file.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if output, err := exec.Command(
+		"mkfs."+writableFSType, "-q", "-F", "-m", "0", imagePath).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("can't create writable image: %v, %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// MountWritable attaches writable image to loop device and mounts it. Loop device is detached on unmount.
+func MountWritable(imagePath, mountPoint string) (err error) {
+	log.WithFields(log.Fields{"image": imagePath, "mountPoint": mountPoint}).Debug("Mount writable image")
+
+	imageFile, err := os.OpenFile(imagePath, os.O_RDWR, 0)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer imageFile.Close()
+
+	loopFile, err := attachLoopDevice(imageFile, false)
+	if err != nil {
+		return err
+	}
+	defer loopFile.Close()
+
+	if err = fs.Mount(loopFile.Name(), mountPoint, writableFSType, 0, ""); err != nil {
+		// Loop device is attached with autoclear flag, it is detached on unmount only
+		if clearErr := unix.IoctlSetInt(int(loopFile.Fd()), unix.LOOP_CLR_FD, 0); clearErr != nil {
+			log.Errorf("Can't detach loop device: %v", clearErr)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// FormatVerity creates dm-verity hash tree of image and returns its root hash.
+func FormatVerity(imagePath, hashTreePath string) (rootHash string, err error) {
+	log.WithFields(log.Fields{"image": imagePath, "hashTree": hashTreePath}).Debug("Format verity hash tree")
+
+	output, err := exec.Command("veritysetup", "format", imagePath, hashTreePath).CombinedOutput()
+	if err != nil {
+		os.Remove(hashTreePath)
+
+		return "", aoserrors.Errorf("can't format verity hash tree: %v, %s", err, strings.TrimSpace(string(output)))
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(name) == "Root hash" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	return "", aoserrors.New("verity root hash not found")
+}
+
+// GetVerityHashTreeSize returns size of dm-verity hash tree created by FormatVerity for image of specified size.
+func GetVerityHashTreeSize(imageSize uint64) (size uint64) {
+	hashesPerBlock := uint64(verityBlockSize / verityHashSize)
+	blockCount := (imageSize + verityBlockSize - 1) / verityBlockSize
+
+	size = veritySuperblockSize
+
+	for {
+		blockCount = (blockCount + hashesPerBlock - 1) / hashesPerBlock
+		size += blockCount * verityBlockSize
+
+		if blockCount <= 1 {
+			return size
+		}
+	}
+}
+
+// MountVerity opens dm-verity device of image and mounts it read-only. Device is opened only if hash tree matches
+// root hash, image blocks are verified on read.
+func MountVerity(imagePath, hashTreePath, rootHash, mountPoint string) (err error) {
+	format, err := GetFormat(imagePath)
+	if err != nil {
+		return err
+	}
+
+	deviceName := getVerityDeviceName(mountPoint)
+
+	log.WithFields(log.Fields{
+		"image": imagePath, "format": format, "device": deviceName, "mountPoint": mountPoint,
+	}).Debug("Mount verity image")
+
+	if output, err := exec.Command(
+		"veritysetup", "open", imagePath, deviceName, hashTreePath, rootHash).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("can't open verity device: %v, %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if err = fs.Mount(filepath.Join(deviceMapperDir, deviceName), mountPoint, format, unix.MS_RDONLY, ""); err != nil {
+		if closeErr := closeVerityDevice(deviceName); closeErr != nil {
+			log.Errorf("Can't close verity device: %v", closeErr)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// CloseVerity closes dm-verity device of unmounted image. Nothing is done if there is no device for the mount point.
+func CloseVerity(mountPoint string) error {
+	deviceName := getVerityDeviceName(mountPoint)
+
+	if _, err := os.Stat(filepath.Join(deviceMapperDir, deviceName)); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return closeVerityDevice(deviceName)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func getVerityDeviceName(mountPoint string) string {
+	hash := sha256.Sum256([]byte(mountPoint))
+
+	return verityDevicePrefix + hex.EncodeToString(hash[:])[:verityDeviceHashLen]
+}
+
+func closeVerityDevice(deviceName string) error {
+	log.WithField("device", deviceName).Debug("Close verity device")
+
+	if output, err := exec.Command("veritysetup", "close", deviceName).CombinedOutput(); err != nil {
+		return aoserrors.Errorf("can't close verity device: %v, %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func attachLoopDevice(imageFile *os.File, readOnly bool) (loopFile *os.File, err error) {
+	openFlag, loopFlags := os.O_RDWR, uint32(unix.LO_FLAGS_AUTOCLEAR)
+
+	if readOnly {
+		openFlag, loopFlags = os.O_RDONLY, loopFlags|unix.LO_FLAGS_READ_ONLY
+	}
+
+	controlFile, err := os.OpenFile(loopControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer controlFile.Close()
+
+	for i := 0; i < loopRetryCount; i++ {
+		loopNumber, err := unix.IoctlRetInt(int(controlFile.Fd()), unix.LOOP_CTL_GET_FREE)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if loopFile, err = os.OpenFile(fmt.Sprintf("/dev/loop%d", loopNumber), openFlag, 0); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		err = unix.IoctlLoopConfigure(int(loopFile.Fd()), &unix.LoopConfig{
+			Fd:   uint32(imageFile.Fd()),
+			Info: unix.LoopInfo64{Flags: loopFlags},
+		})
+		if err == nil {
+			return loopFile, nil
+		}
+
+		loopFile.Close()
+
+		// Loop device is taken by someone else, try next one
+		if !errors.Is(err, unix.EBUSY) {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	return nil, aoserrors.New("can't find free loop device")
+}
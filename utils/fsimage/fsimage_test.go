@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsimage_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/utils/fsimage"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "aos_"); err != nil {
+		log.Fatalf("Error create tmp folder: %v", err)
+	}
+
+	ret := m.Run()
+
+	if err = os.RemoveAll(tmpDir); err != nil {
+		log.Errorf("Can't remove tmp folder: %v", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestGetFormat(t *testing.T) {
+	squashFSImage := []byte("hsqs")
+
+	erofsImage := make([]byte, 2048)
+	copy(erofsImage[1024:], []byte{0xe2, 0xe1, 0xf5, 0xe0})
+
+	testData := []struct {
+		name    string
+		content []byte
+		format  string
+		err     error
+	}{
+		{name: "squashfs", content: squashFSImage, format: fsimage.FormatSquashFS},
+		{name: "erofs", content: erofsImage, format: fsimage.FormatEROFS},
+		{name: "unknown", content: make([]byte, 2048), err: fsimage.ErrUnknownFormat},
+		{name: "short", content: []byte("hs"), err: fsimage.ErrUnknownFormat},
+	}
+
+	for _, data := range testData {
+		t.Run(data.name, func(t *testing.T) {
+			imagePath := filepath.Join(tmpDir, data.name)
+
+			if err := os.WriteFile(imagePath, data.content, 0o600); err != nil {
+				t.Fatalf("Can't create image: %v", err)
+			}
+
+			format, err := fsimage.GetFormat(imagePath)
+			if !errors.Is(err, data.err) {
+				t.Fatalf("Wrong error: %v", err)
+			}
+
+			if format != data.format {
+				t.Errorf("Wrong format: %s", format)
+			}
+		})
+	}
+}
+
+func TestCreateImage(t *testing.T) {
+	testData := []struct {
+		format string
+		tool   string
+	}{
+		{format: fsimage.FormatSquashFS, tool: "mksquashfs"},
+		{format: fsimage.FormatEROFS, tool: "mkfs.erofs"},
+	}
+
+	sourceDir := filepath.Join(tmpDir, "source")
+
+	if err := os.MkdirAll(filepath.Join(sourceDir, "home"), 0o755); err != nil {
+		t.Fatalf("Can't create source dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "home", "data"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("Can't create source file: %v", err)
+	}
+
+	for _, data := range testData {
+		t.Run(data.format, func(t *testing.T) {
+			if _, err := exec.LookPath(data.tool); err != nil {
+				t.Skipf("%s is not available", data.tool)
+			}
+
+			imagePath := filepath.Join(tmpDir, data.format+".img")
+
+			if err := fsimage.Create(data.format, sourceDir, imagePath); err != nil {
+				t.Fatalf("Can't create image: %v", err)
+			}
+
+			format, err := fsimage.GetFormat(imagePath)
+			if err != nil {
+				t.Fatalf("Can't get image format: %v", err)
+			}
+
+			if format != data.format {
+				t.Errorf("Wrong format: %s", format)
+			}
+		})
+	}
+
+	if err := fsimage.Create("unknown", sourceDir, filepath.Join(tmpDir, "unknown.img")); !errors.Is(
+		err, fsimage.ErrUnknownFormat) {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestCreateWritableImage(t *testing.T) {
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 is not available")
+	}
+
+	imagePath := filepath.Join(tmpDir, "writable.img")
+
+	if err := fsimage.CreateWritable(imagePath, 4*1024*1024); err != nil {
+		t.Fatalf("Can't create writable image: %v", err)
+	}
+
+	fileInfo, err := os.Stat(imagePath)
+	if err != nil {
+		t.Fatalf("Can't stat image: %v", err)
+	}
+
+	if fileInfo.Size() != 4*1024*1024 {
+		t.Errorf("Wrong image size: %d", fileInfo.Size())
+	}
+
+	if err := fsimage.CreateWritable(imagePath, 4*1024*1024); err == nil {
+		t.Error("Existing image should not be overwritten")
+	}
+}
+
+func TestFormatVerity(t *testing.T) {
+	if _, err := exec.LookPath("veritysetup"); err != nil {
+		t.Skip("veritysetup is not available")
+	}
+
+	imagePath := filepath.Join(tmpDir, "verity.img")
+
+	if err := os.WriteFile(imagePath, make([]byte, 64*1024), 0o600); err != nil {
+		t.Fatalf("Can't create image: %v", err)
+	}
+
+	rootHash, err := fsimage.FormatVerity(imagePath, imagePath+".verity")
+	if err != nil {
+		t.Fatalf("Can't format verity: %v", err)
+	}
+
+	if rootHash == "" {
+		t.Error("Empty verity root hash")
+	}
+}
+
+func TestGetVerityHashTreeSize(t *testing.T) {
+	testData := []struct {
+		imageSize    uint64
+		hashTreeSize uint64
+	}{
+		{imageSize: 64 * 1024, hashTreeSize: 2 * 4096},
+		{imageSize: 128 * 4096, hashTreeSize: 2 * 4096},
+		{imageSize: 129 * 4096, hashTreeSize: 4 * 4096},
+		{imageSize: 128 * 128 * 4096, hashTreeSize: (1 + 128 + 1) * 4096},
+	}
+
+	for _, data := range testData {
+		if size := fsimage.GetVerityHashTreeSize(data.imageSize); size != data.hashTreeSize {
+			t.Errorf("Wrong hash tree size for image size %d: %d", data.imageSize, size)
+		}
+	}
+}
+
+func TestCloseNotOpenedVerity(t *testing.T) {
+	if err := fsimage.CloseVerity(filepath.Join(tmpDir, "notMounted")); err != nil {
+		t.Errorf("Can't close verity: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsverity enables and checks fs-verity protection of directory trees.
+package fsverity
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/opencontainers/go-digest"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	enableArgVersion = 1
+	blockSize        = 4096
+	maxDigestSize    = sha256.Size
+	digestHeaderSize = 4
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ErrRootHashMismatch tree root hash doesn't match expected one.
+var ErrRootHashMismatch = errors.New("fs-verity root hash mismatch")
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Enable enables fs-verity on all regular files of the tree and returns the tree root hash.
+func Enable(dir string) (rootHash string, err error) {
+	log.WithField("dir", dir).Debug("Enable fs-verity")
+
+	return calculateRootHash(dir, true)
+}
+
+// Verify checks that all regular files of the tree are protected by fs-verity and the tree root hash matches
+// expected one. Only file measurements are read, so the check doesn't depend on the tree content size.
+func Verify(dir, rootHash string) error {
+	log.WithField("dir", dir).Debug("Verify fs-verity")
+
+	treeRootHash, err := calculateRootHash(dir, false)
+	if err != nil {
+		return err
+	}
+
+	if treeRootHash != rootHash {
+		return aoserrors.Wrap(ErrRootHashMismatch)
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// calculateRootHash calculates digest of path, mode and fs-verity measurement or link target of each tree entry.
+func calculateRootHash(dir string, enable bool) (rootHash string, err error) {
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	digester := digest.SHA256.Digester()
+
+	if err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		fmt.Fprintf(digester.Hash(), "%s\x00%o\x00", relPath, entryInfo.Mode())
+
+		switch {
+		case entryInfo.Mode().IsRegular():
+			measurement, err := measureFile(path, enable)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(digester.Hash(), "%x\x00", measurement)
+
+		case entryInfo.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			fmt.Fprintf(digester.Hash(), "%s\x00", target)
+		}
+
+		return nil
+	}); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return digester.Digest().String(), nil
+}
+
+func measureFile(path string, enable bool) (measurement []byte, err error) {
+	// fs-verity can't be enabled if file is opened for writing
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if enable {
+		if err = enableFile(file); err != nil {
+			return nil, aoserrors.Errorf("can't enable fs-verity on %s: %v", path, err)
+		}
+	}
+
+	buffer := make([]byte, digestHeaderSize+maxDigestSize)
+
+	binary.NativeEndian.PutUint16(buffer[2:], maxDigestSize)
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), unix.FS_IOC_MEASURE_VERITY,
+		uintptr(unsafe.Pointer(&buffer[0]))); errno != 0 {
+		return nil, aoserrors.Errorf("can't measure fs-verity of %s: %v", path, errno)
+	}
+
+	size := binary.NativeEndian.Uint16(buffer[2:])
+
+	return buffer[digestHeaderSize : digestHeaderSize+int(size)], nil
+}
+
+func enableFile(file *os.File) error {
+	arg := unix.FsverityEnableArg{
+		Version:        enableArgVersion,
+		Hash_algorithm: unix.FS_VERITY_HASH_ALG_SHA256,
+		Block_size:     blockSize,
+	}
+
+	// File is already protected if it is shared with other installed content
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), unix.FS_IOC_ENABLE_VERITY,
+		uintptr(unsafe.Pointer(&arg))); errno != 0 && !errors.Is(errno, unix.EEXIST) {
+		return errno
+	}
+
+	return nil
+}
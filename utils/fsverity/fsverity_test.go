@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsverity_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/utils/fsverity"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "aos_"); err != nil {
+		log.Fatalf("Error create tmp folder: %v", err)
+	}
+
+	ret := m.Run()
+
+	if err = os.RemoveAll(tmpDir); err != nil {
+		log.Errorf("Can't remove tmp folder: %v", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestVerifyNotProtectedTree(t *testing.T) {
+	treeDir := createTree(t, "notProtected")
+
+	if err := fsverity.Verify(treeDir, "sha256:0000"); err == nil {
+		t.Error("Tree without fs-verity should fail verification")
+	}
+}
+
+func TestEnableVerity(t *testing.T) {
+	treeDir := createTree(t, "protected")
+
+	rootHash, err := fsverity.Enable(treeDir)
+	if err != nil {
+		t.Skipf("fs-verity is not supported: %v", err)
+	}
+
+	if err = fsverity.Verify(treeDir, rootHash); err != nil {
+		t.Errorf("Can't verify tree: %v", err)
+	}
+
+	// Enabling already protected tree should give the same root hash
+	if enabledRootHash, err := fsverity.Enable(treeDir); err != nil || enabledRootHash != rootHash {
+		t.Errorf("Wrong root hash: %s, %v", enabledRootHash, err)
+	}
+
+	if err = os.Rename(filepath.Join(treeDir, "home", "data"), filepath.Join(treeDir, "home", "renamed")); err != nil {
+		t.Fatalf("Can't rename file: %v", err)
+	}
+
+	if err = fsverity.Verify(treeDir, rootHash); !errors.Is(err, fsverity.ErrRootHashMismatch) {
+		t.Errorf("Wrong verify error: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func createTree(t *testing.T, name string) (treeDir string) {
+	t.Helper()
+
+	treeDir = filepath.Join(tmpDir, name)
+
+	if err := os.MkdirAll(filepath.Join(treeDir, "home"), 0o755); err != nil {
+		t.Fatalf("Can't create tree dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(treeDir, "home", "data"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("Can't create tree file: %v", err)
+	}
+
+	if err := os.Symlink("home/data", filepath.Join(treeDir, "link")); err != nil {
+		t.Fatalf("Can't create tree link: %v", err)
+	}
+
+	return treeDir
+}
@@ -0,0 +1,313 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ocilayer unpacks OCI layers of different media types.
+package ocilayer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/opencontainers/go-digest"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+* Consts
+***********************************************************************************************************************/
+
+// MediaTypeImageLayerZstd zstd compressed OCI layer media type.
+const MediaTypeImageLayerZstd = "application/vnd.oci.image.layer.v1.tar+zstd"
+
+// AnnotationDiffID layer descriptor annotation which contains DiffID of the layer.
+const AnnotationDiffID = "io.aos.layer.diffID"
+
+const dirSymlinkSize = 4 * 1024
+
+// zstdTool external tool used to decompress zstd layers as there is no zstd decoder in Go standard library.
+const zstdTool = "zstd"
+
+const (
+	compressionNone compression = iota
+	compressionGzip
+	compressionZstd
+)
+
+/***********************************************************************************************************************
+* Types
+***********************************************************************************************************************/
+
+type compression int
+
+type layerReader struct {
+	io.Reader
+	compression compression
+	closers     []func() error
+}
+
+/***********************************************************************************************************************
+* Vars
+***********************************************************************************************************************/
+
+//nolint:gochecknoglobals // magic numbers of supported compressions
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+/***********************************************************************************************************************
+* Public
+***********************************************************************************************************************/
+
+// GetUncompressedSize returns size of layer content after unpacking.
+func GetUncompressedSize(source, mediaType string) (size int64, err error) {
+	reader, err := openLayer(source, mediaType)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil && err == nil {
+			size, err = 0, closeErr
+		}
+	}()
+
+	tarReader := tar.NewReader(reader)
+
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+
+		if err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeSymlink:
+			size += dirSymlinkSize
+
+		case tar.TypeReg:
+			size += header.Size
+		}
+	}
+}
+
+// CheckZstd checks that zstd tool required to unpack zstd compressed layers is available.
+func CheckZstd() error {
+	if _, err := exec.LookPath(zstdTool); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// Unpack unpacks layer to destination. If diffID is set, it is verified against the uncompressed layer content.
+// DiffID is mandatory for zstd layers: they are decompressed by external tool and its output should be verified.
+func Unpack(source, destination, mediaType string, diffID digest.Digest) (err error) {
+	log.WithFields(log.Fields{
+		"source": source, "destination": destination, "mediaType": mediaType,
+	}).Debug("Unpack layer")
+
+	if diffID != "" {
+		if err = diffID.Validate(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if err = os.MkdirAll(destination, 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	reader, err := openLayer(source, mediaType)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if reader.compression == compressionZstd && diffID == "" {
+		return aoserrors.New("DiffID is required for zstd layer")
+	}
+
+	var (
+		verifier digest.Verifier
+		content  io.Reader = reader
+	)
+
+	if diffID != "" {
+		verifier = diffID.Verifier()
+		content = io.TeeReader(reader, verifier)
+	}
+
+	cmd := exec.Command("tar", "xf", "-", "-C", destination)
+	cmd.Stdin = content
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Errorf("Failed to unpack layer: %s", string(output))
+
+		return aoserrors.Wrap(err)
+	}
+
+	if verifier == nil {
+		return nil
+	}
+
+	// tar stops reading at the end of archive marker, the rest of content should be verified as well
+	if _, err = io.Copy(io.Discard, content); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !verifier.Verified() {
+		return aoserrors.New("layer DiffID mismatch")
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+* Private
+***********************************************************************************************************************/
+
+func openLayer(source, mediaType string) (*layerReader, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	reader := &layerReader{closers: []func() error{file.Close}}
+
+	defer func() {
+		if err != nil {
+			reader.Close()
+		}
+	}()
+
+	bufReader := bufio.NewReader(file)
+
+	if reader.compression, err = getCompression(mediaType, bufReader); err != nil {
+		return nil, err
+	}
+
+	switch reader.compression {
+	case compressionGzip:
+		var gzipReader *gzip.Reader
+
+		if gzipReader, err = gzip.NewReader(bufReader); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		reader.Reader = gzipReader
+		reader.closers = append(reader.closers, gzipReader.Close)
+
+	case compressionZstd:
+		if reader.Reader, err = startZstd(bufReader, reader); err != nil {
+			return nil, err
+		}
+
+	default:
+		reader.Reader = bufReader
+	}
+
+	return reader, nil
+}
+
+// Layer compression is defined by media type. Unknown media types are detected by content.
+func getCompression(mediaType string, reader *bufio.Reader) (compression, error) {
+	switch {
+	case strings.HasSuffix(mediaType, "+zstd") || strings.HasSuffix(mediaType, ".zstd"):
+		return compressionZstd, nil
+
+	case strings.HasSuffix(mediaType, "+gzip") || strings.HasSuffix(mediaType, ".gzip"):
+		return compressionGzip, nil
+
+	case strings.HasSuffix(mediaType, ".tar"):
+		return compressionNone, nil
+	}
+
+	magic, err := reader.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return compressionNone, aoserrors.Wrap(err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return compressionGzip, nil
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		return compressionZstd, nil
+
+	default:
+		return compressionNone, nil
+	}
+}
+
+func startZstd(source io.Reader, reader *layerReader) (io.Reader, error) {
+	cmd := exec.Command(zstdTool, "-dcq")
+	cmd.Stdin = source
+
+	var stderr bytes.Buffer
+
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	reader.closers = append(reader.closers, func() error {
+		// Drain the rest of output to let zstd finish
+		if _, err := io.Copy(io.Discard, stdout); err != nil {
+			log.Errorf("Can't read zstd output: %v", err)
+		}
+
+		if err := cmd.Wait(); err != nil {
+			return aoserrors.Errorf("zstd failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		return nil
+	})
+
+	return stdout, nil
+}
+
+func (reader *layerReader) Close() (err error) {
+	for i := len(reader.closers) - 1; i >= 0; i-- {
+		if closeErr := reader.closers[i](); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocilayer_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_servicemanager/utils/ocilayer"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	fileSize      = 64 * 1024
+	expectedSize  = 2*4*1024 + fileSize
+	layerFileName = "data.bin"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "aos_"); err != nil {
+		log.Fatalf("Error create tmp folder: %v", err)
+	}
+
+	ret := m.Run()
+
+	if err = os.RemoveAll(tmpDir); err != nil {
+		log.Errorf("Can't remove tmp folder: %v", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUnpackLayer(t *testing.T) {
+	tarFile, diffID, err := createTarLayer()
+	if err != nil {
+		t.Fatalf("Can't create tar layer: %v", err)
+	}
+
+	testData := []struct {
+		name      string
+		mediaType string
+		compress  []string
+	}{
+		{name: "tar", mediaType: imagespec.MediaTypeImageLayer},
+		{name: "gzip", mediaType: imagespec.MediaTypeImageLayerGzip, compress: []string{"gzip", "-kf"}},
+		{name: "zstd", mediaType: ocilayer.MediaTypeImageLayerZstd, compress: []string{"zstd", "-qkf"}},
+		{name: "detected", mediaType: "", compress: []string{"gzip", "-kf"}},
+	}
+
+	for _, data := range testData {
+		t.Run(data.name, func(t *testing.T) {
+			layerFile := tarFile
+
+			if data.compress != nil {
+				if _, err := exec.LookPath(data.compress[0]); err != nil {
+					t.Skipf("%s is not available", data.compress[0])
+				}
+
+				if layerFile, err = compressLayer(tarFile, data.compress); err != nil {
+					t.Fatalf("Can't compress layer: %v", err)
+				}
+				defer os.Remove(layerFile)
+			}
+
+			size, err := ocilayer.GetUncompressedSize(layerFile, data.mediaType)
+			if err != nil {
+				t.Fatalf("Can't get uncompressed size: %v", err)
+			}
+
+			if size != expectedSize {
+				t.Errorf("Wrong uncompressed size: %d", size)
+			}
+
+			destination := filepath.Join(tmpDir, "unpack", data.name)
+			defer os.RemoveAll(destination)
+
+			if err = ocilayer.Unpack(layerFile, destination, data.mediaType, diffID); err != nil {
+				t.Fatalf("Can't unpack layer: %v", err)
+			}
+
+			info, err := os.Stat(filepath.Join(destination, "home", layerFileName))
+			if err != nil {
+				t.Fatalf("Can't stat unpacked file: %v", err)
+			}
+
+			if info.Size() != fileSize {
+				t.Errorf("Wrong unpacked file size: %d", info.Size())
+			}
+		})
+	}
+}
+
+func TestDiffIDMismatch(t *testing.T) {
+	tarFile, _, err := createTarLayer()
+	if err != nil {
+		t.Fatalf("Can't create tar layer: %v", err)
+	}
+
+	layerFile, err := compressLayer(tarFile, []string{"gzip", "-kf"})
+	if err != nil {
+		t.Fatalf("Can't compress layer: %v", err)
+	}
+	defer os.Remove(layerFile)
+
+	destination := filepath.Join(tmpDir, "unpack", "mismatch")
+	defer os.RemoveAll(destination)
+
+	if err = ocilayer.Unpack(
+		layerFile, destination, imagespec.MediaTypeImageLayerGzip, digest.FromString("wrong")); err == nil {
+		t.Error("Unpack should fail on DiffID mismatch")
+	}
+
+	// Media type defines compression
+	if _, err = ocilayer.GetUncompressedSize(tarFile, imagespec.MediaTypeImageLayerGzip); err == nil {
+		t.Error("Getting size should fail on media type mismatch")
+	}
+}
+
+func TestZstdRequiresDiffID(t *testing.T) {
+	if err := ocilayer.CheckZstd(); err != nil {
+		t.Skipf("zstd is not available: %v", err)
+	}
+
+	tarFile, _, err := createTarLayer()
+	if err != nil {
+		t.Fatalf("Can't create tar layer: %v", err)
+	}
+
+	layerFile, err := compressLayer(tarFile, []string{"zstd", "-qkf"})
+	if err != nil {
+		t.Fatalf("Can't compress layer: %v", err)
+	}
+	defer os.Remove(layerFile)
+
+	destination := filepath.Join(tmpDir, "unpack", "nodiffid")
+	defer os.RemoveAll(destination)
+
+	if err = ocilayer.Unpack(layerFile, destination, ocilayer.MediaTypeImageLayerZstd, ""); err == nil {
+		t.Error("Unpack should fail for zstd layer without DiffID")
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func createTarLayer() (tarFile string, diffID digest.Digest, err error) {
+	layerDir := filepath.Join(tmpDir, "layer")
+
+	if err = os.MkdirAll(filepath.Join(layerDir, "home"), 0o755); err != nil {
+		return "", "", aoserrors.Wrap(err)
+	}
+	defer os.RemoveAll(layerDir)
+
+	if err = os.WriteFile(
+		filepath.Join(layerDir, "home", layerFileName), make([]byte, fileSize), 0o600); err != nil {
+		return "", "", aoserrors.Wrap(err)
+	}
+
+	tarFile = filepath.Join(tmpDir, "layer.tar")
+
+	if output, err := exec.Command("tar", "-C", layerDir, "-cf", tarFile, "./").CombinedOutput(); err != nil {
+		return "", "", aoserrors.Errorf("tar failed: %v, output: %s", err, string(output))
+	}
+
+	file, err := os.Open(tarFile)
+	if err != nil {
+		return "", "", aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if diffID, err = digest.FromReader(file); err != nil {
+		return "", "", aoserrors.Wrap(err)
+	}
+
+	return tarFile, diffID, nil
+}
+
+func compressLayer(tarFile string, compress []string) (layerFile string, err error) {
+	args := append(append([]string{}, compress[1:]...), tarFile)
+
+	if output, err := exec.Command(compress[0], args...).CombinedOutput(); err != nil {
+		return "", aoserrors.Errorf("%s failed: %v, output: %s", compress[0], err, string(output))
+	}
+
+	extension := ".gz"
+
+	if compress[0] == "zstd" {
+		extension = ".zst"
+	}
+
+	return tarFile + extension, nil
+}
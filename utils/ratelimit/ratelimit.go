@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits I/O bandwidth of readers.
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// ErrClosed limiter is closed.
+var ErrClosed = errors.New("rate limiter closed")
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Limiter limits read rate of all readers created by it. Nil limiter doesn't limit anything.
+type Limiter struct {
+	sync.Mutex
+	bytesPerSecond uint64
+	next           time.Time
+	closeChannel   chan struct{}
+	closeOnce      sync.Once
+}
+
+type reader struct {
+	io.Reader
+	limiter *Limiter
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates new limiter. Zero rate means unlimited read with possibility to abort it by closing the limiter.
+func New(bytesPerSecond uint64) (limiter *Limiter) {
+	return &Limiter{bytesPerSecond: bytesPerSecond, closeChannel: make(chan struct{})}
+}
+
+// Close aborts current and further reads with ErrClosed error.
+func (limiter *Limiter) Close() {
+	if limiter == nil {
+		return
+	}
+
+	limiter.closeOnce.Do(func() { close(limiter.closeChannel) })
+}
+
+// Reader returns reader which read rate is limited by the limiter.
+func (limiter *Limiter) Reader(r io.Reader) io.Reader {
+	if limiter == nil {
+		return r
+	}
+
+	return &reader{Reader: r, limiter: limiter}
+}
+
+// Read reads data and waits until read amount fits the limiter rate.
+func (r *reader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+
+	if waitErr := r.limiter.wait(n); waitErr != nil {
+		return n, waitErr
+	}
+
+	// io.EOF is returned as is to be handled by callers
+	return n, err
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (limiter *Limiter) wait(n int) error {
+	delay := limiter.reserve(n)
+
+	if delay <= 0 {
+		select {
+		case <-limiter.closeChannel:
+			return aoserrors.Wrap(ErrClosed)
+
+		default:
+			return nil
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+
+	case <-limiter.closeChannel:
+		return aoserrors.Wrap(ErrClosed)
+	}
+}
+
+func (limiter *Limiter) reserve(n int) (delay time.Duration) {
+	if limiter.bytesPerSecond == 0 || n <= 0 {
+		return 0
+	}
+
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	now := time.Now()
+
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+
+	limiter.next = limiter.next.Add(time.Duration(uint64(n) * uint64(time.Second) / limiter.bytesPerSecond))
+
+	return limiter.next.Sub(now)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_servicemanager/utils/ratelimit"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestLimitRate(t *testing.T) {
+	limiter := ratelimit.New(100 * 1024)
+	defer limiter.Close()
+
+	data := make([]byte, 50*1024)
+	start := time.Now()
+
+	read, err := io.Copy(io.Discard, limiter.Reader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("Can't read data: %v", err)
+	}
+
+	if read != int64(len(data)) {
+		t.Errorf("Wrong read size: %d", read)
+	}
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Read is not limited: %v", elapsed)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var limiter *ratelimit.Limiter
+
+	defer limiter.Close()
+
+	data := make([]byte, 1024)
+
+	read, err := io.Copy(io.Discard, limiter.Reader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("Can't read data: %v", err)
+	}
+
+	if read != int64(len(data)) {
+		t.Errorf("Wrong read size: %d", read)
+	}
+}
+
+func TestCloseLimiter(t *testing.T) {
+	limiter := ratelimit.New(1024)
+
+	time.AfterFunc(100*time.Millisecond, limiter.Close)
+
+	start := time.Now()
+
+	if _, err := io.Copy(io.Discard, limiter.Reader(bytes.NewReader(make([]byte, 10*1024)))); !errors.Is(
+		err, ratelimit.ErrClosed) {
+		t.Errorf("Wrong read error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read is not aborted: %v", elapsed)
+	}
+}